@@ -186,6 +186,9 @@ module "core" {
   launch_template_linux_private_id   = module.compute.launch_template_linux_private_id
   launch_template_windows_private_id = module.compute.launch_template_windows_private_id
 
+  # IAM configuration
+  permission_boundary_arn = var.permission_boundary_arn
+
   # App Runner configuration
   app_image              = var.app_image
   app_tag                = var.app_tag
@@ -101,6 +101,27 @@ resource "aws_iam_role_policy" "ec2_create_tags_volumes" {
           "arn:aws:ec2:${var.region}:${var.account_id}:volume/*",
           "arn:aws:ec2:${var.region}:*:snapshot/*"
         ]
+        Condition = {
+          StringEquals = {
+            "aws:ResourceTag/runs-on-stack-name" = var.stack_name
+          }
+        }
+      },
+      {
+        # Volumes and snapshots not claimed by any stack yet, e.g. just created
+        Effect = "Allow"
+        Action = [
+          "ec2:CreateTags"
+        ]
+        Resource = [
+          "arn:aws:ec2:${var.region}:${var.account_id}:volume/*",
+          "arn:aws:ec2:${var.region}:*:snapshot/*"
+        ]
+        Condition = {
+          Null = {
+            "aws:ResourceTag/runs-on-stack-name" = "true"
+          }
+        }
       }
     ]
   })
@@ -249,6 +270,26 @@ resource "aws_iam_role_policy" "ec2_snapshot_create" {
     Version = "2012-10-17"
     Statement = [
       {
+        # The new volume or snapshot
+        Effect = "Allow"
+        Action = [
+          "ec2:CreateVolume"
+        ]
+        Resource = [
+          "arn:aws:ec2:${var.region}:${var.account_id}:volume/*"
+        ]
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "ec2:CreateSnapshot"
+        ]
+        Resource = [
+          "arn:aws:ec2:${var.region}::snapshot/*"
+        ]
+      },
+      {
+        # The source snapshot or volume must belong to this stack
         Effect = "Allow"
         Action = [
           "ec2:CreateVolume",
@@ -258,6 +299,11 @@ resource "aws_iam_role_policy" "ec2_snapshot_create" {
           "arn:aws:ec2:${var.region}:${var.account_id}:volume/*",
           "arn:aws:ec2:${var.region}::snapshot/*"
         ]
+        Condition = {
+          StringEquals = {
+            "aws:ResourceTag/runs-on-stack-name" = var.stack_name
+          }
+        }
       }
     ]
   })
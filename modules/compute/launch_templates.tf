@@ -47,17 +47,17 @@ resource "aws_launch_template" "linux_default" {
 
   tag_specifications {
     resource_type = "instance"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   tag_specifications {
     resource_type = "volume"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   tag_specifications {
     resource_type = "network-interface"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   user_data = base64encode(templatefile("${path.module}/user-data-linux.sh", {
@@ -123,17 +123,17 @@ resource "aws_launch_template" "windows_default" {
 
   tag_specifications {
     resource_type = "instance"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   tag_specifications {
     resource_type = "volume"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   tag_specifications {
     resource_type = "network-interface"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   user_data = base64encode(templatefile("${path.module}/user-data-windows.ps1", {
@@ -199,17 +199,17 @@ resource "aws_launch_template" "linux_private" {
 
   tag_specifications {
     resource_type = "instance"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   tag_specifications {
     resource_type = "volume"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   tag_specifications {
     resource_type = "network-interface"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   user_data = base64encode(templatefile("${path.module}/user-data-linux.sh", {
@@ -275,17 +275,17 @@ resource "aws_launch_template" "windows_private" {
 
   tag_specifications {
     resource_type = "instance"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   tag_specifications {
     resource_type = "volume"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   tag_specifications {
     resource_type = "network-interface"
-    tags          = local.common_tags
+    tags          = local.runner_tags
   }
 
   user_data = base64encode(templatefile("${path.module}/user-data-windows.ps1", {
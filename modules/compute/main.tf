@@ -12,9 +12,15 @@ terraform {
   }
 }
 
+# Provider default_tags don't reach launch template tag_specifications on their own
+data "aws_default_tags" "current" {}
+
 # Local variables
 locals {
   log_group_name = "${var.stack_name}/ec2/instances"
 
   common_tags = var.tags
+
+  # Tags for runner instances, volumes and ENIs, including the provider default_tags
+  runner_tags = merge(data.aws_default_tags.current.tags, local.common_tags)
 }
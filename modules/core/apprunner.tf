@@ -60,6 +60,8 @@ resource "aws_iam_role" "apprunner" {
     ]
   })
 
+  permissions_boundary = var.permission_boundary_arn != "" ? var.permission_boundary_arn : null
+
   tags = merge(
     local.common_tags,
     {
@@ -94,6 +96,8 @@ resource "aws_iam_role" "apprunner_ecr_access" {
     ]
   })
 
+  permissions_boundary = var.permission_boundary_arn != "" ? var.permission_boundary_arn : null
+
   tags = merge(
     local.common_tags,
     {
@@ -134,6 +134,8 @@ resource "aws_iam_role" "scheduler" {
     ]
   })
 
+  permissions_boundary = var.permission_boundary_arn != "" ? var.permission_boundary_arn : null
+
   tags = merge(
     local.common_tags,
     {
@@ -60,6 +60,8 @@ resource "aws_iam_role" "slack_webhook" {
     ]
   })
 
+  permissions_boundary = var.permission_boundary_arn != "" ? var.permission_boundary_arn : null
+
   tags = merge(
     local.common_tags,
     {
@@ -107,6 +107,11 @@ variable "ec2_instance_profile_arn" {
   type        = string
 }
 
+variable "permission_boundary_arn" {
+  description = "IAM permission boundary ARN"
+  type        = string
+}
+
 variable "launch_template_linux_default_id" {
   description = "ID of the Linux default launch template"
   type        = string
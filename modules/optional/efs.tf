@@ -74,26 +74,28 @@ resource "aws_security_group" "efs" {
 # EFS Mount Targets
 ###########################
 
-resource "aws_efs_mount_target" "az1" {
-  count = var.enable_efs ? 1 : 0
+# One mount target per subnet. EFS allows a single mount target per AZ, so the subnets
+# must be in distinct availability zones.
+resource "aws_efs_mount_target" "this" {
+  count = var.enable_efs ? length(var.public_subnet_ids) : 0
 
   file_system_id  = local.efs_file_system_id
-  subnet_id       = var.public_subnet_ids[0]
+  subnet_id       = var.public_subnet_ids[count.index]
   security_groups = [aws_security_group.efs[0].id]
 }
 
-resource "aws_efs_mount_target" "az2" {
-  count = var.enable_efs && length(var.public_subnet_ids) > 1 ? 1 : 0
-
-  file_system_id  = local.efs_file_system_id
-  subnet_id       = var.public_subnet_ids[1]
-  security_groups = [aws_security_group.efs[0].id]
+# Mount targets used to be fixed az1/az2/az3 resources
+moved {
+  from = aws_efs_mount_target.az1[0]
+  to   = aws_efs_mount_target.this[0]
 }
 
-resource "aws_efs_mount_target" "az3" {
-  count = var.enable_efs && length(var.public_subnet_ids) > 2 ? 1 : 0
+moved {
+  from = aws_efs_mount_target.az2[0]
+  to   = aws_efs_mount_target.this[1]
+}
 
-  file_system_id  = local.efs_file_system_id
-  subnet_id       = var.public_subnet_ids[2]
-  security_groups = [aws_security_group.efs[0].id]
+moved {
+  from = aws_efs_mount_target.az3[0]
+  to   = aws_efs_mount_target.this[2]
 }
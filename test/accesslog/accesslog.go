@@ -0,0 +1,190 @@
+// Package accesslog parses S3 server access log records.
+//
+// Format reference: https://docs.aws.amazon.com/AmazonS3/latest/userguide/LogFormat.html
+package accesslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeLayout is the layout of the bracketed time field, e.g. [06/Feb/2019:00:00:38 +0000].
+const TimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// Record is one S3 server access log line. Fields logged as "-" are left empty (or zero).
+// Durations are omitted from JSON; Writer emits them in milliseconds.
+type Record struct {
+	BucketOwner        string        `json:"bucket_owner"`
+	Bucket             string        `json:"bucket"`
+	Time               time.Time     `json:"time"`
+	RemoteIP           string        `json:"remote_ip"`
+	Requester          string        `json:"requester"`
+	RequestID          string        `json:"request_id"`
+	Operation          string        `json:"operation"`
+	Key                string        `json:"key"`
+	RequestURI         string        `json:"request_uri"`
+	HTTPStatus         int           `json:"http_status"`
+	ErrorCode          string        `json:"error_code"`
+	BytesSent          int64         `json:"bytes_sent"`
+	ObjectSize         int64         `json:"object_size"`
+	TotalTime          time.Duration `json:"-"`
+	TurnAroundTime     time.Duration `json:"-"`
+	Referer            string        `json:"referer"`
+	UserAgent          string        `json:"user_agent"`
+	VersionID          string        `json:"version_id"`
+	HostID             string        `json:"host_id"`
+	SignatureVersion   string        `json:"signature_version"`
+	CipherSuite        string        `json:"cipher_suite"`
+	AuthenticationType string        `json:"authentication_type"`
+	HostHeader         string        `json:"host_header"`
+	TLSVersion         string        `json:"tls_version"`
+	AccessPointARN     string        `json:"access_point_arn"`
+	ACLRequired        string        `json:"acl_required"`
+}
+
+// minFields is the number of fields every S3 access log line has had since the format was introduced.
+// Newer fields are appended at the end and are optional.
+const minFields = 18
+
+// ParseLine parses a single access log line.
+func ParseLine(line string) (Record, error) {
+	fields, err := splitFields(line)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(fields) < minFields {
+		return Record{}, fmt.Errorf("expected at least %d fields, got %d", minFields, len(fields))
+	}
+	field := func(i int) string {
+		if i >= len(fields) || fields[i] == "-" {
+			return ""
+		}
+		return fields[i]
+	}
+
+	r := Record{
+		BucketOwner:        field(0),
+		Bucket:             field(1),
+		RemoteIP:           field(3),
+		Requester:          field(4),
+		RequestID:          field(5),
+		Operation:          field(6),
+		Key:                decodeKey(field(7)),
+		RequestURI:         field(8),
+		ErrorCode:          field(10),
+		Referer:            field(15),
+		UserAgent:          field(16),
+		VersionID:          field(17),
+		HostID:             field(18),
+		SignatureVersion:   field(19),
+		CipherSuite:        field(20),
+		AuthenticationType: field(21),
+		HostHeader:         field(22),
+		TLSVersion:         field(23),
+		AccessPointARN:     field(24),
+		ACLRequired:        field(25),
+	}
+
+	if r.Time, err = time.Parse(TimeLayout, fields[2]); err != nil {
+		return Record{}, fmt.Errorf("invalid time %q: %w", fields[2], err)
+	}
+	if r.HTTPStatus, err = atoi(field(9)); err != nil {
+		return Record{}, fmt.Errorf("invalid HTTP status %q: %w", fields[9], err)
+	}
+	if r.BytesSent, err = atoi64(field(11)); err != nil {
+		return Record{}, fmt.Errorf("invalid bytes sent %q: %w", fields[11], err)
+	}
+	if r.ObjectSize, err = atoi64(field(12)); err != nil {
+		return Record{}, fmt.Errorf("invalid object size %q: %w", fields[12], err)
+	}
+	totalMillis, err := atoi64(field(13))
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid total time %q: %w", fields[13], err)
+	}
+	turnAroundMillis, err := atoi64(field(14))
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid turn-around time %q: %w", fields[14], err)
+	}
+	r.TotalTime = time.Duration(totalMillis) * time.Millisecond
+	r.TurnAroundTime = time.Duration(turnAroundMillis) * time.Millisecond
+	return r, nil
+}
+
+// Parse reads every record from an access log object. Blank lines are skipped.
+func Parse(reader io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		record, err := ParseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// splitFields splits a log line on spaces, keeping "quoted" and [bracketed] fields whole.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case ' ':
+			i++
+		case '"', '[':
+			closing := byte('"')
+			if line[i] == '[' {
+				closing = ']'
+			}
+			end := strings.IndexByte(line[i+1:], closing)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %c field at offset %d", line[i], i)
+			}
+			fields = append(fields, line[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := strings.IndexByte(line[i:], ' ')
+			if end < 0 {
+				end = len(line) - i
+			}
+			fields = append(fields, line[i:i+end])
+			i += end
+		}
+	}
+	return fields, nil
+}
+
+// decodeKey undoes the URL encoding S3 applies to the key field.
+func decodeKey(key string) string {
+	decoded, err := url.PathUnescape(key)
+	if err != nil {
+		return key
+	}
+	return decoded
+}
+
+func atoi(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func atoi64(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
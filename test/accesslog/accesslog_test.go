@@ -0,0 +1,82 @@
+package accesslog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Captured from a cache bucket's s3-cache-access-logs/ prefix, account and instance IDs anonymised
+const (
+	putLine    = `79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be stack-cache [06/Feb/2025:00:00:38 +0000] 192.0.2.3 arn:aws:sts::123456789012:assumed-role/stack-ec2-role/i-0123456789abcdef0 3E57427F3EXAMPLE REST.PUT.OBJECT cache/actions/linux%20node.tgz "PUT /cache/actions/linux%20node.tgz HTTP/1.1" 200 - - 1048576 57 12 "-" "aws-cli/2.15.0 md/command#s3.cp" - s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234= SigV4 ECDHE-RSA-AES128-GCM-SHA256 AuthHeader stack-cache.s3.us-east-1.amazonaws.com TLSv1.3 - -`
+	getLine    = `79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be stack-cache [06/Feb/2025:00:01:02 +0000] 192.0.2.3 arn:aws:sts::123456789012:assumed-role/stack-ec2-role/i-0123456789abcdef0 891CE47D2EXAMPLE REST.GET.OBJECT runners/AROAEXAMPLE:i-0123456789abcdef0/config.json "GET /runners/AROAEXAMPLE:i-0123456789abcdef0/config.json HTTP/1.1" 404 NoSuchKey 243 - 15 - "-" "aws-sdk-go-v2/1.41.0" - Ib6rH7cwt1BeDvHcvAmW7G6LmtxKq8FwVfexOdtPzVzB+kgKwYZQY0dfRpiIQ6pKrObNHtxFE7k= SigV4 ECDHE-RSA-AES128-GCM-SHA256 AuthHeader stack-cache.s3.us-east-1.amazonaws.com TLSv1.3`
+	legacyLine = `79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be stack-config [06/Feb/2025:00:02:00 +0000] 192.0.2.9 - 7B4A0FABBEXAMPLE REST.GET.BUCKET - "GET /?list-type=2 HTTP/1.1" 403 AccessDenied 243 - 7 - "-" "curl/8.5.0" -`
+)
+
+func TestParseLine(t *testing.T) {
+	record, err := ParseLine(putLine)
+	require.NoError(t, err)
+
+	assert.Equal(t, "stack-cache", record.Bucket)
+	assert.Equal(t, time.Date(2025, 2, 6, 0, 0, 38, 0, time.UTC), record.Time.UTC())
+	assert.Equal(t, "192.0.2.3", record.RemoteIP)
+	assert.Equal(t, "arn:aws:sts::123456789012:assumed-role/stack-ec2-role/i-0123456789abcdef0", record.Requester)
+	assert.Equal(t, "REST.PUT.OBJECT", record.Operation)
+	assert.Equal(t, "cache/actions/linux node.tgz", record.Key)
+	assert.Equal(t, "PUT /cache/actions/linux%20node.tgz HTTP/1.1", record.RequestURI)
+	assert.Equal(t, 200, record.HTTPStatus)
+	assert.Empty(t, record.ErrorCode)
+	assert.Zero(t, record.BytesSent)
+	assert.Equal(t, int64(1048576), record.ObjectSize)
+	assert.Equal(t, 57*time.Millisecond, record.TotalTime)
+	assert.Equal(t, 12*time.Millisecond, record.TurnAroundTime)
+	assert.Empty(t, record.Referer)
+	assert.Equal(t, "aws-cli/2.15.0 md/command#s3.cp", record.UserAgent)
+	assert.Equal(t, "SigV4", record.SignatureVersion)
+	assert.Equal(t, "AuthHeader", record.AuthenticationType)
+	assert.Equal(t, "TLSv1.3", record.TLSVersion)
+	assert.Empty(t, record.AccessPointARN)
+}
+
+func TestParseLineErrorAndLegacyFormat(t *testing.T) {
+	record, err := ParseLine(getLine)
+	require.NoError(t, err)
+	assert.Equal(t, 404, record.HTTPStatus)
+	assert.Equal(t, "NoSuchKey", record.ErrorCode)
+	assert.Equal(t, "runners/AROAEXAMPLE:i-0123456789abcdef0/config.json", record.Key)
+	assert.Empty(t, record.ACLRequired)
+
+	record, err = ParseLine(legacyLine)
+	require.NoError(t, err)
+	assert.Empty(t, record.Requester, "anonymous requests are logged as -")
+	assert.Empty(t, record.Key)
+	assert.Equal(t, "AccessDenied", record.ErrorCode)
+	assert.Empty(t, record.HostID)
+}
+
+func TestParseLineInvalid(t *testing.T) {
+	for name, line := range map[string]string{
+		"TooFewFields": "owner bucket [06/Feb/2025:00:00:38 +0000] 192.0.2.3",
+		"BadTime":      strings.Replace(putLine, "06/Feb/2025:00:00:38 +0000", "yesterday", 1),
+		"BadStatus":    strings.Replace(putLine, " 200 ", " OK ", 1),
+		"Unterminated": strings.Replace(putLine, `"-" "aws-cli/2.15.0 md/command#s3.cp" - s9lz`, `"-" "aws-cli/2.15.0 s9lz`, 1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseLine(line)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	records, err := Parse(strings.NewReader(putLine + "\n\n" + getLine + "\n"))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "REST.GET.OBJECT", records[1].Operation)
+
+	_, err = Parse(strings.NewReader(putLine + "\ngarbage\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,84 @@
+package accesslog
+
+import (
+	"strings"
+	"time"
+)
+
+// Filter selects records. Zero-valued fields match everything.
+type Filter struct {
+	// Requester matches the requester ARN exactly, or as a prefix when it ends in "/",
+	// e.g. "arn:aws:sts::123456789012:assumed-role/stack-ec2-role/" for every session of a role.
+	Requester string
+
+	// UserID is an aws:userid such as "AROAEXAMPLE:i-0123456789abcdef0", as used in the cache
+	// bucket's runners/ paths. Role sessions are logged by ARN, so this matches the session name.
+	UserID string
+
+	// KeyPrefixes matches records whose key starts with any of the prefixes (cache/, runners/).
+	KeyPrefixes []string
+
+	// Operations matches exact operations such as REST.GET.OBJECT.
+	Operations []string
+
+	// Since and Until bound the request time, inclusive and exclusive respectively.
+	Since time.Time
+	Until time.Time
+}
+
+// Match reports whether the record passes the filter.
+func (f Filter) Match(r Record) bool {
+	if f.Requester != "" {
+		if strings.HasSuffix(f.Requester, "/") {
+			if !strings.HasPrefix(r.Requester, f.Requester) {
+				return false
+			}
+		} else if r.Requester != f.Requester {
+			return false
+		}
+	}
+	if f.UserID != "" && !matchesUserID(r.Requester, f.UserID) {
+		return false
+	}
+	if len(f.KeyPrefixes) > 0 && !hasAnyPrefix(r.Key, f.KeyPrefixes) {
+		return false
+	}
+	if len(f.Operations) > 0 && !contains(f.Operations, r.Operation) {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// matchesUserID reports whether a requester ARN belongs to an aws:userid.
+// "AROA...:session" matches an assumed-role ARN ending in "/session"; any other user ID
+// (an IAM user's AIDA... or an account ID) is compared with the whole requester.
+func matchesUserID(requester, userID string) bool {
+	if _, session, ok := strings.Cut(userID, ":"); ok {
+		return strings.Contains(requester, ":assumed-role/") && strings.HasSuffix(requester, "/"+session)
+	}
+	return requester == userID
+}
+
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package accesslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterMatch(t *testing.T) {
+	put, err := ParseLine(putLine)
+	require.NoError(t, err)
+	get, err := ParseLine(getLine)
+	require.NoError(t, err)
+	anonymous, err := ParseLine(legacyLine)
+	require.NoError(t, err)
+
+	role := "arn:aws:sts::123456789012:assumed-role/stack-ec2-role/"
+	testCases := []struct {
+		name    string
+		filter  Filter
+		matches []bool // put, get, anonymous
+	}{
+		{"Empty", Filter{}, []bool{true, true, true}},
+		{"RoleARNPrefix", Filter{Requester: role}, []bool{true, true, false}},
+		{"ExactARN", Filter{Requester: role + "i-0123456789abcdef0"}, []bool{true, true, false}},
+		{"OtherSession", Filter{Requester: role + "i-0fedcba9876543210"}, []bool{false, false, false}},
+		{"UserID", Filter{UserID: "AROAEXAMPLE:i-0123456789abcdef0"}, []bool{true, true, false}},
+		{"OtherUserID", Filter{UserID: "AROAEXAMPLE:i-0fedcba9876543210"}, []bool{false, false, false}},
+		{"CachePrefix", Filter{KeyPrefixes: []string{"cache/"}}, []bool{true, false, false}},
+		{"CacheOrRunners", Filter{KeyPrefixes: []string{"cache/", "runners/"}}, []bool{true, true, false}},
+		{"Operation", Filter{Operations: []string{"REST.GET.OBJECT"}}, []bool{false, true, false}},
+		{"Since", Filter{Since: time.Date(2025, 2, 6, 0, 1, 0, 0, time.UTC)}, []bool{false, true, true}},
+		{"Until", Filter{Until: time.Date(2025, 2, 6, 0, 1, 2, 0, time.UTC)}, []bool{true, false, false}},
+		{"Combined", Filter{UserID: "AROAEXAMPLE:i-0123456789abcdef0", KeyPrefixes: []string{"runners/"}}, []bool{false, true, false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.matches, []bool{tc.filter.Match(put), tc.filter.Match(get), tc.filter.Match(anonymous)})
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package accesslog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Writer writes records in an output format. Call Flush once all records are written.
+type Writer interface {
+	Write(Record) error
+	Flush() error
+}
+
+// NewWriter returns a Writer for "json" (one object per line) or "csv" (with a header row).
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "json":
+		return &jsonWriter{encoder: json.NewEncoder(w)}, nil
+	case "csv":
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (want json or csv)", format)
+}
+
+// jsonRecord adds the durations to a record's JSON in milliseconds, as they are logged.
+type jsonRecord struct {
+	Record
+	TotalTimeMs      int64 `json:"total_time_ms"`
+	TurnAroundTimeMs int64 `json:"turn_around_time_ms"`
+}
+
+type jsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonWriter) Write(r Record) error {
+	return w.encoder.Encode(jsonRecord{
+		Record:           r,
+		TotalTimeMs:      r.TotalTime.Milliseconds(),
+		TurnAroundTimeMs: r.TurnAroundTime.Milliseconds(),
+	})
+}
+
+func (w *jsonWriter) Flush() error {
+	return nil
+}
+
+// CSVHeader is the column order of CSV output.
+var CSVHeader = []string{
+	"time", "bucket", "requester", "remote_ip", "operation", "key", "http_status", "error_code",
+	"bytes_sent", "object_size", "total_time_ms", "user_agent", "request_id",
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(r Record) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(CSVHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.writer.Write([]string{
+		r.Time.UTC().Format(time.RFC3339),
+		r.Bucket,
+		r.Requester,
+		r.RemoteIP,
+		r.Operation,
+		r.Key,
+		strconv.Itoa(r.HTTPStatus),
+		r.ErrorCode,
+		strconv.FormatInt(r.BytesSent, 10),
+		strconv.FormatInt(r.ObjectSize, 10),
+		strconv.FormatInt(r.TotalTime.Milliseconds(), 10),
+		r.UserAgent,
+		r.RequestID,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	if !w.headerWritten {
+		if err := w.writer.Write(CSVHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
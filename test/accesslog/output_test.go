@@ -0,0 +1,56 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	record, err := ParseLine(putLine)
+	require.NoError(t, err)
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		writer, err := NewWriter("json", &out)
+		require.NoError(t, err)
+		require.NoError(t, writer.Write(record))
+		require.NoError(t, writer.Flush())
+
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		assert.Equal(t, "cache/actions/linux node.tgz", decoded["key"])
+		assert.Equal(t, float64(57), decoded["total_time_ms"])
+		assert.Equal(t, "2025-02-06T00:00:38Z", decoded["time"])
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		var out bytes.Buffer
+		writer, err := NewWriter("csv", &out)
+		require.NoError(t, err)
+		require.NoError(t, writer.Write(record))
+		require.NoError(t, writer.Flush())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(t, strings.Join(CSVHeader, ","), lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "2025-02-06T00:00:38Z,stack-cache,arn:aws:sts::123456789012:assumed-role/stack-ec2-role/i-0123456789abcdef0,"))
+	})
+
+	t.Run("CSVHeaderWithoutRecords", func(t *testing.T) {
+		var out bytes.Buffer
+		writer, err := NewWriter("csv", &out)
+		require.NoError(t, err)
+		require.NoError(t, writer.Flush())
+		assert.Equal(t, strings.Join(CSVHeader, ",")+"\n", out.String())
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		_, err := NewWriter("xml", &bytes.Buffer{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,85 @@
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// KeyTimeLayout is the timestamp that starts every delivered log object name after the prefix,
+// e.g. s3-cache-access-logs/2025-02-06-00-15-21-C2A1E2B3D4F5A6B7.
+const KeyTimeLayout = "2006-01-02-15-04-05"
+
+// DeliveryLag bounds how long after a request S3 may deliver its log record. Objects
+// delivered later than Until plus this lag are not read.
+const DeliveryLag = 24 * time.Hour
+
+// S3API is the subset of the S3 client used to stream log objects.
+type S3API interface {
+	s3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Stream reads every log object under bucket/prefix in delivery order and calls fn for each
+// record matching the filter. Since and Until also narrow which objects are listed, since a log
+// object is always delivered after the requests it records. Returning an error from fn stops the stream.
+func Stream(ctx context.Context, client S3API, bucket, prefix string, filter Filter, fn func(Record) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	if !filter.Since.IsZero() {
+		input.StartAfter = aws.String(prefix + filter.Since.UTC().Format(KeyTimeLayout))
+	}
+	var last string
+	if !filter.Until.IsZero() {
+		last = prefix + filter.Until.Add(DeliveryLag).UTC().Format(KeyTimeLayout)
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("listing s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if last != "" && key > last {
+				return nil
+			}
+			if err := streamObject(ctx, client, bucket, key, filter, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// streamObject parses one log object and passes matching records to fn.
+func streamObject(ctx context.Context, client S3API, bucket, key string, filter Filter, fn func(Record) error) error {
+	object, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("reading s3://%s/%s: %w", bucket, key, err)
+	}
+	defer object.Body.Close()
+
+	records, err := Parse(object.Body)
+	if err != nil {
+		return fmt.Errorf("parsing s3://%s/%s: %w", bucket, key, err)
+	}
+	for _, record := range records {
+		if !filter.Match(record) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLogBucket serves log objects from memory, one page per call
+type fakeLogBucket struct {
+	objects map[string]string
+	gets    []string
+}
+
+func (f *fakeLogBucket) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) && key > aws.ToString(params.StartAfter) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	output := &s3.ListObjectsV2Output{}
+	for _, key := range keys {
+		output.Contents = append(output.Contents, s3types.Object{Key: aws.String(key)})
+	}
+	return output, nil
+}
+
+func (f *fakeLogBucket) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	key := aws.ToString(params.Key)
+	f.gets = append(f.gets, key)
+	body, ok := f.objects[key]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func newFakeLogBucket() *fakeLogBucket {
+	return &fakeLogBucket{objects: map[string]string{
+		"s3-cache-access-logs/2025-02-06-00-05-00-AAAA":  putLine + "\n",
+		"s3-cache-access-logs/2025-02-06-00-10-00-BBBB":  getLine + "\n",
+		"s3-config-access-logs/2025-02-06-00-10-00-CCCC": legacyLine + "\n",
+		"s3-cache-access-logs/2025-02-08-00-00-00-DDDD":  strings.Replace(getLine, "06/Feb/2025", "07/Feb/2025", 1) + "\n",
+	}}
+}
+
+func TestStream(t *testing.T) {
+	collect := func(client S3API, filter Filter) []Record {
+		var records []Record
+		err := Stream(context.Background(), client, "stack-logging", "s3-cache-access-logs/", filter, func(r Record) error {
+			records = append(records, r)
+			return nil
+		})
+		require.NoError(t, err)
+		return records
+	}
+
+	t.Run("AllRecordsUnderPrefix", func(t *testing.T) {
+		records := collect(newFakeLogBucket(), Filter{})
+		require.Len(t, records, 3)
+		assert.Equal(t, "REST.PUT.OBJECT", records[0].Operation)
+	})
+
+	t.Run("FilterApplied", func(t *testing.T) {
+		records := collect(newFakeLogBucket(), Filter{KeyPrefixes: []string{"runners/"}})
+		assert.Len(t, records, 2)
+	})
+
+	t.Run("TimeRangeNarrowsListing", func(t *testing.T) {
+		bucket := newFakeLogBucket()
+		records := collect(bucket, Filter{
+			Since: time.Date(2025, 2, 6, 0, 6, 0, 0, time.UTC),
+			Until: time.Date(2025, 2, 6, 12, 0, 0, 0, time.UTC),
+		})
+		assert.Empty(t, records, "the only object read holds the GET at 00:01:02, which is before Since")
+		assert.Equal(t, []string{"s3-cache-access-logs/2025-02-06-00-10-00-BBBB"}, bucket.gets,
+			"objects delivered before Since or after Until+DeliveryLag should not be read")
+	})
+
+	t.Run("CallbackErrorStops", func(t *testing.T) {
+		stop := errors.New("stop")
+		err := Stream(context.Background(), newFakeLogBucket(), "stack-logging", "s3-cache-access-logs/", Filter{}, func(Record) error {
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+	})
+
+	t.Run("ParseErrorNamesObject", func(t *testing.T) {
+		bucket := newFakeLogBucket()
+		bucket.objects["s3-cache-access-logs/2025-02-06-00-07-00-EEEE"] = "garbage\n"
+		err := Stream(context.Background(), bucket, "stack-logging", "s3-cache-access-logs/", Filter{}, func(Record) error { return nil })
+		assert.ErrorContains(t, err, "s3://stack-logging/s3-cache-access-logs/2025-02-06-00-07-00-EEEE")
+	})
+}
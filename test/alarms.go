@@ -0,0 +1,299 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// CLOUDWATCH ALARM VALIDATORS
+// =============================================================================
+
+// AlarmExpectation describes a metric alarm from modules/core/cloudwatch.tf.
+type AlarmExpectation struct {
+	Name               string
+	Namespace          string
+	MetricName         string
+	Statistic          string
+	Dimensions         map[string]string
+	Threshold          float64
+	Period             int32
+	EvaluationPeriods  int32
+	ComparisonOperator string
+	TreatMissingData   string
+
+	// ActionARN is the only alarm and OK action (the sns_topic_arn output)
+	ActionARN string
+}
+
+// AlarmExpectations returns the alarms the module should create for the scenario config.
+// The SQS alarm is only expected when SQSOldestMessageThresholdSeconds is set.
+func (c ScenarioConfig) AlarmExpectations(stackName, appRunnerServiceArn, mainQueueURL, topicArn string) []AlarmExpectation {
+	alarms := []AlarmExpectation{{
+		Name:       stackName + "-app-daily-budget",
+		Namespace:  "AWS/AppRunner",
+		MetricName: "ActiveInstances",
+		Statistic:  "Sum",
+		Dimensions: map[string]string{
+			"ServiceName": appRunnerServiceName(appRunnerServiceArn),
+			"ServiceArn":  appRunnerServiceArn,
+		},
+		Threshold:          float64(c.AppAlarmDailyMinutes),
+		Period:             86400,
+		EvaluationPeriods:  1,
+		ComparisonOperator: string(cwtypes.ComparisonOperatorGreaterThanThreshold),
+		TreatMissingData:   "missing",
+		ActionARN:          topicArn,
+	}}
+	if c.SQSOldestMessageThresholdSeconds > 0 {
+		alarms = append(alarms, AlarmExpectation{
+			Name:               stackName + "-sqs-main-oldest-message",
+			Namespace:          "AWS/SQS",
+			MetricName:         "ApproximateAgeOfOldestMessage",
+			Statistic:          "Maximum",
+			Dimensions:         map[string]string{"QueueName": mainQueueURL[strings.LastIndex(mainQueueURL, "/")+1:]},
+			Threshold:          float64(c.SQSOldestMessageThresholdSeconds),
+			Period:             60,
+			EvaluationPeriods:  1,
+			ComparisonOperator: string(cwtypes.ComparisonOperatorGreaterThanOrEqualToThreshold),
+			TreatMissingData:   "notBreaching",
+			ActionARN:          topicArn,
+		})
+	}
+	return alarms
+}
+
+// appRunnerServiceName extracts the service name from arn:aws:apprunner:<region>:<account>:service/<name>/<id>.
+func appRunnerServiceName(serviceArn string) string {
+	parts := strings.Split(serviceArn, "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// ValidateAlarms checks every <stackName>- metric alarm against the expectations, and that no
+// alarm exists that isn't expected (such as the SQS alarm with its threshold at 0).
+func ValidateAlarms(t *testing.T, stackName string, expected []AlarmExpectation) {
+	ctx := context.Background()
+	client := cloudwatch.NewFromConfig(MustGetAWSConfig(ctx))
+
+	var alarms []cwtypes.MetricAlarm
+	paginator := cloudwatch.NewDescribeAlarmsPaginator(client, &cloudwatch.DescribeAlarmsInput{
+		AlarmNamePrefix: aws.String(stackName + "-"),
+		AlarmTypes:      []cwtypes.AlarmType{cwtypes.AlarmTypeMetricAlarm},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		require.NoError(t, err, "Failed to describe alarms for %s", stackName)
+		alarms = append(alarms, page.MetricAlarms...)
+	}
+
+	violations := alarmViolations(alarms, expected)
+	for _, violation := range violations {
+		assert.Fail(t, violation)
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ %d alarms match namespace, metric, dimensions, threshold, period and actions", len(expected))
+	}
+}
+
+// alarmViolations compares described alarms with the expectations. Returns one message per violation.
+func alarmViolations(alarms []cwtypes.MetricAlarm, expected []AlarmExpectation) []string {
+	var violations []string
+	byName := map[string]cwtypes.MetricAlarm{}
+	for _, alarm := range alarms {
+		byName[aws.ToString(alarm.AlarmName)] = alarm
+	}
+
+	expectedNames := map[string]bool{}
+	for _, want := range expected {
+		expectedNames[want.Name] = true
+		alarm, ok := byName[want.Name]
+		if !ok {
+			violations = append(violations, fmt.Sprintf("alarm %s does not exist", want.Name))
+			continue
+		}
+		check := func(field string, got, want interface{}) {
+			if got != want {
+				violations = append(violations, fmt.Sprintf("alarm %s %s should be %v, got %v", aws.ToString(alarm.AlarmName), field, want, got))
+			}
+		}
+		check("namespace", aws.ToString(alarm.Namespace), want.Namespace)
+		check("metric", aws.ToString(alarm.MetricName), want.MetricName)
+		check("statistic", string(alarm.Statistic), want.Statistic)
+		check("threshold", aws.ToFloat64(alarm.Threshold), want.Threshold)
+		check("period", aws.ToInt32(alarm.Period), want.Period)
+		check("evaluation periods", aws.ToInt32(alarm.EvaluationPeriods), want.EvaluationPeriods)
+		check("comparison", string(alarm.ComparisonOperator), want.ComparisonOperator)
+		check("missing data treatment", aws.ToString(alarm.TreatMissingData), want.TreatMissingData)
+
+		dimensions := map[string]string{}
+		for _, dimension := range alarm.Dimensions {
+			dimensions[aws.ToString(dimension.Name)] = aws.ToString(dimension.Value)
+		}
+		if missing := missingTagValues(dimensions, want.Dimensions); len(missing) > 0 || len(dimensions) != len(want.Dimensions) {
+			violations = append(violations, fmt.Sprintf("alarm %s dimensions should be %v, got %v", want.Name, want.Dimensions, dimensions))
+		}
+
+		for _, actions := range []struct {
+			name string
+			arns []string
+		}{{"alarm actions", alarm.AlarmActions}, {"OK actions", alarm.OKActions}} {
+			if !slices.Equal(actions.arns, []string{want.ActionARN}) {
+				violations = append(violations, fmt.Sprintf("alarm %s %s should be [%s], got %v", want.Name, actions.name, want.ActionARN, actions.arns))
+			}
+		}
+		if !aws.ToBool(alarm.ActionsEnabled) {
+			violations = append(violations, fmt.Sprintf("alarm %s has actions disabled", want.Name))
+		}
+	}
+
+	var unexpected []string
+	for name := range byName {
+		if !expectedNames[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+	sort.Strings(unexpected)
+	for _, name := range unexpected {
+		violations = append(violations, fmt.Sprintf("alarm %s is not expected", name))
+	}
+	return violations
+}
+
+// alarmNotification is the CloudWatch alarm message inside an SNS notification.
+type alarmNotification struct {
+	AlarmName      string `json:"AlarmName"`
+	NewStateValue  string `json:"NewStateValue"`
+	NewStateReason string `json:"NewStateReason"`
+}
+
+// parseAlarmNotification decodes an SQS message body carrying an SNS envelope around an alarm message.
+func parseAlarmNotification(body string) (alarmNotification, error) {
+	var envelope struct {
+		Type    string `json:"Type"`
+		Message string `json:"Message"`
+	}
+	var notification alarmNotification
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return notification, fmt.Errorf("parsing SNS envelope: %w", err)
+	}
+	if envelope.Type != "Notification" {
+		return notification, fmt.Errorf("SNS message type %q is not a notification", envelope.Type)
+	}
+	if err := json.Unmarshal([]byte(envelope.Message), &notification); err != nil {
+		return notification, fmt.Errorf("parsing alarm message: %w", err)
+	}
+	return notification, nil
+}
+
+// ValidateAlarmNotification proves an alarm notifies the alerts topic end to end. It subscribes a
+// temporary SQS queue to the topic, forces the alarm into ALARM with SetAlarmState and waits for
+// the notification carrying a unique reason. The alarm is set back to OK afterwards.
+func ValidateAlarmNotification(t *testing.T, alarmName, topicArn string, timeout time.Duration) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	cwClient := cloudwatch.NewFromConfig(cfg)
+	snsClient := sns.NewFromConfig(cfg)
+	sqsClient := sqs.NewFromConfig(cfg)
+
+	queue, err := sqsClient.CreateQueue(ctx, &sqs.CreateQueueInput{
+		QueueName: aws.String(fmt.Sprintf("terratest-alarm-%d", time.Now().UnixNano())),
+	})
+	require.NoError(t, err, "Failed to create subscriber queue")
+	defer func() {
+		_, err := sqsClient.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: queue.QueueUrl})
+		assert.NoError(t, err, "Failed to delete subscriber queue")
+	}()
+
+	attributes, err := sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       queue.QueueUrl,
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameQueueArn},
+	})
+	require.NoError(t, err, "Failed to get subscriber queue ARN")
+	queueArn := attributes.Attributes[string(sqstypes.QueueAttributeNameQueueArn)]
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "sns.amazonaws.com"},
+			"Action":    "sqs:SendMessage",
+			"Resource":  queueArn,
+			"Condition": map[string]map[string]string{"ArnEquals": {"aws:SourceArn": topicArn}},
+		}},
+	})
+	require.NoError(t, err)
+	_, err = sqsClient.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   queue.QueueUrl,
+		Attributes: map[string]string{string(sqstypes.QueueAttributeNamePolicy): string(policy)},
+	})
+	require.NoError(t, err, "Failed to allow %s to send to the subscriber queue", topicArn)
+
+	subscription, err := snsClient.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn:              aws.String(topicArn),
+		Protocol:              aws.String("sqs"),
+		Endpoint:              aws.String(queueArn),
+		ReturnSubscriptionArn: true,
+	})
+	require.NoError(t, err, "Failed to subscribe queue to %s", topicArn)
+	defer func() {
+		_, err := snsClient.Unsubscribe(ctx, &sns.UnsubscribeInput{SubscriptionArn: subscription.SubscriptionArn})
+		assert.NoError(t, err, "Failed to unsubscribe test queue")
+	}()
+
+	reason := fmt.Sprintf("terratest alarm notification check %d", time.Now().UnixNano())
+	_, err = cwClient.SetAlarmState(ctx, &cloudwatch.SetAlarmStateInput{
+		AlarmName:   aws.String(alarmName),
+		StateValue:  cwtypes.StateValueAlarm,
+		StateReason: aws.String(reason),
+	})
+	require.NoError(t, err, "Failed to set %s to ALARM", alarmName)
+	defer func() {
+		_, err := cwClient.SetAlarmState(ctx, &cloudwatch.SetAlarmStateInput{
+			AlarmName:   aws.String(alarmName),
+			StateValue:  cwtypes.StateValueOk,
+			StateReason: aws.String("terratest alarm notification check finished"),
+		})
+		assert.NoError(t, err, "Failed to reset %s to OK", alarmName)
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		messages, err := sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            queue.QueueUrl,
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     20,
+		})
+		require.NoError(t, err, "Failed to receive from subscriber queue")
+		for _, message := range messages.Messages {
+			notification, err := parseAlarmNotification(aws.ToString(message.Body))
+			if err != nil {
+				t.Logf("Ignoring message: %v", err)
+				continue
+			}
+			if notification.AlarmName == alarmName && notification.NewStateValue == string(cwtypes.StateValueAlarm) &&
+				notification.NewStateReason == reason {
+				t.Logf("✓ Alarm %s notification reached %s", alarmName, topicArn)
+				return
+			}
+		}
+	}
+	assert.Fail(t, fmt.Sprintf("No ALARM notification for %s reached %s within %s", alarmName, topicArn, timeout))
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTopicArn      = "arn:aws:sns:us-east-1:123456789012:stack-alerts"
+	testAppRunnerArn  = "arn:aws:apprunner:us-east-1:123456789012:service/stack-app/0123456789abcdef"
+	testMainQueueURL  = "https://sqs.us-east-1.amazonaws.com/123456789012/stack-main.fifo"
+	testMainQueueName = "stack-main.fifo"
+)
+
+// describedAlarm builds a MetricAlarm as DescribeAlarms returns it for an expectation
+func describedAlarm(want AlarmExpectation) cwtypes.MetricAlarm {
+	alarm := cwtypes.MetricAlarm{
+		AlarmName:          aws.String(want.Name),
+		Namespace:          aws.String(want.Namespace),
+		MetricName:         aws.String(want.MetricName),
+		Statistic:          cwtypes.Statistic(want.Statistic),
+		Threshold:          aws.Float64(want.Threshold),
+		Period:             aws.Int32(want.Period),
+		EvaluationPeriods:  aws.Int32(want.EvaluationPeriods),
+		ComparisonOperator: cwtypes.ComparisonOperator(want.ComparisonOperator),
+		TreatMissingData:   aws.String(want.TreatMissingData),
+		ActionsEnabled:     aws.Bool(true),
+		AlarmActions:       []string{want.ActionARN},
+		OKActions:          []string{want.ActionARN},
+	}
+	for name, value := range want.Dimensions {
+		alarm.Dimensions = append(alarm.Dimensions, cwtypes.Dimension{Name: aws.String(name), Value: aws.String(value)})
+	}
+	return alarm
+}
+
+func TestAlarmExpectations(t *testing.T) {
+	config := DefaultScenarioConfig()
+	alarms := config.AlarmExpectations("stack", testAppRunnerArn, testMainQueueURL, testTopicArn)
+	require.Len(t, alarms, 1, "SQS alarm is disabled by default")
+	assert.Equal(t, map[string]string{"ServiceName": "stack-app", "ServiceArn": testAppRunnerArn}, alarms[0].Dimensions)
+	assert.Equal(t, 4000.0, alarms[0].Threshold)
+
+	config.SQSOldestMessageThresholdSeconds = 600
+	alarms = config.AlarmExpectations("stack", testAppRunnerArn, testMainQueueURL, testTopicArn)
+	require.Len(t, alarms, 2)
+	assert.Equal(t, "stack-sqs-main-oldest-message", alarms[1].Name)
+	assert.Equal(t, map[string]string{"QueueName": testMainQueueName}, alarms[1].Dimensions)
+}
+
+func TestAlarmViolations(t *testing.T) {
+	config := DefaultScenarioConfig()
+	config.SQSOldestMessageThresholdSeconds = 600
+	expected := config.AlarmExpectations("stack", testAppRunnerArn, testMainQueueURL, testTopicArn)
+
+	testCases := []struct {
+		name     string
+		mutate   func(alarms []cwtypes.MetricAlarm) []cwtypes.MetricAlarm
+		contains []string
+	}{
+		{"Matching", func(a []cwtypes.MetricAlarm) []cwtypes.MetricAlarm { return a }, nil},
+		{"Missing", func(a []cwtypes.MetricAlarm) []cwtypes.MetricAlarm { return a[:1] }, []string{"stack-sqs-main-oldest-message does not exist"}},
+		{"Unexpected", func(a []cwtypes.MetricAlarm) []cwtypes.MetricAlarm {
+			return append(a, cwtypes.MetricAlarm{AlarmName: aws.String("stack-sqs-jobs-oldest-message")})
+		}, []string{"stack-sqs-jobs-oldest-message is not expected"}},
+		{"Threshold", func(a []cwtypes.MetricAlarm) []cwtypes.MetricAlarm {
+			a[0].Threshold = aws.Float64(1440)
+			return a
+		}, []string{"threshold should be 4000, got 1440"}},
+		{"Period", func(a []cwtypes.MetricAlarm) []cwtypes.MetricAlarm {
+			a[1].Period = aws.Int32(300)
+			return a
+		}, []string{"period should be 60, got 300"}},
+		{"MissingDimension", func(a []cwtypes.MetricAlarm) []cwtypes.MetricAlarm {
+			a[0].Dimensions = a[0].Dimensions[:1]
+			return a
+		}, []string{"stack-app-daily-budget dimensions"}},
+		{"WrongQueue", func(a []cwtypes.MetricAlarm) []cwtypes.MetricAlarm {
+			a[1].Dimensions = []cwtypes.Dimension{{Name: aws.String("QueueName"), Value: aws.String("stack-jobs.fifo")}}
+			return a
+		}, []string{"stack-sqs-main-oldest-message dimensions"}},
+		{"Actions", func(a []cwtypes.MetricAlarm) []cwtypes.MetricAlarm {
+			a[0].OKActions = nil
+			a[1].AlarmActions = []string{"arn:aws:sns:us-east-1:123456789012:other"}
+			return a
+		}, []string{"stack-app-daily-budget OK actions", "stack-sqs-main-oldest-message alarm actions"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var alarms []cwtypes.MetricAlarm
+			for _, want := range expected {
+				alarms = append(alarms, describedAlarm(want))
+			}
+			violations := alarmViolations(tc.mutate(alarms), expected)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			joined := strings.Join(violations, "\n")
+			for _, want := range tc.contains {
+				assert.Contains(t, joined, want)
+			}
+		})
+	}
+}
+
+func TestParseAlarmNotification(t *testing.T) {
+	body := `{
+  "Type" : "Notification",
+  "MessageId" : "5d1b7c6e-0000-4000-8000-000000000000",
+  "TopicArn" : "arn:aws:sns:us-east-1:123456789012:stack-alerts",
+  "Subject" : "ALARM: \"stack-app-daily-budget\" in US East (N. Virginia)",
+  "Message" : "{\"AlarmName\":\"stack-app-daily-budget\",\"AlarmDescription\":\"Alarm when App Runner active instances exceed daily budget\",\"NewStateValue\":\"ALARM\",\"NewStateReason\":\"terratest alarm notification check 42\",\"OldStateValue\":\"INSUFFICIENT_DATA\"}",
+  "Timestamp" : "2025-02-06T10:15:00.000Z"
+}`
+	notification, err := parseAlarmNotification(body)
+	require.NoError(t, err)
+	assert.Equal(t, alarmNotification{
+		AlarmName:      "stack-app-daily-budget",
+		NewStateValue:  "ALARM",
+		NewStateReason: "terratest alarm notification check 42",
+	}, notification)
+
+	_, err = parseAlarmNotification(`{"Type":"SubscriptionConfirmation","Message":"You have chosen to subscribe"}`)
+	assert.ErrorContains(t, err, "not a notification")
+}
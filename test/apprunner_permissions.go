@@ -0,0 +1,214 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// APP RUNNER ROLE PERMISSIONS
+// =============================================================================
+
+// CoreAppRunnerConfig is the file defining the App Runner role policies, relative to the test directory.
+const CoreAppRunnerConfig = "../modules/core/apprunner.tf"
+
+// AppRunnerRoleResource is the address of the App Runner instance role within the core module.
+const AppRunnerRoleResource = "aws_iam_role.apprunner"
+
+// PermissionCase is one request with the decision the role's policies must reach for it.
+type PermissionCase struct {
+	Name    string
+	Request PolicyRequest
+	Allowed bool
+}
+
+// AppRunnerPermissionMatrix lists what the App Runner instance role may and may not do: launch
+// runners and pass only the runner role, manage only the stack's instances, volumes, parameters,
+// objects, queues and tables, and nothing in IAM beyond the Spot service-linked role.
+func AppRunnerPermissionMatrix(vars IAMBaselineVars) []PermissionCase {
+	arn := func(service, resource string) string {
+		return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, vars.Region, vars.AccountID, resource)
+	}
+	role := func(name string) string { return fmt.Sprintf("arn:aws:iam::%s:role/%s", vars.AccountID, name) }
+	stackTag := func(stack string) map[string]string {
+		return map[string]string{"aws:ResourceTag/runs-on-stack-name": stack}
+	}
+	instance := arn("ec2", "instance/i-0123456789abcdef0")
+	otherStack := vars.StackName + "-other"
+
+	return []PermissionCase{
+		// Launching runners
+		{"RunInstances", PolicyRequest{Action: "ec2:RunInstances", Resource: instance}, true},
+		{"RunInstancesFromImage", PolicyRequest{Action: "ec2:RunInstances", Resource: fmt.Sprintf("arn:aws:ec2:%s::image/ami-0123456789abcdef0", vars.Region)}, true},
+		{"RunInstancesOtherRegion", PolicyRequest{Action: "ec2:RunInstances", Resource: fmt.Sprintf("arn:aws:ec2:eu-north-1:%s:instance/i-0123456789abcdef0", vars.AccountID)}, false},
+		{"CreateFleet", PolicyRequest{Action: "ec2:CreateFleet", Resource: arn("ec2", "fleet/*")}, true},
+		{"PassRunnerRole", PolicyRequest{Action: "iam:PassRole", Resource: role(vars.StackName + "-ec2-instance-role")}, true},
+		{"PassOwnRole", PolicyRequest{Action: "iam:PassRole", Resource: role(vars.StackName + "-apprunner-role")}, false},
+		{"PassOtherStackRunnerRole", PolicyRequest{Action: "iam:PassRole", Resource: role(otherStack + "-ec2-instance-role")}, false},
+		{"PassAdminRole", PolicyRequest{Action: "iam:PassRole", Resource: role("admin")}, false},
+		{"SpotServiceLinkedRole", PolicyRequest{
+			Action:   "iam:CreateServiceLinkedRole",
+			Resource: role("aws-service-role/spot.amazonaws.com/AWSServiceRoleForEC2Spot"),
+			Context:  map[string]string{"iam:AWSServiceName": "spot.amazonaws.com"},
+		}, true},
+		{"OtherServiceLinkedRole", PolicyRequest{
+			Action:   "iam:CreateServiceLinkedRole",
+			Resource: role("aws-service-role/autoscaling.amazonaws.com/AWSServiceRoleForAutoScaling"),
+			Context:  map[string]string{"iam:AWSServiceName": "autoscaling.amazonaws.com"},
+		}, false},
+
+		// Instance lifecycle is limited to instances tagged with the stack
+		{"TerminateStackInstance", PolicyRequest{Action: "ec2:TerminateInstances", Resource: instance, Context: stackTag(vars.StackName)}, true},
+		{"TerminateOtherStackInstance", PolicyRequest{Action: "ec2:TerminateInstances", Resource: instance, Context: stackTag(otherStack)}, false},
+		{"TerminateUntaggedInstance", PolicyRequest{Action: "ec2:TerminateInstances", Resource: instance}, false},
+		{"StopStackInstance", PolicyRequest{Action: "ec2:StopInstances", Resource: instance, Context: stackTag(vars.StackName)}, true},
+		{"StartOtherStackInstance", PolicyRequest{Action: "ec2:StartInstances", Resource: instance, Context: stackTag(otherStack)}, false},
+		{"DeleteStackVolume", PolicyRequest{Action: "ec2:DeleteVolume", Resource: arn("ec2", "volume/vol-0123456789abcdef0"), Context: stackTag(vars.StackName)}, true},
+		{"DeleteOtherStackVolume", PolicyRequest{Action: "ec2:DeleteVolume", Resource: arn("ec2", "volume/vol-0123456789abcdef0"), Context: stackTag(otherStack)}, false},
+		{"DeleteUntaggedSnapshot", PolicyRequest{Action: "ec2:DeleteSnapshot", Resource: fmt.Sprintf("arn:aws:ec2:%s::snapshot/snap-0123456789abcdef0", vars.Region)}, false},
+		{"ModifyInstanceAttribute", PolicyRequest{Action: "ec2:ModifyInstanceAttribute", Resource: instance, Context: stackTag(vars.StackName)}, false},
+		{"DeleteLaunchTemplate", PolicyRequest{Action: "ec2:DeleteLaunchTemplate", Resource: arn("ec2", "launch-template/lt-0123456789abcdef0")}, false},
+
+		// SSM parameters under /<stack_name>/
+		{"GetStackParameter", PolicyRequest{Action: "ssm:GetParameter", Resource: arn("ssm", "parameter/"+vars.StackName+"/license-key")}, true},
+		{"PutStackParameter", PolicyRequest{Action: "ssm:PutParameter", Resource: arn("ssm", "parameter/"+vars.StackName+"/github-app")}, true},
+		{"GetOtherStackParameter", PolicyRequest{Action: "ssm:GetParameter", Resource: arn("ssm", "parameter/"+otherStack+"/license-key")}, false},
+		{"GetRootParameter", PolicyRequest{Action: "ssm:GetParameter", Resource: arn("ssm", "parameter/license-key")}, false},
+
+		// S3: read/write config, delete only the database, write-only cache
+		{"GetConfigObject", PolicyRequest{Action: "s3:GetObject", Resource: s3BucketArn(vars.ConfigBucket) + "/runs-on/config.yml"}, true},
+		{"DeleteDatabaseObject", PolicyRequest{Action: "s3:DeleteObject", Resource: s3BucketArn(vars.ConfigBucket) + "/runs-on/db/jobs.json"}, true},
+		{"DeleteAgentObject", PolicyRequest{Action: "s3:DeleteObject", Resource: s3BucketArn(vars.ConfigBucket) + "/agents/runs-on-agent"}, false},
+		{"PutRunnerCacheObject", PolicyRequest{Action: "s3:PutObject", Resource: s3BucketArn(vars.CacheBucket) + "/runners/i-0123456789abcdef0/config"}, true},
+		{"PutActionsCacheObject", PolicyRequest{Action: "s3:PutObject", Resource: s3BucketArn(vars.CacheBucket) + "/cache/key"}, false},
+		{"GetCacheObject", PolicyRequest{Action: "s3:GetObject", Resource: s3BucketArn(vars.CacheBucket) + "/runners/i-0123456789abcdef0/config"}, false},
+		{"DeleteConfigBucket", PolicyRequest{Action: "s3:DeleteBucket", Resource: s3BucketArn(vars.ConfigBucket)}, false},
+		{"PutBucketPolicy", PolicyRequest{Action: "s3:PutBucketPolicy", Resource: s3BucketArn(vars.ConfigBucket)}, false},
+
+		// Queues, tables and topic of the stack only
+		{"SendToMainQueue", PolicyRequest{Action: "sqs:SendMessage", Resource: arn("sqs", vars.StackName+"-main.fifo")}, true},
+		{"ReceiveFromEventsQueue", PolicyRequest{Action: "sqs:ReceiveMessage", Resource: arn("sqs", vars.StackName+"-events")}, true},
+		{"SendToOtherStackQueue", PolicyRequest{Action: "sqs:SendMessage", Resource: arn("sqs", otherStack+"-main.fifo")}, false},
+		{"PurgeMainQueue", PolicyRequest{Action: "sqs:PurgeQueue", Resource: arn("sqs", vars.StackName+"-main.fifo")}, false},
+		{"DeleteLock", PolicyRequest{Action: "dynamodb:DeleteItem", Resource: arn("dynamodb", "table/"+vars.StackName+"-locks")}, true},
+		{"QueryWorkflowJobsIndex", PolicyRequest{Action: "dynamodb:Query", Resource: arn("dynamodb", "table/"+vars.StackName+"-workflow-jobs/index/status")}, true},
+		{"DeleteWorkflowJob", PolicyRequest{Action: "dynamodb:DeleteItem", Resource: arn("dynamodb", "table/"+vars.StackName+"-workflow-jobs")}, false},
+		{"ScanLocks", PolicyRequest{Action: "dynamodb:Scan", Resource: arn("dynamodb", "table/"+vars.StackName+"-locks")}, false},
+		{"DeleteLocksTable", PolicyRequest{Action: "dynamodb:DeleteTable", Resource: arn("dynamodb", "table/"+vars.StackName+"-locks")}, false},
+		{"PublishAlert", PolicyRequest{Action: "sns:Publish", Resource: arn("sns", vars.StackName+"-alerts")}, true},
+		{"PublishOtherTopic", PolicyRequest{Action: "sns:Publish", Resource: arn("sns", otherStack+"-alerts")}, false},
+
+		// Logs of the service only
+		{"PutServiceLogs", PolicyRequest{Action: "logs:PutLogEvents", Resource: arn("logs", "log-group:/aws/apprunner/RunsOnService-abc123/service:log-stream:events")}, true},
+		{"PutRunnerLogs", PolicyRequest{Action: "logs:PutLogEvents", Resource: arn("logs", "log-group:"+vars.StackName+"/ec2/instances:log-stream:i-0123456789abcdef0")}, false},
+
+		// Pulling the app image
+		{"PullImage", PolicyRequest{Action: "ecr:BatchGetImage", Resource: arn("ecr", "repository/runs-on")}, true},
+		{"PushImage", PolicyRequest{Action: "ecr:PutImage", Resource: arn("ecr", "repository/runs-on")}, false},
+
+		// No escalation paths
+		{"CreateRole", PolicyRequest{Action: "iam:CreateRole", Resource: role("escalate")}, false},
+		{"PutRolePolicy", PolicyRequest{Action: "iam:PutRolePolicy", Resource: role(vars.StackName + "-apprunner-role")}, false},
+		{"AttachRolePolicy", PolicyRequest{Action: "iam:AttachRolePolicy", Resource: role(vars.StackName + "-ec2-instance-role")}, false},
+		{"AssumeRole", PolicyRequest{Action: "sts:AssumeRole", Resource: role("admin")}, false},
+		{"DecryptWithAnyKey", PolicyRequest{Action: "kms:Decrypt", Resource: arn("kms", "key/0123abcd-0123-0123-0123-0123456789ab")}, false},
+	}
+}
+
+// permissionMatrixViolations evaluates every case against the policies and returns one message
+// per case whose decision differs from the expected one.
+func permissionMatrixViolations(policies map[string]PolicyDocument, cases []PermissionCase) []string {
+	var violations []string
+	for _, c := range cases {
+		decision, err := EvaluatePolicies(policies, c.Request)
+		switch {
+		case err != nil:
+			violations = append(violations, fmt.Sprintf("%s: %v", c.Name, err))
+		case decision.Allowed && !c.Allowed:
+			violations = append(violations, fmt.Sprintf("%s: %s on %s is allowed by %s, expected denied",
+				c.Name, c.Request.Action, c.Request.Resource, decision.Statement))
+		case !decision.Allowed && c.Allowed:
+			violations = append(violations, fmt.Sprintf("%s: %s on %s is denied, expected allowed",
+				c.Name, c.Request.Action, c.Request.Resource))
+		}
+	}
+	return violations
+}
+
+// referencesResource reports whether a configured expression refers to the resource address.
+func referencesResource(expression *tfjson.Expression, address string) bool {
+	if expression == nil || expression.ExpressionData == nil {
+		return false
+	}
+	for _, reference := range expression.References {
+		if reference == address {
+			return true
+		}
+	}
+	return false
+}
+
+// plannedRolePolicies returns the inline policies and the AWS managed policies attached to a
+// role in plan JSON, keyed by policy name or ARN. Roles are matched by the resource address their
+// policies reference, since role names and IDs are unknown until apply. The inline documents of
+// this module reference queues and tables, so they are only known in a plan of a deployed stack.
+// Attached managed policies are looked up with managedPolicy.
+func plannedRolePolicies(plan *terraform.PlanStruct, roleAddress string, managedPolicy func(arn string) (PolicyDocument, error)) (map[string]PolicyDocument, error) {
+	policies := map[string]PolicyDocument{}
+	for _, resource := range plannedResources(plan, "aws_iam_role_policy") {
+		if !referencesResource(configuredExpression(plan, resource, "role"), roleAddress) {
+			continue
+		}
+		document := attributeString(resource, "policy")
+		if document == "" {
+			return nil, fmt.Errorf("%s: policy is not known until apply", resource.Address)
+		}
+		policy, err := ParsePolicyDocument(document)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", resource.Address, err)
+		}
+		policies[attributeString(resource, "name")] = policy
+	}
+	for _, resource := range plannedResources(plan, "aws_iam_role_policy_attachment") {
+		if !referencesResource(configuredExpression(plan, resource, "role"), roleAddress) {
+			continue
+		}
+		arn := attributeString(resource, "policy_arn")
+		policy, err := managedPolicy(arn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", resource.Address, err)
+		}
+		policies[arn] = policy
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("no planned policies reference %s", roleAddress)
+	}
+	return policies, nil
+}
+
+// ValidatePlannedAppRunnerPermissions evaluates the App Runner instance role policies in plan
+// JSON against AppRunnerPermissionMatrix. Attached AWS managed policies are fetched from IAM.
+func ValidatePlannedAppRunnerPermissions(t *testing.T, plan *terraform.PlanStruct, vars IAMBaselineVars) {
+	ctx := context.Background()
+	client := iam.NewFromConfig(MustGetAWSConfig(ctx))
+	policies, err := plannedRolePolicies(plan, AppRunnerRoleResource, func(arn string) (PolicyDocument, error) {
+		return managedPolicyDocument(ctx, client, arn)
+	})
+	require.NoError(t, err, "Failed to read the App Runner role policies from the plan")
+
+	cases := AppRunnerPermissionMatrix(vars)
+	violations := permissionMatrixViolations(policies, cases)
+	for _, violation := range violations {
+		assert.Fail(t, "App Runner role permission violation", violation)
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ App Runner role: %d policies decide all %d permission cases as expected", len(policies), len(cases))
+	}
+}
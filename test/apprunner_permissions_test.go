@@ -0,0 +1,272 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// renderAppRunnerPolicy renders AppRunnerEC2Permissions from apprunner.tf with the ARNs the
+// module would give the referenced resources.
+func renderAppRunnerPolicy(t *testing.T, vars IAMBaselineVars) string {
+	arn := func(service, resource string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"arn": cty.StringVal(fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, vars.Region, vars.AccountID, resource)),
+		})
+	}
+	queues := map[string]cty.Value{}
+	for name, queue := range map[string]string{
+		"main": "main.fifo", "jobs": "jobs.fifo", "github": "github.fifo",
+		"pool": "pool", "housekeeping": "housekeeping", "termination": "termination", "events": "events",
+	} {
+		queues[name] = arn("sqs", vars.StackName+"-"+queue)
+	}
+
+	rendered, err := renderResourceAttributes(CoreAppRunnerConfig, "aws_iam_role_policy", []string{"policy"}, &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"region":                cty.StringVal(vars.Region),
+				"account_id":            cty.StringVal(vars.AccountID),
+				"stack_name":            cty.StringVal(vars.StackName),
+				"config_bucket_arn":     cty.StringVal(s3BucketArn(vars.ConfigBucket)),
+				"cache_bucket_arn":      cty.StringVal(s3BucketArn(vars.CacheBucket)),
+				"ec2_instance_role_arn": cty.StringVal(fmt.Sprintf("arn:aws:iam::%s:role/%s-ec2-instance-role", vars.AccountID, vars.StackName)),
+			}),
+			"aws_sns_topic": cty.ObjectVal(map[string]cty.Value{"alerts": arn("sns", vars.StackName+"-alerts")}),
+			"aws_sqs_queue": cty.ObjectVal(queues),
+			"aws_dynamodb_table": cty.ObjectVal(map[string]cty.Value{
+				"locks":         arn("dynamodb", "table/"+vars.StackName+"-locks"),
+				"workflow_jobs": arn("dynamodb", "table/"+vars.StackName+"-workflow-jobs"),
+			}),
+		},
+	})
+	require.NoError(t, err)
+	require.Contains(t, rendered, "apprunner_permissions")
+	return rendered["apprunner_permissions"]["policy"]
+}
+
+// appRunnerPlanJSON is a plan of the core module's App Runner role policies, as a plan of a
+// deployed stack shows them. An empty policy is left unknown.
+func appRunnerPlanJSON(t *testing.T, policy string) string {
+	values := map[string]interface{}{"name": "AppRunnerEC2Permissions"}
+	if policy != "" {
+		values["policy"] = policy
+	}
+	roleReference := map[string]interface{}{"references": []string{"aws_iam_role.apprunner.id", "aws_iam_role.apprunner"}}
+	plan := map[string]interface{}{
+		"format_version": "1.2",
+		"planned_values": map[string]interface{}{
+			"root_module": map[string]interface{}{
+				"child_modules": []interface{}{map[string]interface{}{
+					"address": "module.core",
+					"resources": []interface{}{
+						map[string]interface{}{
+							"address": "module.core.aws_iam_role_policy.apprunner_permissions",
+							"mode":    "managed", "type": "aws_iam_role_policy", "name": "apprunner_permissions",
+							"values": values,
+						},
+						map[string]interface{}{
+							"address": "module.core.aws_iam_role_policy_attachment.apprunner_ecr",
+							"mode":    "managed", "type": "aws_iam_role_policy_attachment", "name": "apprunner_ecr",
+							"values": map[string]interface{}{"policy_arn": "arn:aws:iam::aws:policy/service-role/AWSAppRunnerServicePolicyForECRAccess"},
+						},
+						map[string]interface{}{
+							"address": "module.core.aws_iam_role_policy.scheduler_sqs[0]",
+							"mode":    "managed", "type": "aws_iam_role_policy", "name": "scheduler_sqs", "index": 0,
+							"values": map[string]interface{}{"name": "SchedulerSQS", "policy": `{"Statement":[{"Effect":"Allow","Action":"*","Resource":"*"}]}`},
+						},
+					},
+				}},
+			},
+		},
+		"configuration": map[string]interface{}{
+			"root_module": map[string]interface{}{
+				"module_calls": map[string]interface{}{
+					"core": map[string]interface{}{
+						"module": map[string]interface{}{
+							"resources": []interface{}{
+								map[string]interface{}{
+									"address": "aws_iam_role_policy.apprunner_permissions", "mode": "managed",
+									"type": "aws_iam_role_policy", "name": "apprunner_permissions",
+									"expressions": map[string]interface{}{"role": roleReference},
+								},
+								map[string]interface{}{
+									"address": "aws_iam_role_policy_attachment.apprunner_ecr", "mode": "managed",
+									"type": "aws_iam_role_policy_attachment", "name": "apprunner_ecr",
+									"expressions": map[string]interface{}{"role": roleReference},
+								},
+								map[string]interface{}{
+									"address": "aws_iam_role_policy.scheduler_sqs", "mode": "managed",
+									"type": "aws_iam_role_policy", "name": "scheduler_sqs",
+									"expressions": map[string]interface{}{"role": map[string]interface{}{
+										"references": []string{"aws_iam_role.scheduler[0].id", "aws_iam_role.scheduler[0]", "aws_iam_role.scheduler"},
+									}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestAppRunnerPermissionMatrix(t *testing.T) {
+	rendered := renderAppRunnerPolicy(t, testIAMBaselineVars)
+
+	t.Run("Module", func(t *testing.T) {
+		plan, err := terraform.ParsePlanJSON(appRunnerPlanJSON(t, rendered))
+		require.NoError(t, err)
+		policies, err := plannedRolePolicies(plan, AppRunnerRoleResource, checkedInManagedPolicy)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"AppRunnerEC2Permissions", "arn:aws:iam::aws:policy/service-role/AWSAppRunnerServicePolicyForECRAccess"},
+			sortedKeys(policies), "only policies referencing the App Runner role should be evaluated")
+		assert.Empty(t, permissionMatrixViolations(policies, AppRunnerPermissionMatrix(testIAMBaselineVars)))
+	})
+
+	testCases := []struct {
+		name     string
+		from, to string
+		contains []string
+	}{
+		{
+			name: "PassRoleOnEveryRole",
+			from: `"Resource":"arn:aws:iam::123456789012:role/tt-a1b2c3-ec2-instance-role"`,
+			to:   `"Resource":"*"`,
+			contains: []string{
+				"PassOwnRole: iam:PassRole on arn:aws:iam::123456789012:role/tt-a1b2c3-apprunner-role is allowed by AppRunnerEC2Permissions[4], expected denied",
+				"PassOtherStackRunnerRole: iam:PassRole",
+				"PassAdminRole: iam:PassRole",
+			},
+		},
+		{
+			name: "TerminateWithoutTagCondition",
+			from: `"Condition":{"StringEquals":{"aws:ResourceTag/runs-on-stack-name":"tt-a1b2c3"}},"Effect":"Allow","Resource":"arn:aws:ec2`,
+			to:   `"Effect":"Allow","Resource":"arn:aws:ec2`,
+			contains: []string{
+				"TerminateOtherStackInstance: ec2:TerminateInstances on arn:aws:ec2:us-east-1:123456789012:instance/i-0123456789abcdef0 is allowed by AppRunnerEC2Permissions[6], expected denied",
+				"TerminateUntaggedInstance: ec2:TerminateInstances",
+				"StartOtherStackInstance: ec2:StartInstances",
+			},
+		},
+		{
+			name: "SSMParametersUnscoped",
+			from: `parameter/tt-a1b2c3/*`,
+			to:   `parameter/*`,
+			contains: []string{
+				"GetOtherStackParameter: ssm:GetParameter",
+				"GetRootParameter: ssm:GetParameter",
+			},
+		},
+		{
+			name:     "StackParametersDropped",
+			from:     `"ssm:PutParameter",`,
+			to:       ``,
+			contains: []string{"PutStackParameter: ssm:PutParameter on arn:aws:ssm:us-east-1:123456789012:parameter/tt-a1b2c3/github-app is denied, expected allowed"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Contains(t, rendered, tc.from)
+			plan, err := terraform.ParsePlanJSON(appRunnerPlanJSON(t, strings.Replace(rendered, tc.from, tc.to, 1)))
+			require.NoError(t, err)
+			policies, err := plannedRolePolicies(plan, AppRunnerRoleResource, checkedInManagedPolicy)
+			require.NoError(t, err)
+			violations := permissionMatrixViolations(policies, AppRunnerPermissionMatrix(testIAMBaselineVars))
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			for i, want := range tc.contains {
+				assert.Contains(t, violations[i], want)
+			}
+		})
+	}
+
+	t.Run("PolicyUnknown", func(t *testing.T) {
+		plan, err := terraform.ParsePlanJSON(appRunnerPlanJSON(t, ""))
+		require.NoError(t, err)
+		_, err = plannedRolePolicies(plan, AppRunnerRoleResource, checkedInManagedPolicy)
+		assert.ErrorContains(t, err, "module.core.aws_iam_role_policy.apprunner_permissions: policy is not known until apply")
+	})
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	policies := map[string]PolicyDocument{
+		"Runner": {Statement: []PolicyStatement{
+			{Effect: "Allow", Action: StringOrSlice{"s3:GetObject"}, Resource: StringOrSlice{"arn:aws:s3:::cache/runners/${aws:userid}/*"}},
+			{Sid: "Tagged", Effect: "Allow", Action: StringOrSlice{"ec2:Stop*"}, Resource: StringOrSlice{"*"},
+				Condition: map[string]map[string]StringOrSlice{"StringLike": {"aws:ResourceTag/stack": {"prod-*"}}}},
+			{Effect: "Allow", NotAction: StringOrSlice{"iam:*"}, NotResource: StringOrSlice{"arn:aws:s3:::secrets/*"},
+				Condition: map[string]map[string]StringOrSlice{"BoolIfExists": {"aws:MultiFactorAuthPresent": {"true"}}}},
+		}},
+		"Guardrail": {Statement: []PolicyStatement{
+			{Effect: "Deny", Action: StringOrSlice{"ec2:TerminateInstances"}, Resource: StringOrSlice{"*"},
+				Condition: map[string]map[string]StringOrSlice{"StringNotEquals": {"aws:ResourceTag/stack": {"prod-a"}}}},
+			{Effect: "Deny", Action: StringOrSlice{"ec2:DeleteVolume"}, Resource: StringOrSlice{"*"},
+				Condition: map[string]map[string]StringOrSlice{"Null": {"aws:ResourceTag/stack": {"true"}}}},
+		}},
+	}
+
+	testCases := []struct {
+		name     string
+		request  PolicyRequest
+		decision PolicyDecision
+	}{
+		{"PolicyVariable", PolicyRequest{Action: "s3:GetObject", Resource: "arn:aws:s3:::cache/runners/AROA1:i-1/key",
+			Context: map[string]string{"aws:userid": "AROA1:i-1", "aws:MultiFactorAuthPresent": "false"}},
+			PolicyDecision{Allowed: true, Statement: "Runner[0]"}},
+		{"PolicyVariableOtherUser", PolicyRequest{Action: "s3:GetObject", Resource: "arn:aws:s3:::cache/runners/AROA1:i-2/key",
+			Context: map[string]string{"aws:userid": "AROA1:i-1", "aws:MultiFactorAuthPresent": "false"}},
+			PolicyDecision{}},
+		{"ActionWildcardCaseInsensitive", PolicyRequest{Action: "EC2:StopInstances", Resource: "arn:aws:ec2:us-east-1:1:instance/i-1",
+			Context: map[string]string{"aws:ResourceTag/stack": "prod-a", "aws:MultiFactorAuthPresent": "false"}},
+			PolicyDecision{Allowed: true, Statement: "Runner[Tagged]"}},
+		{"ConditionKeyMissing", PolicyRequest{Action: "ec2:StopInstances", Resource: "arn:aws:ec2:us-east-1:1:instance/i-1",
+			Context: map[string]string{"aws:MultiFactorAuthPresent": "false"}},
+			PolicyDecision{}},
+		{"IfExistsKeyMissing", PolicyRequest{Action: "sqs:SendMessage", Resource: "arn:aws:sqs:us-east-1:1:queue"},
+			PolicyDecision{Allowed: true, Statement: "Runner[2]"}},
+		{"NotResource", PolicyRequest{Action: "s3:GetObject", Resource: "arn:aws:s3:::secrets/key"},
+			PolicyDecision{}},
+		{"NotAction", PolicyRequest{Action: "iam:CreateRole", Resource: "arn:aws:iam::1:role/x"},
+			PolicyDecision{}},
+		{"ResourceCaseSensitive", PolicyRequest{Action: "s3:GetObject", Resource: "arn:aws:s3:::SECRETS/key"},
+			PolicyDecision{Allowed: true, Statement: "Runner[2]"}},
+		{"ExplicitDenyWins", PolicyRequest{Action: "ec2:TerminateInstances", Resource: "arn:aws:ec2:us-east-1:1:instance/i-1",
+			Context: map[string]string{"aws:ResourceTag/stack": "prod-b"}},
+			PolicyDecision{ExplicitDeny: true, Statement: "Guardrail[0]"}},
+		{"DenyConditionNotMet", PolicyRequest{Action: "ec2:TerminateInstances", Resource: "arn:aws:ec2:us-east-1:1:instance/i-1",
+			Context: map[string]string{"aws:ResourceTag/stack": "prod-a"}},
+			PolicyDecision{Allowed: true, Statement: "Runner[2]"}},
+		{"NullKeyMissing", PolicyRequest{Action: "ec2:DeleteVolume", Resource: "arn:aws:ec2:us-east-1:1:volume/vol-1"},
+			PolicyDecision{ExplicitDeny: true, Statement: "Guardrail[1]"}},
+		{"NullKeyPresent", PolicyRequest{Action: "ec2:DeleteVolume", Resource: "arn:aws:ec2:us-east-1:1:volume/vol-1",
+			Context: map[string]string{"aws:ResourceTag/stack": "prod-b"}},
+			PolicyDecision{Allowed: true, Statement: "Runner[2]"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decision, err := EvaluatePolicies(policies, tc.request)
+			require.NoError(t, err)
+			assert.Equal(t, tc.decision, decision)
+		})
+	}
+
+	t.Run("UnsupportedOperator", func(t *testing.T) {
+		_, err := EvaluatePolicies(map[string]PolicyDocument{"P": {Statement: []PolicyStatement{
+			{Effect: "Allow", Action: StringOrSlice{"*"}, Resource: StringOrSlice{"*"},
+				Condition: map[string]map[string]StringOrSlice{"IpAddress": {"aws:SourceIp": {"10.0.0.0/8"}}}},
+		}}}, PolicyRequest{Action: "s3:GetObject", Resource: "*", Context: map[string]string{"aws:SourceIp": "10.1.2.3"}})
+		assert.ErrorContains(t, err, "P[0]: unsupported condition operator IpAddress")
+	})
+}
@@ -0,0 +1,166 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// BUILDKIT PROGRESS PARSING
+// =============================================================================
+
+// buildKitStatus is one line of `docker buildx build --progress=rawjson` output.
+// Only vertex updates are decoded; statuses, logs and warnings are ignored.
+type buildKitStatus struct {
+	Vertexes []buildKitVertex `json:"vertexes"`
+}
+
+// buildKitVertex is a vertex update. The same digest is reported again as it starts,
+// completes or is found in the cache.
+type buildKitVertex struct {
+	Digest    string     `json:"digest"`
+	Name      string     `json:"name"`
+	Completed *time.Time `json:"completed"`
+	Cached    bool       `json:"cached"`
+	Error     string     `json:"error"`
+}
+
+// buildStepName matches Dockerfile step vertices such as "[2/4] RUN apk add jq" or "[builder 2/4] COPY . ."
+var buildStepName = regexp.MustCompile(`^\[(?:(\S+) )?(\d+)/(\d+)\] (.*)$`)
+
+// BuildStep is a Dockerfile instruction with its vertex updates merged.
+type BuildStep struct {
+	Name        string
+	Stage       string
+	Index       int
+	Total       int
+	Instruction string
+	Cached      bool
+	Completed   bool
+	Error       string
+}
+
+// IsFrom reports whether the step is a base image, which never produces a cached layer.
+func (s BuildStep) IsFrom() bool {
+	return strings.HasPrefix(s.Instruction, "FROM ")
+}
+
+// BuildReport summarizes a BuildKit progress stream.
+type BuildReport struct {
+	// Steps are the Dockerfile steps in the order they were first reported
+	Steps []BuildStep
+
+	// CacheImports are the "importing cache manifest from <ref>" vertices that completed without error
+	CacheImports []string
+
+	// Errors are the errors of any vertex, Dockerfile step or not
+	Errors []string
+}
+
+// CachedLayers returns the non-FROM steps that BuildKit reported as cached.
+func (r BuildReport) CachedLayers() []BuildStep {
+	var cached []BuildStep
+	for _, step := range r.Steps {
+		if step.Cached && !step.IsFrom() {
+			cached = append(cached, step)
+		}
+	}
+	return cached
+}
+
+// UncachedLayers returns the non-FROM steps that BuildKit executed.
+func (r BuildReport) UncachedLayers() []BuildStep {
+	var uncached []BuildStep
+	for _, step := range r.Steps {
+		if !step.Cached && !step.IsFrom() {
+			uncached = append(uncached, step)
+		}
+	}
+	return uncached
+}
+
+// ParseBuildKitProgress reads a `--progress=rawjson` stream, one JSON status per line.
+// Lines not starting with "{" (docker CLI messages) are skipped.
+func ParseBuildKitProgress(r io.Reader) (BuildReport, error) {
+	var report BuildReport
+	steps := map[string]int{}
+	imports := map[string]bool{}
+	failed := map[string]bool{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var status buildKitStatus
+		if err := json.Unmarshal([]byte(line), &status); err != nil {
+			return report, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		for _, vertex := range status.Vertexes {
+			if vertex.Error != "" && !failed[vertex.Digest] {
+				failed[vertex.Digest] = true
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", vertex.Name, vertex.Error))
+			}
+			if ref, ok := strings.CutPrefix(vertex.Name, "importing cache manifest from "); ok {
+				if vertex.Completed != nil && vertex.Error == "" && !imports[vertex.Digest] {
+					imports[vertex.Digest] = true
+					report.CacheImports = append(report.CacheImports, ref)
+				}
+				continue
+			}
+			match := buildStepName.FindStringSubmatch(vertex.Name)
+			if match == nil {
+				continue
+			}
+			i, seen := steps[vertex.Digest]
+			if !seen {
+				index, _ := strconv.Atoi(match[2])
+				total, _ := strconv.Atoi(match[3])
+				report.Steps = append(report.Steps, BuildStep{
+					Name: vertex.Name, Stage: match[1], Index: index, Total: total, Instruction: match[4],
+				})
+				i = len(report.Steps) - 1
+				steps[vertex.Digest] = i
+			}
+			step := &report.Steps[i]
+			step.Cached = step.Cached || vertex.Cached
+			step.Completed = step.Completed || vertex.Completed != nil
+			if vertex.Error != "" {
+				step.Error = vertex.Error
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return report, fmt.Errorf("reading BuildKit progress: %w", err)
+	}
+	return report, nil
+}
+
+// buildCacheViolations checks that a build imported a registry cache, had no errors and
+// reused at least minCachedLayers layers. Returns one message per violation.
+func buildCacheViolations(report BuildReport, minCachedLayers int) []string {
+	var violations []string
+	for _, err := range report.Errors {
+		violations = append(violations, "build error: "+err)
+	}
+	if len(report.CacheImports) == 0 {
+		violations = append(violations, "no cache manifest was imported")
+	}
+	if cached := len(report.CachedLayers()); cached < minCachedLayers {
+		var uncached []string
+		for _, step := range report.UncachedLayers() {
+			uncached = append(uncached, step.Name)
+		}
+		violations = append(violations, fmt.Sprintf("%d of at least %d layers were cached; rebuilt: %s",
+			cached, minCachedLayers, strings.Join(uncached, "; ")))
+	}
+	return violations
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseBuildKitFixture parses a recorded `--progress=rawjson` stream from testdata/buildkit
+func parseBuildKitFixture(t *testing.T, name string) BuildReport {
+	file, err := os.Open(filepath.Join("testdata", "buildkit", name))
+	require.NoError(t, err)
+	defer file.Close()
+	report, err := ParseBuildKitProgress(file)
+	require.NoError(t, err)
+	return report
+}
+
+func TestParseBuildKitProgress(t *testing.T) {
+	t.Run("CacheHit", func(t *testing.T) {
+		report := parseBuildKitFixture(t, "cache-hit.jsonl")
+		require.Len(t, report.Steps, 4)
+		assert.True(t, report.Steps[0].IsFrom())
+		assert.Equal(t, 2, report.Steps[1].Index)
+		assert.Equal(t, 4, report.Steps[1].Total)
+		assert.Equal(t, "RUN apk add --no-cache curl", report.Steps[1].Instruction)
+		assert.Len(t, report.CachedLayers(), 3)
+		assert.Empty(t, report.UncachedLayers())
+		require.Len(t, report.CacheImports, 1)
+		assert.Contains(t, report.CacheImports[0], ":cache-test-")
+		assert.Empty(t, report.Errors)
+		assert.Empty(t, buildCacheViolations(report, 3))
+	})
+
+	t.Run("PartialHit", func(t *testing.T) {
+		report := parseBuildKitFixture(t, "cache-partial.jsonl")
+		assert.Len(t, report.CachedLayers(), 1)
+		require.Len(t, report.UncachedLayers(), 2)
+		assert.True(t, report.UncachedLayers()[0].Completed)
+
+		violations := buildCacheViolations(report, 3)
+		require.Len(t, violations, 1)
+		assert.Contains(t, violations[0], "1 of at least 3 layers were cached")
+		assert.Contains(t, violations[0], "[3/4] RUN apk add --no-cache jq")
+	})
+
+	t.Run("ImportAndStepErrors", func(t *testing.T) {
+		report := parseBuildKitFixture(t, "cache-error.jsonl")
+		assert.Empty(t, report.CacheImports)
+		require.Len(t, report.Errors, 2)
+		assert.Contains(t, report.Steps[1].Error, "exit code: 1")
+
+		violations := buildCacheViolations(report, 3)
+		assert.Len(t, violations, 4, "two errors, no import, no cached layers: %v", violations)
+	})
+
+	t.Run("TrimmedLines", func(t *testing.T) {
+		// ValidateECRPushPullFromEC2 drops statuses and logs on the instance to fit SSM output
+		raw, err := os.ReadFile(filepath.Join("testdata", "buildkit", "cache-hit.jsonl"))
+		require.NoError(t, err)
+		var trimmed []string
+		for _, line := range strings.Split(string(raw), "\n") {
+			if i := strings.Index(line, `,"statuses":`); i >= 0 {
+				trimmed = append(trimmed, line[:i]+"}")
+			}
+		}
+		report, err := ParseBuildKitProgress(strings.NewReader("WARNING: cli message\n" + strings.Join(trimmed, "\n")))
+		require.NoError(t, err)
+		assert.Len(t, report.CachedLayers(), 3)
+		assert.Len(t, report.CacheImports, 1)
+	})
+
+	t.Run("TruncatedStream", func(t *testing.T) {
+		_, err := ParseBuildKitProgress(strings.NewReader(`{"vertexes":[{"digest":"sha256:ab`))
+		assert.ErrorContains(t, err, "line 1")
+	})
+}
@@ -0,0 +1,204 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// CLOUDWATCH LOG INGESTION
+// =============================================================================
+
+// cloudWatchLogsAPI is the subset of the CloudWatch Logs client used to find an instance's log events.
+type cloudWatchLogsAPI interface {
+	DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error)
+	FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error)
+}
+
+// LogStreamScheme is how the CloudWatch agent named the stream an instance's logs landed in.
+type LogStreamScheme string
+
+const (
+	// LogStreamInstanceID is a stream named after the instance, e.g. i-0123456789abcdef0
+	LogStreamInstanceID LogStreamScheme = "{instance_id}"
+
+	// LogStreamInstanceIDPrefix is a per-file stream under the instance, e.g. i-0123456789abcdef0/messages
+	LogStreamInstanceIDPrefix LogStreamScheme = "{instance_id}/<name>"
+
+	// LogStreamInstanceIDSuffix is a per-file stream ending with the instance, e.g. messages/i-0123456789abcdef0
+	LogStreamInstanceIDSuffix LogStreamScheme = "<name>/{instance_id}"
+
+	// LogStreamInstanceIDInfix is any other stream name containing the instance ID
+	LogStreamInstanceIDInfix LogStreamScheme = "<name>{instance_id}<name>"
+)
+
+// filterLogEventsMaxStreams is the most stream names FilterLogEvents accepts in one call.
+const filterLogEventsMaxStreams = 100
+
+// classifyLogStream returns the naming scheme of a stream, or false if the stream is not the instance's.
+func classifyLogStream(stream, instanceID string) (LogStreamScheme, bool) {
+	switch {
+	case stream == instanceID:
+		return LogStreamInstanceID, true
+	case strings.HasPrefix(stream, instanceID+"/"):
+		return LogStreamInstanceIDPrefix, true
+	case strings.HasSuffix(stream, "/"+instanceID):
+		return LogStreamInstanceIDSuffix, true
+	case containsInstanceID(stream, instanceID):
+		return LogStreamInstanceIDInfix, true
+	}
+	return "", false
+}
+
+// containsInstanceID reports whether the instance ID occurs in s not followed by another hex
+// digit, so i-0123456789abcdef0 doesn't match inside i-0123456789abcdef01.
+func containsInstanceID(s, instanceID string) bool {
+	for i := strings.Index(s, instanceID); i >= 0; {
+		end := i + len(instanceID)
+		if end == len(s) || !strings.ContainsRune("0123456789abcdef", rune(s[end])) {
+			return true
+		}
+		next := strings.Index(s[i+1:], instanceID)
+		if next < 0 {
+			return false
+		}
+		i += 1 + next
+	}
+	return false
+}
+
+// LogMarkerMatch is the log event a marker was found in.
+type LogMarkerMatch struct {
+	LogStream string
+	Scheme    LogStreamScheme
+	Message   string
+	Timestamp time.Time
+}
+
+// instanceLogStreams lists the streams in the log group named after the instance. The other
+// stream names seen are returned too, to explain a miss.
+func instanceLogStreams(ctx context.Context, client cloudWatchLogsAPI, logGroupName, instanceID string) ([]string, []string, error) {
+	var mine, others []string
+	paginator := cloudwatchlogs.NewDescribeLogStreamsPaginator(client, &cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName: aws.String(logGroupName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to describe log streams of %s: %w", logGroupName, err)
+		}
+		for _, stream := range page.LogStreams {
+			name := aws.ToString(stream.LogStreamName)
+			if _, ok := classifyLogStream(name, instanceID); ok {
+				mine = append(mine, name)
+			} else {
+				others = append(others, name)
+			}
+		}
+	}
+	return mine, others, nil
+}
+
+// searchLogMarker looks for the marker in the given streams once.
+func searchLogMarker(ctx context.Context, client cloudWatchLogsAPI, logGroupName string, streams []string,
+	instanceID, marker string, since time.Time) (LogMarkerMatch, bool, error) {
+	for start := 0; start < len(streams); start += filterLogEventsMaxStreams {
+		batch := streams[start:min(start+filterLogEventsMaxStreams, len(streams))]
+		paginator := cloudwatchlogs.NewFilterLogEventsPaginator(client, &cloudwatchlogs.FilterLogEventsInput{
+			LogGroupName:   aws.String(logGroupName),
+			LogStreamNames: batch,
+			FilterPattern:  aws.String(fmt.Sprintf("%q", marker)),
+			StartTime:      aws.Int64(since.UnixMilli()),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return LogMarkerMatch{}, false, fmt.Errorf("failed to filter log events in %s: %w", logGroupName, err)
+			}
+			for _, event := range page.Events {
+				message := aws.ToString(event.Message)
+				if !strings.Contains(message, marker) {
+					continue
+				}
+				stream := aws.ToString(event.LogStreamName)
+				scheme, _ := classifyLogStream(stream, instanceID)
+				return LogMarkerMatch{
+					LogStream: stream,
+					Scheme:    scheme,
+					Message:   message,
+					Timestamp: time.UnixMilli(aws.ToInt64(event.Timestamp)).UTC(),
+				}, true, nil
+			}
+		}
+	}
+	return LogMarkerMatch{}, false, nil
+}
+
+// findLogMarker polls the instance's streams in the log group until an event containing the
+// marker appears. Streams are listed again on every poll, since the agent creates them lazily.
+func findLogMarker(ctx context.Context, client cloudWatchLogsAPI, logGroupName, instanceID, marker string,
+	since time.Time, pollInterval time.Duration, maxPolls int) (LogMarkerMatch, error) {
+	var streams, others []string
+	for i := 0; i < maxPolls; i++ {
+		if i > 0 {
+			time.Sleep(pollInterval)
+		}
+		var err error
+		if streams, others, err = instanceLogStreams(ctx, client, logGroupName, instanceID); err != nil {
+			return LogMarkerMatch{}, err
+		}
+		if len(streams) == 0 {
+			continue
+		}
+		match, found, err := searchLogMarker(ctx, client, logGroupName, streams, instanceID, marker, since)
+		if err != nil || found {
+			return match, err
+		}
+	}
+
+	waited := pollInterval * time.Duration(maxPolls-1)
+	if len(streams) == 0 {
+		return LogMarkerMatch{}, fmt.Errorf("no log stream named after %s in %s after %v; other streams: %s",
+			instanceID, logGroupName, waited, truncateString(strings.Join(others, ", "), 300))
+	}
+	return LogMarkerMatch{}, fmt.Errorf("marker %q not found in %s streams %s after %v",
+		marker, logGroupName, strings.Join(streams, ", "), waited)
+}
+
+// ValidateEC2CloudWatchLogs verifies that a line logged on an EC2 instance reaches its CloudWatch log group.
+func ValidateEC2CloudWatchLogs(t *testing.T, instanceID, logGroupName string) {
+	ValidateEC2CloudWatchLogsForOS(t, OSLinux, instanceID, logGroupName)
+}
+
+// ValidateEC2CloudWatchLogsForOS runs the ValidateEC2CloudWatchLogs check on a Linux or Windows instance.
+// A unique marker is logged on the instance (syslog on Linux, the Application event log on Windows)
+// and the instance's streams are polled with FilterLogEvents until it shows up.
+func ValidateEC2CloudWatchLogsForOS(t *testing.T, instanceOS InstanceOS, instanceID, logGroupName string) {
+	shell, err := shellForOS(instanceOS)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := cloudwatchlogs.NewFromConfig(cfg)
+
+	// Event timestamps come from the instance clock, so allow for some skew
+	since := time.Now().Add(-time.Minute)
+	marker := fmt.Sprintf("terratest-%s-%d", instanceID, time.Now().UnixNano())
+	logCmd := shell.Log("terratest", fmt.Sprintf("Functional test log entry %s", marker))
+	_, _, err = RunSSMCommandForOS(t, instanceID, instanceOS, []string{logCmd})
+	require.NoError(t, err, "Failed to log the marker on %s", instanceID)
+	logged := time.Now()
+
+	match, err := findLogMarker(ctx, client, logGroupName, instanceID, marker, since, 15*time.Second, 21)
+	require.NoError(t, err, "Log entry from %s never reached CloudWatch", instanceID)
+
+	t.Logf("✓ Log entry from %s reached %s in stream %s (scheme %s) within %v",
+		instanceID, logGroupName, match.LogStream, match.Scheme, time.Since(logged).Round(time.Second))
+}
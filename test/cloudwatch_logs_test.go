@@ -0,0 +1,202 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwltypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLogMarker = "terratest-i-0123456789abcdef0-42"
+
+// fakeLogStream is a stream that appears once DescribeLogStreams has been polled visibleAfter times.
+type fakeLogStream struct {
+	name         string
+	visibleAfter int
+	events       []fakeLogEvent
+}
+
+// fakeLogEvent is an event that is ingested once DescribeLogStreams has been polled visibleAfter times.
+type fakeLogEvent struct {
+	message      string
+	visibleAfter int
+}
+
+// fakeCloudWatchLogs serves streams two per page and events one stream per page, and records
+// the stream names of each FilterLogEvents call.
+type fakeCloudWatchLogs struct {
+	streams  []fakeLogStream
+	polls    int
+	filtered [][]string
+}
+
+func (f *fakeCloudWatchLogs) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	start := 0
+	if params.NextToken == nil {
+		f.polls++
+	} else {
+		start, _ = strconv.Atoi(aws.ToString(params.NextToken))
+	}
+	var visible []cwltypes.LogStream
+	for _, stream := range f.streams {
+		if stream.visibleAfter <= f.polls {
+			visible = append(visible, cwltypes.LogStream{LogStreamName: aws.String(stream.name)})
+		}
+	}
+	out := &cloudwatchlogs.DescribeLogStreamsOutput{LogStreams: visible[start:min(start+2, len(visible))]}
+	if start+2 < len(visible) {
+		out.NextToken = aws.String(strconv.Itoa(start + 2))
+	}
+	return out, nil
+}
+
+func (f *fakeCloudWatchLogs) FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	if params.NextToken == nil {
+		f.filtered = append(f.filtered, params.LogStreamNames)
+	}
+	i, _ := strconv.Atoi(aws.ToString(params.NextToken))
+	pattern := strings.Trim(aws.ToString(params.FilterPattern), `"`)
+	out := &cloudwatchlogs.FilterLogEventsOutput{}
+	if i+1 < len(params.LogStreamNames) {
+		out.NextToken = aws.String(strconv.Itoa(i + 1))
+	}
+	for _, stream := range f.streams {
+		if stream.name != params.LogStreamNames[i] {
+			continue
+		}
+		for _, event := range stream.events {
+			if event.visibleAfter <= f.polls && strings.Contains(event.message, pattern) {
+				out.Events = append(out.Events, cwltypes.FilteredLogEvent{
+					LogStreamName: aws.String(stream.name),
+					Message:       aws.String(event.message),
+					Timestamp:     aws.Int64(1738836000000),
+				})
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestClassifyLogStream(t *testing.T) {
+	const instanceID = "i-0123456789abcdef0"
+	for stream, want := range map[string]LogStreamScheme{
+		"i-0123456789abcdef0":                      LogStreamInstanceID,
+		"i-0123456789abcdef0/messages":             LogStreamInstanceIDPrefix,
+		"messages/i-0123456789abcdef0":             LogStreamInstanceIDSuffix,
+		"runs-on-i-0123456789abcdef0-agent":        LogStreamInstanceIDInfix,
+		"i-0123456789abcdef01/messages":            "",
+		"runs-on-i-0123456789abcdef01-agent":       "",
+		"i-0123456789abcdef01-i-0123456789abcdef0": LogStreamInstanceIDInfix,
+		"ip-10-0-1-23.ec2.internal":                "",
+		"i-0fedcba9876543210/messages":             "",
+		"i-0123456789abcdef0/amazon-ssm-agent.log": LogStreamInstanceIDPrefix,
+	} {
+		scheme, ok := classifyLogStream(stream, instanceID)
+		assert.Equal(t, want, scheme, stream)
+		assert.Equal(t, want != "", ok, stream)
+	}
+}
+
+func TestFindLogMarker(t *testing.T) {
+	const instanceID = "i-0123456789abcdef0"
+	markerEvent := func(visibleAfter int) []fakeLogEvent {
+		return []fakeLogEvent{
+			{message: "Feb  6 10:00:00 ip-10-0-1-23 systemd[1]: Started session", visibleAfter: 1},
+			{message: "Feb  6 10:00:01 ip-10-0-1-23 terratest: Functional test log entry " + testLogMarker, visibleAfter: visibleAfter},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		streams  []fakeLogStream
+		scheme   LogStreamScheme
+		stream   string
+		polls    int
+		contains string
+	}{
+		{
+			name:    "InstanceStream",
+			streams: []fakeLogStream{{name: instanceID, visibleAfter: 1, events: markerEvent(1)}},
+			scheme:  LogStreamInstanceID, stream: instanceID, polls: 1,
+		},
+		{
+			name: "PerFileStreamsCreatedLate",
+			streams: []fakeLogStream{
+				{name: "i-0fedcba9876543210/messages", visibleAfter: 1, events: markerEvent(1)},
+				{name: instanceID + "/cloud-init.log", visibleAfter: 2},
+				{name: instanceID + "/messages", visibleAfter: 2, events: markerEvent(3)},
+			},
+			scheme: LogStreamInstanceIDPrefix, stream: instanceID + "/messages", polls: 3,
+		},
+		{
+			name: "SuffixStream",
+			streams: []fakeLogStream{
+				{name: "syslog/i-0fedcba9876543210", visibleAfter: 1},
+				{name: "syslog/" + instanceID, visibleAfter: 1, events: markerEvent(2)},
+			},
+			scheme: LogStreamInstanceIDSuffix, stream: "syslog/" + instanceID, polls: 2,
+		},
+		{
+			name: "MarkerOnlyInAnotherInstanceStream",
+			streams: []fakeLogStream{
+				{name: "i-0fedcba9876543210", visibleAfter: 1, events: markerEvent(1)},
+				{name: instanceID, visibleAfter: 1, events: markerEvent(99)[:1]},
+			},
+			polls:    4,
+			contains: `marker "` + testLogMarker + `" not found in /stack/ec2/instances streams ` + instanceID,
+		},
+		{
+			name:     "NoInstanceStreams",
+			streams:  []fakeLogStream{{name: "ip-10-0-1-23.ec2.internal", visibleAfter: 1, events: markerEvent(1)}},
+			polls:    4,
+			contains: "no log stream named after " + instanceID + " in /stack/ec2/instances after 3ms; other streams: ip-10-0-1-23.ec2.internal",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeCloudWatchLogs{streams: tc.streams}
+			match, err := findLogMarker(context.Background(), client, "/stack/ec2/instances", instanceID, testLogMarker,
+				time.Now(), time.Millisecond, 4)
+			assert.Equal(t, tc.polls, client.polls)
+			if tc.contains != "" {
+				assert.ErrorContains(t, err, tc.contains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.stream, match.LogStream)
+			assert.Equal(t, tc.scheme, match.Scheme)
+			assert.Contains(t, match.Message, testLogMarker)
+			for _, names := range client.filtered {
+				for _, name := range names {
+					_, ok := classifyLogStream(name, instanceID)
+					assert.True(t, ok, "only the instance's streams should be searched, got %s", name)
+				}
+			}
+		})
+	}
+
+	t.Run("StreamsBatchedForFilterLogEvents", func(t *testing.T) {
+		var streams []fakeLogStream
+		for i := 0; i < 150; i++ {
+			streams = append(streams, fakeLogStream{name: fmt.Sprintf("%s/file-%03d", instanceID, i), visibleAfter: 1})
+		}
+		streams[130].events = markerEvent(1)
+		client := &fakeCloudWatchLogs{streams: streams}
+		match, err := findLogMarker(context.Background(), client, "/stack/ec2/instances", instanceID, testLogMarker,
+			time.Now(), time.Millisecond, 1)
+		require.NoError(t, err)
+		assert.Equal(t, instanceID+"/file-130", match.LogStream)
+		require.Len(t, client.filtered, 2)
+		assert.Len(t, client.filtered[0], 100)
+		assert.Len(t, client.filtered[1], 50)
+	})
+}
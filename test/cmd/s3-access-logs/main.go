@@ -0,0 +1,102 @@
+// Command s3-access-logs queries the S3 server access logs delivered to a RunsOn logging bucket.
+//
+// Example: which runner read which cache key in the last day
+//
+//	go run ./cmd/s3-access-logs -bucket <stack>-logging-... -since 24h \
+//	  -key-prefix cache/ -operation REST.GET.OBJECT -format csv
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sjysngh/runs-on-tf/test/accesslog"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "s3-access-logs:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	bucket := flag.String("bucket", "", "logging bucket name (logging_bucket_name output)")
+	prefix := flag.String("prefix", "s3-cache-access-logs/", "log object prefix (s3-cache-access-logs/ or s3-config-access-logs/)")
+	requester := flag.String("requester", "", "requester ARN, or an ARN prefix ending in / such as arn:aws:sts::<account>:assumed-role/<role>/")
+	userID := flag.String("user-id", "", "aws:userid of the requester, e.g. AROAEXAMPLE:i-0123456789abcdef0")
+	keyPrefixes := flag.String("key-prefix", "", "comma-separated key prefixes, e.g. cache/,runners/")
+	operations := flag.String("operation", "", "comma-separated operations, e.g. REST.GET.OBJECT,REST.PUT.OBJECT")
+	since := flag.String("since", "", "start time: RFC3339 or a duration before now such as 6h")
+	until := flag.String("until", "", "end time: RFC3339 or a duration before now")
+	format := flag.String("format", "json", "output format: json (one object per line) or csv")
+	region := flag.String("region", "", "AWS region (default from the environment)")
+	flag.Parse()
+
+	if *bucket == "" {
+		return fmt.Errorf("-bucket is required")
+	}
+	now := time.Now()
+	filter := accesslog.Filter{
+		Requester:   *requester,
+		UserID:      *userID,
+		KeyPrefixes: splitList(*keyPrefixes),
+		Operations:  splitList(*operations),
+	}
+	var err error
+	if filter.Since, err = parseTime(*since, now); err != nil {
+		return fmt.Errorf("-since: %w", err)
+	}
+	if filter.Until, err = parseTime(*until, now); err != nil {
+		return fmt.Errorf("-until: %w", err)
+	}
+
+	writer, err := accesslog.NewWriter(*format, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var opts []func(*config.LoadOptions) error
+	if *region != "" {
+		opts = append(opts, config.WithRegion(*region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("loading AWS config: %w", err)
+	}
+
+	err = accesslog.Stream(ctx, s3.NewFromConfig(cfg), *bucket, *prefix, filter, writer.Write)
+	if flushErr := writer.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// parseTime accepts an RFC3339 timestamp or a duration before now. Empty means unbounded.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
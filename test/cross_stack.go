@@ -0,0 +1,245 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// CROSS-STACK ISOLATION
+// =============================================================================
+
+// CrossStackTarget is what a runner of one stack probes in another stack of the same account.
+type CrossStackTarget struct {
+	StackName    string
+	ConfigBucket string
+	QueueURL     string // a FIFO queue of the stack, e.g. sqs_queue_main_url
+	VolumeID     string // a volume tagged runs-on-stack-name=StackName
+}
+
+// CrossStackPermissionMatrix lists what the runner role of own may do to its own stack and may
+// not do to other's: its buckets, volumes, snapshots, queues and logs. Both stacks are in the
+// same account and region.
+func CrossStackPermissionMatrix(own, other IAMBaselineVars) []PermissionCase {
+	arn := func(service, resource string) string {
+		return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, own.Region, own.AccountID, resource)
+	}
+	stackTag := func(stack string) map[string]string {
+		return map[string]string{"aws:ResourceTag/runs-on-stack-name": stack}
+	}
+	volume := arn("ec2", "volume/vol-0123456789abcdef0")
+	snapshot := fmt.Sprintf("arn:aws:ec2:%s::snapshot/snap-0123456789abcdef0", own.Region)
+	userID := map[string]string{"aws:userid": "AROA0123456789ABCDEF0:i-0123456789abcdef0"}
+	runnerObject := "/runners/AROA0123456789ABCDEF0:i-0123456789abcdef0/config"
+
+	return []PermissionCase{
+		// Buckets
+		{"GetOwnAgent", PolicyRequest{Action: "s3:GetObject", Resource: s3BucketArn(own.ConfigBucket) + "/agents/runs-on-agent"}, true},
+		{"GetOtherStackAgent", PolicyRequest{Action: "s3:GetObject", Resource: s3BucketArn(other.ConfigBucket) + "/agents/runs-on-agent"}, false},
+		{"GetOtherStackConfig", PolicyRequest{Action: "s3:GetObject", Resource: s3BucketArn(other.ConfigBucket) + "/runs-on/config.yml"}, false},
+		{"ListOtherStackConfig", PolicyRequest{Action: "s3:ListBucket", Resource: s3BucketArn(other.ConfigBucket)}, false},
+		{"PutOtherStackCache", PolicyRequest{Action: "s3:PutObject", Resource: s3BucketArn(other.CacheBucket) + "/cache/key"}, false},
+		{"GetOwnRunnerObject", PolicyRequest{Action: "s3:GetObject", Resource: s3BucketArn(own.CacheBucket) + runnerObject, Context: userID}, true},
+		{"GetOtherStackRunnerObject", PolicyRequest{Action: "s3:GetObject", Resource: s3BucketArn(other.CacheBucket) + runnerObject, Context: userID}, false},
+
+		// Tags decide which volumes and snapshots belong to a stack
+		{"TagOwnVolume", PolicyRequest{Action: "ec2:CreateTags", Resource: volume, Context: stackTag(own.StackName)}, true},
+		{"TagUntaggedVolume", PolicyRequest{Action: "ec2:CreateTags", Resource: volume}, true},
+		{"TagOtherStackVolume", PolicyRequest{Action: "ec2:CreateTags", Resource: volume, Context: stackTag(other.StackName)}, false},
+		{"TagOtherStackSnapshot", PolicyRequest{Action: "ec2:CreateTags", Resource: snapshot, Context: stackTag(other.StackName)}, false},
+
+		// CreateSnapshot is authorized on the source volume and the new snapshot, CreateVolume on
+		// the new volume and the source snapshot
+		{"SnapshotOwnVolume", PolicyRequest{Action: "ec2:CreateSnapshot", Resource: volume, Context: stackTag(own.StackName)}, true},
+		{"CreateSnapshot", PolicyRequest{Action: "ec2:CreateSnapshot", Resource: snapshot}, true},
+		{"SnapshotOtherStackVolume", PolicyRequest{Action: "ec2:CreateSnapshot", Resource: volume, Context: stackTag(other.StackName)}, false},
+		{"SnapshotUntaggedVolume", PolicyRequest{Action: "ec2:CreateSnapshot", Resource: volume}, false},
+		{"CreateVolume", PolicyRequest{Action: "ec2:CreateVolume", Resource: volume}, true},
+		{"RestoreOwnSnapshot", PolicyRequest{Action: "ec2:CreateVolume", Resource: snapshot, Context: stackTag(own.StackName)}, true},
+		{"RestoreOtherStackSnapshot", PolicyRequest{Action: "ec2:CreateVolume", Resource: snapshot, Context: stackTag(other.StackName)}, false},
+		{"AttachOtherStackVolume", PolicyRequest{Action: "ec2:AttachVolume", Resource: volume, Context: stackTag(other.StackName)}, false},
+		{"DeleteOtherStackVolume", PolicyRequest{Action: "ec2:DeleteVolume", Resource: volume, Context: stackTag(other.StackName)}, false},
+		{"DeleteOtherStackSnapshot", PolicyRequest{Action: "ec2:DeleteSnapshot", Resource: snapshot, Context: stackTag(other.StackName)}, false},
+
+		// Queues are the App Runner service's; runners have no SQS access at all
+		{"SendToOtherStackQueue", PolicyRequest{Action: "sqs:SendMessage", Resource: arn("sqs", other.StackName+"-main.fifo")}, false},
+		{"ReceiveFromOtherStackQueue", PolicyRequest{Action: "sqs:ReceiveMessage", Resource: arn("sqs", other.StackName+"-jobs.fifo")}, false},
+		{"SendToOwnQueue", PolicyRequest{Action: "sqs:SendMessage", Resource: arn("sqs", own.StackName+"-main.fifo")}, false},
+
+		// Logs
+		{"PutOwnLogs", PolicyRequest{Action: "logs:PutLogEvents", Resource: arn("logs", "log-group:"+own.StackName+"/ec2/instances:log-stream:i-0123456789abcdef0")}, true},
+		{"PutOtherStackLogs", PolicyRequest{Action: "logs:PutLogEvents", Resource: arn("logs", "log-group:"+other.StackName+"/ec2/instances:log-stream:i-0123456789abcdef0")}, false},
+	}
+}
+
+// ValidateCrossStackPermissions fetches the policies of a runner role and evaluates them
+// against CrossStackPermissionMatrix.
+func ValidateCrossStackPermissions(t *testing.T, roleName string, own, other IAMBaselineVars) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+
+	policies, err := rolePolicyDocuments(ctx, iam.NewFromConfig(cfg), roleName)
+	require.NoError(t, err, "Failed to fetch the policies of role %s", roleName)
+
+	cases := CrossStackPermissionMatrix(own, other)
+	violations := permissionMatrixViolations(policies, cases)
+	for _, violation := range violations {
+		assert.Fail(t, "Cross-stack permission violation", "%s: %s", roleName, violation)
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ %s: all %d cross-stack permission cases against %s decided as expected", roleName, len(cases), other.StackName)
+	}
+}
+
+// isDryRunAllowed reports whether the output of an EC2 --dry-run call says the request would
+// have succeeded. A denied dry run fails with UnauthorizedOperation instead.
+func isDryRunAllowed(output string) bool {
+	return strings.Contains(output, "DryRunOperation")
+}
+
+// CreateStackVolume creates a 1 GiB volume tagged as belonging to stackName, standing in for a
+// runner volume of that stack. Returns the volume ID; DeleteStackVolume removes it.
+func CreateStackVolume(t *testing.T, stackName, availabilityZone string) string {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := ec2.NewFromConfig(cfg)
+
+	result, err := client.CreateVolume(ctx, &ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String(availabilityZone),
+		Size:             aws.Int32(1),
+		VolumeType:       ec2types.VolumeTypeGp3,
+		TagSpecifications: []ec2types.TagSpecification{
+			{
+				ResourceType: ec2types.ResourceTypeVolume,
+				Tags: []ec2types.Tag{
+					{Key: aws.String("runs-on-stack-name"), Value: aws.String(stackName)},
+					{Key: aws.String("Name"), Value: aws.String("terratest-cross-stack")},
+					{Key: aws.String("TestFramework"), Value: aws.String("terratest")},
+					{Key: aws.String("AutoCleanup"), Value: aws.String("true")},
+				},
+			},
+		},
+	})
+	require.NoError(t, err, "Failed to create a volume for %s", stackName)
+
+	volumeID := aws.ToString(result.VolumeId)
+	err = ec2.NewVolumeAvailableWaiter(client).Wait(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{volumeID}}, 2*time.Minute)
+	require.NoError(t, err, "Volume %s did not become available", volumeID)
+	t.Logf("Created volume %s tagged runs-on-stack-name=%s", volumeID, stackName)
+	return volumeID
+}
+
+// DeleteStackVolume deletes a volume created by CreateStackVolume.
+func DeleteStackVolume(t *testing.T, volumeID string) {
+	if volumeID == "" {
+		return
+	}
+
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := ec2.NewFromConfig(cfg)
+	t.Logf("Deleting volume: %s", volumeID)
+
+	if _, err := client.DeleteVolume(ctx, &ec2.DeleteVolumeInput{VolumeId: aws.String(volumeID)}); err != nil {
+		t.Logf("Warning: Failed to delete volume %s: %v", volumeID, err)
+	}
+}
+
+// instanceRootVolume returns the ID of the volume attached as the instance's root device.
+func instanceRootVolume(ctx context.Context, client *ec2.Client, instanceID string) (string, error) {
+	result, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe instance %s: %w", instanceID, err)
+	}
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			for _, mapping := range instance.BlockDeviceMappings {
+				if aws.ToString(mapping.DeviceName) == aws.ToString(instance.RootDeviceName) && mapping.Ebs != nil {
+					return aws.ToString(mapping.Ebs.VolumeId), nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("instance %s has no root EBS volume", instanceID)
+}
+
+// ValidateCrossStackIsolation runs AWS CLI calls on a Linux runner of one stack against the
+// resources of another stack in the same account:
+//
+// Positive controls (the runner's own stack):
+//   - CAN dry-run a snapshot and a tag of its own root volume
+//
+// Negative cases:
+//   - CANNOT read agents/* in the other stack's config bucket
+//   - CANNOT dry-run a snapshot or a tag of the other stack's volume
+//   - CANNOT send to the other stack's queue
+func ValidateCrossStackIsolation(t *testing.T, instanceID string, other CrossStackTarget) {
+	region := GetAWSRegion()
+
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	s3Client := s3.NewFromConfig(cfg)
+
+	ownVolumeID, err := instanceRootVolume(ctx, ec2.NewFromConfig(cfg), instanceID)
+	require.NoError(t, err)
+
+	snapshotCmd := func(volumeID string) string {
+		return fmt.Sprintf("aws ec2 create-snapshot --dry-run --volume-id %s --region %s 2>&1", volumeID, region)
+	}
+	tagCmd := func(volumeID string) string {
+		return fmt.Sprintf("aws ec2 create-tags --dry-run --resources %s --tags Key=terratest-cross-stack,Value=%s --region %s 2>&1",
+			volumeID, instanceID, region)
+	}
+
+	// === Controls: the runner can snapshot and tag its own volume ===
+	stdout, _, _ := RunSSMCommand(t, instanceID, []string{snapshotCmd(ownVolumeID)})
+	require.True(t, isDryRunAllowed(stdout), "Should be able to snapshot own volume %s, got: %s", ownVolumeID, stdout)
+	stdout, _, _ = RunSSMCommand(t, instanceID, []string{tagCmd(ownVolumeID)})
+	require.True(t, isDryRunAllowed(stdout), "Should be able to tag own volume %s, got: %s", ownVolumeID, stdout)
+	t.Logf("✓ CAN snapshot and tag own volume %s", ownVolumeID)
+
+	// === Test 1: CANNOT read the other stack's config bucket ===
+	// The object exists, so a denial is not a missing key
+	agentsKey := fmt.Sprintf("agents/cross-stack-test-%d", time.Now().UnixNano())
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(other.ConfigBucket),
+		Key:    aws.String(agentsKey),
+		Body:   strings.NewReader("other-stack-content"),
+	})
+	require.NoError(t, err, "Admin failed to upload to the other stack's agents path")
+
+	stdout, _, _ = RunSSMCommand(t, instanceID, []string{fmt.Sprintf("aws s3 cp s3://%s/%s - --region %s 2>&1", other.ConfigBucket, agentsKey, region)})
+	assert.True(t, isAccessDenied(stdout), "Should NOT be able to read %s/%s, got: %s", other.ConfigBucket, agentsKey, stdout)
+	assert.NotContains(t, stdout, "other-stack-content")
+	t.Logf("✓ CANNOT read agents/* of %s", other.ConfigBucket)
+
+	_, _ = s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(other.ConfigBucket), Key: aws.String(agentsKey)})
+
+	// === Test 2: CANNOT snapshot the other stack's volume ===
+	stdout, _, _ = RunSSMCommand(t, instanceID, []string{snapshotCmd(other.VolumeID)})
+	assert.True(t, isAccessDenied(stdout), "Should NOT be able to snapshot %s of %s, got: %s", other.VolumeID, other.StackName, stdout)
+	t.Logf("✓ CANNOT snapshot volume %s of %s", other.VolumeID, other.StackName)
+
+	// === Test 3: CANNOT tag the other stack's volume ===
+	stdout, _, _ = RunSSMCommand(t, instanceID, []string{tagCmd(other.VolumeID)})
+	assert.True(t, isAccessDenied(stdout), "Should NOT be able to tag %s of %s, got: %s", other.VolumeID, other.StackName, stdout)
+	t.Logf("✓ CANNOT tag volume %s of %s", other.VolumeID, other.StackName)
+
+	// === Test 4: CANNOT send to the other stack's queue ===
+	sendCmd := fmt.Sprintf("aws sqs send-message --queue-url %s --message-body cross-stack-test --message-group-id terratest "+
+		"--message-deduplication-id %s --region %s 2>&1", other.QueueURL, instanceID, region)
+	stdout, _, _ = RunSSMCommand(t, instanceID, []string{sendCmd})
+	assert.True(t, isAccessDenied(stdout), "Should NOT be able to send to %s, got: %s", other.QueueURL, stdout)
+	t.Logf("✓ CANNOT send to %s", other.QueueURL)
+}
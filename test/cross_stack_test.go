@@ -0,0 +1,96 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOtherStackVars is a second stack deployed next to testIAMBaselineVars.
+var testOtherStackVars = IAMBaselineVars{
+	Region:       "us-east-1",
+	AccountID:    "123456789012",
+	StackName:    "tt-d4e5f6",
+	ConfigBucket: "tt-d4e5f6-config-20250206",
+	CacheBucket:  "tt-d4e5f6-cache-20250206",
+}
+
+func TestCrossStackPermissionMatrix(t *testing.T) {
+	cases := CrossStackPermissionMatrix(testIAMBaselineVars, testOtherStackVars)
+
+	testCases := []struct {
+		name     string
+		mutate   func(policies map[string]PolicyDocument)
+		contains []string
+	}{
+		{
+			name:   "Module",
+			mutate: func(policies map[string]PolicyDocument) {},
+		},
+		{
+			name: "TagsNotScopedToStack",
+			mutate: func(policies map[string]PolicyDocument) {
+				policies["CreateTagsOnVolumesAndSnapshots"].Statement[0].Condition = nil
+			},
+			contains: []string{
+				"TagOtherStackVolume: ec2:CreateTags on arn:aws:ec2:us-east-1:123456789012:volume/vol-0123456789abcdef0 is allowed by CreateTagsOnVolumesAndSnapshots[0], expected denied",
+				"TagOtherStackSnapshot: ec2:CreateTags on arn:aws:ec2:us-east-1::snapshot/snap-0123456789abcdef0 is allowed by CreateTagsOnVolumesAndSnapshots[0], expected denied",
+			},
+		},
+		{
+			name: "SnapshotSourceNotScopedToStack",
+			mutate: func(policies map[string]PolicyDocument) {
+				policies["VolumeSnapshotCreate"].Statement[2].Condition = nil
+			},
+			contains: []string{
+				"SnapshotOtherStackVolume: ec2:CreateSnapshot on arn:aws:ec2:us-east-1:123456789012:volume/vol-0123456789abcdef0 is allowed by VolumeSnapshotCreate[2], expected denied",
+				"SnapshotUntaggedVolume: ec2:CreateSnapshot on arn:aws:ec2:us-east-1:123456789012:volume/vol-0123456789abcdef0 is allowed by VolumeSnapshotCreate[2], expected denied",
+				"RestoreOtherStackSnapshot: ec2:CreateVolume on arn:aws:ec2:us-east-1::snapshot/snap-0123456789abcdef0 is allowed by VolumeSnapshotCreate[2], expected denied",
+			},
+		},
+		{
+			name: "ConfigBucketWildcard",
+			mutate: func(policies map[string]PolicyDocument) {
+				policies["EC2AccessS3BucketPolicy"].Statement[2].Resource = StringOrSlice{"arn:aws:s3:::*/agents/*"}
+			},
+			contains: []string{
+				"GetOtherStackAgent: s3:GetObject on arn:aws:s3:::tt-d4e5f6-config-20250206/agents/runs-on-agent is allowed by EC2AccessS3BucketPolicy[2], expected denied",
+			},
+		},
+		{
+			name: "QueueAccess",
+			mutate: func(policies map[string]PolicyDocument) {
+				policies["Queues"] = PolicyDocument{Statement: []PolicyStatement{
+					{Effect: "Allow", Action: StringOrSlice{"sqs:SendMessage"}, Resource: StringOrSlice{"arn:aws:sqs:us-east-1:123456789012:tt-*"}},
+				}}
+			},
+			contains: []string{
+				"SendToOtherStackQueue: sqs:SendMessage on arn:aws:sqs:us-east-1:123456789012:tt-d4e5f6-main.fifo is allowed by Queues[0], expected denied",
+				"SendToOwnQueue: sqs:SendMessage on arn:aws:sqs:us-east-1:123456789012:tt-a1b2c3-main.fifo is allowed by Queues[0], expected denied",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policies := runnerRolePolicies(t)
+			tc.mutate(policies)
+			violations := permissionMatrixViolations(policies, cases)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			for i, want := range tc.contains {
+				assert.Equal(t, want, violations[i])
+			}
+		})
+	}
+}
+
+func TestEC2DryRunOutput(t *testing.T) {
+	allowed := "An error occurred (DryRunOperation) when calling the CreateSnapshot operation: Request would have succeeded, but DryRun flag is set."
+	denied := "An error occurred (UnauthorizedOperation) when calling the CreateSnapshot operation: You are not authorized to perform this operation."
+
+	assert.True(t, isDryRunAllowed(allowed))
+	assert.False(t, isAccessDenied(allowed))
+	assert.False(t, isDryRunAllowed(denied))
+	assert.True(t, isAccessDenied(denied))
+}
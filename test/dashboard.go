@@ -0,0 +1,263 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwltypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/sjysngh/runs-on-tf/test/logsinsights"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// =============================================================================
+// DASHBOARD QUERIES
+// =============================================================================
+
+// CoreCloudWatchConfig is the file defining the dashboard, relative to the test directory.
+const CoreCloudWatchConfig = "../modules/core/cloudwatch.tf"
+
+// AppRunnerLogCorpus is a recorded App Runner application log stream covering every event
+// the dashboard plots: metric snapshots, job events, scheduled runners, errors and spot interruptions.
+const AppRunnerLogCorpus = "testdata/apprunner/application.jsonl"
+
+// DashboardVars mirrors the references in the dashboard_body of modules/core/cloudwatch.tf.
+type DashboardVars struct {
+	Region            string
+	AppRunnerLogGroup string
+	MainQueueName     string
+}
+
+// RenderDashboardBody evaluates the dashboard_body of the aws_cloudwatch_dashboard resource in
+// configPath with the HCL engine, yielding the JSON the provider would send to PutDashboard.
+func RenderDashboardBody(configPath string, vars DashboardVars) (string, error) {
+	rendered, err := renderResourceAttributes(configPath, "aws_cloudwatch_dashboard", []string{"dashboard_body"}, &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{"region": cty.StringVal(vars.Region)}),
+			"local": cty.ObjectVal(map[string]cty.Value{
+				"apprunner_log_group_name": cty.StringVal(vars.AppRunnerLogGroup),
+			}),
+			"aws_sqs_queue": cty.ObjectVal(map[string]cty.Value{
+				"main": cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal(vars.MainQueueName)}),
+			}),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, attributes := range rendered {
+		return attributes["dashboard_body"], nil
+	}
+	return "", fmt.Errorf("%s: no aws_cloudwatch_dashboard resource", configPath)
+}
+
+// DashboardQuery is the Logs Insights query of a log widget.
+type DashboardQuery struct {
+	Title string
+	Query logsinsights.Query
+}
+
+// ParseDashboardQueries extracts and parses the query of every log widget in a dashboard body.
+func ParseDashboardQueries(body string) ([]DashboardQuery, error) {
+	var dashboard struct {
+		Widgets []struct {
+			Type       string `json:"type"`
+			Properties struct {
+				Title string `json:"title"`
+				Query string `json:"query"`
+			} `json:"properties"`
+		} `json:"widgets"`
+	}
+	if err := json.Unmarshal([]byte(body), &dashboard); err != nil {
+		return nil, fmt.Errorf("failed to parse dashboard body: %w", err)
+	}
+
+	var queries []DashboardQuery
+	for i, widget := range dashboard.Widgets {
+		if widget.Type != "log" {
+			continue
+		}
+		title := widget.Properties.Title
+		if title == "" {
+			title = fmt.Sprintf("widget %d", i)
+		}
+		query, err := logsinsights.Parse(widget.Properties.Query)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", title, err)
+		}
+		queries = append(queries, DashboardQuery{Title: title, Query: query})
+	}
+	return queries, nil
+}
+
+// LoadLogEvents reads recorded log events, one FilterLogEvents event per line as written by
+// `aws logs filter-log-events --log-group-name <group> | jq -c '.events[]'`.
+func LoadLogEvents(path string) ([]logsinsights.Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []logsinsights.Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var event struct {
+			Timestamp     int64  `json:"timestamp"`
+			Message       string `json:"message"`
+			LogStreamName string `json:"logStreamName"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		events = append(events, logsinsights.Event{
+			Timestamp: time.UnixMilli(event.Timestamp).UTC(),
+			Message:   event.Message,
+			LogStream: event.LogStreamName,
+		})
+	}
+	return events, scanner.Err()
+}
+
+// dashboardQueryViolations checks that every query reads only logGroup and, run against the
+// recorded events, returns rows with a value in every column. Returns one message per violation.
+func dashboardQueryViolations(queries []DashboardQuery, logGroup string, events []logsinsights.Event) []string {
+	var violations []string
+	for _, q := range queries {
+		if len(q.Query.Sources) != 1 || q.Query.Sources[0] != logGroup {
+			violations = append(violations, fmt.Sprintf("%q reads %v, expected [%s]", q.Title, q.Query.Sources, logGroup))
+		}
+		result, err := q.Query.Run(events)
+		switch {
+		case err != nil:
+			violations = append(violations, fmt.Sprintf("%q failed: %v", q.Title, err))
+		case len(result.Rows) == 0:
+			violations = append(violations, fmt.Sprintf("%q returns no rows", q.Title))
+		default:
+			if blank := result.BlankColumns(); len(blank) > 0 {
+				violations = append(violations, fmt.Sprintf("%q always returns an empty %s", q.Title, strings.Join(blank, ", ")))
+			}
+		}
+	}
+	return violations
+}
+
+// ValidateDashboard checks that the dashboard exists, that every log widget queries logGroup and
+// that the log group exists, and that every query returns data from the recorded events.
+// A query that comes back empty here would leave its widget blank on a live stack.
+func ValidateDashboard(t *testing.T, dashboardName, logGroup string, events []logsinsights.Event) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+
+	dashboard, err := cloudwatch.NewFromConfig(cfg).GetDashboard(ctx, &cloudwatch.GetDashboardInput{
+		DashboardName: aws.String(dashboardName),
+	})
+	require.NoError(t, err, "Failed to get dashboard %s", dashboardName)
+
+	queries, err := ParseDashboardQueries(aws.ToString(dashboard.DashboardBody))
+	require.NoError(t, err, "Dashboard %s has an invalid query", dashboardName)
+	require.NotEmpty(t, queries, "Dashboard %s has no log widgets", dashboardName)
+
+	groups, err := cloudwatchlogs.NewFromConfig(cfg).DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String(logGroup),
+	})
+	require.NoError(t, err, "Failed to describe log groups with prefix %s", logGroup)
+	found := false
+	for _, group := range groups.LogGroups {
+		found = found || aws.ToString(group.LogGroupName) == logGroup
+	}
+	assert.True(t, found, "Dashboard log group %s should exist", logGroup)
+
+	violations := dashboardQueryViolations(queries, logGroup, events)
+	for _, violation := range violations {
+		assert.Fail(t, "Dashboard query violation", "%s: %s", dashboardName, violation)
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ Dashboard %s: %d log widgets query %s and return data", dashboardName, len(queries), logGroup)
+	}
+}
+
+// ValidateDashboardQueriesLive runs every log widget query with StartQuery over the logs since
+// the given time. Widgets listed in required must return rows before the timeout; the others
+// are only reported, since a fresh stack may legitimately have nothing to show for them.
+func ValidateDashboardQueriesLive(t *testing.T, dashboardName string, since time.Time, required []string, timeout time.Duration) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+
+	dashboard, err := cloudwatch.NewFromConfig(cfg).GetDashboard(ctx, &cloudwatch.GetDashboardInput{
+		DashboardName: aws.String(dashboardName),
+	})
+	require.NoError(t, err, "Failed to get dashboard %s", dashboardName)
+	queries, err := ParseDashboardQueries(aws.ToString(dashboard.DashboardBody))
+	require.NoError(t, err, "Dashboard %s has an invalid query", dashboardName)
+
+	client := cloudwatchlogs.NewFromConfig(cfg)
+	for _, title := range required {
+		found := false
+		for _, q := range queries {
+			found = found || q.Title == title
+		}
+		assert.True(t, found, "Dashboard %s has no widget %q", dashboardName, title)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for _, q := range queries {
+		isRequired := false
+		for _, title := range required {
+			isRequired = isRequired || q.Title == title
+		}
+		for {
+			rows, err := runInsightsQuery(ctx, client, q.Query, since, time.Now())
+			require.NoError(t, err, "Query for %q failed", q.Title)
+			if rows > 0 || !isRequired || time.Now().After(deadline) {
+				if isRequired {
+					assert.Positive(t, rows, "Widget %q should show data", q.Title)
+				}
+				t.Logf("  %s: %d rows", q.Title, rows)
+				break
+			}
+			time.Sleep(15 * time.Second)
+		}
+	}
+}
+
+// runInsightsQuery runs a query with StartQuery and returns the number of result rows.
+func runInsightsQuery(ctx context.Context, client *cloudwatchlogs.Client, query logsinsights.Query, start, end time.Time) (int, error) {
+	started, err := client.StartQuery(ctx, &cloudwatchlogs.StartQueryInput{
+		LogGroupNames: query.Sources,
+		QueryString:   aws.String(query.Body),
+		StartTime:     aws.Int64(start.Unix()),
+		EndTime:       aws.Int64(end.Unix()),
+	})
+	if err != nil {
+		return 0, err
+	}
+	for {
+		results, err := client.GetQueryResults(ctx, &cloudwatchlogs.GetQueryResultsInput{QueryId: started.QueryId})
+		if err != nil {
+			return 0, err
+		}
+		switch results.Status {
+		case cwltypes.QueryStatusComplete:
+			return len(results.Results), nil
+		case cwltypes.QueryStatusFailed, cwltypes.QueryStatusCancelled, cwltypes.QueryStatusTimeout:
+			return 0, fmt.Errorf("query %s", results.Status)
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sjysngh/runs-on-tf/test/logsinsights"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAppRunnerLogGroup = "/aws/apprunner/stack-app/0123456789abcdef/application"
+
+// renderDashboardQueries parses the log widget queries of the dashboard in modules/core/cloudwatch.tf
+func renderDashboardQueries(t *testing.T) []DashboardQuery {
+	body, err := RenderDashboardBody(CoreCloudWatchConfig, DashboardVars{
+		Region:            "us-east-1",
+		AppRunnerLogGroup: testAppRunnerLogGroup,
+		MainQueueName:     testMainQueueName,
+	})
+	require.NoError(t, err)
+	queries, err := ParseDashboardQueries(body)
+	require.NoError(t, err)
+	return queries
+}
+
+func TestDashboardQueries(t *testing.T) {
+	queries := renderDashboardQueries(t)
+	require.Len(t, queries, 15)
+
+	events, err := LoadLogEvents(AppRunnerLogCorpus)
+	require.NoError(t, err)
+	assert.Empty(t, dashboardQueryViolations(queries, testAppRunnerLogGroup, events))
+}
+
+func TestDashboardQueryViolations(t *testing.T) {
+	queries := renderDashboardQueries(t)
+	corpus, err := LoadLogEvents(AppRunnerLogCorpus)
+	require.NoError(t, err)
+
+	// rewrite returns the corpus with a string replaced in every message, as if the app's log format changed
+	rewrite := func(old, new string) []logsinsights.Event {
+		events := make([]logsinsights.Event, len(corpus))
+		for i, event := range corpus {
+			event.Message = strings.ReplaceAll(event.Message, old, new)
+			events[i] = event
+		}
+		return events
+	}
+
+	testCases := []struct {
+		name     string
+		logGroup string
+		events   []logsinsights.Event
+		contains []string
+	}{
+		{"Matching", testAppRunnerLogGroup, corpus, nil},
+		{"WrongLogGroup", "/aws/apprunner/other/application", corpus, func() []string {
+			var all []string
+			for range queries {
+				all = append(all, "expected [/aws/apprunner/other/application]")
+			}
+			return all
+		}()},
+		{"ScheduledMessageChanged", testAppRunnerLogGroup, rewrite("🎉 Runner scheduled successfully", "Runner scheduled"), []string{
+			`"Total Runners Scheduled (Current Period)" returns no rows`,
+			`"Runners Scheduled over time (5min intervals)" returns no rows`,
+		}},
+		{"FieldRenamed", testAppRunnerLogGroup, rewrite(`"ec2_read"`, `"ec2_describe"`), []string{
+			"always returns an empty avg_tokens, avg_burst",
+		}},
+		{"NoSpotInterruptions", testAppRunnerLogGroup, rewrite(`"spot_interruption"`, `"other"`), []string{
+			`"Recent Spot Interruptions (Last 50)" returns no rows`,
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := dashboardQueryViolations(queries, tc.logGroup, tc.events)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			joined := strings.Join(violations, "\n")
+			for _, want := range tc.contains {
+				assert.Contains(t, joined, want)
+			}
+		})
+	}
+}
+
+func TestParseDashboardQueriesRejectsInvalidQuery(t *testing.T) {
+	_, err := ParseDashboardQueries(`{"widgets":[{"type":"log","properties":{"title":"Queue","query":"SOURCE 'g'\n| | stats count()"}}]}`)
+	assert.ErrorContains(t, err, `"Queue": command 2 is empty`)
+}
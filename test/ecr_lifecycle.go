@@ -0,0 +1,275 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// ECR REPOSITORY VALIDATORS
+// =============================================================================
+
+// ECRLifecyclePolicy is an ECR lifecycle policy document.
+type ECRLifecyclePolicy struct {
+	Rules []ECRLifecycleRule `json:"rules"`
+}
+
+// ECRLifecycleRule is one rule of an ECR lifecycle policy.
+type ECRLifecycleRule struct {
+	RulePriority int    `json:"rulePriority"`
+	Description  string `json:"description"`
+	Selection    struct {
+		TagStatus      string   `json:"tagStatus"`
+		TagPrefixList  []string `json:"tagPrefixList"`
+		TagPatternList []string `json:"tagPatternList"`
+		CountType      string   `json:"countType"`
+		CountUnit      string   `json:"countUnit"`
+		CountNumber    int      `json:"countNumber"`
+	} `json:"selection"`
+	Action struct {
+		Type string `json:"type"`
+	} `json:"action"`
+}
+
+// ParseECRLifecyclePolicy parses the lifecycle policy text returned by GetLifecyclePolicy.
+func ParseECRLifecyclePolicy(text string) (ECRLifecyclePolicy, error) {
+	var policy ECRLifecyclePolicy
+	if err := json.Unmarshal([]byte(text), &policy); err != nil {
+		return policy, fmt.Errorf("parsing ECR lifecycle policy: %w", err)
+	}
+	return policy, nil
+}
+
+// ECRImage is a simulated image in a repository.
+type ECRImage struct {
+	Digest   string
+	Tags     []string
+	PushedAt time.Time
+}
+
+func (i ECRImage) String() string {
+	if len(i.Tags) == 0 {
+		return "untagged " + i.Digest
+	}
+	return strings.Join(i.Tags, ",")
+}
+
+// EvaluateECRLifecycle returns the images the policy expires at now, as digest to rule priority.
+// Rules run in priority order and an image selected by one rule is never considered by a
+// lower priority rule, whether that rule expired it or kept it.
+func EvaluateECRLifecycle(policy ECRLifecyclePolicy, images []ECRImage, now time.Time) map[string]int {
+	rules := append([]ECRLifecycleRule{}, policy.Rules...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].RulePriority < rules[j].RulePriority })
+
+	expired := map[string]int{}
+	selected := map[string]bool{}
+	for _, rule := range rules {
+		var candidates []ECRImage
+		for _, image := range images {
+			if !selected[image.Digest] && ecrRuleSelects(rule, image) {
+				selected[image.Digest] = true
+				candidates = append(candidates, image)
+			}
+		}
+		if rule.Action.Type != "expire" {
+			continue
+		}
+		switch rule.Selection.CountType {
+		case "imageCountMoreThan":
+			// Keep the newest countNumber images
+			sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].PushedAt.After(candidates[j].PushedAt) })
+			for i := rule.Selection.CountNumber; i < len(candidates); i++ {
+				expired[candidates[i].Digest] = rule.RulePriority
+			}
+		case "sinceImagePushed":
+			maxAge := time.Duration(rule.Selection.CountNumber) * 24 * time.Hour
+			for _, image := range candidates {
+				if now.Sub(image.PushedAt) > maxAge {
+					expired[image.Digest] = rule.RulePriority
+				}
+			}
+		}
+	}
+	return expired
+}
+
+// ecrRuleSelects reports whether the rule's tag selection matches the image.
+// With several prefixes or patterns, each one must match at least one tag.
+func ecrRuleSelects(rule ECRLifecycleRule, image ECRImage) bool {
+	switch rule.Selection.TagStatus {
+	case "any":
+		return true
+	case "untagged":
+		return len(image.Tags) == 0
+	case "tagged":
+		if len(image.Tags) == 0 {
+			return false
+		}
+		for _, prefix := range rule.Selection.TagPrefixList {
+			if !anyTag(image.Tags, func(tag string) bool { return strings.HasPrefix(tag, prefix) }) {
+				return false
+			}
+		}
+		for _, pattern := range rule.Selection.TagPatternList {
+			re := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+			if !anyTag(image.Tags, re.MatchString) {
+				return false
+			}
+		}
+		return len(rule.Selection.TagPrefixList)+len(rule.Selection.TagPatternList) > 0
+	}
+	return false
+}
+
+// anyTag reports whether any tag satisfies match.
+func anyTag(tags []string, match func(string) bool) bool {
+	for _, tag := range tags {
+		if match(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ECRLifecycleCase is an image and whether the repository's lifecycle policy should expire it.
+// A Cache image is a buildx cache tag, which some rule must expire sooner or later whatever its
+// age at now; Expired is not checked for it.
+type ECRLifecycleCase struct {
+	Image   ECRImage
+	Expired bool
+	Cache   bool
+}
+
+// ecrExpiringRule returns the priority of the rule that decides the image's fate, false if no
+// rule selects it or that rule doesn't expire images, i.e. no rule ever expires it.
+func ecrExpiringRule(policy ECRLifecyclePolicy, image ECRImage) (int, bool) {
+	rules := append([]ECRLifecycleRule{}, policy.Rules...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].RulePriority < rules[j].RulePriority })
+	for _, rule := range rules {
+		if ecrRuleSelects(rule, image) {
+			return rule.RulePriority, rule.Action.Type == "expire"
+		}
+	}
+	return 0, false
+}
+
+// ecrLifecycleCaseMismatches evaluates the cases as one repository and returns one message per
+// wrong outcome and per cache image no rule ever expires.
+func ecrLifecycleCaseMismatches(policy ECRLifecyclePolicy, cases []ECRLifecycleCase, now time.Time) []string {
+	images := make([]ECRImage, len(cases))
+	for i, c := range cases {
+		images[i] = c.Image
+	}
+	expired := EvaluateECRLifecycle(policy, images, now)
+
+	var mismatches []string
+	for _, c := range cases {
+		priority, ok := expired[c.Image.Digest]
+		age := now.Sub(c.Image.PushedAt).Round(time.Hour)
+		if c.Cache {
+			if _, expires := ecrExpiringRule(policy, c.Image); !expires {
+				mismatches = append(mismatches, fmt.Sprintf("buildx cache %s pushed %s ago is never expired by any rule", c.Image, age))
+			}
+			continue
+		}
+		switch {
+		case ok && !c.Expired:
+			mismatches = append(mismatches, fmt.Sprintf("%s pushed %s ago should be kept but rule %d expires it", c.Image, age, priority))
+		case !ok && c.Expired:
+			mismatches = append(mismatches, fmt.Sprintf("%s pushed %s ago should be expired but no rule removes it", c.Image, age))
+		}
+	}
+	return mismatches
+}
+
+// EphemeralRegistryLifecycleCases are the expected outcomes for the ephemeral registry at now:
+// only the 10 newest v-prefixed tags are kept and untagged images expire after 1 day. Buildx
+// cache tags such as cache-test-<nanos> must be expired by some rule, or they pile up; the
+// manifests they replace become untagged and expire.
+func EphemeralRegistryLifecycleCases(now time.Time) []ECRLifecycleCase {
+	days := func(d float64) time.Time { return now.Add(-time.Duration(d * float64(24*time.Hour))) }
+	digest := func(name string) string { return "sha256:" + name }
+
+	var cases []ECRLifecycleCase
+	// v1 is the oldest release tag; the two oldest of twelve are expired
+	for i := 1; i <= 12; i++ {
+		cases = append(cases, ECRLifecycleCase{
+			Image:   ECRImage{digest(fmt.Sprintf("release-v%d", i)), []string{fmt.Sprintf("v1.%d.0", i)}, days(float64(13 - i))},
+			Expired: i <= 2,
+		})
+	}
+	return append(cases,
+		ECRLifecycleCase{Image: ECRImage{digest("cache-current"), []string{fmt.Sprintf("cache-test-%d", days(30).UnixNano())}, days(30)}, Cache: true},
+		ECRLifecycleCase{Image: ECRImage{digest("buildcache"), []string{"buildcache"}, days(90)}, Cache: true},
+		ECRLifecycleCase{Image: ECRImage{digest("cache-superseded-old"), nil, days(2)}, Expired: true},
+		ECRLifecycleCase{Image: ECRImage{digest("cache-superseded-new"), nil, days(0.5)}, Expired: false},
+	)
+}
+
+// ECRRepositoryExpectation describes how modules/optional/ecr.tf configures the ephemeral registry.
+type ECRRepositoryExpectation struct {
+	ImageTagMutability string
+	ScanOnPush         bool
+	EncryptionType     string
+	LifecycleCases     []ECRLifecycleCase
+}
+
+// EphemeralRegistryExpectation is the expected ephemeral registry configuration with lifecycle
+// cases evaluated at now.
+func EphemeralRegistryExpectation(now time.Time) ECRRepositoryExpectation {
+	return ECRRepositoryExpectation{
+		ImageTagMutability: "MUTABLE",
+		ScanOnPush:         false,
+		EncryptionType:     "AES256",
+		LifecycleCases:     EphemeralRegistryLifecycleCases(now),
+	}
+}
+
+// ValidateECRRepository checks the repository's tag mutability, scan-on-push and encryption,
+// then evaluates its lifecycle policy against the expectation's simulated images at now, which
+// must be the time the lifecycle cases were built for.
+func ValidateECRRepository(t *testing.T, repositoryName string, expected ECRRepositoryExpectation, now time.Time) {
+	ctx := context.Background()
+	client := ecr.NewFromConfig(MustGetAWSConfig(ctx))
+
+	repositories, err := client.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{
+		RepositoryNames: []string{repositoryName},
+	})
+	require.NoError(t, err, "Failed to describe ECR repository %s", repositoryName)
+	require.Len(t, repositories.Repositories, 1, "ECR repository %s not found", repositoryName)
+	repository := repositories.Repositories[0]
+
+	assert.Equal(t, expected.ImageTagMutability, string(repository.ImageTagMutability),
+		"Repository %s tag mutability", repositoryName)
+	require.NotNil(t, repository.ImageScanningConfiguration, "Repository %s has no scanning configuration", repositoryName)
+	assert.Equal(t, expected.ScanOnPush, repository.ImageScanningConfiguration.ScanOnPush,
+		"Repository %s scan on push", repositoryName)
+	require.NotNil(t, repository.EncryptionConfiguration, "Repository %s has no encryption configuration", repositoryName)
+	assert.Equal(t, expected.EncryptionType, string(repository.EncryptionConfiguration.EncryptionType),
+		"Repository %s encryption type", repositoryName)
+
+	lifecycle, err := client.GetLifecyclePolicy(ctx, &ecr.GetLifecyclePolicyInput{RepositoryName: aws.String(repositoryName)})
+	require.NoError(t, err, "Failed to get lifecycle policy of %s", repositoryName)
+	policy, err := ParseECRLifecyclePolicy(aws.ToString(lifecycle.LifecyclePolicyText))
+	require.NoError(t, err)
+
+	mismatches := ecrLifecycleCaseMismatches(policy, expected.LifecycleCases, now)
+	for _, mismatch := range mismatches {
+		assert.Fail(t, fmt.Sprintf("Repository %s: %s", repositoryName, mismatch))
+	}
+	if len(mismatches) == 0 {
+		t.Logf("✓ Repository %s: %s tags, scan on push %t, %s, lifecycle matches %d simulated images",
+			repositoryName, expected.ImageTagMutability, expected.ScanOnPush, expected.EncryptionType, len(expected.LifecycleCases))
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ephemeralRegistryPolicy is the policy modules/optional/ecr.tf installs, as GetLifecyclePolicy returns it
+const ephemeralRegistryPolicy = `{"rules":[` +
+	`{"action":{"type":"expire"},"description":"Keep last 10 images","rulePriority":1,` +
+	`"selection":{"countNumber":10,"countType":"imageCountMoreThan","tagPrefixList":["v"],"tagStatus":"tagged"}},` +
+	`{"action":{"type":"expire"},"description":"Remove untagged images after 1 day","rulePriority":2,` +
+	`"selection":{"countNumber":1,"countType":"sinceImagePushed","countUnit":"days","tagStatus":"untagged"}}]}`
+
+func TestEphemeralRegistryLifecycleCases(t *testing.T) {
+	policy, err := ParseECRLifecyclePolicy(ephemeralRegistryPolicy)
+	require.NoError(t, err)
+	require.Len(t, policy.Rules, 2)
+
+	now := time.Date(2025, 2, 6, 12, 0, 0, 0, time.UTC)
+	// The installed policy expires no buildx cache tag, so both cache images are findings
+	mismatches := ecrLifecycleCaseMismatches(policy, EphemeralRegistryLifecycleCases(now), now)
+	require.Len(t, mismatches, 2, "mismatches: %v", mismatches)
+	assert.Contains(t, mismatches[0], "buildx cache cache-test-")
+	assert.Contains(t, mismatches[1], "buildx cache buildcache")
+	for _, mismatch := range mismatches {
+		assert.Contains(t, mismatch, "is never expired by any rule")
+	}
+
+	t.Run("CacheRuleFixesLeak", func(t *testing.T) {
+		withCacheRule, err := ParseECRLifecyclePolicy(strings.Replace(ephemeralRegistryPolicy, `]}`,
+			`,{"action":{"type":"expire"},"rulePriority":3,`+
+				`"selection":{"countNumber":7,"countType":"sinceImagePushed","countUnit":"days","tagPatternList":["cache-*"],"tagStatus":"tagged"}},`+
+				`{"action":{"type":"expire"},"rulePriority":4,`+
+				`"selection":{"countNumber":7,"countType":"sinceImagePushed","countUnit":"days","tagPatternList":["buildcache"],"tagStatus":"tagged"}}]}`, 1))
+		require.NoError(t, err)
+		assert.Empty(t, ecrLifecycleCaseMismatches(withCacheRule, EphemeralRegistryLifecycleCases(now), now))
+	})
+}
+
+func TestEvaluateECRLifecycle(t *testing.T) {
+	now := time.Date(2025, 2, 6, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) time.Time { return now.AddDate(0, 0, -d) }
+	rule := func(priority int, tagStatus string, prefixes, patterns []string, countType string, count int) ECRLifecycleRule {
+		var r ECRLifecycleRule
+		r.RulePriority = priority
+		r.Selection.TagStatus = tagStatus
+		r.Selection.TagPrefixList = prefixes
+		r.Selection.TagPatternList = patterns
+		r.Selection.CountType = countType
+		r.Selection.CountNumber = count
+		r.Action.Type = "expire"
+		return r
+	}
+	images := []ECRImage{
+		{"sha256:prod", []string{"prod-1", "release"}, daysAgo(30)},
+		{"sha256:prod-only", []string{"prod-2"}, daysAgo(30)},
+		{"sha256:cache", []string{"cache-main"}, daysAgo(10)},
+		{"sha256:untagged", nil, daysAgo(10)},
+	}
+
+	testCases := []struct {
+		name    string
+		rules   []ECRLifecycleRule
+		expired map[string]int
+	}{
+		{
+			"AllPrefixesMustMatch",
+			[]ECRLifecycleRule{rule(1, "tagged", []string{"prod", "rel"}, nil, "sinceImagePushed", 7)},
+			map[string]int{"sha256:prod": 1},
+		},
+		{
+			"PatternWildcard",
+			[]ECRLifecycleRule{rule(1, "tagged", nil, []string{"cache-*"}, "sinceImagePushed", 7)},
+			map[string]int{"sha256:cache": 1},
+		},
+		{
+			"KeptImageNotExpiredByLowerPriority",
+			[]ECRLifecycleRule{
+				rule(1, "tagged", []string{"prod"}, nil, "imageCountMoreThan", 5),
+				rule(2, "any", nil, nil, "sinceImagePushed", 1),
+			},
+			map[string]int{"sha256:cache": 2, "sha256:untagged": 2},
+		},
+		{
+			"CountKeepsNewest",
+			[]ECRLifecycleRule{rule(1, "any", nil, nil, "imageCountMoreThan", 2)},
+			map[string]int{"sha256:prod": 1, "sha256:prod-only": 1},
+		},
+		{
+			"TaggedNeedsPrefixOrPattern",
+			[]ECRLifecycleRule{rule(1, "tagged", nil, nil, "sinceImagePushed", 1)},
+			map[string]int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expired := EvaluateECRLifecycle(ECRLifecyclePolicy{Rules: tc.rules}, images, now)
+			assert.Equal(t, tc.expired, expired)
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	efstypes "github.com/aws/aws-sdk-go-v2/service/efs/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// EFS CONFIGURATION VALIDATORS
+// =============================================================================
+
+// EFS file system variants in modules/optional/efs.tf, chosen by prevent_destroy_optional_resources
+const (
+	efsProtectedVariant   = "this_protected"
+	efsUnprotectedVariant = "this_unprotected"
+
+	// efsPlannedSecurityGroup stands in for the EFS security group ID before it is created
+	efsPlannedSecurityGroup = "(known after apply)"
+)
+
+// EFSExpectation describes how modules/optional/efs.tf should configure the file system.
+type EFSExpectation struct {
+	// SubnetIDs are the public_subnet_ids input; each needs exactly one mount target
+	SubnetIDs []string
+
+	// RunnerSecurityGroupIDs are the only sources allowed to reach NFS
+	RunnerSecurityGroupIDs []string
+
+	// Protected is prevent_destroy_optional_resources
+	Protected bool
+
+	// KMSKeyID is the expected key ARN; empty means the AWS managed aws/elasticfilesystem key
+	KMSKeyID string
+
+	// LifecyclePolicies are "TransitionToIA=AFTER_30_DAYS" style entries; the module sets none
+	LifecyclePolicies []string
+
+	// BackupEnabled is the expected automatic backup status; the module leaves backups off
+	BackupEnabled bool
+}
+
+// EFSExpectation builds the EFS expectation for the scenario config
+func (c ScenarioConfig) EFSExpectation(subnetIDs, runnerSecurityGroupIDs []string) EFSExpectation {
+	return EFSExpectation{
+		SubnetIDs:              subnetIDs,
+		RunnerSecurityGroupIDs: runnerSecurityGroupIDs,
+		Protected:              c.PreventDestroyOptionalResources,
+	}
+}
+
+// efsIngressRule is one ingress permission of the EFS security group.
+type efsIngressRule struct {
+	Protocol       string
+	FromPort       int32
+	ToPort         int32
+	SourceGroupIDs []string
+	OtherSources   []string
+}
+
+// efsSnapshot is the EFS configuration read from AWS or from plan JSON.
+type efsSnapshot struct {
+	Variant           string
+	Encrypted         bool
+	KMSKeyID          string
+	KMSAWSManaged     bool
+	LifecyclePolicies []string
+	BackupEnabled     bool
+
+	// MountTargets maps subnet ID to the security groups of its mount target
+	MountTargets map[string][]string
+
+	Ingress      []efsIngressRule
+	IngressKnown bool
+}
+
+// ValidateEFSConfiguration checks the deployed file system:
+//   - Encrypted with the expected KMS key, lifecycle policies and backup status
+//   - The protected or unprotected variant matching prevent_destroy_optional_resources
+//   - One mount target per subnet, all behind the EFS security group
+//   - The EFS security group only allows TCP 2049 from the runner security groups
+func ValidateEFSConfiguration(t *testing.T, moduleOptions *terraform.Options, expected EFSExpectation) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	efsClient := efs.NewFromConfig(cfg)
+	fileSystemID := terraform.Output(t, moduleOptions, "efs_file_system_id")
+	require.NotEmpty(t, fileSystemID, "efs_file_system_id output should not be empty")
+
+	snapshot := efsSnapshot{MountTargets: map[string][]string{}, IngressKnown: true}
+	snapshot.Variant = efsVariant(terraformStateResources(t, moduleOptions))
+
+	fileSystems, err := efsClient.DescribeFileSystems(ctx, &efs.DescribeFileSystemsInput{FileSystemId: aws.String(fileSystemID)})
+	require.NoError(t, err, "Failed to describe file system %s", fileSystemID)
+	require.Len(t, fileSystems.FileSystems, 1, "File system %s not found", fileSystemID)
+	fileSystem := fileSystems.FileSystems[0]
+	snapshot.Encrypted = aws.ToBool(fileSystem.Encrypted)
+	snapshot.KMSKeyID = aws.ToString(fileSystem.KmsKeyId)
+
+	if snapshot.KMSKeyID != "" {
+		key, err := kms.NewFromConfig(cfg).DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: fileSystem.KmsKeyId})
+		require.NoError(t, err, "Failed to describe KMS key %s", snapshot.KMSKeyID)
+		snapshot.KMSAWSManaged = key.KeyMetadata.KeyManager == kmstypes.KeyManagerTypeAws
+	}
+
+	lifecycle, err := efsClient.DescribeLifecycleConfiguration(ctx, &efs.DescribeLifecycleConfigurationInput{FileSystemId: aws.String(fileSystemID)})
+	require.NoError(t, err, "Failed to describe lifecycle configuration of %s", fileSystemID)
+	snapshot.LifecyclePolicies = efsLifecyclePolicies(lifecycle.LifecyclePolicies)
+
+	backup, err := efsClient.DescribeBackupPolicy(ctx, &efs.DescribeBackupPolicyInput{FileSystemId: aws.String(fileSystemID)})
+	var notFound *efstypes.PolicyNotFound
+	if !errors.As(err, &notFound) {
+		require.NoError(t, err, "Failed to describe backup policy of %s", fileSystemID)
+		snapshot.BackupEnabled = backup.BackupPolicy != nil && backup.BackupPolicy.Status == efstypes.StatusEnabled
+	}
+
+	mountTargets, err := efsClient.DescribeMountTargets(ctx, &efs.DescribeMountTargetsInput{FileSystemId: aws.String(fileSystemID)})
+	require.NoError(t, err, "Failed to describe mount targets of %s", fileSystemID)
+	efsGroups := map[string]bool{}
+	for _, mountTarget := range mountTargets.MountTargets {
+		groups, err := efsClient.DescribeMountTargetSecurityGroups(ctx, &efs.DescribeMountTargetSecurityGroupsInput{
+			MountTargetId: mountTarget.MountTargetId,
+		})
+		require.NoError(t, err, "Failed to describe security groups of mount target %s", aws.ToString(mountTarget.MountTargetId))
+		snapshot.MountTargets[aws.ToString(mountTarget.SubnetId)] = groups.SecurityGroups
+		for _, group := range groups.SecurityGroups {
+			efsGroups[group] = true
+		}
+	}
+
+	if len(efsGroups) > 0 {
+		var groupIDs []string
+		for group := range efsGroups {
+			groupIDs = append(groupIDs, group)
+		}
+		rules, err := describeSecurityGroupRules(ctx, ec2.NewFromConfig(cfg), groupIDs)
+		require.NoError(t, err, "Failed to describe EFS security group rules")
+		for _, rule := range rules {
+			if aws.ToBool(rule.IsEgress) {
+				continue
+			}
+			ingress := efsIngressRule{
+				Protocol: aws.ToString(rule.IpProtocol),
+				FromPort: aws.ToInt32(rule.FromPort),
+				ToPort:   aws.ToInt32(rule.ToPort),
+			}
+			if rule.ReferencedGroupInfo != nil {
+				ingress.SourceGroupIDs = []string{aws.ToString(rule.ReferencedGroupInfo.GroupId)}
+			} else {
+				ingress.OtherSources = []string{aws.ToString(rule.CidrIpv4) + aws.ToString(rule.CidrIpv6) + aws.ToString(rule.PrefixListId)}
+			}
+			snapshot.Ingress = append(snapshot.Ingress, ingress)
+		}
+	}
+
+	assertEFSConfiguration(t, fileSystemID, snapshot, expected)
+}
+
+// ValidatePlannedEFSConfiguration runs the same checks as ValidateEFSConfiguration against plan JSON.
+// An unset kms_key_id means the AWS managed key. Ingress sources are only checked once the
+// runner security group IDs are known.
+func ValidatePlannedEFSConfiguration(t *testing.T, plan *terraform.PlanStruct, expected EFSExpectation) {
+	snapshot, err := plannedEFSSnapshot(plan)
+	require.NoError(t, err)
+	assertEFSConfiguration(t, "planned file system", snapshot, expected)
+}
+
+// plannedEFSSnapshot extracts the EFS configuration from plan JSON.
+func plannedEFSSnapshot(plan *terraform.PlanStruct) (efsSnapshot, error) {
+	snapshot := efsSnapshot{MountTargets: map[string][]string{}}
+
+	fileSystems := plannedResources(plan, "aws_efs_file_system")
+	if len(fileSystems) != 1 {
+		return snapshot, fmt.Errorf("expected exactly 1 planned aws_efs_file_system, got %d", len(fileSystems))
+	}
+	fileSystem := fileSystems[0]
+	snapshot.Variant = efsVariant(fileSystems)
+	snapshot.Encrypted, _ = fileSystem.AttributeValues["encrypted"].(bool)
+	if configuredExpression(plan, fileSystem, "kms_key_id") != nil {
+		snapshot.KMSKeyID = attributeString(fileSystem, "kms_key_id")
+	} else {
+		snapshot.KMSAWSManaged = true
+	}
+	for _, policy := range attributeBlocks(fileSystem, "lifecycle_policy") {
+		for name, value := range policy {
+			if s, ok := value.(string); ok && s != "" {
+				snapshot.LifecyclePolicies = append(snapshot.LifecyclePolicies, efsPolicyName(name)+"="+s)
+			}
+		}
+	}
+	sort.Strings(snapshot.LifecyclePolicies)
+	for _, backup := range plannedResources(plan, "aws_efs_backup_policy") {
+		for _, policy := range attributeBlocks(backup, "backup_policy") {
+			snapshot.BackupEnabled = policy["status"] == string(efstypes.StatusEnabled)
+		}
+	}
+
+	for _, mountTarget := range plannedResources(plan, "aws_efs_mount_target") {
+		groupIDs := blockStrings(mountTarget.AttributeValues, "security_groups")
+		if _, known := mountTarget.AttributeValues["security_groups"]; !known {
+			// The EFS security group is created in the same apply
+			groupIDs = []string{efsPlannedSecurityGroup}
+		}
+		snapshot.MountTargets[attributeString(mountTarget, "subnet_id")] = groupIDs
+	}
+
+	for _, group := range plannedResources(plan, "aws_security_group") {
+		if !strings.HasSuffix(attributeString(group, "name"), "-efs-sg") {
+			continue
+		}
+		_, snapshot.IngressKnown = group.AttributeValues["ingress"]
+		for _, block := range attributeBlocks(group, "ingress") {
+			if _, known := block["security_groups"]; !known {
+				snapshot.IngressKnown = false
+			}
+			ingress := efsIngressRule{
+				SourceGroupIDs: blockStrings(block, "security_groups"),
+			}
+			ingress.Protocol, _ = block["protocol"].(string)
+			fromPort, _ := block["from_port"].(float64)
+			toPort, _ := block["to_port"].(float64)
+			ingress.FromPort, ingress.ToPort = int32(fromPort), int32(toPort)
+			for _, source := range []string{"cidr_blocks", "ipv6_cidr_blocks", "prefix_list_ids"} {
+				ingress.OtherSources = append(ingress.OtherSources, blockStrings(block, source)...)
+			}
+			if self, _ := block["self"].(bool); self {
+				ingress.OtherSources = append(ingress.OtherSources, "self")
+			}
+			snapshot.Ingress = append(snapshot.Ingress, ingress)
+		}
+	}
+	return snapshot, nil
+}
+
+// assertEFSConfiguration fails the test for each violation in the snapshot.
+func assertEFSConfiguration(t *testing.T, name string, snapshot efsSnapshot, expected EFSExpectation) {
+	violations := efsConfigurationViolations(snapshot, expected)
+	for _, violation := range violations {
+		assert.Fail(t, fmt.Sprintf("EFS %s: %s", name, violation))
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ EFS %s: %s variant, encrypted, %d mount targets, NFS only from %v",
+			name, snapshot.Variant, len(snapshot.MountTargets), expected.RunnerSecurityGroupIDs)
+	}
+}
+
+// efsConfigurationViolations compares an EFS snapshot with the expectation.
+// Returns one message per violation.
+func efsConfigurationViolations(snapshot efsSnapshot, expected EFSExpectation) []string {
+	var violations []string
+
+	wantVariant := efsUnprotectedVariant
+	if expected.Protected {
+		wantVariant = efsProtectedVariant
+	}
+	if snapshot.Variant != wantVariant {
+		violations = append(violations, fmt.Sprintf("uses the %q variant but prevent_destroy_optional_resources=%t selects %q",
+			snapshot.Variant, expected.Protected, wantVariant))
+	}
+
+	if !snapshot.Encrypted {
+		violations = append(violations, "is not encrypted")
+	}
+	if expected.KMSKeyID == "" && !snapshot.KMSAWSManaged {
+		violations = append(violations, fmt.Sprintf("should use the AWS managed key, got %s", snapshot.KMSKeyID))
+	}
+	if expected.KMSKeyID != "" && snapshot.KMSKeyID != expected.KMSKeyID {
+		violations = append(violations, fmt.Sprintf("should use KMS key %s, got %q", expected.KMSKeyID, snapshot.KMSKeyID))
+	}
+
+	wantPolicies := append([]string{}, expected.LifecyclePolicies...)
+	sort.Strings(wantPolicies)
+	if strings.Join(snapshot.LifecyclePolicies, ",") != strings.Join(wantPolicies, ",") {
+		violations = append(violations, fmt.Sprintf("lifecycle policies should be %v, got %v", wantPolicies, snapshot.LifecyclePolicies))
+	}
+	if snapshot.BackupEnabled != expected.BackupEnabled {
+		violations = append(violations, fmt.Sprintf("automatic backups should be enabled=%t", expected.BackupEnabled))
+	}
+
+	for _, subnet := range expected.SubnetIDs {
+		if _, ok := snapshot.MountTargets[subnet]; !ok {
+			violations = append(violations, fmt.Sprintf("has no mount target in subnet %s", subnet))
+		}
+	}
+	var subnets []string
+	for subnet := range snapshot.MountTargets {
+		subnets = append(subnets, subnet)
+	}
+	sort.Strings(subnets)
+	efsGroups := map[string]bool{}
+	for _, subnet := range subnets {
+		if !slices.Contains(expected.SubnetIDs, subnet) {
+			violations = append(violations, fmt.Sprintf("has an unexpected mount target in subnet %s", subnet))
+		}
+		groups := snapshot.MountTargets[subnet]
+		if len(groups) != 1 {
+			violations = append(violations, fmt.Sprintf("mount target in %s should use only the EFS security group, got %v", subnet, groups))
+		}
+		for _, group := range groups {
+			efsGroups[group] = true
+			if slices.Contains(expected.RunnerSecurityGroupIDs, group) {
+				violations = append(violations, fmt.Sprintf("mount target in %s uses runner security group %s directly", subnet, group))
+			}
+		}
+	}
+	if len(efsGroups) > 1 {
+		violations = append(violations, fmt.Sprintf("mount targets should share one EFS security group, got %d", len(efsGroups)))
+	}
+
+	if !snapshot.IngressKnown {
+		return violations
+	}
+	sourceGroups := map[string]bool{}
+	for _, rule := range snapshot.Ingress {
+		if rule.Protocol != "tcp" || rule.FromPort != 2049 || rule.ToPort != 2049 {
+			violations = append(violations, fmt.Sprintf("ingress %s %d-%d should be tcp 2049 only", rule.Protocol, rule.FromPort, rule.ToPort))
+		}
+		for _, source := range rule.OtherSources {
+			violations = append(violations, fmt.Sprintf("ingress should only allow runner security groups, got source %s", source))
+		}
+		for _, group := range rule.SourceGroupIDs {
+			sourceGroups[group] = true
+			if !slices.Contains(expected.RunnerSecurityGroupIDs, group) {
+				violations = append(violations, fmt.Sprintf("ingress allows non-runner security group %s", group))
+			}
+		}
+	}
+	for _, group := range expected.RunnerSecurityGroupIDs {
+		if !sourceGroups[group] {
+			violations = append(violations, fmt.Sprintf("ingress does not allow runner security group %s", group))
+		}
+	}
+	return violations
+}
+
+// efsVariant returns which file system resource (this_protected or this_unprotected) exists.
+func efsVariant(resources []*tfjson.StateResource) string {
+	for _, resource := range resources {
+		if resource.Type != "aws_efs_file_system" {
+			continue
+		}
+		for _, variant := range []string{efsProtectedVariant, efsUnprotectedVariant} {
+			if strings.Contains(resource.Address, "aws_efs_file_system."+variant+"[") {
+				return variant
+			}
+		}
+	}
+	return ""
+}
+
+// efsLifecyclePolicies formats live lifecycle policies like plannedEFSSnapshot does.
+func efsLifecyclePolicies(policies []efstypes.LifecyclePolicy) []string {
+	var formatted []string
+	for _, policy := range policies {
+		if policy.TransitionToIA != "" {
+			formatted = append(formatted, "TransitionToIA="+string(policy.TransitionToIA))
+		}
+		if policy.TransitionToPrimaryStorageClass != "" {
+			formatted = append(formatted, "TransitionToPrimaryStorageClass="+string(policy.TransitionToPrimaryStorageClass))
+		}
+		if policy.TransitionToArchive != "" {
+			formatted = append(formatted, "TransitionToArchive="+string(policy.TransitionToArchive))
+		}
+	}
+	sort.Strings(formatted)
+	return formatted
+}
+
+// efsPolicyName maps a lifecycle_policy attribute (transition_to_ia) to the API name (TransitionToIA).
+func efsPolicyName(attribute string) string {
+	switch attribute {
+	case "transition_to_ia":
+		return "TransitionToIA"
+	case "transition_to_primary_storage_class":
+		return "TransitionToPrimaryStorageClass"
+	case "transition_to_archive":
+		return "TransitionToArchive"
+	}
+	return attribute
+}
+
+// blockStrings returns a list(string) attribute of a nested block or attribute map.
+func blockStrings(block map[string]interface{}, name string) []string {
+	raw, _ := block[name].([]interface{})
+	var values []string
+	for _, value := range raw {
+		if s, ok := value.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// efsPlanJSON is a trimmed plan of modules/optional/efs.tf with two subnets
+const efsPlanJSON = `{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "child_modules": [{
+        "address": "module.optional",
+        "resources": [
+          {
+            "address": "module.optional.aws_efs_file_system.this_unprotected[0]",
+            "mode": "managed", "type": "aws_efs_file_system", "name": "this_unprotected", "index": 0,
+            "values": {"encrypted": true, "lifecycle_policy": []}
+          },
+          {
+            "address": "module.optional.aws_security_group.efs[0]",
+            "mode": "managed", "type": "aws_security_group", "name": "efs", "index": 0,
+            "values": {
+              "name": "stack-efs-sg",
+              "ingress": [{
+                "protocol": "tcp", "from_port": 2049, "to_port": 2049,
+                "security_groups": ["sg-runner"], "cidr_blocks": [], "ipv6_cidr_blocks": [],
+                "prefix_list_ids": [], "self": false
+              }]
+            }
+          },
+          {
+            "address": "module.optional.aws_efs_mount_target.this[0]",
+            "mode": "managed", "type": "aws_efs_mount_target", "name": "this", "index": 0,
+            "values": {"subnet_id": "subnet-a"}
+          },
+          {
+            "address": "module.optional.aws_efs_mount_target.this[1]",
+            "mode": "managed", "type": "aws_efs_mount_target", "name": "this", "index": 1,
+            "values": {"subnet_id": "subnet-b"}
+          }
+        ]
+      }]
+    }
+  },
+  "configuration": {
+    "root_module": {
+      "module_calls": {
+        "optional": {
+          "module": {
+            "resources": [{
+              "address": "aws_efs_file_system.this_unprotected",
+              "mode": "managed", "type": "aws_efs_file_system", "name": "this_unprotected",
+              "expressions": {"encrypted": {"constant_value": true}}
+            }]
+          }
+        }
+      }
+    }
+  }
+}`
+
+func TestPlannedEFSSnapshot(t *testing.T) {
+	plan, err := terraform.ParsePlanJSON(efsPlanJSON)
+	require.NoError(t, err)
+
+	snapshot, err := plannedEFSSnapshot(plan)
+	require.NoError(t, err)
+	assert.Equal(t, efsUnprotectedVariant, snapshot.Variant)
+	assert.True(t, snapshot.Encrypted)
+	assert.True(t, snapshot.KMSAWSManaged, "an unset kms_key_id means the AWS managed key")
+	assert.True(t, snapshot.IngressKnown)
+	assert.Equal(t, map[string][]string{
+		"subnet-a": {efsPlannedSecurityGroup},
+		"subnet-b": {efsPlannedSecurityGroup},
+	}, snapshot.MountTargets)
+
+	expected := EFSExpectation{SubnetIDs: []string{"subnet-a", "subnet-b"}, RunnerSecurityGroupIDs: []string{"sg-runner"}}
+	assert.Empty(t, efsConfigurationViolations(snapshot, expected))
+}
+
+func TestEFSConfigurationViolations(t *testing.T) {
+	valid := func() efsSnapshot {
+		return efsSnapshot{
+			Variant:       efsUnprotectedVariant,
+			Encrypted:     true,
+			KMSKeyID:      "arn:aws:kms:us-east-1:123456789012:key/aws-managed",
+			KMSAWSManaged: true,
+			MountTargets:  map[string][]string{"subnet-a": {"sg-efs"}, "subnet-b": {"sg-efs"}},
+			Ingress:       []efsIngressRule{{Protocol: "tcp", FromPort: 2049, ToPort: 2049, SourceGroupIDs: []string{"sg-runner"}}},
+			IngressKnown:  true,
+		}
+	}
+	expected := EFSExpectation{SubnetIDs: []string{"subnet-a", "subnet-b"}, RunnerSecurityGroupIDs: []string{"sg-runner"}}
+
+	testCases := []struct {
+		name     string
+		mutate   func(*efsSnapshot, *EFSExpectation)
+		contains []string
+	}{
+		{"Valid", func(*efsSnapshot, *EFSExpectation) {}, nil},
+		{"WrongVariant", func(_ *efsSnapshot, e *EFSExpectation) { e.Protected = true }, []string{`selects "this_protected"`}},
+		{"Unencrypted", func(s *efsSnapshot, _ *EFSExpectation) { s.Encrypted = false }, []string{"not encrypted"}},
+		{"CustomerKey", func(s *efsSnapshot, _ *EFSExpectation) { s.KMSAWSManaged = false }, []string{"AWS managed key"}},
+		{"LifecyclePolicy", func(s *efsSnapshot, _ *EFSExpectation) {
+			s.LifecyclePolicies = []string{"TransitionToIA=AFTER_30_DAYS"}
+		}, []string{"lifecycle policies"}},
+		{"BackupEnabled", func(s *efsSnapshot, _ *EFSExpectation) { s.BackupEnabled = true }, []string{"automatic backups"}},
+		{"MissingSubnet", func(s *efsSnapshot, _ *EFSExpectation) { delete(s.MountTargets, "subnet-b") }, []string{"no mount target in subnet subnet-b"}},
+		{"ExtraSubnet", func(s *efsSnapshot, _ *EFSExpectation) { s.MountTargets["subnet-c"] = []string{"sg-efs"} }, []string{"unexpected mount target in subnet subnet-c"}},
+		{"RunnerGroupOnMountTarget", func(s *efsSnapshot, _ *EFSExpectation) { s.MountTargets["subnet-a"] = []string{"sg-runner"} },
+			[]string{"uses runner security group sg-runner directly", "share one EFS security group"}},
+		{"WrongPort", func(s *efsSnapshot, _ *EFSExpectation) { s.Ingress[0].FromPort, s.Ingress[0].ToPort = 0, 65535 }, []string{"tcp 0-65535"}},
+		{"CIDRSource", func(s *efsSnapshot, _ *EFSExpectation) {
+			s.Ingress = append(s.Ingress, efsIngressRule{Protocol: "tcp", FromPort: 2049, ToPort: 2049, OtherSources: []string{"0.0.0.0/0"}})
+		}, []string{"source 0.0.0.0/0"}},
+		{"OtherGroup", func(s *efsSnapshot, _ *EFSExpectation) { s.Ingress[0].SourceGroupIDs = []string{"sg-other"} },
+			[]string{"non-runner security group sg-other", "does not allow runner security group sg-runner"}},
+		{"UnknownIngressSkipped", func(s *efsSnapshot, _ *EFSExpectation) { s.Ingress, s.IngressKnown = nil, false }, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			snapshot, expectation := valid(), expected
+			tc.mutate(&snapshot, &expectation)
+			violations := efsConfigurationViolations(snapshot, expectation)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			joined := strings.Join(violations, "\n")
+			for _, want := range tc.contains {
+				assert.Contains(t, joined, want)
+			}
+		})
+	}
+}
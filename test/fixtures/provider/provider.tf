@@ -0,0 +1,13 @@
+# Copied into each scenario's copy of the root module, which has no provider block of its own,
+# so the module is applied with the same provider default_tags as the VPC fixture.
+
+variable "test_default_tags" {
+  description = "Tags applied to every module resource through provider default_tags"
+  type        = map(string)
+}
+
+provider "aws" {
+  default_tags {
+    tags = var.test_default_tags
+  }
+}
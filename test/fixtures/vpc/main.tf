@@ -12,15 +12,29 @@ provider "aws" {
   region = var.aws_region
 
   default_tags {
-    tags = {
-      TestFramework = "terratest"
-      TestID        = var.test_id
-      ManagedBy     = "terratest"
-      AutoCleanup   = "true"
-    }
+    tags = var.default_tags
+  }
+}
+
+# Subnets are spread over the first subnet_count AZs of the region, so the fixture works in
+# any region and with any supported subnet count
+data "aws_availability_zones" "available" {
+  state = "available"
+
+  filter {
+    name   = "opt-in-status"
+    values = ["opt-in-not-required"]
   }
 }
 
+locals {
+  azs = slice(data.aws_availability_zones.available.names, 0, min(var.subnet_count, length(data.aws_availability_zones.available.names)))
+
+  # /20 subnets: public from 10.0.0.0, private from 10.0.128.0
+  public_subnets  = [for i in range(length(local.azs)) : cidrsubnet("10.0.0.0/16", 4, i)]
+  private_subnets = [for i in range(length(local.azs)) : cidrsubnet("10.0.0.0/16", 4, i + 8)]
+}
+
 module "vpc" {
   source  = "terraform-aws-modules/vpc/aws"
   version = "5.0.0"
@@ -28,9 +42,9 @@ module "vpc" {
   name = "test-runs-on-vpc-${var.test_id}"
   cidr = "10.0.0.0/16"
 
-  azs             = ["us-east-1a", "us-east-1b", "us-east-1c"]
-  public_subnets  = ["10.0.0.0/20", "10.0.16.0/20", "10.0.32.0/20"]
-  private_subnets = ["10.0.128.0/20", "10.0.144.0/20", "10.0.160.0/20"]
+  azs             = local.azs
+  public_subnets  = local.public_subnets
+  private_subnets = local.private_subnets
 
   enable_nat_gateway = var.enable_nat
   single_nat_gateway = true
@@ -44,3 +58,64 @@ module "vpc" {
     AutoCleanup = "true"
   }
 }
+
+# Security group passed in through security_group_ids to test bring-your-own mode
+resource "aws_security_group" "byo" {
+  count = var.enable_byo_security_group ? 1 : 0
+
+  name_prefix = "test-runs-on-byo-${var.test_id}-"
+  description = "Bring-your-own security group for runs-on tests"
+  vpc_id      = module.vpc.vpc_id
+
+  tags = {
+    Name    = "test-runs-on-byo-sg"
+    Purpose = "terratest"
+  }
+}
+
+# Permission boundary passed in through permission_boundary_arn. It allows everything the stack
+# needs but denies IAM user management, so a role without it would be detectably different.
+resource "aws_iam_policy" "boundary" {
+  count = var.enable_permission_boundary ? 1 : 0
+
+  name_prefix = "test-runs-on-boundary-${var.test_id}-"
+  description = "Permission boundary for runs-on tests"
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Sid      = "AllowAll"
+        Effect   = "Allow"
+        Action   = "*"
+        Resource = "*"
+      },
+      {
+        Sid    = "DenyUserManagement"
+        Effect = "Deny"
+        Action = [
+          "iam:CreateUser",
+          "iam:CreateAccessKey",
+          "iam:CreateLoginProfile",
+          "iam:AttachUserPolicy",
+          "iam:PutUserPolicy"
+        ]
+        Resource = "*"
+      },
+      {
+        Sid    = "DenyBoundaryRemoval"
+        Effect = "Deny"
+        Action = [
+          "iam:DeleteRolePermissionsBoundary",
+          "iam:PutRolePermissionsBoundary"
+        ]
+        Resource = "*"
+      }
+    ]
+  })
+
+  tags = {
+    Name    = "test-runs-on-boundary"
+    Purpose = "terratest"
+  }
+}
@@ -12,3 +12,23 @@ output "private_subnets" {
   description = "List of private subnet IDs"
   value       = module.vpc.private_subnets
 }
+
+output "azs" {
+  description = "Availability zones of the subnets, in subnet order"
+  value       = local.azs
+
+  precondition {
+    condition     = length(local.azs) == var.subnet_count
+    error_message = "Region ${var.aws_region} has fewer than ${var.subnet_count} availability zones."
+  }
+}
+
+output "byo_security_group_ids" {
+  description = "Bring-your-own security group IDs (empty unless enabled)"
+  value       = aws_security_group.byo[*].id
+}
+
+output "permission_boundary_arn" {
+  description = "Permission boundary policy ARN (empty unless enabled)"
+  value       = var.enable_permission_boundary ? aws_iam_policy.boundary[0].arn : ""
+}
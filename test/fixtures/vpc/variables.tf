@@ -9,8 +9,36 @@ variable "test_id" {
   type        = string
 }
 
+variable "default_tags" {
+  description = "Tags applied to every fixture resource through provider default_tags"
+  type        = map(string)
+}
+
+variable "subnet_count" {
+  description = "Number of public and private subnets, one per availability zone"
+  type        = number
+  default     = 3
+
+  validation {
+    condition     = var.subnet_count >= 1 && var.subnet_count <= 8
+    error_message = "subnet_count must be between 1 and 8."
+  }
+}
+
 variable "enable_nat" {
   description = "Enable NAT gateway for private subnet internet access"
   type        = bool
   default     = false
 }
+
+variable "enable_byo_security_group" {
+  description = "Create a security group to pass to the module as a bring-your-own security group"
+  type        = bool
+  default     = false
+}
+
+variable "enable_permission_boundary" {
+  description = "Create an IAM policy to pass to the module as the permission boundary of every role"
+  type        = bool
+  default     = false
+}
@@ -11,7 +11,9 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
@@ -19,6 +21,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/google/go-github/v68/github"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -58,6 +61,51 @@ type ScenarioConfig struct {
 	EnableNAT  bool
 	AWSRegion  string
 
+	// SubnetCount is the number of public and private fixture subnets, one per AZ of AWSRegion
+	SubnetCount int
+
+	// CostAllocationTag is the tag key the module sets to the stack name (module default "stack")
+	CostAllocationTag string
+
+	// PreventDestroyOptionalResources selects the protected EFS/ECR variants. Tests leave it
+	// off so the stack can be destroyed.
+	PreventDestroyOptionalResources bool
+
+	// CacheExpirationDays is the cache_expiration_days input (lifetime of cache/ objects)
+	CacheExpirationDays int
+
+	// Alarm thresholds. A zero SQSOldestMessageThresholdSeconds disables the SQS alarm.
+	AppAlarmDailyMinutes             int
+	SQSOldestMessageThresholdSeconds int
+
+	// Runner security group settings. SecurityGroupIDs switches the module to
+	// bring-your-own mode (typically the VPC fixture's byo_security_group_ids).
+	SSHAllowed             bool
+	SSHCIDRRange           string
+	EnableBYOSecurityGroup bool
+	SecurityGroupIDs       []string
+
+	// IAM permission boundary settings. EnablePermissionBoundary has the VPC fixture create a
+	// boundary policy; PermissionBoundaryARN (typically its permission_boundary_arn output) is
+	// passed to the module for every role.
+	EnablePermissionBoundary bool
+	PermissionBoundaryARN    string
+
+	// AlertSlackWebhookURL enables the Slack webhook Lambda and its role when set
+	AlertSlackWebhookURL string
+
+	// Runner placement and disk settings. PrivateMode other than "false" requires EnableNAT so
+	// the private subnets are passed to the module.
+	PrivateMode           string
+	RunnerDefaultDiskSize int
+	RunnerLargeDiskSize   int
+
+	// RunnerMaxRuntime is the runner_max_runtime input in minutes
+	RunnerMaxRuntime int
+
+	// SpotCircuitBreaker is the spot_circuit_breaker input, "<interruptions>/<window min>/<block min>"
+	SpotCircuitBreaker string
+
 	// App version overrides (optional - empty means use module defaults)
 	AppImage string
 	AppTag   string
@@ -69,9 +117,28 @@ func DefaultScenarioConfig() ScenarioConfig {
 		TestID:     GetTestID(),
 		GithubOrg:  getGithubOrg(),
 		LicenseKey: GetOptionalEnv("RUNS_ON_LICENSE_KEY", "test-license"),
-		AWSRegion:  GetOptionalEnv("AWS_REGION", "us-east-1"),
+		AWSRegion:  GetAWSRegion(),
 		AppImage:   os.Getenv("RUNS_ON_APP_IMAGE"),
 		AppTag:     os.Getenv("RUNS_ON_APP_TAG"),
+
+		// One public and one private subnet in each of the first three AZs
+		SubnetCount: 3,
+
+		// Module defaults
+		CostAllocationTag: "stack",
+		SSHAllowed:        true,
+		SSHCIDRRange:      "0.0.0.0/0",
+
+		// Keep test caches short-lived
+		CacheExpirationDays: 1,
+
+		// Module defaults
+		AppAlarmDailyMinutes:  4000,
+		PrivateMode:           "false",
+		RunnerDefaultDiskSize: 40,
+		RunnerLargeDiskSize:   80,
+		RunnerMaxRuntime:      720,
+		SpotCircuitBreaker:    "2/15/30",
 	}
 }
 
@@ -95,12 +162,28 @@ func getGithubOrg() string {
 	return "test-org"
 }
 
+// DefaultTags returns the tags the VPC fixture and the module under test apply through provider
+// default_tags (fixtures/provider). Resources that set their own Name override it.
+func (c ScenarioConfig) DefaultTags() map[string]string {
+	return map[string]string{
+		"Name":          fmt.Sprintf("terratest-%s", c.TestID),
+		"TestFramework": "terratest",
+		"TestID":        c.TestID,
+		"ManagedBy":     "terratest",
+		"AutoCleanup":   "true",
+	}
+}
+
 // ToVPCVars converts config to VPC module variables
 func (c ScenarioConfig) ToVPCVars() map[string]interface{} {
 	return map[string]interface{}{
-		"test_id":    c.TestID,
-		"aws_region": c.AWSRegion,
-		"enable_nat": c.EnableNAT,
+		"test_id":                    c.TestID,
+		"default_tags":               c.DefaultTags(),
+		"aws_region":                 c.AWSRegion,
+		"subnet_count":               c.SubnetCount,
+		"enable_nat":                 c.EnableNAT,
+		"enable_byo_security_group":  c.EnableBYOSecurityGroup,
+		"enable_permission_boundary": c.EnablePermissionBoundary,
 	}
 }
 
@@ -110,20 +193,24 @@ func (c ScenarioConfig) ToModuleVars(vpcID string, publicSubnets, privateSubnets
 		"stack_name":                         fmt.Sprintf("test-%s", c.TestID),
 		"github_organization":                c.GithubOrg,
 		"license_key":                        c.LicenseKey,
+		"cost_allocation_tag":                c.CostAllocationTag,
 		"vpc_id":                             vpcID,
 		"public_subnet_ids":                  publicSubnets,
 		"enable_efs":                         c.EnableEFS,
 		"enable_ecr":                         c.EnableECR,
+		"ssh_allowed":                        c.SSHAllowed,
+		"ssh_cidr_range":                     c.SSHCIDRRange,
 		"environment":                        "test",
 		"email":                              "test@example.com",
 		"log_retention_days":                 1,
-		"cache_expiration_days":              1,
+		"cache_expiration_days":              c.CacheExpirationDays,
 		"detailed_monitoring_enabled":        false,
 		"app_cpu":                            1024,
 		"app_memory":                         2048,
-		"force_destroy_buckets":              true,  // Enable force destroy for S3 test cleanup
-		"force_delete_ecr":                   true,  // Enable force delete for ECR test cleanup
-		"prevent_destroy_optional_resources": false, // Disable prevent_destroy for test cleanup
+		"force_destroy_buckets":              true, // Enable force destroy for S3 test cleanup
+		"force_delete_ecr":                   true, // Enable force delete for ECR test cleanup
+		"prevent_destroy_optional_resources": c.PreventDestroyOptionalResources,
+		"test_default_tags":                  c.DefaultTags(), // Declared by fixtures/provider
 	}
 
 	// App version overrides (only set if provided via env vars)
@@ -134,9 +221,29 @@ func (c ScenarioConfig) ToModuleVars(vpcID string, publicSubnets, privateSubnets
 		vars["app_tag"] = c.AppTag
 	}
 
+	if len(c.SecurityGroupIDs) > 0 {
+		vars["security_group_ids"] = c.SecurityGroupIDs
+	}
+
+	if c.PermissionBoundaryARN != "" {
+		vars["permission_boundary_arn"] = c.PermissionBoundaryARN
+	}
+	if c.AlertSlackWebhookURL != "" {
+		vars["alert_slack_webhook_url"] = c.AlertSlackWebhookURL
+	}
+
+	// Alarm thresholds
+	vars["app_alarm_daily_minutes"] = c.AppAlarmDailyMinutes
+	vars["sqs_queue_oldest_message_threshold_seconds"] = c.SQSOldestMessageThresholdSeconds
+
 	if len(privateSubnets) > 0 && c.EnableNAT {
 		vars["private_subnet_ids"] = privateSubnets
 	}
+	vars["private_mode"] = c.PrivateMode
+	vars["runner_default_disk_size"] = c.RunnerDefaultDiskSize
+	vars["runner_large_disk_size"] = c.RunnerLargeDiskSize
+	vars["runner_max_runtime"] = c.RunnerMaxRuntime
+	vars["spot_circuit_breaker"] = c.SpotCircuitBreaker
 
 	return vars
 }
@@ -161,6 +268,20 @@ func MustGetAWSConfig(ctx context.Context) aws.Config {
 	return cfg
 }
 
+// GetAWSPartition returns the partition of the caller's account, e.g. aws or aws-us-gov, for
+// building ARNs.
+func GetAWSPartition(ctx context.Context, cfg aws.Config) (string, error) {
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	callerArn, err := arn.Parse(aws.ToString(identity.Arn))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse caller ARN: %w", err)
+	}
+	return callerArn.Partition, nil
+}
+
 // =============================================================================
 // SECURITY VALIDATIONS
 // =============================================================================
@@ -180,21 +301,6 @@ func ValidateS3BucketEncryption(t *testing.T, bucketName string) {
 	assert.Equal(t, "aws:kms", algo, "Bucket %s should use KMS encryption, got %s", bucketName, algo)
 }
 
-// ValidateS3BucketLogging checks bucket has access logging enabled
-func ValidateS3BucketLogging(t *testing.T, bucketName, expectedTargetBucket string) {
-	ctx := context.Background()
-	cfg := MustGetAWSConfig(ctx)
-	client := s3.NewFromConfig(cfg)
-
-	result, err := client.GetBucketLogging(ctx, &s3.GetBucketLoggingInput{
-		Bucket: aws.String(bucketName),
-	})
-	require.NoError(t, err, "Failed to get bucket logging for %s", bucketName)
-	require.NotNil(t, result.LoggingEnabled, "Bucket %s should have logging enabled", bucketName)
-	assert.Contains(t, *result.LoggingEnabled.TargetBucket, expectedTargetBucket,
-		"Bucket %s should log to %s", bucketName, expectedTargetBucket)
-}
-
 // ValidateS3BucketPublicAccessBlocked checks bucket has public access blocked
 func ValidateS3BucketPublicAccessBlocked(t *testing.T, bucketName string) {
 	ctx := context.Background()
@@ -318,44 +424,45 @@ func ValidateAppRunnerHealth(t *testing.T, serviceURL string, maxRetries int) {
 	require.NoError(t, lastErr, "App Runner health check failed after %d retries", maxRetries)
 }
 
+// GetAppRunnerEnvironment returns the runtime environment variables configured on the App Runner service
+func GetAppRunnerEnvironment(t *testing.T, serviceArn string) map[string]string {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := apprunner.NewFromConfig(cfg)
+
+	result, err := client.DescribeService(ctx, &apprunner.DescribeServiceInput{
+		ServiceArn: aws.String(serviceArn),
+	})
+	require.NoError(t, err, "Failed to describe App Runner service %s", serviceArn)
+
+	source := result.Service.SourceConfiguration
+	require.NotNil(t, source.ImageRepository, "App Runner service %s is not image-based", serviceArn)
+	require.NotNil(t, source.ImageRepository.ImageConfiguration, "App Runner service %s has no image configuration", serviceArn)
+	return source.ImageRepository.ImageConfiguration.RuntimeEnvironmentVariables
+}
+
 // =============================================================================
 // EC2 AND SSM HELPERS FOR FUNCTIONAL TESTING
 // =============================================================================
 
 // GetLatestAmazonLinux2023AMI returns the latest Amazon Linux 2023 AMI ID for the current region.
 func GetLatestAmazonLinux2023AMI(t *testing.T) string {
+	return GetLatestAMI(t, OSLinux)
+}
+
+// GetLatestWindowsServerAMI returns the latest Windows Server 2022 AMI ID for the current region.
+func GetLatestWindowsServerAMI(t *testing.T) string {
+	return GetLatestAMI(t, OSWindows)
+}
+
+// GetLatestAMI returns the latest Amazon-owned AMI ID for the given OS in the current region.
+func GetLatestAMI(t *testing.T, instanceOS InstanceOS) string {
 	ctx := context.Background()
 	cfg := MustGetAWSConfig(ctx)
 	client := ec2.NewFromConfig(cfg)
 
-	result, err := client.DescribeImages(ctx, &ec2.DescribeImagesInput{
-		Owners: []string{"amazon"},
-		Filters: []ec2types.Filter{
-			{
-				Name:   aws.String("name"),
-				Values: []string{"al2023-ami-2023*-x86_64"},
-			},
-			{
-				Name:   aws.String("state"),
-				Values: []string{"available"},
-			},
-			{
-				Name:   aws.String("architecture"),
-				Values: []string{"x86_64"},
-			},
-		},
-	})
-	require.NoError(t, err, "Failed to describe AMIs")
-	require.NotEmpty(t, result.Images, "No Amazon Linux 2023 AMIs found")
-
-	// Find the most recent AMI
-	var latestAMI *ec2types.Image
-	for i := range result.Images {
-		img := &result.Images[i]
-		if latestAMI == nil || *img.CreationDate > *latestAMI.CreationDate {
-			latestAMI = img
-		}
-	}
+	latestAMI, err := findLatestAMI(ctx, client, instanceOS)
+	require.NoError(t, err, "Failed to resolve %s AMI", instanceOS)
 
 	t.Logf("Using AMI: %s (%s)", *latestAMI.ImageId, *latestAMI.Name)
 	return *latestAMI.ImageId
@@ -366,6 +473,30 @@ func GetLatestAmazonLinux2023AMI(t *testing.T) string {
 // Set publicIP to true for public subnets (SSM access via internet) or false for private subnets (SSM via NAT).
 // Returns the instance ID.
 func LaunchTestInstance(t *testing.T, launchTemplateID, subnetID string, publicIP bool) string {
+	return LaunchTestInstanceForOS(t, OSLinux, launchTemplateID, subnetID, publicIP)
+}
+
+// LaunchTestInstanceForOS launches an EC2 instance from a Linux or Windows launch template,
+// overriding the AMI with the latest Amazon image for that OS.
+func LaunchTestInstanceForOS(t *testing.T, instanceOS InstanceOS, launchTemplateID, subnetID string, publicIP bool) string {
+	instanceName := "terratest-functional-test"
+	if instanceOS == OSWindows {
+		instanceName += "-windows"
+	}
+	if !publicIP {
+		instanceName += "-private"
+	}
+
+	return launchInstanceFromTemplate(t, instanceOS, launchTemplateID, subnetID, publicIP, []ec2types.Tag{
+		{Key: aws.String("Name"), Value: aws.String(instanceName)},
+		{Key: aws.String("TestFramework"), Value: aws.String("terratest")},
+		{Key: aws.String("AutoCleanup"), Value: aws.String("true")},
+	})
+}
+
+// launchInstanceFromTemplate launches one instance from a launch template.
+// When instanceTags is nil the instance only gets the launch template's tag_specifications.
+func launchInstanceFromTemplate(t *testing.T, instanceOS InstanceOS, launchTemplateID, subnetID string, publicIP bool, instanceTags []ec2types.Tag) string {
 	ctx := context.Background()
 	cfg := MustGetAWSConfig(ctx)
 	client := ec2.NewFromConfig(cfg)
@@ -378,8 +509,8 @@ func LaunchTestInstance(t *testing.T, launchTemplateID, subnetID string, publicI
 		version = parts[1]
 	}
 
-	// Get the latest Amazon Linux 2023 AMI since the launch template may not have one
-	amiID := GetLatestAmazonLinux2023AMI(t)
+	// Get the latest AMI for the OS since the launch template may not have one
+	amiID := GetLatestAMI(t, instanceOS)
 
 	instanceType := "public"
 	if !publicIP {
@@ -388,11 +519,6 @@ func LaunchTestInstance(t *testing.T, launchTemplateID, subnetID string, publicI
 	t.Logf("Launching %s test instance from template %s (version %s) in subnet %s with AMI %s",
 		instanceType, templateID, version, subnetID, amiID)
 
-	instanceName := "terratest-functional-test"
-	if !publicIP {
-		instanceName = "terratest-functional-test-private"
-	}
-
 	input := &ec2.RunInstancesInput{
 		LaunchTemplate: &ec2types.LaunchTemplateSpecification{
 			LaunchTemplateId: aws.String(templateID),
@@ -410,16 +536,14 @@ func LaunchTestInstance(t *testing.T, launchTemplateID, subnetID string, publicI
 				DeleteOnTermination:      aws.Bool(true),
 			},
 		},
-		TagSpecifications: []ec2types.TagSpecification{
+	}
+	if instanceTags != nil {
+		input.TagSpecifications = []ec2types.TagSpecification{
 			{
 				ResourceType: ec2types.ResourceTypeInstance,
-				Tags: []ec2types.Tag{
-					{Key: aws.String("Name"), Value: aws.String(instanceName)},
-					{Key: aws.String("TestFramework"), Value: aws.String("terratest")},
-					{Key: aws.String("AutoCleanup"), Value: aws.String("true")},
-				},
+				Tags:         instanceTags,
 			},
-		},
+		}
 	}
 
 	result, err := client.RunInstances(ctx, input)
@@ -519,59 +643,17 @@ func WaitForInstanceReady(t *testing.T, instanceID string, timeout time.Duration
 // RunSSMCommand executes a shell command on an EC2 instance via SSM and returns the output.
 // Returns stdout, stderr, and any error.
 func RunSSMCommand(t *testing.T, instanceID string, commands []string) (string, string, error) {
+	return RunSSMCommandForOS(t, instanceID, OSLinux, commands)
+}
+
+// RunSSMCommandForOS executes commands via AWS-RunShellScript on Linux or
+// AWS-RunPowerShellScript on Windows. Returns stdout, stderr, and any error.
+func RunSSMCommandForOS(t *testing.T, instanceID string, instanceOS InstanceOS, commands []string) (string, string, error) {
 	ctx := context.Background()
 	cfg := MustGetAWSConfig(ctx)
 	client := ssm.NewFromConfig(cfg)
 
-	t.Logf("Running SSM command on instance %s: %v", instanceID, commands)
-
-	sendResult, err := client.SendCommand(ctx, &ssm.SendCommandInput{
-		InstanceIds:  []string{instanceID},
-		DocumentName: aws.String("AWS-RunShellScript"),
-		Parameters: map[string][]string{
-			"commands": commands,
-		},
-		TimeoutSeconds: aws.Int32(120),
-	})
-	if err != nil {
-		return "", "", fmt.Errorf("failed to send SSM command: %w", err)
-	}
-
-	commandID := *sendResult.Command.CommandId
-	t.Logf("SSM command ID: %s", commandID)
-
-	// Wait for command completion
-	for i := 0; i < 60; i++ {
-		time.Sleep(3 * time.Second)
-
-		result, err := client.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
-			CommandId:  aws.String(commandID),
-			InstanceId: aws.String(instanceID),
-		})
-		if err != nil {
-			// Command may not be ready yet
-			if strings.Contains(err.Error(), "InvocationDoesNotExist") {
-				continue
-			}
-			return "", "", fmt.Errorf("failed to get command invocation: %w", err)
-		}
-
-		status := result.Status
-		t.Logf("SSM command status: %s", status)
-
-		switch status {
-		case ssmtypes.CommandInvocationStatusSuccess:
-			stdout := aws.ToString(result.StandardOutputContent)
-			stderr := aws.ToString(result.StandardErrorContent)
-			return stdout, stderr, nil
-		case ssmtypes.CommandInvocationStatusFailed, ssmtypes.CommandInvocationStatusCancelled, ssmtypes.CommandInvocationStatusTimedOut:
-			stdout := aws.ToString(result.StandardOutputContent)
-			stderr := aws.ToString(result.StandardErrorContent)
-			return stdout, stderr, fmt.Errorf("SSM command %s: %s", status, stderr)
-		}
-	}
-
-	return "", "", fmt.Errorf("SSM command timed out after 3 minutes")
+	return runSSMCommand(ctx, t, client, instanceID, instanceOS, commands, 3*time.Second, 60)
 }
 
 // =============================================================================
@@ -583,6 +665,7 @@ func RunSSMCommand(t *testing.T, instanceID string, commands []string) (string,
 func isAccessDenied(output string) bool {
 	return strings.Contains(output, "AccessDenied") ||
 		strings.Contains(output, "Access Denied") ||
+		strings.Contains(output, "UnauthorizedOperation") ||
 		strings.Contains(output, "403") ||
 		strings.Contains(output, "Forbidden")
 }
@@ -600,6 +683,15 @@ func isAccessDenied(output string) bool {
 //   - CANNOT write to runners/* in cache bucket
 //   - CANNOT read from runners/{other-userid}/* in cache bucket
 func ValidateS3AccessFromEC2(t *testing.T, instanceID, cacheBucket, configBucket string) {
+	ValidateS3AccessFromEC2ForOS(t, OSLinux, instanceID, cacheBucket, configBucket)
+}
+
+// ValidateS3AccessFromEC2ForOS runs the ValidateS3AccessFromEC2 checks on a Linux or Windows instance.
+func ValidateS3AccessFromEC2ForOS(t *testing.T, instanceOS InstanceOS, instanceID, cacheBucket, configBucket string) {
+	shell, err := shellForOS(instanceOS)
+	require.NoError(t, err)
+	region := GetAWSRegion()
+
 	ctx := context.Background()
 	cfg := MustGetAWSConfig(ctx)
 	s3Client := s3.NewFromConfig(cfg)
@@ -608,8 +700,8 @@ func ValidateS3AccessFromEC2(t *testing.T, instanceID, cacheBucket, configBucket
 	testContent := fmt.Sprintf("test-content-%d", time.Now().UnixNano())
 
 	// Get the EC2 instance's aws:userid for runners path testing
-	getUserIdCmd := "aws sts get-caller-identity --query 'UserId' --output text"
-	stdout, stderr, err := RunSSMCommand(t, instanceID, []string{getUserIdCmd})
+	getUserIdCmd := shell.CallerUserID(region)
+	stdout, stderr, err := RunSSMCommandForOS(t, instanceID, instanceOS, []string{getUserIdCmd})
 	require.NoError(t, err, "Failed to get caller identity. stderr: %s", stderr)
 	userId := strings.TrimSpace(stdout)
 	require.NotEmpty(t, userId, "UserId should not be empty")
@@ -617,15 +709,14 @@ func ValidateS3AccessFromEC2(t *testing.T, instanceID, cacheBucket, configBucket
 
 	// === Test 1: CAN write to cache/* ===
 	cacheKey := fmt.Sprintf("cache/%s", testFile)
-	writeCmd := fmt.Sprintf("echo '%s' | aws s3 cp - s3://%s/%s --region %s 2>&1",
-		testContent, cacheBucket, cacheKey, GetAWSRegion())
-	stdout, _, err = RunSSMCommand(t, instanceID, []string{writeCmd})
+	writeCmd := shell.S3Write(cacheBucket, cacheKey, testContent, region)
+	stdout, _, err = RunSSMCommandForOS(t, instanceID, instanceOS, []string{writeCmd})
 	require.NoError(t, err, "Should be able to write to cache/*. stderr: %s", stdout)
 	t.Logf("✓ CAN write to cache/*")
 
 	// === Test 2: CAN read from cache/* ===
-	readCmd := fmt.Sprintf("aws s3 cp s3://%s/%s - --region %s 2>&1", cacheBucket, cacheKey, GetAWSRegion())
-	stdout, _, err = RunSSMCommand(t, instanceID, []string{readCmd})
+	readCmd := shell.S3Read(cacheBucket, cacheKey, region)
+	stdout, _, err = RunSSMCommandForOS(t, instanceID, instanceOS, []string{readCmd})
 	require.NoError(t, err, "Should be able to read from cache/*")
 	assert.Contains(t, stdout, testContent, "Content mismatch reading from cache/*")
 	t.Logf("✓ CAN read from cache/*")
@@ -643,8 +734,8 @@ func ValidateS3AccessFromEC2(t *testing.T, instanceID, cacheBucket, configBucket
 	})
 	require.NoError(t, err, "Admin failed to upload to runners path")
 
-	readCmd = fmt.Sprintf("aws s3 cp s3://%s/%s - --region %s 2>&1", cacheBucket, ownRunnersKey, GetAWSRegion())
-	stdout, _, err = RunSSMCommand(t, instanceID, []string{readCmd})
+	readCmd = shell.S3Read(cacheBucket, ownRunnersKey, region)
+	stdout, _, err = RunSSMCommandForOS(t, instanceID, instanceOS, []string{readCmd})
 	require.NoError(t, err, "Should be able to read from own runners path")
 	assert.Contains(t, stdout, ownRunnersContent)
 	t.Logf("✓ CAN read from runners/{own-userid}/*")
@@ -662,8 +753,8 @@ func ValidateS3AccessFromEC2(t *testing.T, instanceID, cacheBucket, configBucket
 	})
 	require.NoError(t, err, "Admin failed to upload to agents path")
 
-	readCmd = fmt.Sprintf("aws s3 cp s3://%s/%s - --region %s 2>&1", configBucket, agentsKey, GetAWSRegion())
-	stdout, _, err = RunSSMCommand(t, instanceID, []string{readCmd})
+	readCmd = shell.S3Read(configBucket, agentsKey, region)
+	stdout, _, err = RunSSMCommandForOS(t, instanceID, instanceOS, []string{readCmd})
 	require.NoError(t, err, "Should be able to read from agents/*")
 	assert.Contains(t, stdout, agentsContent)
 	t.Logf("✓ CAN read from agents/* (config bucket)")
@@ -673,9 +764,8 @@ func ValidateS3AccessFromEC2(t *testing.T, instanceID, cacheBucket, configBucket
 
 	// === Test 5: CANNOT write to runners/* ===
 	runnersWriteKey := fmt.Sprintf("runners/%s", testFile)
-	writeCmd = fmt.Sprintf("echo 'test' | aws s3 cp - s3://%s/%s --region %s 2>&1",
-		cacheBucket, runnersWriteKey, GetAWSRegion())
-	stdout, _, _ = RunSSMCommand(t, instanceID, []string{writeCmd})
+	writeCmd = shell.S3Write(cacheBucket, runnersWriteKey, "test", region)
+	stdout, _, _ = RunSSMCommandForOS(t, instanceID, instanceOS, []string{writeCmd})
 	accessDenied := isAccessDenied(stdout)
 	assert.True(t, accessDenied, "Should NOT be able to write to runners/*, got: %s", stdout)
 	t.Logf("✓ CANNOT write to runners/*")
@@ -689,8 +779,8 @@ func ValidateS3AccessFromEC2(t *testing.T, instanceID, cacheBucket, configBucket
 	})
 	require.NoError(t, err, "Admin failed to upload to other user's runners path")
 
-	readCmd = fmt.Sprintf("aws s3 cp s3://%s/%s - --region %s 2>&1", cacheBucket, otherRunnersKey, GetAWSRegion())
-	stdout, _, _ = RunSSMCommand(t, instanceID, []string{readCmd})
+	readCmd = shell.S3Read(cacheBucket, otherRunnersKey, region)
+	stdout, _, _ = RunSSMCommandForOS(t, instanceID, instanceOS, []string{readCmd})
 	accessDenied = isAccessDenied(stdout)
 	assert.True(t, accessDenied, "Should NOT be able to read from other user's runners path, got: %s", stdout)
 	t.Logf("✓ CANNOT read from runners/{other-userid}/*")
@@ -699,29 +789,6 @@ func ValidateS3AccessFromEC2(t *testing.T, instanceID, cacheBucket, configBucket
 	_, _ = s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(cacheBucket), Key: aws.String(otherRunnersKey)})
 }
 
-// ValidateEC2CloudWatchLogs verifies that an EC2 instance is sending logs to CloudWatch.
-func ValidateEC2CloudWatchLogs(t *testing.T, instanceID, logGroupName string) {
-	ctx := context.Background()
-	cfg := MustGetAWSConfig(ctx)
-	client := cloudwatchlogs.NewFromConfig(cfg)
-
-	// First, generate some log activity on the instance
-	logCmd := fmt.Sprintf("logger -t terratest 'Functional test log entry from %s'", instanceID)
-	_, _, _ = RunSSMCommand(t, instanceID, []string{logCmd})
-
-	// Wait a bit for logs to propagate
-	time.Sleep(10 * time.Second)
-
-	// Check if the log group exists
-	result, err := client.DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
-		LogGroupNamePrefix: aws.String(logGroupName),
-	})
-	require.NoError(t, err, "Failed to describe log groups")
-	require.NotEmpty(t, result.LogGroups, "Log group %s not found", logGroupName)
-
-	t.Logf("CloudWatch log group %s exists and is configured", logGroupName)
-}
-
 // =============================================================================
 // INTEGRATION TEST HELPERS
 // =============================================================================
@@ -856,6 +923,34 @@ func WatchForWorkflowRun(t *testing.T, repo, workflowFile, testID string, startT
 	return 0, fmt.Errorf("timeout waiting for workflow run of %s", workflowFile)
 }
 
+// WaitForAppRegistration waits for the operator to register the RunsOn app at serviceURL on repo
+// and signal it by touching /tmp/runson-{testID}-ready. Jobs queued before the app is installed
+// never reach the stack, so tests that push workflows must wait here first.
+// Supports graceful abort via /tmp/runson-{testID}-abort file.
+func WaitForAppRegistration(t *testing.T, serviceURL, repo, testID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	readyFile := fmt.Sprintf("/tmp/runson-%s-ready", testID)
+	abortFile := fmt.Sprintf("/tmp/runson-%s-abort", testID)
+
+	t.Logf("Register the RunsOn app at https://%s on %s, then: touch %s", serviceURL, repo, readyFile)
+	t.Logf("To abort gracefully: touch %s", abortFile)
+
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(abortFile); err == nil {
+			os.Remove(abortFile)
+			return fmt.Errorf("test aborted by user (detected %s)", abortFile)
+		}
+		if _, err := os.Stat(readyFile); err == nil {
+			os.Remove(readyFile)
+			t.Logf("RunsOn app registered (detected %s)", readyFile)
+			return nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return fmt.Errorf("timeout waiting for the RunsOn app to be registered (touch %s)", readyFile)
+}
+
 // MonitorWorkflowJobStates monitors job states and detects stuck "queued" jobs.
 // Returns nil when any job reaches "in_progress" or "completed" (runner picked it up).
 // Returns error if all jobs stay "queued" longer than queuedTimeout.
@@ -1060,8 +1155,11 @@ func ValidateEFSMountFromEC2(t *testing.T, instanceID, efsFileSystemID string) {
 //  2. Creates a simple Dockerfile
 //  3. Builds with cache-to ECR (first build - cache miss)
 //  4. Builds again with cache-from ECR (second build - cache hit)
-//  5. Verifies the second build used cached layers
+//  5. Parses the BuildKit progress and fails unless every layer came from the cache
 func ValidateECRPushPullFromEC2(t *testing.T, instanceID, ecrURL string) {
+	// Layers (RUN steps) in the test Dockerfile, all of which should be cache hits on the second build
+	const ecrCacheTestLayers = 3
+
 	region := GetAWSRegion()
 	testTag := fmt.Sprintf("cache-test-%d", time.Now().UnixNano())
 	cacheRef := fmt.Sprintf("%s:%s", ecrURL, testTag)
@@ -1083,8 +1181,9 @@ func ValidateECRPushPullFromEC2(t *testing.T, instanceID, ecrURL string) {
 
 	// Step 2: Set up Docker Buildx (required for cache-to/cache-from with registry)
 	buildxSetupCmd := `
-		sudo docker buildx version || {
-			# Install buildx if not available
+		# --progress=rawjson needs buildx v0.12.0 or later
+		sudo docker buildx build --help 2>/dev/null | grep -q rawjson || {
+			# Install buildx if not available or too old
 			mkdir -p ~/.docker/cli-plugins
 			curl -sSL https://github.com/docker/buildx/releases/download/v0.12.0/buildx-v0.12.0.linux-amd64 -o ~/.docker/cli-plugins/docker-buildx
 			chmod +x ~/.docker/cli-plugins/docker-buildx
@@ -1138,27 +1237,34 @@ DOCKERFILE
 	_, _, _ = RunSSMCommand(t, instanceID, []string{clearCacheCmd})
 	t.Logf("✓ Cleared local build cache")
 
-	// Step 7: Second build - should use cache from ECR (cache hit expected)
+	// Step 7: Second build - should use cache from ECR (cache hit expected).
+	// The rawjson progress stream goes to a file; only vertex updates are printed, with
+	// statuses and logs cut off, so the stream fits in the SSM command output.
 	secondBuildCmd := fmt.Sprintf(`
 		cd /tmp/ecr-cache-test
 		sudo docker buildx build \
+			--progress=rawjson \
 			--cache-from type=registry,ref=%s \
 			--load \
 			-t test-image:second \
-			. 2>&1
+			. 2> second-build.json
+		status=$?
+		grep '"vertexes":\[{' second-build.json | sed -E 's/,"statuses":.*$/}/'
+		[ $status -eq 0 ] || tail -c 2000 second-build.json >&2
+		exit $status
 	`, cacheRef)
 	stdout, stderr, err = RunSSMCommand(t, instanceID, []string{secondBuildCmd})
-	require.NoError(t, err, "Second build failed. stdout: %s, stderr: %s", stdout, stderr)
-
-	// Check for cache hit indicators in output
-	buildOutput := stdout + stderr
-	cacheHit := strings.Contains(buildOutput, "CACHED") || strings.Contains(buildOutput, "importing cache")
-	t.Logf("Second build output (checking for cache): %s", truncateString(buildOutput, 500))
-
-	if cacheHit {
-		t.Logf("✓ Second build used cached layers from ECR")
-	} else {
-		t.Logf("⚠ Cache indicators not found in output, but build succeeded")
+	require.NoError(t, err, "Second build failed. stderr: %s", stderr)
+
+	// Every RUN layer of the Dockerfile should come from the ECR cache
+	report, err := ParseBuildKitProgress(strings.NewReader(stdout))
+	require.NoError(t, err, "Failed to parse BuildKit progress: %s", truncateString(stdout, 500))
+	violations := buildCacheViolations(report, ecrCacheTestLayers)
+	for _, violation := range violations {
+		assert.Fail(t, "Second build did not use the ECR cache: "+violation)
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ Second build used %d cached layers from ECR", len(report.CachedLayers()))
 	}
 
 	// Step 8: Verify the built image works
@@ -1183,15 +1289,11 @@ DOCKERFILE
 // INTEGRATION TEST HELPERS
 // =============================================================================
 
-// ValidateRunnerLaunched checks if an EC2 runner instance was launched for the stack
-// after the given start time.
-func ValidateRunnerLaunched(t *testing.T, stackName string, since time.Time) bool {
-	ctx := context.Background()
-	cfg := MustGetAWSConfig(ctx)
-	client := ec2.NewFromConfig(cfg)
-
-	// Look for instances with the runs-on-stack-name tag launched after 'since'
-	result, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+// stackRunnerInstances returns the instances tagged runs-on-stack-name=stackName that were
+// launched after since, whether running, stopped or already terminated.
+func stackRunnerInstances(ctx context.Context, client ec2.DescribeInstancesAPIClient, stackName string, since time.Time) ([]ec2types.Instance, error) {
+	var instances []ec2types.Instance
+	paginator := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{
 		Filters: []ec2types.Filter{
 			{
 				Name:   aws.String("tag:runs-on-stack-name"),
@@ -1203,22 +1305,18 @@ func ValidateRunnerLaunched(t *testing.T, stackName string, since time.Time) boo
 			},
 		},
 	})
-	if err != nil {
-		t.Logf("Error describing instances: %v", err)
-		return false
-	}
-
-	for _, reservation := range result.Reservations {
-		for _, instance := range reservation.Instances {
-			launchTime := instance.LaunchTime
-			if launchTime != nil && launchTime.After(since) {
-				t.Logf("Found runner instance %s launched at %s (after %s)",
-					*instance.InstanceId, launchTime.Format(time.RFC3339), since.Format(time.RFC3339))
-				return true
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.LaunchTime != nil && instance.LaunchTime.After(since) {
+					instances = append(instances, instance)
+				}
 			}
 		}
 	}
-
-	t.Logf("No runner instances found for stack %s launched after %s", stackName, since.Format(time.RFC3339))
-	return false
+	return instances, nil
 }
@@ -0,0 +1,421 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// =============================================================================
+// IAM PERMISSION BASELINE
+// =============================================================================
+
+// ComputeIAMConfig is the file defining the runner role's policies, relative to the test directory.
+const ComputeIAMConfig = "../modules/compute/iam.tf"
+
+// RunnerRoleBaseline is the approved permission set of the runner EC2 instance role.
+const RunnerRoleBaseline = "testdata/iam/ec2-instance-role.json"
+
+// IAMGrant is one action allowed or denied on one resource, the unit permissions are diffed in.
+// NotAction and NotResource statements keep that prefix on the action or resource.
+type IAMGrant struct {
+	Effect    string
+	Action    string
+	Resource  string
+	Condition string
+}
+
+// String renders the grant as it appears in the baseline, e.g.
+// "Allow ec2:CreateTags on * if StringEquals aws:ARN=${ec2:SourceInstanceARN}".
+func (g IAMGrant) String() string {
+	s := fmt.Sprintf("%s %s on %s", g.Effect, g.Action, g.Resource)
+	if g.Condition != "" {
+		s += " if " + g.Condition
+	}
+	return s
+}
+
+// IAMBaseline is an approved permission set, keyed by inline policy name or managed policy ARN.
+// Stack-specific values in grants are replaced by the placeholders of IAMBaselineVars.
+type IAMBaseline struct {
+	Policies map[string][]string `json:"policies"`
+
+	// Optional maps policies that only exist with a feature enabled to that feature (efs, ecr)
+	Optional map[string]string `json:"optional"`
+
+	// Accepted are reviewed grants that iamGrantRisks flags but the role needs
+	Accepted []IAMAcceptedRisk `json:"accepted"`
+}
+
+// IAMAcceptedRisk accepts the flagged grants of a policy whose action (and resource, if set)
+// match the patterns.
+type IAMAcceptedRisk struct {
+	Policy   string `json:"policy"`
+	Action   string `json:"action"`
+	Resource string `json:"resource,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// LoadIAMBaseline reads a baseline file.
+func LoadIAMBaseline(path string) (IAMBaseline, error) {
+	var baseline IAMBaseline
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return baseline, err
+	}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, fmt.Errorf("%s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// expected returns the baseline policies present with the given features enabled.
+func (b IAMBaseline) expected(features map[string]bool) map[string][]string {
+	policies := map[string][]string{}
+	for policy, grants := range b.Policies {
+		if feature, optional := b.Optional[policy]; !optional || features[feature] {
+			policies[policy] = grants
+		}
+	}
+	return policies
+}
+
+// accepts reports whether a flagged grant of the policy has been reviewed.
+func (b IAMBaseline) accepts(policy string, grant IAMGrant) bool {
+	for _, accepted := range b.Accepted {
+		if accepted.Policy == policy && policyPatternMatch(accepted.Action, grant.Action) &&
+			(accepted.Resource == "" || policyPatternMatch(accepted.Resource, grant.Resource)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IAMFeatures returns the optional features that add policies to the runner role.
+func (c ScenarioConfig) IAMFeatures() map[string]bool {
+	return map[string]bool{"efs": c.EnableEFS, "ecr": c.EnableECR}
+}
+
+// IAMBaselineVars are the stack-specific values replaced by placeholders before diffing.
+type IAMBaselineVars struct {
+	Region            string
+	AccountID         string
+	StackName         string
+	ConfigBucket      string
+	CacheBucket       string
+	EphemeralRegistry string
+}
+
+// normalize replaces stack-specific values with their placeholders, longest value first
+// since bucket and repository names contain the stack name.
+func (v IAMBaselineVars) normalize(s string) string {
+	placeholders := [][2]string{
+		{v.Region, "<region>"},
+		{v.AccountID, "<account_id>"},
+		{v.StackName, "<stack_name>"},
+		{v.ConfigBucket, "<config_bucket>"},
+		{v.CacheBucket, "<cache_bucket>"},
+		{v.EphemeralRegistry, "<ephemeral_registry>"},
+	}
+	sort.SliceStable(placeholders, func(i, j int) bool { return len(placeholders[i][0]) > len(placeholders[j][0]) })
+	for _, p := range placeholders {
+		if p[0] != "" {
+			s = strings.ReplaceAll(s, p[0], p[1])
+		}
+	}
+	return s
+}
+
+// policyGrants expands a policy document into its grants, sorted and deduplicated.
+func policyGrants(policy PolicyDocument, vars IAMBaselineVars) []IAMGrant {
+	seen := map[string]bool{}
+	var grants []IAMGrant
+	for _, statement := range policy.Statement {
+		actions := prefixed("", statement.Action)
+		if len(statement.NotAction) > 0 {
+			actions = prefixed("NotAction ", statement.NotAction)
+		}
+		resources := prefixed("", statement.Resource)
+		if len(statement.NotResource) > 0 {
+			resources = prefixed("NotResource ", statement.NotResource)
+		}
+		condition := vars.normalize(conditionString(statement.Condition))
+		for _, action := range actions {
+			for _, resource := range resources {
+				grant := IAMGrant{Effect: statement.Effect, Action: action, Resource: vars.normalize(resource), Condition: condition}
+				if !seen[grant.String()] {
+					seen[grant.String()] = true
+					grants = append(grants, grant)
+				}
+			}
+		}
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].String() < grants[j].String() })
+	return grants
+}
+
+func prefixed(prefix string, values []string) []string {
+	out := make([]string, len(values))
+	for i, value := range values {
+		out[i] = prefix + value
+	}
+	return out
+}
+
+// conditionString renders a condition block in a stable order, e.g.
+// "StringEquals aws:ResourceTag/runs-on-stack-name=<stack_name>".
+func conditionString(condition map[string]map[string]StringOrSlice) string {
+	var parts []string
+	for operator, keys := range condition {
+		for key, values := range keys {
+			sorted := append([]string{}, values...)
+			sort.Strings(sorted)
+			parts = append(parts, fmt.Sprintf("%s %s=%s", operator, key, strings.Join(sorted, "|")))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " and ")
+}
+
+// readOnlyActionPrefixes are the verbs of actions that don't change anything.
+var readOnlyActionPrefixes = []string{"Describe", "Get", "List", "BatchGet", "BatchCheck", "Head", "Lookup", "Search", "View"}
+
+// isWriteAction reports whether an action may change something. Wildcards are assumed to.
+func isWriteAction(action string) bool {
+	_, name, ok := strings.Cut(action, ":")
+	if !ok || strings.ContainsAny(name, "*?") {
+		return true
+	}
+	for _, prefix := range readOnlyActionPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// iamGrantRisks flags an Allow grant of every action, of an IAM wildcard, of sts:AssumeRole,
+// or of a write action on every resource without a condition narrowing it.
+func iamGrantRisks(grant IAMGrant) []string {
+	if grant.Effect != "Allow" {
+		return nil
+	}
+	var risks []string
+	action, notAction := strings.CutPrefix(grant.Action, "NotAction ")
+	switch {
+	case notAction:
+		risks = append(risks, "allows every action except "+action)
+	case action == "*":
+		risks = append(risks, "allows every action")
+	case strings.HasPrefix(strings.ToLower(action), "iam:") && strings.ContainsAny(action, "*?"):
+		risks = append(risks, "allows the IAM wildcard "+action)
+	case policyPatternMatch(action, "sts:AssumeRole"):
+		risks = append(risks, "allows sts:AssumeRole")
+	}
+	_, notResource := strings.CutPrefix(grant.Resource, "NotResource ")
+	if (grant.Resource == "*" || notResource) && grant.Condition == "" && (notAction || isWriteAction(action)) {
+		risks = append(risks, "allows a write action on every resource")
+	}
+	return risks
+}
+
+// iamBaselineViolations diffs a role's policies against the baseline for the enabled features
+// and flags risky grants the baseline hasn't accepted. Returns one message per violation.
+func iamBaselineViolations(policies map[string]PolicyDocument, baseline IAMBaseline, features map[string]bool, vars IAMBaselineVars) []string {
+	var violations []string
+	expected := baseline.expected(features)
+	for _, name := range sortedKeys(expected) {
+		if _, ok := policies[name]; !ok {
+			violations = append(violations, fmt.Sprintf("baseline policy %s is not on the role", name))
+		}
+	}
+	for _, name := range sortedKeys(policies) {
+		grants := policyGrants(policies[name], vars)
+		approved, inBaseline := expected[name]
+		if !inBaseline {
+			violations = append(violations, fmt.Sprintf("policy %s is not in the baseline", name))
+		}
+		actual := map[string]bool{}
+		for _, grant := range grants {
+			actual[grant.String()] = true
+			if inBaseline && !containsString(approved, grant.String()) {
+				violations = append(violations, fmt.Sprintf("%s: unapproved grant %s", name, grant))
+			}
+			if baseline.accepts(name, grant) {
+				continue
+			}
+			for _, risk := range iamGrantRisks(grant) {
+				violations = append(violations, fmt.Sprintf("%s: %s %s", name, grant, risk))
+			}
+		}
+		for _, grant := range approved {
+			if !actual[grant] {
+				violations = append(violations, fmt.Sprintf("%s: approved grant missing: %s", name, grant))
+			}
+		}
+	}
+	return violations
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containsString reports whether the slice contains s.
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// rolePolicyDocuments fetches every inline policy and the default version of every attached
+// policy of a role, keyed by inline policy name or managed policy ARN.
+func rolePolicyDocuments(ctx context.Context, client *iam.Client, roleName string) (map[string]PolicyDocument, error) {
+	documents := map[string]PolicyDocument{}
+
+	inline := iam.NewListRolePoliciesPaginator(client, &iam.ListRolePoliciesInput{RoleName: aws.String(roleName)})
+	for inline.HasMorePages() {
+		page, err := inline.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list inline policies of %s: %w", roleName, err)
+		}
+		for _, name := range page.PolicyNames {
+			policy, err := client.GetRolePolicy(ctx, &iam.GetRolePolicyInput{RoleName: aws.String(roleName), PolicyName: aws.String(name)})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get inline policy %s: %w", name, err)
+			}
+			if documents[name], err = ParsePolicyDocument(aws.ToString(policy.PolicyDocument)); err != nil {
+				return nil, fmt.Errorf("inline policy %s: %w", name, err)
+			}
+		}
+	}
+
+	attached := iam.NewListAttachedRolePoliciesPaginator(client, &iam.ListAttachedRolePoliciesInput{RoleName: aws.String(roleName)})
+	for attached.HasMorePages() {
+		page, err := attached.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list attached policies of %s: %w", roleName, err)
+		}
+		for _, attachment := range page.AttachedPolicies {
+			arn := aws.ToString(attachment.PolicyArn)
+			if documents[arn], err = managedPolicyDocument(ctx, client, arn); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return documents, nil
+}
+
+// managedPolicyDocument fetches the default version of a managed policy, as AWS currently
+// publishes it for AWS managed policies.
+func managedPolicyDocument(ctx context.Context, client *iam.Client, arn string) (PolicyDocument, error) {
+	policy, err := client.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: aws.String(arn)})
+	if err != nil {
+		return PolicyDocument{}, fmt.Errorf("failed to get policy %s: %w", arn, err)
+	}
+	version, err := client.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{
+		PolicyArn: aws.String(arn),
+		VersionId: policy.Policy.DefaultVersionId,
+	})
+	if err != nil {
+		return PolicyDocument{}, fmt.Errorf("failed to get default version of %s: %w", arn, err)
+	}
+	document, err := ParsePolicyDocument(aws.ToString(version.PolicyVersion.Document))
+	if err != nil {
+		return PolicyDocument{}, fmt.Errorf("policy %s: %w", arn, err)
+	}
+	return document, nil
+}
+
+// renderRunnerRolePolicies renders the inline policies and managed policy attachments of
+// modules/compute/iam.tf. local.log_group_name mirrors modules/compute/main.tf.
+func renderRunnerRolePolicies(configPath string, vars IAMBaselineVars) (map[string]PolicyDocument, []string, error) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"region":                 cty.StringVal(vars.Region),
+				"account_id":             cty.StringVal(vars.AccountID),
+				"stack_name":             cty.StringVal(vars.StackName),
+				"config_bucket_arn":      cty.StringVal(s3BucketArn(vars.ConfigBucket)),
+				"cache_bucket_arn":       cty.StringVal(s3BucketArn(vars.CacheBucket)),
+				"ephemeral_registry_arn": cty.StringVal(fmt.Sprintf("arn:aws:ecr:%s:%s:repository/%s", vars.Region, vars.AccountID, vars.EphemeralRegistry)),
+			}),
+			"local": cty.ObjectVal(map[string]cty.Value{
+				"log_group_name": cty.StringVal(vars.StackName + "/ec2/instances"),
+			}),
+		},
+	}
+	inline, err := renderResourceAttributes(configPath, "aws_iam_role_policy", []string{"name", "policy"}, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	attachments, err := renderResourceAttributes(configPath, "aws_iam_role_policy_attachment", []string{"policy_arn"}, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	documents := map[string]PolicyDocument{}
+	for resource, attributes := range inline {
+		if documents[attributes["name"]], err = ParsePolicyDocument(attributes["policy"]); err != nil {
+			return nil, nil, fmt.Errorf("aws_iam_role_policy.%s: %w", resource, err)
+		}
+	}
+	var managed []string
+	for _, attributes := range attachments {
+		managed = append(managed, attributes["policy_arn"])
+	}
+	sort.Strings(managed)
+	return documents, managed, nil
+}
+
+// ValidateIAMRoleBaseline fetches every inline and attached policy of the role and diffs the
+// effective grants against the baseline. Grants that allow every action, IAM wildcards,
+// sts:AssumeRole, or writes on every resource fail unless the baseline accepts them.
+func ValidateIAMRoleBaseline(t *testing.T, roleName string, baseline IAMBaseline, features map[string]bool, vars IAMBaselineVars) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+
+	policies, err := rolePolicyDocuments(ctx, iam.NewFromConfig(cfg), roleName)
+	require.NoError(t, err, "Failed to fetch the policies of role %s", roleName)
+
+	violations := iamBaselineViolations(policies, baseline, features, vars)
+	for _, violation := range violations {
+		assert.Fail(t, "IAM baseline violation", "%s: %s", roleName, violation)
+	}
+	if len(violations) > 0 {
+		observed := map[string][]string{}
+		for name, policy := range policies {
+			for _, grant := range policyGrants(policy, vars) {
+				observed[name] = append(observed[name], grant.String())
+			}
+		}
+		var data strings.Builder
+		encoder := json.NewEncoder(&data)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(observed)
+		t.Logf("Observed grants of %s, for review before updating %s:\n%s", roleName, RunnerRoleBaseline, data.String())
+		return
+	}
+	t.Logf("✓ IAM role %s matches the approved baseline (%d policies)", roleName, len(policies))
+}
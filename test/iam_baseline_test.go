@@ -0,0 +1,282 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testIAMBaselineVars are the stack values the runner role policies are rendered with offline
+var testIAMBaselineVars = IAMBaselineVars{
+	Region:            "us-east-1",
+	AccountID:         "123456789012",
+	StackName:         "tt-a1b2c3",
+	ConfigBucket:      "tt-a1b2c3-config-20250206",
+	CacheBucket:       "tt-a1b2c3-cache-20250206",
+	EphemeralRegistry: "tt-a1b2c3-ephemeral-registry",
+}
+
+// awsManagedPolicies are the default versions of the AWS managed policies the module attaches,
+// for the offline tests only; the live checks fetch them with iam:GetPolicyVersion. Copied from
+// the documents AWS published in October 2026, so they may lag behind later updates.
+var awsManagedPolicies = map[string]string{
+	"arn:aws:iam::aws:policy/service-role/AWSAppRunnerServicePolicyForECRAccess": `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "ecr:GetDownloadUrlForLayer", "ecr:BatchGetImage", "ecr:DescribeImages",
+        "ecr:GetAuthorizationToken", "ecr:BatchCheckLayerAvailability"
+      ],
+      "Resource": "*"
+    }
+  ]
+}`,
+	"arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore": `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "ssm:DescribeAssociation", "ssm:GetDeployablePatchSnapshotForInstance", "ssm:GetDocument",
+        "ssm:DescribeDocument", "ssm:GetManifest", "ssm:GetParameter", "ssm:GetParameters",
+        "ssm:ListAssociations", "ssm:ListInstanceAssociations", "ssm:PutInventory",
+        "ssm:PutComplianceItems", "ssm:PutConfigurePackageResult", "ssm:UpdateAssociationStatus",
+        "ssm:UpdateInstanceAssociationStatus", "ssm:UpdateInstanceInformation"
+      ],
+      "Resource": "*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ssmmessages:CreateControlChannel", "ssmmessages:CreateDataChannel", "ssmmessages:OpenControlChannel", "ssmmessages:OpenDataChannel"],
+      "Resource": "*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ec2messages:AcknowledgeMessage", "ec2messages:DeleteMessage", "ec2messages:FailMessage", "ec2messages:GetEndpoint", "ec2messages:GetMessages", "ec2messages:SendReply"],
+      "Resource": "*"
+    }
+  ]
+}`,
+	"arn:aws:iam::aws:policy/AmazonElasticContainerRegistryPublicFullAccess": `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Action": ["ecr-public:*", "sts:GetServiceBearerToken"], "Resource": "*"}
+  ]
+}`,
+}
+
+// checkedInManagedPolicy returns the checked-in copy of an AWS managed policy.
+func checkedInManagedPolicy(arn string) (PolicyDocument, error) {
+	document, ok := awsManagedPolicies[arn]
+	if !ok {
+		return PolicyDocument{}, fmt.Errorf("no document for managed policy %q", arn)
+	}
+	return ParsePolicyDocument(document)
+}
+
+// runnerRolePolicies renders the runner role as the module would create it with every feature enabled.
+func runnerRolePolicies(t *testing.T) map[string]PolicyDocument {
+	policies, managed, err := renderRunnerRolePolicies(ComputeIAMConfig, testIAMBaselineVars)
+	require.NoError(t, err)
+	for _, arn := range managed {
+		policies[arn], err = checkedInManagedPolicy(arn)
+		require.NoError(t, err)
+	}
+	return policies
+}
+
+func TestRunnerRoleBaseline(t *testing.T) {
+	baseline, err := LoadIAMBaseline(RunnerRoleBaseline)
+	require.NoError(t, err)
+	for _, accepted := range baseline.Accepted {
+		assert.Contains(t, baseline.Policies, accepted.Policy, "accepted risk for unknown policy")
+		assert.NotEmpty(t, accepted.Reason, "accepted risk %s on %s needs a reason", accepted.Action, accepted.Policy)
+	}
+
+	policies := runnerRolePolicies(t)
+	all := map[string]bool{"efs": true, "ecr": true}
+	assert.Empty(t, iamBaselineViolations(policies, baseline, all, testIAMBaselineVars))
+
+	delete(policies, "EfsMountAccess")
+	delete(policies, "EphemeralRegistryAccess")
+	assert.Empty(t, iamBaselineViolations(policies, baseline, map[string]bool{}, testIAMBaselineVars))
+}
+
+func TestIAMBaselineViolations(t *testing.T) {
+	baseline, err := LoadIAMBaseline(RunnerRoleBaseline)
+	require.NoError(t, err)
+	all := map[string]bool{"efs": true, "ecr": true}
+
+	addStatement := func(policies map[string]PolicyDocument, name string, statement PolicyStatement) {
+		policy := policies[name]
+		policy.Statement = append(policy.Statement, statement)
+		policies[name] = policy
+	}
+
+	testCases := []struct {
+		name     string
+		mutate   func(policies map[string]PolicyDocument)
+		features map[string]bool
+		contains []string
+	}{
+		{
+			name: "PolicyAdded",
+			mutate: func(policies map[string]PolicyDocument) {
+				policies["arn:aws:iam::aws:policy/AdministratorAccess"] = PolicyDocument{Statement: []PolicyStatement{
+					{Effect: "Allow", Action: StringOrSlice{"*"}, Resource: StringOrSlice{"*"}},
+				}}
+			},
+			contains: []string{
+				"policy arn:aws:iam::aws:policy/AdministratorAccess is not in the baseline",
+				"AdministratorAccess: Allow * on * allows every action",
+				"AdministratorAccess: Allow * on * allows a write action on every resource",
+			},
+		},
+		{
+			name: "PolicyRemoved",
+			mutate: func(policies map[string]PolicyDocument) {
+				delete(policies, "SendLogs")
+			},
+			contains: []string{"baseline policy SendLogs is not on the role"},
+		},
+		{
+			name: "IAMWildcard",
+			mutate: func(policies map[string]PolicyDocument) {
+				addStatement(policies, "PutMetrics", PolicyStatement{Effect: "Allow", Action: StringOrSlice{"iam:Pass*"},
+					Resource: StringOrSlice{"arn:aws:iam::123456789012:role/tt-a1b2c3-*"}})
+			},
+			contains: []string{
+				"PutMetrics: unapproved grant Allow iam:Pass* on arn:aws:iam::<account_id>:role/<stack_name>-*",
+				"allows the IAM wildcard iam:Pass*",
+			},
+		},
+		{
+			name: "AssumeRole",
+			mutate: func(policies map[string]PolicyDocument) {
+				addStatement(policies, "ReadOnly", PolicyStatement{Effect: "Allow", Action: StringOrSlice{"sts:AssumeRole"},
+					Resource: StringOrSlice{"arn:aws:iam::123456789012:role/deploy"}})
+			},
+			contains: []string{
+				"ReadOnly: unapproved grant Allow sts:AssumeRole on arn:aws:iam::<account_id>:role/deploy",
+				"allows sts:AssumeRole",
+			},
+		},
+		{
+			name: "NotAction",
+			mutate: func(policies map[string]PolicyDocument) {
+				addStatement(policies, "ReadOnly", PolicyStatement{Effect: "Allow", NotAction: StringOrSlice{"iam:*"},
+					Resource: StringOrSlice{"*"}})
+			},
+			contains: []string{
+				"ReadOnly: unapproved grant Allow NotAction iam:* on *",
+				"allows every action except iam:*",
+				"allows a write action on every resource",
+			},
+		},
+		{
+			name: "ConditionRemoved",
+			mutate: func(policies map[string]PolicyDocument) {
+				policy := policies["CreateTags"]
+				policy.Statement = append([]PolicyStatement{}, policy.Statement...)
+				policy.Statement[0].Condition = nil
+				policies["CreateTags"] = policy
+			},
+			contains: []string{
+				"CreateTags: unapproved grant Allow ec2:CreateTags on *",
+				"CreateTags: Allow ec2:CreateTags on * allows a write action on every resource",
+				"CreateTags: approved grant missing: Allow ec2:CreateTags on * if StringEquals aws:ARN=${ec2:SourceInstanceARN}",
+			},
+		},
+		{
+			name: "ResourceWidened",
+			mutate: func(policies map[string]PolicyDocument) {
+				addStatement(policies, "SendLogs", PolicyStatement{Effect: "Allow", Action: StringOrSlice{"logs:DeleteLogGroup"},
+					Resource: StringOrSlice{"*"}})
+			},
+			contains: []string{
+				"SendLogs: unapproved grant Allow logs:DeleteLogGroup on *",
+				"allows a write action on every resource",
+			},
+		},
+		{
+			name: "ReadOnlyOnEveryResource",
+			mutate: func(policies map[string]PolicyDocument) {
+				addStatement(policies, "ReadOnly", PolicyStatement{Effect: "Allow", Action: StringOrSlice{"ec2:DescribeVpcs"},
+					Resource: StringOrSlice{"*"}})
+			},
+			contains: []string{"ReadOnly: unapproved grant Allow ec2:DescribeVpcs on *"},
+		},
+		{
+			name: "UnacceptedRiskInAcceptedPolicy",
+			mutate: func(policies map[string]PolicyDocument) {
+				addStatement(policies, "EfsMountAccess", PolicyStatement{Effect: "Allow", Action: StringOrSlice{"elasticfilesystem:DeleteFileSystem"},
+					Resource: StringOrSlice{"*"}})
+			},
+			contains: []string{
+				"EfsMountAccess: unapproved grant Allow elasticfilesystem:DeleteFileSystem on *",
+				"allows a write action on every resource",
+			},
+		},
+		{
+			name:     "OptionalPolicyWithFeatureDisabled",
+			mutate:   func(policies map[string]PolicyDocument) {},
+			features: map[string]bool{"efs": true},
+			contains: []string{"policy EphemeralRegistryAccess is not in the baseline"},
+		},
+		{
+			name: "OptionalPolicyMissing",
+			mutate: func(policies map[string]PolicyDocument) {
+				delete(policies, "EfsMountAccess")
+			},
+			contains: []string{"baseline policy EfsMountAccess is not on the role"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policies := runnerRolePolicies(t)
+			tc.mutate(policies)
+			features := tc.features
+			if features == nil {
+				features = all
+			}
+			violations := iamBaselineViolations(policies, baseline, features, testIAMBaselineVars)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			for i, want := range tc.contains {
+				assert.Contains(t, violations[i], want)
+			}
+		})
+	}
+}
+
+func TestPolicyPatternMatch(t *testing.T) {
+	assert.True(t, policyPatternMatch("ssm:*", "ssm:UpdateInstanceInformation"))
+	assert.True(t, policyPatternMatch("sts:Assume*", "sts:AssumeRole"))
+	assert.True(t, policyPatternMatch("STS:ASSUMEROLE", "sts:AssumeRole"))
+	assert.True(t, policyPatternMatch("s3:?etObject", "s3:GetObject"))
+	assert.True(t, policyPatternMatch("*", "*"))
+	assert.False(t, policyPatternMatch("sts:AssumeRole", "sts:AssumeRoleWithSAML"))
+	assert.False(t, policyPatternMatch("s3:Get.bject", "s3:GetObject"))
+	assert.False(t, policyPatternMatch("ssm:*", "ssmmessages:OpenDataChannel"))
+}
+
+func TestIsWriteAction(t *testing.T) {
+	for action, write := range map[string]bool{
+		"ec2:DescribeInstances":           false,
+		"s3:GetObject":                    false,
+		"s3:ListBucket":                   false,
+		"ecr:BatchGetImage":               false,
+		"ecr:BatchCheckLayerAvailability": false,
+		"s3:PutObject":                    true,
+		"ec2:CreateTags":                  true,
+		"logs:DeleteLogGroup":             true,
+		"ec2:Describe*":                   true,
+		"*":                               true,
+	} {
+		assert.Equal(t, write, isWriteAction(action), action)
+	}
+}
@@ -0,0 +1,230 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// =============================================================================
+// OS-AWARE INSTANCE HELPERS
+// =============================================================================
+
+// InstanceOS identifies the operating system of a test instance, matching the
+// linux/windows split of the module's launch templates.
+type InstanceOS string
+
+const (
+	OSLinux   InstanceOS = "linux"
+	OSWindows InstanceOS = "windows"
+)
+
+// ec2ImagesAPI is the subset of the EC2 client used to resolve AMIs.
+type ec2ImagesAPI interface {
+	DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
+}
+
+// ssmCommandAPI is the subset of the SSM client used to run commands on instances.
+type ssmCommandAPI interface {
+	SendCommand(ctx context.Context, params *ssm.SendCommandInput, optFns ...func(*ssm.Options)) (*ssm.SendCommandOutput, error)
+	GetCommandInvocation(ctx context.Context, params *ssm.GetCommandInvocationInput, optFns ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error)
+}
+
+// amiNamePattern returns the AMI name filter for the OS.
+// Windows uses Server 2022, the same base as RunsOn's Windows runner images.
+func amiNamePattern(instanceOS InstanceOS) (string, error) {
+	switch instanceOS {
+	case OSLinux:
+		return "al2023-ami-2023*-x86_64", nil
+	case OSWindows:
+		return "Windows_Server-2022-English-Full-Base-*", nil
+	}
+	return "", fmt.Errorf("unsupported instance OS: %q", instanceOS)
+}
+
+// ssmDocumentForOS returns the SSM document that runs commands on the OS.
+func ssmDocumentForOS(instanceOS InstanceOS) (string, error) {
+	switch instanceOS {
+	case OSLinux:
+		return "AWS-RunShellScript", nil
+	case OSWindows:
+		return "AWS-RunPowerShellScript", nil
+	}
+	return "", fmt.Errorf("unsupported instance OS: %q", instanceOS)
+}
+
+// findLatestAMI returns the most recently created Amazon-owned AMI for the OS.
+func findLatestAMI(ctx context.Context, client ec2ImagesAPI, instanceOS InstanceOS) (ec2types.Image, error) {
+	namePattern, err := amiNamePattern(instanceOS)
+	if err != nil {
+		return ec2types.Image{}, err
+	}
+
+	result, err := client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{"amazon"},
+		Filters: []ec2types.Filter{
+			{
+				Name:   aws.String("name"),
+				Values: []string{namePattern},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: []string{"available"},
+			},
+			{
+				Name:   aws.String("architecture"),
+				Values: []string{"x86_64"},
+			},
+		},
+	})
+	if err != nil {
+		return ec2types.Image{}, fmt.Errorf("failed to describe AMIs: %w", err)
+	}
+	if len(result.Images) == 0 {
+		return ec2types.Image{}, fmt.Errorf("no %s AMIs found matching %s", instanceOS, namePattern)
+	}
+
+	// Find the most recent AMI
+	var latestAMI *ec2types.Image
+	for i := range result.Images {
+		img := &result.Images[i]
+		if latestAMI == nil || aws.ToString(img.CreationDate) > aws.ToString(latestAMI.CreationDate) {
+			latestAMI = img
+		}
+	}
+	return *latestAMI, nil
+}
+
+// runSSMCommand sends commands with the OS's SSM document and polls until they finish.
+func runSSMCommand(ctx context.Context, t *testing.T, client ssmCommandAPI, instanceID string, instanceOS InstanceOS,
+	commands []string, pollInterval time.Duration, maxPolls int) (string, string, error) {
+	document, err := ssmDocumentForOS(instanceOS)
+	if err != nil {
+		return "", "", err
+	}
+
+	t.Logf("Running SSM command (%s) on instance %s: %v", document, instanceID, commands)
+
+	sendResult, err := client.SendCommand(ctx, &ssm.SendCommandInput{
+		InstanceIds:  []string{instanceID},
+		DocumentName: aws.String(document),
+		Parameters: map[string][]string{
+			"commands": commands,
+		},
+		TimeoutSeconds: aws.Int32(120),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to send SSM command: %w", err)
+	}
+
+	commandID := *sendResult.Command.CommandId
+	t.Logf("SSM command ID: %s", commandID)
+
+	// Wait for command completion
+	for i := 0; i < maxPolls; i++ {
+		time.Sleep(pollInterval)
+
+		result, err := client.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(instanceID),
+		})
+		if err != nil {
+			// Command may not be ready yet
+			if strings.Contains(err.Error(), "InvocationDoesNotExist") {
+				continue
+			}
+			return "", "", fmt.Errorf("failed to get command invocation: %w", err)
+		}
+
+		status := result.Status
+		t.Logf("SSM command status: %s", status)
+
+		switch status {
+		case ssmtypes.CommandInvocationStatusSuccess:
+			stdout := aws.ToString(result.StandardOutputContent)
+			stderr := aws.ToString(result.StandardErrorContent)
+			return stdout, stderr, nil
+		case ssmtypes.CommandInvocationStatusFailed, ssmtypes.CommandInvocationStatusCancelled, ssmtypes.CommandInvocationStatusTimedOut:
+			stdout := aws.ToString(result.StandardOutputContent)
+			stderr := aws.ToString(result.StandardErrorContent)
+			return stdout, stderr, fmt.Errorf("SSM command %s: %s", status, stderr)
+		}
+	}
+
+	return "", "", fmt.Errorf("SSM command timed out after %v", pollInterval*time.Duration(maxPolls))
+}
+
+// =============================================================================
+// OS-SPECIFIC COMMANDS
+// =============================================================================
+
+// instanceShell builds the commands functional validators run on an instance.
+// Each command prints its result (or the error message) to stdout.
+type instanceShell interface {
+	CallerUserID(region string) string
+	S3Write(bucket, key, content, region string) string
+	S3Read(bucket, key, region string) string
+	Log(tag, message string) string
+}
+
+// shellForOS returns the command builder for the OS.
+func shellForOS(instanceOS InstanceOS) (instanceShell, error) {
+	switch instanceOS {
+	case OSLinux:
+		return linuxShell{}, nil
+	case OSWindows:
+		return windowsShell{}, nil
+	}
+	return nil, fmt.Errorf("unsupported instance OS: %q", instanceOS)
+}
+
+// linuxShell builds bash commands using the AWS CLI preinstalled on Amazon Linux.
+type linuxShell struct{}
+
+func (linuxShell) CallerUserID(region string) string {
+	return "aws sts get-caller-identity --query 'UserId' --output text"
+}
+
+func (linuxShell) S3Write(bucket, key, content, region string) string {
+	return fmt.Sprintf("echo '%s' | aws s3 cp - s3://%s/%s --region %s 2>&1", content, bucket, key, region)
+}
+
+func (linuxShell) S3Read(bucket, key, region string) string {
+	return fmt.Sprintf("aws s3 cp s3://%s/%s - --region %s 2>&1", bucket, key, region)
+}
+
+func (linuxShell) Log(tag, message string) string {
+	return fmt.Sprintf("logger -t %s '%s'", tag, message)
+}
+
+// windowsShell builds PowerShell commands using the AWS Tools for PowerShell preinstalled
+// on Windows Server AMIs. Errors are caught and written to stdout so access-denied checks
+// see the same text they would from the AWS CLI.
+type windowsShell struct{}
+
+func (windowsShell) CallerUserID(region string) string {
+	return fmt.Sprintf("(Get-STSCallerIdentity -Region %s).UserId", region)
+}
+
+func (windowsShell) S3Write(bucket, key, content, region string) string {
+	return fmt.Sprintf("try { Write-S3Object -BucketName '%s' -Key '%s' -Content '%s' -Region %s; Write-Output 'upload: s3://%s/%s' } "+
+		"catch { Write-Output $_.Exception.Message; exit 1 }", bucket, key, content, region, bucket, key)
+}
+
+func (windowsShell) S3Read(bucket, key, region string) string {
+	return fmt.Sprintf("try { $f = New-TemporaryFile; Read-S3Object -BucketName '%s' -Key '%s' -File $f.FullName -Region %s | Out-Null; "+
+		"Get-Content $f.FullName; Remove-Item $f.FullName } catch { Write-Output $_.Exception.Message; exit 1 }", bucket, key, region)
+}
+
+func (windowsShell) Log(tag, message string) string {
+	return fmt.Sprintf("New-EventLog -LogName Application -Source %s -ErrorAction SilentlyContinue; "+
+		"Write-EventLog -LogName Application -Source %s -EntryType Information -EventId 1000 -Message '%s'", tag, tag, message)
+}
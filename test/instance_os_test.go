@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEC2Images returns a fixed image list and records the requested name filter.
+type fakeEC2Images struct {
+	images      []ec2types.Image
+	namePattern string
+}
+
+func (f *fakeEC2Images) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	for _, filter := range params.Filters {
+		if aws.ToString(filter.Name) == "name" {
+			f.namePattern = filter.Values[0]
+		}
+	}
+	return &ec2.DescribeImagesOutput{Images: f.images}, nil
+}
+
+// fakeSSMCommands records the document used and replays a sequence of invocation results.
+type fakeSSMCommands struct {
+	document    string
+	invocations []fakeInvocation
+	polls       int
+}
+
+type fakeInvocation struct {
+	status ssmtypes.CommandInvocationStatus
+	stdout string
+	err    error
+}
+
+func (f *fakeSSMCommands) SendCommand(ctx context.Context, params *ssm.SendCommandInput, optFns ...func(*ssm.Options)) (*ssm.SendCommandOutput, error) {
+	f.document = aws.ToString(params.DocumentName)
+	return &ssm.SendCommandOutput{Command: &ssmtypes.Command{CommandId: aws.String("cmd-123")}}, nil
+}
+
+func (f *fakeSSMCommands) GetCommandInvocation(ctx context.Context, params *ssm.GetCommandInvocationInput, optFns ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error) {
+	inv := f.invocations[f.polls]
+	f.polls++
+	if inv.err != nil {
+		return nil, inv.err
+	}
+	return &ssm.GetCommandInvocationOutput{Status: inv.status, StandardOutputContent: aws.String(inv.stdout)}, nil
+}
+
+func TestFindLatestAMIForOS(t *testing.T) {
+	images := []ec2types.Image{
+		{ImageId: aws.String("ami-old"), Name: aws.String("old"), CreationDate: aws.String("2024-01-01T00:00:00.000Z")},
+		{ImageId: aws.String("ami-new"), Name: aws.String("new"), CreationDate: aws.String("2025-06-01T00:00:00.000Z")},
+		{ImageId: aws.String("ami-mid"), Name: aws.String("mid"), CreationDate: aws.String("2024-09-01T00:00:00.000Z")},
+	}
+
+	for _, tc := range []struct {
+		os      InstanceOS
+		pattern string
+	}{
+		{OSLinux, "al2023-ami-2023*-x86_64"},
+		{OSWindows, "Windows_Server-2022-English-Full-Base-*"},
+	} {
+		t.Run(string(tc.os), func(t *testing.T) {
+			client := &fakeEC2Images{images: images}
+			img, err := findLatestAMI(context.Background(), client, tc.os)
+			require.NoError(t, err)
+			assert.Equal(t, tc.pattern, client.namePattern)
+			assert.Equal(t, "ami-new", aws.ToString(img.ImageId))
+		})
+	}
+
+	_, err := findLatestAMI(context.Background(), &fakeEC2Images{}, OSWindows)
+	assert.Error(t, err, "Empty image list should be an error")
+	_, err = findLatestAMI(context.Background(), &fakeEC2Images{images: images}, InstanceOS("macos"))
+	assert.Error(t, err, "Unknown OS should be an error")
+}
+
+func TestRunSSMCommandForOS(t *testing.T) {
+	for _, tc := range []struct {
+		os       InstanceOS
+		document string
+	}{
+		{OSLinux, "AWS-RunShellScript"},
+		{OSWindows, "AWS-RunPowerShellScript"},
+	} {
+		t.Run(string(tc.os), func(t *testing.T) {
+			client := &fakeSSMCommands{invocations: []fakeInvocation{
+				{err: errors.New("InvocationDoesNotExist")},
+				{status: ssmtypes.CommandInvocationStatusInProgress},
+				{status: ssmtypes.CommandInvocationStatusSuccess, stdout: "ok"},
+			}}
+			stdout, _, err := runSSMCommand(context.Background(), t, client, "i-123", tc.os, []string{"echo ok"}, time.Millisecond, 5)
+			require.NoError(t, err)
+			assert.Equal(t, "ok", stdout)
+			assert.Equal(t, tc.document, client.document)
+			assert.Equal(t, 3, client.polls)
+		})
+	}
+
+	t.Run("FailedReturnsStdout", func(t *testing.T) {
+		client := &fakeSSMCommands{invocations: []fakeInvocation{
+			{status: ssmtypes.CommandInvocationStatusFailed, stdout: "Access Denied"},
+		}}
+		stdout, _, err := runSSMCommand(context.Background(), t, client, "i-123", OSWindows, []string{"x"}, time.Millisecond, 5)
+		assert.Error(t, err)
+		assert.True(t, isAccessDenied(stdout))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		client := &fakeSSMCommands{invocations: []fakeInvocation{
+			{status: ssmtypes.CommandInvocationStatusInProgress},
+			{status: ssmtypes.CommandInvocationStatusInProgress},
+		}}
+		_, _, err := runSSMCommand(context.Background(), t, client, "i-123", OSLinux, []string{"x"}, time.Millisecond, 2)
+		assert.ErrorContains(t, err, "timed out")
+	})
+}
+
+func TestShellForOS(t *testing.T) {
+	linux, err := shellForOS(OSLinux)
+	require.NoError(t, err)
+	assert.Equal(t, "aws s3 cp s3://bucket/cache/key - --region us-east-1 2>&1", linux.S3Read("bucket", "cache/key", "us-east-1"))
+	assert.Contains(t, linux.S3Write("bucket", "cache/key", "content", "us-east-1"), "aws s3 cp - s3://bucket/cache/key")
+
+	windows, err := shellForOS(OSWindows)
+	require.NoError(t, err)
+	assert.Contains(t, windows.CallerUserID("us-east-1"), "Get-STSCallerIdentity")
+	assert.Contains(t, windows.S3Read("bucket", "cache/key", "us-east-1"), "Read-S3Object -BucketName 'bucket' -Key 'cache/key'")
+	assert.Contains(t, windows.S3Write("bucket", "cache/key", "content", "us-east-1"), "Write-S3Object -BucketName 'bucket' -Key 'cache/key' -Content 'content'")
+	assert.Contains(t, windows.Log("terratest", "hello"), "Write-EventLog")
+
+	_, err = shellForOS(InstanceOS("macos"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,489 @@
+package logsinsights
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// tokenKind classifies a lexed token.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokRegex
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lex splits a command's arguments into tokens. A slash always starts a regex literal,
+// since arithmetic is not supported.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'' || r == '`' || r == '/':
+			end := closingQuote(runes, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %c at %q", r, string(runes[i:]))
+			}
+			text := string(runes[i+1 : end])
+			switch r {
+			case '/':
+				tokens = append(tokens, token{tokRegex, text})
+			case '`':
+				tokens = append(tokens, token{tokIdent, text})
+			default:
+				tokens = append(tokens, token{tokString, unescape(text)})
+			}
+			i = end + 1
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			kind := tokNumber
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				kind = tokDuration
+				i++
+			}
+			tokens = append(tokens, token{kind, string(runes[start:i])})
+		case r == '*':
+			tokens = append(tokens, token{tokIdent, "*"})
+			i++
+		case isIdentRune(r, true):
+			start := i
+			for i < len(runes) && isIdentRune(runes[i], false) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i])})
+		default:
+			if i+1 < len(runes) {
+				if two := string(runes[i : i+2]); two == "!=" || two == "<=" || two == ">=" {
+					tokens = append(tokens, token{tokPunct, two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("(),=<>", r) {
+				return nil, fmt.Errorf("unexpected %q", r)
+			}
+			tokens = append(tokens, token{tokPunct, string(r)})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// closingQuote returns the index of the quote closing the one at start, skipping escaped characters.
+func closingQuote(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case runes[start]:
+			return i
+		}
+	}
+	return -1
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\"`, `"`, `\'`, `'`, `\\`, `\`).Replace(s)
+}
+
+func isIdentRune(r rune, first bool) bool {
+	if r == '_' || r == '@' || unicode.IsLetter(r) {
+		return true
+	}
+	return !first && (r == '.' || r == '-' || unicode.IsDigit(r))
+}
+
+// expr is a parsed expression.
+type expr interface {
+	eval(rec record) interface{}
+	String() string
+}
+
+type fieldExpr struct{ name string }
+
+type literalExpr struct{ value interface{} }
+
+type regexExpr struct{ re *regexp.Regexp }
+
+type callExpr struct {
+	name string
+	args []expr
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+type notExpr struct{ operand expr }
+
+func (e fieldExpr) String() string   { return e.name }
+func (e literalExpr) String() string { return formatValue(e.value) }
+func (e regexExpr) String() string   { return "/" + e.re.String() + "/" }
+func (e notExpr) String() string     { return "not " + e.operand.String() }
+func (e binaryExpr) String() string  { return e.left.String() + " " + e.op + " " + e.right.String() }
+func (e callExpr) String() string {
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		args[i] = arg.String()
+	}
+	return e.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// parser is a recursive-descent parser over a command's tokens.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is the (case-insensitive) keyword.
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tokIdent && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// punct consumes the next token if it is the punctuation.
+func (p *parser) punct(text string) bool {
+	if t := p.peek(); t.kind == tokPunct && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) done() bool { return p.peek().kind == tokEOF }
+
+func (p *parser) parseExpr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{"or", left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{"and", left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	negate := false
+	if t := p.peek(); t.kind == tokIdent && strings.EqualFold(t.text, "not") &&
+		p.tokens[p.pos+1].kind == tokIdent && strings.EqualFold(p.tokens[p.pos+1].text, "like") {
+		p.pos++
+		negate = true
+	}
+	if p.keyword("like") {
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if negate {
+			return binaryExpr{"not like", left, right}, nil
+		}
+		return binaryExpr{"like", left, right}, nil
+	}
+	for _, op := range []string{"=", "!=", "<", ">", "<=", ">="} {
+		if p.punct(op) {
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return binaryExpr{op, left, right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literalExpr{t.text}, nil
+	case tokNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return literalExpr{value}, nil
+	case tokDuration:
+		d, err := parseDuration(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return literalExpr{d}, nil
+	case tokRegex:
+		re, err := regexp.Compile(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex /%s/: %w", t.text, err)
+		}
+		return regexExpr{re}, nil
+	case tokIdent:
+		if !p.punct("(") {
+			return fieldExpr{t.text}, nil
+		}
+		call := callExpr{name: strings.ToLower(t.text)}
+		for !p.punct(")") {
+			if len(call.args) > 0 && !p.punct(",") {
+				return nil, fmt.Errorf("expected , or ) in %s()", t.text)
+			}
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		return call, nil
+	case tokPunct:
+		if t.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.punct(")") {
+				return nil, fmt.Errorf("expected )")
+			}
+			return e, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of command")
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// parseDuration parses bin() periods such as 30s, 5m, 1h or 1d.
+func parseDuration(text string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+	}
+	i := strings.IndexFunc(text, unicode.IsLetter)
+	n, err := strconv.Atoi(text[:i])
+	unit, ok := units[text[i:]]
+	if err != nil || !ok {
+		return 0, fmt.Errorf("invalid period %q", text)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+func (e fieldExpr) eval(rec record) interface{} { return rec[e.name] }
+func (e literalExpr) eval(record) interface{}   { return e.value }
+func (e regexExpr) eval(record) interface{}     { return e.re }
+func (e notExpr) eval(rec record) interface{}   { return !truthy(e.operand.eval(rec)) }
+func (e binaryExpr) eval(rec record) interface{} {
+	switch e.op {
+	case "and":
+		return truthy(e.left.eval(rec)) && truthy(e.right.eval(rec))
+	case "or":
+		return truthy(e.left.eval(rec)) || truthy(e.right.eval(rec))
+	}
+	left, right := e.left.eval(rec), e.right.eval(rec)
+	if left == nil || right == nil {
+		return false
+	}
+	if e.op == "like" || e.op == "not like" {
+		s := formatValue(left)
+		matched := strings.Contains(s, formatValue(right))
+		if re, ok := right.(*regexp.Regexp); ok {
+			matched = re.MatchString(s)
+		}
+		return matched == (e.op == "like")
+	}
+	cmp := compare(left, right)
+	switch e.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func (e callExpr) eval(rec record) interface{} {
+	switch e.name {
+	case "ispresent":
+		return len(e.args) == 1 && e.args[0].eval(rec) != nil
+	case "isempty":
+		return len(e.args) == 1 && formatValue(e.args[0].eval(rec)) == ""
+	case "bin":
+		period, ok := e.args[0].eval(rec).(time.Duration)
+		timestamp, isTime := rec["@timestamp"].(time.Time)
+		if len(e.args) != 1 || !ok || !isTime {
+			return nil
+		}
+		return timestamp.Truncate(period)
+	case "tolower":
+		return strings.ToLower(formatValue(e.args[0].eval(rec)))
+	case "toupper":
+		return strings.ToUpper(formatValue(e.args[0].eval(rec)))
+	}
+	return nil
+}
+
+// validateCall rejects functions the evaluator doesn't know outside stats.
+func validateCall(e expr) error {
+	switch e := e.(type) {
+	case callExpr:
+		switch e.name {
+		case "ispresent", "isempty", "bin", "tolower", "toupper":
+		default:
+			return fmt.Errorf("unsupported function %s()", e.name)
+		}
+		for _, arg := range e.args {
+			if err := validateCall(arg); err != nil {
+				return err
+			}
+		}
+	case binaryExpr:
+		if err := validateCall(e.left); err != nil {
+			return err
+		}
+		return validateCall(e.right)
+	case notExpr:
+		return validateCall(e.operand)
+	}
+	return nil
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case nil:
+		return false
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+// compare orders two values numerically when both are numbers (or numeric strings),
+// chronologically for timestamps, and as strings otherwise.
+func compare(a, b interface{}) int {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := a.(time.Time); ok {
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(formatValue(a), formatValue(b))
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// formatValue renders a value the way Logs Insights returns it in results.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(TimestampLayout)
+	case time.Duration:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,234 @@
+// Package logsinsights parses CloudWatch Logs Insights queries and evaluates them locally
+// against recorded log events.
+//
+// Only the subset of the query language used by the runs-on dashboard is supported:
+// SOURCE, fields, display, filter, parse (regex form), stats, sort and limit, with
+// and/or/not, comparisons, like, ispresent(), isempty(), bin() and the count, count_distinct,
+// sum, avg, min, max and pct aggregations. Anything else is a parse error rather than a
+// silently wrong result.
+//
+// Syntax reference: https://docs.aws.amazon.com/AmazonCloudWatch/latest/logs/CWL_QuerySyntax.html
+package logsinsights
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed Logs Insights query.
+type Query struct {
+	// Text is the query as written
+	Text string
+
+	// Sources are the log groups named by a leading SOURCE command, as used by dashboard widgets
+	Sources []string
+
+	// Body is the query without its SOURCE command, as StartQuery expects it alongside the log group names
+	Body string
+
+	commands []command
+}
+
+// String returns the query text.
+func (q Query) String() string {
+	return q.Text
+}
+
+type namedExpr struct {
+	name string
+	expr expr
+}
+
+type sortKey struct {
+	expr expr
+	desc bool
+}
+
+type (
+	fieldsCommand struct{ items []namedExpr }
+	filterCommand struct{ cond expr }
+	parseCommand  struct {
+		source expr
+		re     *regexp.Regexp
+	}
+	statsCommand struct{ aggregations, groups []namedExpr }
+	sortCommand  struct{ keys []sortKey }
+	limitCommand struct{ n int }
+)
+
+// command is one stage of the query pipeline.
+type command interface {
+	apply(state *pipeline) error
+}
+
+// Parse parses a query. Commands are separated by "|"; pipes inside quotes or regexes
+// don't split.
+func Parse(text string) (Query, error) {
+	query := Query{Text: text, Body: text}
+	parts, err := splitCommands(text)
+	if err != nil {
+		return query, err
+	}
+	for i, part := range parts {
+		name, args, _ := strings.Cut(strings.TrimSpace(part), " ")
+		if name == "" {
+			return query, fmt.Errorf("command %d is empty", i+1)
+		}
+		name, args = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(args)
+		tokens, err := lex(args)
+		if err != nil {
+			return query, fmt.Errorf("%s: %w", name, err)
+		}
+		p := &parser{tokens: tokens}
+		if name == "source" {
+			if i != 0 {
+				return query, fmt.Errorf("SOURCE must be the first command")
+			}
+			for !p.done() {
+				t := p.next()
+				if t.kind != tokString {
+					return query, fmt.Errorf("SOURCE: expected a quoted log group name, got %q", t.text)
+				}
+				query.Sources = append(query.Sources, t.text)
+			}
+			query.Body = strings.TrimSpace(strings.Join(parts[1:], "|"))
+			continue
+		}
+		cmd, err := parseCommandArgs(name, p)
+		if err != nil {
+			return query, fmt.Errorf("%s: %w", name, err)
+		}
+		if !p.done() {
+			return query, fmt.Errorf("%s: unexpected %q", name, p.peek().text)
+		}
+		query.commands = append(query.commands, cmd)
+	}
+	return query, nil
+}
+
+// splitCommands splits a query on "|" outside quotes and regex literals, keeping empty commands
+// so that a stray "| |" is reported.
+func splitCommands(text string) ([]string, error) {
+	var parts []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '"', '\'', '`', '/':
+			end := closingQuote(runes, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %c at %q", runes[i], string(runes[i:]))
+			}
+			i = end
+		case '|':
+			parts = append(parts, string(runes[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, string(runes[start:])), nil
+}
+
+func parseCommandArgs(name string, p *parser) (command, error) {
+	switch name {
+	case "fields", "display":
+		items, err := parseNamedList(p, false)
+		return fieldsCommand{items}, err
+	case "filter":
+		cond, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return filterCommand{cond}, validateCall(cond)
+	case "parse":
+		source, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		pattern, ok := p.next(), false
+		if pattern.kind == tokRegex {
+			var re *regexp.Regexp
+			if re, err = regexp.Compile(pattern.text); err != nil {
+				return nil, fmt.Errorf("invalid regex /%s/: %w", pattern.text, err)
+			}
+			for _, group := range re.SubexpNames() {
+				ok = ok || group != ""
+			}
+			if !ok {
+				return nil, fmt.Errorf("/%s/ has no named capture groups", pattern.text)
+			}
+			return parseCommand{source, re}, validateCall(source)
+		}
+		return nil, fmt.Errorf("only the regex form is supported, got %q", pattern.text)
+	case "stats":
+		aggregations, err := parseNamedList(p, true)
+		if err != nil {
+			return nil, err
+		}
+		var groups []namedExpr
+		if p.keyword("by") {
+			if groups, err = parseNamedList(p, false); err != nil {
+				return nil, err
+			}
+		}
+		return statsCommand{aggregations, groups}, nil
+	case "sort":
+		var keys []sortKey
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			key := sortKey{expr: e}
+			if p.keyword("desc") {
+				key.desc = true
+			} else {
+				p.keyword("asc")
+			}
+			keys = append(keys, key)
+			if !p.punct(",") {
+				return sortCommand{keys}, nil
+			}
+		}
+	case "limit":
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil || n <= 0 {
+			return nil, fmt.Errorf("expected a positive count, got %q", t.text)
+		}
+		return limitCommand{n}, nil
+	}
+	return nil, fmt.Errorf("unsupported command")
+}
+
+// parseNamedList parses "expr [as name], ..." as used by fields, stats and stats ... by.
+func parseNamedList(p *parser, aggregations bool) ([]namedExpr, error) {
+	var items []namedExpr
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if aggregations {
+			err = validateAggregation(e)
+		} else {
+			err = validateCall(e)
+		}
+		if err != nil {
+			return nil, err
+		}
+		item := namedExpr{name: e.String(), expr: e}
+		if p.keyword("as") {
+			alias := p.next()
+			if alias.kind != tokIdent {
+				return nil, fmt.Errorf("expected a name after as, got %q", alias.text)
+			}
+			item.name = alias.text
+		}
+		items = append(items, item)
+		if !p.punct(",") {
+			return items, nil
+		}
+	}
+}
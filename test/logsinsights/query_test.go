@@ -0,0 +1,47 @@
+package logsinsights
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSource(t *testing.T) {
+	query, err := Parse("SOURCE '/aws/apprunner/stack/abc/application'\n| filter message like /a|b/\n| limit 5")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/aws/apprunner/stack/abc/application"}, query.Sources)
+	assert.Equal(t, "filter message like /a|b/\n| limit 5", query.Body)
+	assert.Len(t, query.commands, 2, "the pipe inside the regex must not split the command")
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    string
+		contains string
+	}{
+		{"EmptyCommand", "SOURCE 'g'\n| filter a = 1\n| | stats count()", "command 3 is empty"},
+		{"SourceNotFirst", "fields a | SOURCE 'g'", "SOURCE must be the first command"},
+		{"UnquotedSource", "SOURCE g", "expected a quoted log group name"},
+		{"UnknownCommand", "dedup a", "dedup: unsupported command"},
+		{"UnknownAggregation", "stats stddev(a)", "unsupported aggregation stddev()"},
+		{"NotAnAggregation", "stats a by b", "a is not an aggregation"},
+		{"AggregationArity", "stats pct(a)", "wrong number of arguments to pct()"},
+		{"UnknownFunction", "filter strlen(a) > 1", "unsupported function strlen()"},
+		{"GlobParse", `parse @message "* [*]" as a, b`, "only the regex form is supported"},
+		{"UnnamedParse", "parse @message /(\\d+)/", "has no named capture groups"},
+		{"InvalidRegex", "filter a like /(/", "invalid regex"},
+		{"Unterminated", `filter a = "b`, "unterminated"},
+		{"TrailingTokens", "filter a = 1 b", `filter: unexpected "b"`},
+		{"Limit", "limit none", "expected a positive count"},
+		{"Period", "stats count() by bin(5q)", `invalid period "5q"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.query)
+			assert.ErrorContains(t, err, tc.contains)
+		})
+	}
+}
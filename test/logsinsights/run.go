@@ -0,0 +1,315 @@
+package logsinsights
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is how Logs Insights renders @timestamp and bin() values in results.
+const TimestampLayout = "2006-01-02 15:04:05.000"
+
+// Event is one log event, as returned by FilterLogEvents.
+type Event struct {
+	Timestamp time.Time
+	Message   string
+	LogStream string
+	LogGroup  string
+}
+
+// Result is the output of a query: one map per row, keyed by column. Fields without a
+// value in a row are absent, as in GetQueryResults.
+type Result struct {
+	Columns []string
+	Rows    []map[string]string
+}
+
+// BlankColumns returns the columns that have no value in any row. A widget plotting such a
+// column draws nothing even though the query matched events.
+func (r Result) BlankColumns() []string {
+	var blank []string
+	for _, column := range r.Columns {
+		present := false
+		for _, row := range r.Rows {
+			if row[column] != "" {
+				present = true
+				break
+			}
+		}
+		if !present {
+			blank = append(blank, column)
+		}
+	}
+	return blank
+}
+
+// record is an event's fields during evaluation.
+type record map[string]interface{}
+
+// pipeline is the state passed between commands.
+type pipeline struct {
+	records []record
+	columns []string
+}
+
+// newRecord discovers an event's fields. JSON messages are flattened the way Logs Insights
+// does it: nested keys are joined with "." and array elements are addressed by index,
+// e.g. pools.0.dangling.
+func newRecord(event Event) record {
+	rec := record{
+		"@timestamp": event.Timestamp,
+		"@message":   event.Message,
+		"@logStream": event.LogStream,
+		"@log":       event.LogGroup,
+	}
+	var decoded map[string]interface{}
+	if strings.HasPrefix(strings.TrimSpace(event.Message), "{") && json.Unmarshal([]byte(event.Message), &decoded) == nil {
+		flatten(rec, "", decoded)
+	}
+	return rec
+}
+
+func flatten(rec record, prefix string, value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			flatten(rec, prefix+key+".", child)
+		}
+	case []interface{}:
+		for i, child := range value {
+			flatten(rec, prefix+strconv.Itoa(i)+".", child)
+		}
+	default:
+		rec[strings.TrimSuffix(prefix, ".")] = value
+	}
+}
+
+// Run evaluates the query against events. Events are processed in the order given; use
+// sort to order results.
+func (q Query) Run(events []Event) (Result, error) {
+	state := &pipeline{}
+	for _, event := range events {
+		state.records = append(state.records, newRecord(event))
+	}
+	for _, cmd := range q.commands {
+		if err := cmd.apply(state); err != nil {
+			return Result{}, err
+		}
+	}
+
+	columns := state.columns
+	if columns == nil {
+		columns = []string{"@timestamp", "@message"}
+	}
+	result := Result{Columns: columns}
+	for _, rec := range state.records {
+		row := map[string]string{}
+		for _, column := range columns {
+			if value := rec[column]; value != nil {
+				row[column] = formatValue(value)
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, nil
+}
+
+func (c fieldsCommand) apply(state *pipeline) error {
+	for _, rec := range state.records {
+		values := make([]interface{}, len(c.items))
+		for i, item := range c.items {
+			values[i] = item.expr.eval(rec)
+		}
+		for i, item := range c.items {
+			if values[i] != nil {
+				rec[item.name] = values[i]
+			}
+		}
+	}
+	for _, item := range c.items {
+		if !containsString(state.columns, item.name) {
+			state.columns = append(state.columns, item.name)
+		}
+	}
+	return nil
+}
+
+func (c filterCommand) apply(state *pipeline) error {
+	kept := state.records[:0]
+	for _, rec := range state.records {
+		if truthy(c.cond.eval(rec)) {
+			kept = append(kept, rec)
+		}
+	}
+	state.records = kept
+	return nil
+}
+
+func (c parseCommand) apply(state *pipeline) error {
+	for _, rec := range state.records {
+		match := c.re.FindStringSubmatch(formatValue(c.source.eval(rec)))
+		if match == nil {
+			continue
+		}
+		for i, name := range c.re.SubexpNames() {
+			if name != "" {
+				rec[name] = match[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (c statsCommand) apply(state *pipeline) error {
+	type group struct {
+		keys    []interface{}
+		records []record
+	}
+	var groups []*group
+	index := map[string]*group{}
+	for _, rec := range state.records {
+		keys := make([]interface{}, len(c.groups))
+		parts := make([]string, len(c.groups))
+		for i, g := range c.groups {
+			keys[i] = g.expr.eval(rec)
+			parts[i] = formatValue(keys[i])
+		}
+		id := strings.Join(parts, "\x00")
+		if index[id] == nil {
+			index[id] = &group{keys: keys}
+			groups = append(groups, index[id])
+		}
+		index[id].records = append(index[id].records, rec)
+	}
+
+	var records []record
+	for _, g := range groups {
+		out := record{}
+		for i, item := range c.groups {
+			out[item.name] = g.keys[i]
+		}
+		for _, item := range c.aggregations {
+			if value := aggregate(item.expr.(callExpr), g.records); value != nil {
+				out[item.name] = value
+			}
+		}
+		records = append(records, out)
+	}
+	state.records = records
+	state.columns = nil
+	for _, item := range append(append([]namedExpr{}, c.groups...), c.aggregations...) {
+		state.columns = append(state.columns, item.name)
+	}
+	return nil
+}
+
+func (c sortCommand) apply(state *pipeline) error {
+	sort.SliceStable(state.records, func(i, j int) bool {
+		for _, key := range c.keys {
+			cmp := compare(key.expr.eval(state.records[i]), key.expr.eval(state.records[j]))
+			if cmp == 0 {
+				continue
+			}
+			return (cmp < 0) != key.desc
+		}
+		return false
+	})
+	return nil
+}
+
+func (c limitCommand) apply(state *pipeline) error {
+	if len(state.records) > c.n {
+		state.records = state.records[:c.n]
+	}
+	return nil
+}
+
+// aggregations maps each supported stats function to its number of arguments.
+var aggregations = map[string]int{
+	"count": -1, "count_distinct": 1, "sum": 1, "avg": 1, "min": 1, "max": 1, "pct": 2,
+}
+
+func validateAggregation(e expr) error {
+	call, ok := e.(callExpr)
+	if !ok {
+		return fmt.Errorf("%s is not an aggregation", e)
+	}
+	arity, ok := aggregations[call.name]
+	if !ok {
+		return fmt.Errorf("unsupported aggregation %s()", call.name)
+	}
+	if arity >= 0 && len(call.args) != arity || len(call.args) > 1 && arity < 0 {
+		return fmt.Errorf("wrong number of arguments to %s()", call.name)
+	}
+	for _, arg := range call.args {
+		if err := validateCall(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregate computes a stats function over a group. It returns nil when no record has a
+// numeric value, which Logs Insights shows as an empty cell.
+func aggregate(call callExpr, records []record) interface{} {
+	switch call.name {
+	case "count":
+		n := 0
+		for _, rec := range records {
+			if len(call.args) == 0 || call.args[0].String() == "*" || call.args[0].eval(rec) != nil {
+				n++
+			}
+		}
+		return float64(n)
+	case "count_distinct":
+		seen := map[string]bool{}
+		for _, rec := range records {
+			if value := call.args[0].eval(rec); value != nil {
+				seen[formatValue(value)] = true
+			}
+		}
+		return float64(len(seen))
+	}
+
+	var values []float64
+	for _, rec := range records {
+		if value, ok := toNumber(call.args[0].eval(rec)); ok {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	switch call.name {
+	case "min":
+		return values[0]
+	case "max":
+		return values[len(values)-1]
+	case "pct":
+		p, _ := toNumber(call.args[1].eval(nil))
+		rank := int(math.Ceil(p / 100 * float64(len(values))))
+		return values[max(rank-1, 0)]
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	if call.name == "avg" {
+		return sum / float64(len(values))
+	}
+	return sum
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package logsinsights
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 2, 6, 10, 0, 0, 0, time.UTC)
+
+// events spread over two five-minute bins
+var events = []Event{
+	{Timestamp: start.Add(1 * time.Minute), Message: `{"level":"info","metric_type":"snapshot","jobs":{"queued":4},"pools":[{"pool_name":"small","hot":2}]}`},
+	{Timestamp: start.Add(2 * time.Minute), Message: `{"level":"error","message":"failed to launch instance: InsufficientInstanceCapacity"}`},
+	{Timestamp: start.Add(3 * time.Minute), Message: `{"level":"info","metric_type":"snapshot","jobs":{"queued":2},"pools":[{"pool_name":"large","hot":1}]}`},
+	{Timestamp: start.Add(6 * time.Minute), Message: `{"level":"info","metric_type":"snapshot","jobs":{"queued":7},"breaker":{"active":true}}`},
+	{Timestamp: start.Add(7 * time.Minute), Message: "plain text line"},
+}
+
+func run(t *testing.T, text string) Result {
+	t.Helper()
+	query, err := Parse(text)
+	require.NoError(t, err)
+	result, err := query.Run(events)
+	require.NoError(t, err)
+	return result
+}
+
+func TestRunFilter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		filter string
+		rows   int
+	}{
+		{"Equals", `metric_type = "snapshot"`, 3},
+		{"NumericComparison", `jobs.queued >= 4`, 2},
+		{"ArrayIndex", `pools.0.pool_name = "large"`, 1},
+		{"Boolean", `breaker.active = 1`, 1},
+		{"And", `metric_type = "snapshot" and jobs.queued < 5`, 2},
+		{"Or", `level = "error" or @message like "plain"`, 2},
+		{"Not", `not ispresent(metric_type)`, 2},
+		{"LikeRegex", `message like /Insufficient\w+Capacity/`, 1},
+		{"NotLike", `level not like /info/`, 1},
+		{"MissingFieldNeverMatches", `missing != "x"`, 0},
+		{"Parentheses", `(level = "error" or jobs.queued = 7) and not ispresent(pools.0.hot)`, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Len(t, run(t, "filter "+tc.filter).Rows, tc.rows)
+		})
+	}
+}
+
+func TestRunFieldsSortLimit(t *testing.T) {
+	result := run(t, "filter ispresent(jobs.queued)\n| fields @timestamp, jobs.queued as queued\n| sort queued desc\n| limit 2")
+	assert.Equal(t, []string{"@timestamp", "queued"}, result.Columns)
+	assert.Equal(t, []map[string]string{
+		{"@timestamp": "2025-02-06 10:06:00.000", "queued": "7"},
+		{"@timestamp": "2025-02-06 10:01:00.000", "queued": "4"},
+	}, result.Rows)
+}
+
+func TestRunStats(t *testing.T) {
+	result := run(t, `filter metric_type = "snapshot"
+| stats count() as n, max(jobs.queued) as max_queued, avg(jobs.queued) as avg_queued, pct(jobs.queued, 50) as p50 by bin(5m) as t
+| sort t asc`)
+	assert.Equal(t, []string{"t", "n", "max_queued", "avg_queued", "p50"}, result.Columns)
+	assert.Equal(t, []map[string]string{
+		{"t": "2025-02-06 10:00:00.000", "n": "2", "max_queued": "4", "avg_queued": "3", "p50": "2"},
+		{"t": "2025-02-06 10:05:00.000", "n": "1", "max_queued": "7", "avg_queued": "7", "p50": "7"},
+	}, result.Rows)
+	assert.Empty(t, result.BlankColumns())
+}
+
+func TestRunParse(t *testing.T) {
+	result := run(t, `filter ispresent(pools.0.hot)
+| parse @message /"pool_name":"(?<pool_name>[^"]+)","hot":(?<hot>\d+)/
+| stats max(hot) as max_hot by pool_name
+| sort pool_name asc`)
+	assert.Equal(t, []map[string]string{
+		{"pool_name": "large", "max_hot": "1"},
+		{"pool_name": "small", "max_hot": "2"},
+	}, result.Rows)
+}
+
+func TestBlankColumns(t *testing.T) {
+	result := run(t, `filter metric_type = "snapshot" | stats count(*) as n, max(rate_limiters.s3.tokens) as tokens`)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, map[string]string{"n": "3"}, result.Rows[0])
+	assert.Equal(t, []string{"tokens"}, result.BlankColumns(), "a renamed field leaves its column blank")
+
+	assert.Empty(t, run(t, `filter metric_type = "job_event" | stats count()`).Rows)
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// IAM PERMISSION BOUNDARIES
+// =============================================================================
+
+// StackRoleSuffixes are the roles the module can create, each named <stack_name>-<suffix>.
+// apprunner-ecr-access needs app_ecr_repository_url, scheduler-role enable_cost_reports and
+// slack-webhook-role alert_slack_webhook_url.
+var StackRoleSuffixes = []string{
+	"ec2-instance-role",
+	"apprunner-role",
+	"apprunner-ecr-access",
+	"scheduler-role",
+	"slack-webhook-role",
+}
+
+// iamRolesAPI is the subset of the IAM client used to enumerate a stack's roles.
+type iamRolesAPI interface {
+	ListRoles(ctx context.Context, params *iam.ListRolesInput, optFns ...func(*iam.Options)) (*iam.ListRolesOutput, error)
+	GetRole(ctx context.Context, params *iam.GetRoleInput, optFns ...func(*iam.Options)) (*iam.GetRoleOutput, error)
+}
+
+// stackRole is a role tagged with the stack or named like one of its roles.
+type stackRole struct {
+	Name     string
+	Tagged   bool
+	Boundary string
+}
+
+// isStackRoleName reports whether a role name is one the module gives its roles.
+func isStackRoleName(name, stackName string) bool {
+	for _, suffix := range StackRoleSuffixes {
+		if name == stackName+"-"+suffix {
+			return true
+		}
+	}
+	return false
+}
+
+// stackRoles lists the roles named <stackName>-* that are tagged runs-on-stack-name=stackName
+// or named like the stack's. ListRoles returns neither tags nor boundaries, so only the roles
+// with the stack's name prefix are fetched, to stay clear of IAM throttling in shared accounts.
+func stackRoles(ctx context.Context, client iamRolesAPI, stackName string) ([]stackRole, error) {
+	var roles []stackRole
+	paginator := iam.NewListRolesPaginator(client, &iam.ListRolesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list roles: %w", err)
+		}
+		for _, listed := range page.Roles {
+			if !strings.HasPrefix(aws.ToString(listed.RoleName), stackName+"-") {
+				continue
+			}
+			role, err := client.GetRole(ctx, &iam.GetRoleInput{RoleName: listed.RoleName})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get role %s: %w", aws.ToString(listed.RoleName), err)
+			}
+			r := stackRole{Name: aws.ToString(role.Role.RoleName)}
+			for _, tag := range role.Role.Tags {
+				r.Tagged = r.Tagged || (aws.ToString(tag.Key) == "runs-on-stack-name" && aws.ToString(tag.Value) == stackName)
+			}
+			if role.Role.PermissionsBoundary != nil {
+				r.Boundary = aws.ToString(role.Role.PermissionsBoundary.PermissionsBoundaryArn)
+			}
+			if r.Tagged || isStackRoleName(r.Name, stackName) {
+				roles = append(roles, r)
+			}
+		}
+	}
+	return roles, nil
+}
+
+// plannedStackRoles returns the planned aws_iam_role resources as stack roles.
+func plannedStackRoles(plan *terraform.PlanStruct, stackName string) []stackRole {
+	var roles []stackRole
+	for _, resource := range plannedResources(plan, "aws_iam_role") {
+		roles = append(roles, stackRole{
+			Name:     attributeString(resource, "name"),
+			Tagged:   attributeStringMap(resource, "tags")["runs-on-stack-name"] == stackName,
+			Boundary: attributeString(resource, "permissions_boundary"),
+		})
+	}
+	return roles
+}
+
+// permissionBoundaryViolations checks that every stack role is tagged and has the boundary, and
+// that the roles named by the required suffixes exist. Returns one message per violation.
+func permissionBoundaryViolations(roles []stackRole, stackName, boundaryARN string, required []string) []string {
+	var violations []string
+	found := map[string]bool{}
+	for _, role := range roles {
+		found[role.Name] = true
+		if !role.Tagged {
+			violations = append(violations, fmt.Sprintf("role %s is not tagged runs-on-stack-name=%s", role.Name, stackName))
+		}
+		switch role.Boundary {
+		case boundaryARN:
+		case "":
+			violations = append(violations, fmt.Sprintf("role %s has no permissions boundary", role.Name))
+		default:
+			violations = append(violations, fmt.Sprintf("role %s has permissions boundary %s, expected %s", role.Name, role.Boundary, boundaryARN))
+		}
+	}
+	for _, suffix := range required {
+		if name := stackName + "-" + suffix; !found[name] {
+			violations = append(violations, fmt.Sprintf("role %s not found", name))
+		}
+	}
+	return violations
+}
+
+// assertPermissionBoundaries fails the test for each violation.
+func assertPermissionBoundaries(t *testing.T, source string, roles []stackRole, stackName, boundaryARN string, required []string) {
+	violations := permissionBoundaryViolations(roles, stackName, boundaryARN, required)
+	for _, violation := range violations {
+		assert.Fail(t, "Permission boundary violation", "%s: %s", source, violation)
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ %s: all %d roles of %s have permissions boundary %s", source, len(roles), stackName, boundaryARN)
+	}
+}
+
+// ValidatePermissionBoundaries enumerates the IAM roles of the stack and checks that each has
+// the permissions boundary. required lists the role suffixes the configuration must create.
+func ValidatePermissionBoundaries(t *testing.T, stackName, boundaryARN string, required []string) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+
+	roles, err := stackRoles(ctx, iam.NewFromConfig(cfg), stackName)
+	require.NoError(t, err, "Failed to enumerate the roles of %s", stackName)
+	assertPermissionBoundaries(t, "IAM", roles, stackName, boundaryARN, required)
+}
+
+// ValidatePlannedPermissionBoundaries runs the same checks as ValidatePermissionBoundaries
+// against plan JSON.
+func ValidatePlannedPermissionBoundaries(t *testing.T, plan *terraform.PlanStruct, stackName, boundaryARN string, required []string) {
+	assertPermissionBoundaries(t, "plan", plannedStackRoles(plan, stackName), stackName, boundaryARN, required)
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const testBoundaryARN = "arn:aws:iam::123456789012:policy/test-runs-on-boundary-abc123-20250206"
+
+// fakeIAMRoles serves roles two per ListRoles page and records the GetRole calls.
+type fakeIAMRoles struct {
+	roles []iamtypes.Role
+	got   []string
+}
+
+func (f *fakeIAMRoles) ListRoles(ctx context.Context, params *iam.ListRolesInput, optFns ...func(*iam.Options)) (*iam.ListRolesOutput, error) {
+	start, _ := strconv.Atoi(aws.ToString(params.Marker))
+	out := &iam.ListRolesOutput{}
+	for _, role := range f.roles[start:min(start+2, len(f.roles))] {
+		// ListRoles leaves out tags and boundaries
+		out.Roles = append(out.Roles, iamtypes.Role{RoleName: role.RoleName})
+	}
+	if start+2 < len(f.roles) {
+		out.IsTruncated = true
+		out.Marker = aws.String(strconv.Itoa(start + 2))
+	}
+	return out, nil
+}
+
+func (f *fakeIAMRoles) GetRole(ctx context.Context, params *iam.GetRoleInput, optFns ...func(*iam.Options)) (*iam.GetRoleOutput, error) {
+	f.got = append(f.got, aws.ToString(params.RoleName))
+	for _, role := range f.roles {
+		if aws.ToString(role.RoleName) == aws.ToString(params.RoleName) {
+			return &iam.GetRoleOutput{Role: &role}, nil
+		}
+	}
+	return nil, &iamtypes.NoSuchEntityException{}
+}
+
+func testIAMRole(name, stackTag, boundary string) iamtypes.Role {
+	role := iamtypes.Role{RoleName: aws.String(name)}
+	if stackTag != "" {
+		role.Tags = []iamtypes.Tag{{Key: aws.String("runs-on-stack-name"), Value: aws.String(stackTag)}}
+	}
+	if boundary != "" {
+		role.PermissionsBoundary = &iamtypes.AttachedPermissionsBoundary{PermissionsBoundaryArn: aws.String(boundary)}
+	}
+	return role
+}
+
+func TestStackRoles(t *testing.T) {
+	client := &fakeIAMRoles{roles: []iamtypes.Role{
+		testIAMRole("test-abc123-ec2-instance-role", "test-abc123", testBoundaryARN),
+		testIAMRole("AWSServiceRoleForSupport", "", ""),
+		testIAMRole("test-abc123-apprunner-role", "test-abc123", ""),
+		testIAMRole("renamed-scheduler", "test-abc123", testBoundaryARN),
+		testIAMRole("test-abc123-slack-webhook-role", "", ""),
+		testIAMRole("test-abc123-2-ec2-instance-role", "test-abc123-2", ""),
+		testIAMRole("test-abc123-deploy", "", ""),
+	}}
+
+	roles, err := stackRoles(context.Background(), client, "test-abc123")
+	require.NoError(t, err)
+	assert.Equal(t, []stackRole{
+		{Name: "test-abc123-ec2-instance-role", Tagged: true, Boundary: testBoundaryARN},
+		{Name: "test-abc123-apprunner-role", Tagged: true},
+		{Name: "test-abc123-slack-webhook-role"},
+	}, roles)
+	assert.Equal(t, []string{
+		"test-abc123-ec2-instance-role",
+		"test-abc123-apprunner-role",
+		"test-abc123-slack-webhook-role",
+		"test-abc123-2-ec2-instance-role",
+		"test-abc123-deploy",
+	}, client.got, "only roles with the stack's name prefix should be fetched")
+}
+
+func TestPermissionBoundaryViolations(t *testing.T) {
+	valid := func() []stackRole {
+		return []stackRole{
+			{Name: "stack-ec2-instance-role", Tagged: true, Boundary: testBoundaryARN},
+			{Name: "stack-apprunner-role", Tagged: true, Boundary: testBoundaryARN},
+			{Name: "stack-scheduler-role", Tagged: true, Boundary: testBoundaryARN},
+		}
+	}
+	required := []string{"ec2-instance-role", "apprunner-role", "scheduler-role"}
+
+	testCases := []struct {
+		name     string
+		mutate   func(roles []stackRole) []stackRole
+		contains []string
+	}{
+		{
+			name:   "Valid",
+			mutate: func(roles []stackRole) []stackRole { return roles },
+		},
+		{
+			name: "MissingBoundary",
+			mutate: func(roles []stackRole) []stackRole {
+				roles[1].Boundary = ""
+				return roles
+			},
+			contains: []string{"role stack-apprunner-role has no permissions boundary"},
+		},
+		{
+			name: "OtherBoundary",
+			mutate: func(roles []stackRole) []stackRole {
+				roles[2].Boundary = "arn:aws:iam::123456789012:policy/other"
+				return roles
+			},
+			contains: []string{"role stack-scheduler-role has permissions boundary arn:aws:iam::123456789012:policy/other, expected " + testBoundaryARN},
+		},
+		{
+			name: "UntaggedRole",
+			mutate: func(roles []stackRole) []stackRole {
+				return append(roles, stackRole{Name: "stack-slack-webhook-role"})
+			},
+			contains: []string{
+				"role stack-slack-webhook-role is not tagged runs-on-stack-name=stack",
+				"role stack-slack-webhook-role has no permissions boundary",
+			},
+		},
+		{
+			name: "RequiredRoleMissing",
+			mutate: func(roles []stackRole) []stackRole {
+				return roles[1:]
+			},
+			contains: []string{"role stack-ec2-instance-role not found"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := permissionBoundaryViolations(tc.mutate(valid()), "stack", testBoundaryARN, required)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			for i, want := range tc.contains {
+				assert.Equal(t, want, violations[i])
+			}
+		})
+	}
+}
+
+// boundaryPlanJSON is a trimmed plan with one role in each of the compute and core modules
+const boundaryPlanJSON = `{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "child_modules": [
+        {
+          "address": "module.compute",
+          "resources": [{
+            "address": "module.compute.aws_iam_role.ec2_instance",
+            "mode": "managed", "type": "aws_iam_role", "name": "ec2_instance",
+            "values": {
+              "name": "stack-ec2-instance-role",
+              "permissions_boundary": "` + testBoundaryARN + `",
+              "tags": {"runs-on-stack-name": "stack", "Name": "stack-ec2-instance-role"}
+            }
+          }]
+        },
+        {
+          "address": "module.core",
+          "resources": [{
+            "address": "module.core.aws_iam_role.scheduler[0]",
+            "mode": "managed", "type": "aws_iam_role", "name": "scheduler", "index": 0,
+            "values": {
+              "name": "stack-scheduler-role",
+              "permissions_boundary": null,
+              "tags": {"runs-on-stack-name": "stack", "Name": "stack-scheduler-role"}
+            }
+          }]
+        }
+      ]
+    }
+  }
+}`
+
+func TestPlannedStackRoles(t *testing.T) {
+	plan, err := terraform.ParsePlanJSON(boundaryPlanJSON)
+	require.NoError(t, err)
+
+	roles := plannedStackRoles(plan, "stack")
+	assert.Equal(t, []stackRole{
+		{Name: "stack-ec2-instance-role", Tagged: true, Boundary: testBoundaryARN},
+		{Name: "stack-scheduler-role", Tagged: true},
+	}, roles)
+	assert.Equal(t, []string{"role stack-scheduler-role has no permissions boundary"},
+		permissionBoundaryViolations(roles, "stack", testBoundaryARN, []string{"ec2-instance-role", "scheduler-role"}))
+}
+
+// TestModuleRolesSetPermissionsBoundary renders permissions_boundary of every aws_iam_role in the
+// modules, so a new role that ignores permission_boundary_arn fails without deploying anything.
+func TestModuleRolesSetPermissionsBoundary(t *testing.T) {
+	files, err := filepath.Glob("../modules/*/*.tf")
+	require.NoError(t, err)
+	root, err := filepath.Glob("../*.tf")
+	require.NoError(t, err)
+
+	roles := 0
+	for _, file := range append(files, root...) {
+		rendered, err := renderResourceAttributes(file, "aws_iam_role", []string{"name", "permissions_boundary"}, &hcl.EvalContext{
+			Variables: map[string]cty.Value{
+				"var": cty.ObjectVal(map[string]cty.Value{
+					"stack_name":              cty.StringVal("stack"),
+					"permission_boundary_arn": cty.StringVal(testBoundaryARN),
+				}),
+			},
+		})
+		require.NoError(t, err, "every aws_iam_role should set permissions_boundary")
+		for name, attributes := range rendered {
+			roles++
+			assert.Equal(t, testBoundaryARN, attributes["permissions_boundary"], "%s: aws_iam_role.%s", file, name)
+			assert.True(t, isStackRoleName(attributes["name"], "stack"), "%s: aws_iam_role.%s is named %s, not in StackRoleSuffixes",
+				file, name, attributes["name"])
+		}
+	}
+	assert.Equal(t, len(StackRoleSuffixes), roles)
+}
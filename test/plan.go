@@ -0,0 +1,192 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// =============================================================================
+// PLAN HELPERS
+// =============================================================================
+
+// PlanModule runs init, plan and show for the module and returns the parsed plan JSON.
+// The options are copied so the caller can still apply them without a plan file.
+// Plans need AWS credentials for data sources but create no infrastructure.
+func PlanModule(t *testing.T, options *terraform.Options) *terraform.PlanStruct {
+	planOptions := *options
+	return terraform.InitAndPlanAndShowWithStructNoLogTempPlanFile(t, &planOptions)
+}
+
+// plannedResources returns the planned managed resources of a type, sorted by address.
+// An empty resourceType returns every managed resource.
+func plannedResources(plan *terraform.PlanStruct, resourceType string) []*tfjson.StateResource {
+	var resources []*tfjson.StateResource
+	for _, resource := range plan.ResourcePlannedValuesMap {
+		if resource.Mode != tfjson.ManagedResourceMode {
+			continue
+		}
+		if resourceType != "" && resource.Type != resourceType {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Address < resources[j].Address })
+	return resources
+}
+
+// configuredExpression returns the HCL expression for a resource attribute from the plan's
+// configuration section, or nil when the attribute is not set. This tells an attribute left to
+// its computed default apart from one set explicitly, which planned values cannot.
+func configuredExpression(plan *terraform.PlanStruct, resource *tfjson.StateResource, attribute string) *tfjson.Expression {
+	if plan.RawPlan.Config == nil {
+		return nil
+	}
+	module := plan.RawPlan.Config.RootModule
+	address := stripIndex(resource.Address)
+	for module != nil && strings.HasPrefix(address, "module.") {
+		name, rest, _ := strings.Cut(strings.TrimPrefix(address, "module."), ".")
+		call, ok := module.ModuleCalls[stripIndex(name)]
+		if !ok {
+			return nil
+		}
+		module, address = call.Module, rest
+	}
+	if module == nil {
+		return nil
+	}
+	for _, configured := range module.Resources {
+		if configured.Address == address {
+			return configured.Expressions[attribute]
+		}
+	}
+	return nil
+}
+
+// stripIndex removes a trailing count or for_each index such as [0] or ["a"].
+func stripIndex(address string) string {
+	if strings.HasSuffix(address, "]") {
+		if i := strings.LastIndex(address, "["); i >= 0 {
+			return address[:i]
+		}
+	}
+	return address
+}
+
+// showState returns the module's current state as parsed `show -json` output.
+func showState(t *testing.T, options *terraform.Options) *tfjson.State {
+	var state tfjson.State
+	require.NoError(t, json.Unmarshal([]byte(terraform.Show(t, options)), &state), "Failed to parse state JSON")
+	require.NotNil(t, state.Values, "State has no values")
+	return &state
+}
+
+// terraformStateResources returns every managed resource in the module's state.
+func terraformStateResources(t *testing.T, options *terraform.Options) []*tfjson.StateResource {
+	return stateResources(showState(t, options).Values.RootModule)
+}
+
+// stateResources flattens the managed resources of a state (or plan prior state) module tree.
+func stateResources(module *tfjson.StateModule) []*tfjson.StateResource {
+	if module == nil {
+		return nil
+	}
+	var resources []*tfjson.StateResource
+	for _, resource := range module.Resources {
+		if resource.Mode == tfjson.ManagedResourceMode {
+			resources = append(resources, resource)
+		}
+	}
+	for _, child := range module.ChildModules {
+		resources = append(resources, stateResources(child)...)
+	}
+	return resources
+}
+
+// attributeString returns a string attribute of a planned or state resource.
+func attributeString(resource *tfjson.StateResource, name string) string {
+	value, _ := resource.AttributeValues[name].(string)
+	return value
+}
+
+// attributeStringMap returns a map(string) attribute such as tags of a planned or state resource.
+func attributeStringMap(resource *tfjson.StateResource, name string) map[string]string {
+	raw, _ := resource.AttributeValues[name].(map[string]interface{})
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		}
+	}
+	return values
+}
+
+// attributeBlocks returns a nested block attribute (list of objects) of a planned or state resource.
+func attributeBlocks(resource *tfjson.StateResource, name string) []map[string]interface{} {
+	raw, _ := resource.AttributeValues[name].([]interface{})
+	var blocks []map[string]interface{}
+	for _, item := range raw {
+		if block, ok := item.(map[string]interface{}); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// =============================================================================
+// CONFIGURATION RENDERING
+// =============================================================================
+
+// terraformFunctions are the Terraform functions available when rendering configuration.
+var terraformFunctions = map[string]function.Function{
+	"jsonencode": stdlib.JSONEncodeFunc,
+}
+
+// renderResourceAttributes evaluates string attributes of every resource of a type in a .tf file
+// with the HCL engine, keyed by resource name then attribute. Only the references defined in
+// ctx resolve, so a new dependency in the configuration fails loudly instead of rendering wrong.
+func renderResourceAttributes(configPath, resourceType string, attributes []string, ctx *hcl.EvalContext) (map[string]map[string]string, error) {
+	src, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+	file, diags := hclsyntax.ParseConfig(src, configPath, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse %s: %s", configPath, diags.Error())
+	}
+	if ctx.Functions == nil {
+		ctx.Functions = terraformFunctions
+	}
+
+	rendered := map[string]map[string]string{}
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "resource" || len(block.Labels) != 2 || block.Labels[0] != resourceType {
+			continue
+		}
+		name := block.Labels[1]
+		rendered[name] = map[string]string{}
+		for _, attribute := range attributes {
+			attr, ok := block.Body.Attributes[attribute]
+			if !ok {
+				return nil, fmt.Errorf("%s: %s.%s has no %s", configPath, resourceType, name, attribute)
+			}
+			val, diags := attr.Expr.Value(ctx)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("failed to evaluate %s.%s.%s: %s", resourceType, name, attribute, diags.Error())
+			}
+			rendered[name][attribute] = val.AsString()
+		}
+	}
+	return rendered, nil
+}
@@ -0,0 +1,268 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// POLICY DOCUMENTS
+// =============================================================================
+
+// PolicyDocument is an IAM or resource policy document.
+type PolicyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+// PolicyStatement is a single policy statement. Condition maps operator -> key -> values.
+type PolicyStatement struct {
+	Sid          string                              `json:"Sid,omitempty"`
+	Effect       string                              `json:"Effect"`
+	Principal    PolicyPrincipal                     `json:"Principal,omitempty"`
+	NotPrincipal PolicyPrincipal                     `json:"NotPrincipal,omitempty"`
+	Action       StringOrSlice                       `json:"Action,omitempty"`
+	NotAction    StringOrSlice                       `json:"NotAction,omitempty"`
+	Resource     StringOrSlice                       `json:"Resource,omitempty"`
+	NotResource  StringOrSlice                       `json:"NotResource,omitempty"`
+	Condition    map[string]map[string]StringOrSlice `json:"Condition,omitempty"`
+}
+
+// StringOrSlice accepts both "value" and ["value", ...] as policy documents allow either.
+type StringOrSlice []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *StringOrSlice) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StringOrSlice{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*s = multiple
+	return nil
+}
+
+// Contains reports whether the exact value is present.
+func (s StringOrSlice) Contains(value string) bool {
+	for _, v := range s {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyPrincipal maps a principal type (AWS, Service, Federated) to its values.
+// The bare "*" principal is stored as {"AWS": ["*"]}, which IAM treats the same way.
+type PolicyPrincipal map[string]StringOrSlice
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *PolicyPrincipal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		*p = PolicyPrincipal{"AWS": {wildcard}}
+		return nil
+	}
+	var principals map[string]StringOrSlice
+	if err := json.Unmarshal(data, &principals); err != nil {
+		return err
+	}
+	*p = principals
+	return nil
+}
+
+// IsWildcard reports whether the principal is anyone ("*").
+func (p PolicyPrincipal) IsWildcard() bool {
+	return p["AWS"].Contains("*")
+}
+
+// ConditionValues returns the values of a condition operator and key, or nil.
+func (s PolicyStatement) ConditionValues(operator, key string) StringOrSlice {
+	return s.Condition[operator][key]
+}
+
+// ParsePolicyDocument parses a policy document. IAM APIs return documents URL-encoded,
+// so those are decoded first.
+func ParsePolicyDocument(document string) (PolicyDocument, error) {
+	if !strings.HasPrefix(strings.TrimSpace(document), "{") {
+		decoded, err := url.QueryUnescape(document)
+		if err != nil {
+			return PolicyDocument{}, err
+		}
+		document = decoded
+	}
+	var policy PolicyDocument
+	err := json.Unmarshal([]byte(document), &policy)
+	return policy, err
+}
+
+// policyPatternMatch reports whether value matches a policy pattern, where "*" matches any
+// run of characters and "?" any single character. Matching is case-insensitive, as IAM
+// compares actions that way.
+func policyPatternMatch(pattern, value string) bool {
+	return wildcardMatch(pattern, value, true)
+}
+
+// wildcardMatch matches value against a pattern with "*" and "?" wildcards. ARNs and condition
+// values compare case-sensitively, actions and service names don't.
+func wildcardMatch(pattern, value string, foldCase bool) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+	flags := "(?s)"
+	if foldCase {
+		flags = "(?is)"
+	}
+	return regexp.MustCompile(flags + "^" + expr + "$").MatchString(value)
+}
+
+// =============================================================================
+// POLICY EVALUATION
+// =============================================================================
+
+// PolicyRequest is an API call evaluated against identity policies. Context holds the condition
+// keys of the request, e.g. aws:ResourceTag/runs-on-stack-name, and the values of policy
+// variables such as ${aws:userid}.
+type PolicyRequest struct {
+	Action   string
+	Resource string
+	Context  map[string]string
+}
+
+// PolicyDecision is the outcome of evaluating a request. Statement names the deciding
+// statement as "<policy>[<index>]" (or its Sid), empty for an implicit deny.
+type PolicyDecision struct {
+	Allowed      bool
+	ExplicitDeny bool
+	Statement    string
+}
+
+// EvaluatePolicies evaluates a request against identity policies keyed by name: an explicit
+// Deny wins, otherwise any matching Allow allows. Unsupported condition operators are an error
+// rather than a guess. Permission boundaries, SCPs and resource policies are not considered.
+func EvaluatePolicies(policies map[string]PolicyDocument, request PolicyRequest) (PolicyDecision, error) {
+	var decision PolicyDecision
+	for _, name := range sortedKeys(policies) {
+		for i, statement := range policies[name].Statement {
+			matched, err := statementMatches(statement, request)
+			if err != nil {
+				return PolicyDecision{}, fmt.Errorf("%s[%d]: %w", name, i, err)
+			}
+			if !matched {
+				continue
+			}
+			id := fmt.Sprintf("%s[%d]", name, i)
+			if statement.Sid != "" {
+				id = fmt.Sprintf("%s[%s]", name, statement.Sid)
+			}
+			if statement.Effect == "Deny" {
+				return PolicyDecision{ExplicitDeny: true, Statement: id}, nil
+			}
+			if !decision.Allowed {
+				decision = PolicyDecision{Allowed: true, Statement: id}
+			}
+		}
+	}
+	return decision, nil
+}
+
+// statementMatches reports whether a statement applies to the request.
+func statementMatches(statement PolicyStatement, request PolicyRequest) (bool, error) {
+	actionMatch := func(patterns StringOrSlice) bool {
+		for _, pattern := range patterns {
+			if policyPatternMatch(pattern, request.Action) {
+				return true
+			}
+		}
+		return false
+	}
+	resourceMatch := func(patterns StringOrSlice) bool {
+		for _, pattern := range patterns {
+			if wildcardMatch(substitutePolicyVariables(pattern, request.Context), request.Resource, false) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(statement.NotAction) > 0 {
+		if actionMatch(statement.NotAction) {
+			return false, nil
+		}
+	} else if !actionMatch(statement.Action) {
+		return false, nil
+	}
+	if len(statement.NotResource) > 0 {
+		if resourceMatch(statement.NotResource) {
+			return false, nil
+		}
+	} else if !resourceMatch(statement.Resource) {
+		return false, nil
+	}
+
+	for operator, keys := range statement.Condition {
+		for key, values := range keys {
+			matched, err := conditionMatches(operator, key, values, request.Context)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// conditionMatches evaluates one condition key. Values of a key are ORed; a missing key fails
+// a positive operator and satisfies a negated one, unless the operator ends in IfExists. Null
+// tests presence alone: "true" matches a missing key, "false" a present one.
+func conditionMatches(operator, key string, values StringOrSlice, context map[string]string) (bool, error) {
+	actual, present := context[key]
+	if operator == "Null" {
+		return values.Contains(strconv.FormatBool(!present)), nil
+	}
+	base, ifExists := strings.CutSuffix(operator, "IfExists")
+	if !present {
+		return ifExists || strings.Contains(base, "Not"), nil
+	}
+
+	var match func(value string) bool
+	negated := false
+	switch base {
+	case "StringEquals", "ArnEquals", "Bool":
+		match = func(value string) bool { return actual == value }
+	case "StringNotEquals", "ArnNotEquals":
+		match, negated = func(value string) bool { return actual == value }, true
+	case "StringEqualsIgnoreCase":
+		match = func(value string) bool { return strings.EqualFold(actual, value) }
+	case "StringNotEqualsIgnoreCase":
+		match, negated = func(value string) bool { return strings.EqualFold(actual, value) }, true
+	case "StringLike", "ArnLike":
+		match = func(value string) bool { return wildcardMatch(value, actual, false) }
+	case "StringNotLike", "ArnNotLike":
+		match, negated = func(value string) bool { return wildcardMatch(value, actual, false) }, true
+	default:
+		return false, fmt.Errorf("unsupported condition operator %s", operator)
+	}
+
+	for _, value := range values {
+		if match(substitutePolicyVariables(value, context)) {
+			return !negated, nil
+		}
+	}
+	return negated, nil
+}
+
+// substitutePolicyVariables replaces ${key} with the request's value for key. Unknown
+// variables are left as they are, so they only match themselves.
+func substitutePolicyVariables(s string, context map[string]string) string {
+	for key, value := range context {
+		s = strings.ReplaceAll(s, "${"+key+"}", value)
+	}
+	return s
+}
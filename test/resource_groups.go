@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroups"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// RESOURCE GROUP AND COST ALLOCATION TAG VALIDATORS
+// =============================================================================
+
+// StackTags returns the tags every stack resource should carry: the scenario's default tags
+// except Name, which most resources set themselves, plus runs-on-stack-name and the cost
+// allocation tag.
+func (c ScenarioConfig) StackTags(stackName string) map[string]string {
+	tags := c.DefaultTags()
+	delete(tags, "Name")
+	tags["runs-on-stack-name"] = stackName
+	tags[c.CostAllocationTag] = stackName
+	return tags
+}
+
+// RunnerTags returns the tags every runner instance, volume and ENI should carry: the stack tags
+// and the Name from the provider default_tags, as runners get no Name of their own.
+func (c ScenarioConfig) RunnerTags(stackName string) map[string]string {
+	tags := c.StackTags(stackName)
+	tags["Name"] = c.DefaultTags()["Name"]
+	return tags
+}
+
+// ValidateRunnerTagPropagation launches an instance from a launch template without request-level
+// tags and checks that the launch template's tag_specifications, including the provider
+// default_tags and their Name, reach the instance, its volumes and its ENIs, and that the
+// instance joins the <stack>-ec2-instances resource group.
+func ValidateRunnerTagPropagation(t *testing.T, launchTemplateID, subnetID, stackName string, expectedTags map[string]string) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := ec2.NewFromConfig(cfg)
+
+	instanceID := launchInstanceFromTemplate(t, OSLinux, launchTemplateID, subnetID, true, nil)
+	defer TerminateTestInstance(t, instanceID)
+
+	err := ec2.NewInstanceRunningWaiter(client).Wait(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	}, 5*time.Minute)
+	require.NoError(t, err, "Instance %s did not reach running state", instanceID)
+
+	instances, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}})
+	require.NoError(t, err, "Failed to describe instance %s", instanceID)
+	instance := instances.Reservations[0].Instances[0]
+	assertTags(t, "instance "+instanceID, instance.Tags, expectedTags)
+
+	volumes, err := client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("attachment.instance-id"), Values: []string{instanceID}}},
+	})
+	require.NoError(t, err, "Failed to describe volumes of %s", instanceID)
+	require.NotEmpty(t, volumes.Volumes, "Instance %s has no volumes", instanceID)
+	for _, volume := range volumes.Volumes {
+		assertTags(t, "volume "+aws.ToString(volume.VolumeId), volume.Tags, expectedTags)
+	}
+
+	enis, err := client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("attachment.instance-id"), Values: []string{instanceID}}},
+	})
+	require.NoError(t, err, "Failed to describe network interfaces of %s", instanceID)
+	require.NotEmpty(t, enis.NetworkInterfaces, "Instance %s has no network interfaces", instanceID)
+	for _, eni := range enis.NetworkInterfaces {
+		assertTags(t, "network interface "+aws.ToString(eni.NetworkInterfaceId), eni.TagSet, expectedTags)
+	}
+
+	partition, err := GetAWSPartition(ctx, cfg)
+	require.NoError(t, err)
+	instanceArn := fmt.Sprintf("arn:%s:ec2:%s:%s:instance/%s", partition, GetAWSRegion(), aws.ToString(instances.Reservations[0].OwnerId), instanceID)
+	ValidateResourceGroupContains(t, stackName+"-ec2-instances", instanceArn, 5*time.Minute)
+}
+
+// ValidateResourceGroupContains polls ListGroupResources until the resource appears in the group.
+// Tag-based resource groups are eventually consistent, so newly tagged resources can take a while to show up.
+func ValidateResourceGroupContains(t *testing.T, groupName, resourceArn string, timeout time.Duration) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := resourcegroups.NewFromConfig(cfg)
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		found := false
+		paginator := resourcegroups.NewListGroupResourcesPaginator(client, &resourcegroups.ListGroupResourcesInput{
+			Group: aws.String(groupName),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			require.NoError(t, err, "Failed to list resources of group %s", groupName)
+			for _, item := range page.Resources {
+				if item.Identifier != nil && aws.ToString(item.Identifier.ResourceArn) == resourceArn {
+					found = true
+				}
+			}
+		}
+		if found {
+			t.Logf("✓ %s is a member of resource group %s", resourceArn, groupName)
+			return
+		}
+		t.Logf("%s not yet in resource group %s, waiting...", resourceArn, groupName)
+		time.Sleep(20 * time.Second)
+	}
+
+	assert.Fail(t, fmt.Sprintf("%s did not appear in resource group %s within %v", resourceArn, groupName, timeout))
+}
+
+// assertTags asserts that every expected tag is present with the expected value.
+func assertTags(t *testing.T, resource string, tags []ec2types.Tag, expected map[string]string) {
+	missing := missingTags(tags, expected)
+	assert.Empty(t, missing, "%s is missing tags", resource)
+	if len(missing) == 0 {
+		t.Logf("✓ %s carries all %d expected tags", resource, len(expected))
+	}
+}
+
+// missingTags returns "key=value" for each expected tag absent from tags or set to another value.
+func missingTags(tags []ec2types.Tag, expected map[string]string) []string {
+	actual := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		actual[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return missingTagValues(actual, expected)
+}
+
+// missingTagValues is missingTags for tags already collected into a map.
+func missingTagValues(actual, expected map[string]string) []string {
+	var missing []string
+	for key, value := range expected {
+		if got, ok := actual[key]; !ok || got != value {
+			missing = append(missing, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
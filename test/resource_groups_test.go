@@ -0,0 +1,29 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingTags(t *testing.T) {
+	config := ScenarioConfig{TestID: "123", CostAllocationTag: "stack"}
+	expected := config.RunnerTags("test-123")
+	assert.Equal(t, "test-123", expected["runs-on-stack-name"])
+	assert.Equal(t, "test-123", expected["stack"])
+	assert.Equal(t, "123", expected["TestID"])
+	assert.Equal(t, "terratest-123", expected["Name"])
+	assert.NotContains(t, config.StackTags("test-123"), "Name")
+
+	var tags []ec2types.Tag
+	for key, value := range expected {
+		tags = append(tags, ec2types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	assert.Empty(t, missingTags(tags, expected))
+
+	tags = append(tags[:0:0], ec2types.Tag{Key: aws.String("runs-on-stack-name"), Value: aws.String("other-stack")})
+	missing := missingTags(tags, map[string]string{"runs-on-stack-name": "test-123", "stack": "test-123"})
+	assert.Equal(t, []string{"runs-on-stack-name=test-123", "stack=test-123"}, missing)
+}
@@ -0,0 +1,207 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// RUNNER CLEANUP AND LEAK DETECTION
+// =============================================================================
+
+// RunnerTerminationTimeout is how long a runner may take to terminate after its job completes.
+// The user-data shutdown trap waits 180 seconds before shutting the instance down.
+const RunnerTerminationTimeout = 10 * time.Minute
+
+// runnerCleanupAPI is the subset of the EC2 API used to find leftover runner resources.
+type runnerCleanupAPI interface {
+	ec2.DescribeInstancesAPIClient
+	ec2.DescribeVolumesAPIClient
+	ec2.DescribeNetworkInterfacesAPIClient
+	ec2.DescribeSnapshotsAPIClient
+}
+
+// RunnerLeftover is a runner instance or resource still around when it should be gone.
+type RunnerLeftover struct {
+	Kind    string // "instance", "volume", "network interface" or "snapshot"
+	ID      string
+	State   string
+	Created time.Time // zero for network interfaces, EC2 reports no creation time for them
+}
+
+// describe returns a message naming the leftover and its age at now.
+func (l RunnerLeftover) describe(now time.Time) string {
+	if l.Created.IsZero() {
+		return fmt.Sprintf("%s %s (%s), age unknown", l.Kind, l.ID, l.State)
+	}
+	return fmt.Sprintf("%s %s (%s) is %v old", l.Kind, l.ID, l.State, now.Sub(l.Created).Round(time.Second))
+}
+
+// unterminatedRunners returns the launched instances that have not reached terminated.
+func unterminatedRunners(ctx context.Context, api ec2.DescribeInstancesAPIClient, launches []RunnerLaunch) ([]RunnerLeftover, error) {
+	var ids []string
+	for _, launch := range launches {
+		ids = append(ids, launch.InstanceID)
+	}
+	var leftovers []RunnerLeftover
+	paginator := ec2.NewDescribeInstancesPaginator(api, &ec2.DescribeInstancesInput{InstanceIds: ids})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated {
+					continue
+				}
+				leftovers = append(leftovers, instanceLeftover(instance))
+			}
+		}
+	}
+	return leftovers, nil
+}
+
+// instanceLeftover describes an instance as a leftover.
+func instanceLeftover(instance ec2types.Instance) RunnerLeftover {
+	leftover := RunnerLeftover{Kind: "instance", ID: aws.ToString(instance.InstanceId), Created: aws.ToTime(instance.LaunchTime)}
+	if instance.State != nil {
+		leftover.State = string(instance.State.Name)
+	}
+	return leftover
+}
+
+// stackLeftovers returns the resources tagged with the stack that outlived their runner: instances
+// running and snapshots started longer than maxRuntime ago, and volumes and network interfaces not
+// attached to any instance. Volumes already being deleted and resources of live runners don't
+// count. The age of a detached network interface is unknown, so it is reported however recent.
+func stackLeftovers(ctx context.Context, api runnerCleanupAPI, stackName string, now time.Time, maxRuntime time.Duration) ([]RunnerLeftover, error) {
+	stackFilter := []ec2types.Filter{{Name: aws.String("tag:runs-on-stack-name"), Values: []string{stackName}}}
+	var leftovers []RunnerLeftover
+
+	instances := ec2.NewDescribeInstancesPaginator(api, &ec2.DescribeInstancesInput{Filters: stackFilter})
+	for instances.HasMorePages() {
+		page, err := instances.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated {
+					continue
+				}
+				if now.Sub(aws.ToTime(instance.LaunchTime)) > maxRuntime {
+					leftovers = append(leftovers, instanceLeftover(instance))
+				}
+			}
+		}
+	}
+
+	volumes := ec2.NewDescribeVolumesPaginator(api, &ec2.DescribeVolumesInput{Filters: stackFilter})
+	for volumes.HasMorePages() {
+		page, err := volumes.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe volumes: %w", err)
+		}
+		for _, volume := range page.Volumes {
+			switch volume.State {
+			case ec2types.VolumeStateInUse, ec2types.VolumeStateDeleting, ec2types.VolumeStateDeleted:
+				continue
+			}
+			leftovers = append(leftovers, RunnerLeftover{
+				Kind: "volume", ID: aws.ToString(volume.VolumeId), State: string(volume.State), Created: aws.ToTime(volume.CreateTime),
+			})
+		}
+	}
+
+	enis := ec2.NewDescribeNetworkInterfacesPaginator(api, &ec2.DescribeNetworkInterfacesInput{Filters: stackFilter})
+	for enis.HasMorePages() {
+		page, err := enis.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe network interfaces: %w", err)
+		}
+		for _, eni := range page.NetworkInterfaces {
+			if eni.Status == ec2types.NetworkInterfaceStatusInUse {
+				continue
+			}
+			leftovers = append(leftovers, RunnerLeftover{
+				Kind: "network interface", ID: aws.ToString(eni.NetworkInterfaceId), State: string(eni.Status),
+			})
+		}
+	}
+
+	snapshots := ec2.NewDescribeSnapshotsPaginator(api, &ec2.DescribeSnapshotsInput{OwnerIds: []string{"self"}, Filters: stackFilter})
+	for snapshots.HasMorePages() {
+		page, err := snapshots.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe snapshots: %w", err)
+		}
+		for _, snapshot := range page.Snapshots {
+			// Snapshots of a running job, e.g. of its cache volume, are not leftovers yet
+			if now.Sub(aws.ToTime(snapshot.StartTime)) <= maxRuntime {
+				continue
+			}
+			leftovers = append(leftovers, RunnerLeftover{
+				Kind: "snapshot", ID: aws.ToString(snapshot.SnapshotId), State: string(snapshot.State), Created: aws.ToTime(snapshot.StartTime),
+			})
+		}
+	}
+	return leftovers, nil
+}
+
+// ValidateRunnerCleanup checks that runners don't linger after their jobs. It waits up to timeout
+// for every launched runner to reach terminated, then for the stack to have no instance older
+// than maxRuntime (runner_max_runtime), no snapshot older than maxRuntime and no detached volume
+// or network interface tagged with the stack. Offenders are reported with their age. With no
+// launches it is a leak detector for a long-running stack.
+func ValidateRunnerCleanup(t *testing.T, stackName string, launches []RunnerLaunch, timeout, maxRuntime time.Duration) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := ec2.NewFromConfig(cfg)
+	deadline := time.Now().Add(timeout)
+
+	var pending []RunnerLeftover
+	for len(launches) > 0 {
+		var err error
+		pending, err = unterminatedRunners(ctx, client, launches)
+		require.NoError(t, err, "Failed to check runner instances")
+		if len(pending) == 0 || time.Now().After(deadline) {
+			break
+		}
+		t.Logf("Waiting for %d of %d runners to terminate...", len(pending), len(launches))
+		time.Sleep(15 * time.Second)
+	}
+	for _, leftover := range pending {
+		assert.Fail(t, "Runner not terminated", "%s, not terminated within %v of its job completing", leftover.describe(time.Now()), timeout)
+	}
+	if len(launches) > 0 && len(pending) == 0 {
+		t.Logf("✓ All %d runners terminated", len(launches))
+	}
+
+	// Volumes and network interfaces are deleted shortly after their instance terminates
+	var leftovers []RunnerLeftover
+	for {
+		var err error
+		leftovers, err = stackLeftovers(ctx, client, stackName, time.Now(), maxRuntime)
+		require.NoError(t, err, "Failed to list resources of %s", stackName)
+		if len(leftovers) == 0 || time.Now().After(deadline) {
+			break
+		}
+		t.Logf("%d leftover resources of %s, waiting...", len(leftovers), stackName)
+		time.Sleep(15 * time.Second)
+	}
+	for _, leftover := range leftovers {
+		assert.Fail(t, "Runner resource leaked", leftover.describe(time.Now()))
+	}
+	if len(leftovers) == 0 {
+		t.Logf("✓ No leftover instances, volumes, network interfaces or snapshots tagged with %s", stackName)
+	}
+}
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEC2Leftovers adds tag-filtered volumes, network interfaces and snapshots to fakeEC2Instances.
+type fakeEC2Leftovers struct {
+	fakeEC2Instances
+	volumes   []ec2types.Volume
+	enis      []ec2types.NetworkInterface
+	snapshots []ec2types.Snapshot
+}
+
+func (f *fakeEC2Leftovers) DescribeVolumes(ctx context.Context, params *ec2.DescribeVolumesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error) {
+	out := &ec2.DescribeVolumesOutput{}
+	for _, volume := range f.volumes {
+		if matchesTagFilters(volume.Tags, params.Filters) {
+			out.Volumes = append(out.Volumes, volume)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2Leftovers) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	out := &ec2.DescribeNetworkInterfacesOutput{}
+	for _, eni := range f.enis {
+		if matchesTagFilters(eni.TagSet, params.Filters) {
+			out.NetworkInterfaces = append(out.NetworkInterfaces, eni)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2Leftovers) DescribeSnapshots(ctx context.Context, params *ec2.DescribeSnapshotsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSnapshotsOutput, error) {
+	out := &ec2.DescribeSnapshotsOutput{}
+	for _, snapshot := range f.snapshots {
+		if matchesTagFilters(snapshot.Tags, params.Filters) {
+			out.Snapshots = append(out.Snapshots, snapshot)
+		}
+	}
+	return out, nil
+}
+
+func stackTags(stack string) []ec2types.Tag {
+	return []ec2types.Tag{{Key: aws.String("runs-on-stack-name"), Value: aws.String(stack)}}
+}
+
+func TestUnterminatedRunners(t *testing.T) {
+	running := runnerInstance("i-0000000000000000a", "test-123", "42", "1")
+	terminated := runnerInstance("i-0000000000000000b", "test-123", "42", "2")
+	terminated.State = &ec2types.InstanceState{Name: ec2types.InstanceStateNameTerminated}
+	other := runnerInstance("i-0000000000000000c", "test-123", "43", "9")
+	api := &fakeEC2Instances{instances: []ec2types.Instance{running, terminated, other}}
+
+	leftovers, err := unterminatedRunners(context.Background(), api, []RunnerLaunch{
+		{JobID: 1, InstanceID: "i-0000000000000000a"},
+		{JobID: 2, InstanceID: "i-0000000000000000b"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []RunnerLeftover{
+		{Kind: "instance", ID: "i-0000000000000000a", State: "running", Created: time.Date(2025, 2, 6, 10, 4, 2, 0, time.UTC)},
+	}, leftovers, "instances of other jobs are not waited for")
+}
+
+func TestStackLeftovers(t *testing.T) {
+	now := time.Date(2025, 2, 6, 23, 0, 0, 0, time.UTC)
+	created := aws.Time(time.Date(2025, 2, 6, 10, 0, 0, 0, time.UTC))
+
+	longRunning := runnerInstance("i-0000000000000000a", "test-123", "42", "1")
+	recent := runnerInstance("i-0000000000000000b", "test-123", "42", "2")
+	recent.LaunchTime = aws.Time(now.Add(-time.Hour))
+	terminated := runnerInstance("i-0000000000000000c", "test-123", "42", "3")
+	terminated.State = &ec2types.InstanceState{Name: ec2types.InstanceStateNameTerminated}
+	otherStack := runnerInstance("i-0000000000000000d", "test-456", "42", "4")
+
+	api := &fakeEC2Leftovers{
+		fakeEC2Instances: fakeEC2Instances{instances: []ec2types.Instance{longRunning, recent, terminated, otherStack}},
+		volumes: []ec2types.Volume{
+			{VolumeId: aws.String("vol-0000000000000000a"), State: ec2types.VolumeStateInUse, CreateTime: created, Tags: stackTags("test-123")},
+			{VolumeId: aws.String("vol-0000000000000000b"), State: ec2types.VolumeStateAvailable, CreateTime: created, Tags: stackTags("test-123")},
+			{VolumeId: aws.String("vol-0000000000000000c"), State: ec2types.VolumeStateDeleting, CreateTime: created, Tags: stackTags("test-123")},
+			{VolumeId: aws.String("vol-0000000000000000d"), State: ec2types.VolumeStateAvailable, CreateTime: created, Tags: stackTags("test-456")},
+		},
+		enis: []ec2types.NetworkInterface{
+			{NetworkInterfaceId: aws.String("eni-0000000000000000a"), Status: ec2types.NetworkInterfaceStatusInUse, TagSet: stackTags("test-123")},
+			// Detached, so EC2 reports no attachment and no age
+			{NetworkInterfaceId: aws.String("eni-0000000000000000b"), Status: ec2types.NetworkInterfaceStatusAvailable, TagSet: stackTags("test-123")},
+		},
+		snapshots: []ec2types.Snapshot{
+			{SnapshotId: aws.String("snap-0000000000000000a"), State: ec2types.SnapshotStateCompleted, StartTime: created, Tags: stackTags("test-123")},
+			{SnapshotId: aws.String("snap-0000000000000000b"), State: ec2types.SnapshotStateCompleted, StartTime: created, Tags: stackTags("test-456")},
+			{SnapshotId: aws.String("snap-0000000000000000c"), State: ec2types.SnapshotStatePending, StartTime: aws.Time(now.Add(-time.Hour)), Tags: stackTags("test-123")},
+		},
+	}
+
+	leftovers, err := stackLeftovers(context.Background(), api, "test-123", now, 12*time.Hour)
+	require.NoError(t, err)
+	var messages []string
+	for _, leftover := range leftovers {
+		messages = append(messages, leftover.describe(now))
+	}
+	assert.Equal(t, []string{
+		"instance i-0000000000000000a (running) is 12h55m58s old",
+		"volume vol-0000000000000000b (available) is 13h0m0s old",
+		"network interface eni-0000000000000000b (available), age unknown",
+		"snapshot snap-0000000000000000a (completed) is 13h0m0s old",
+	}, messages)
+}
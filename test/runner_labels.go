@@ -0,0 +1,417 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/google/go-github/v68/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// RUNNER LABEL MATRIX
+// =============================================================================
+
+// RunnerFactsGroup is the log group in which label matrix jobs print what the runner sees of itself.
+const RunnerFactsGroup = "Runner facts"
+
+// RunnerLabelCase is one job of the label matrix: a name and its RunsOn labels, without the
+// runs-on=<run id> and env labels the workflow adds.
+type RunnerLabelCase struct {
+	Name   string
+	Labels string
+}
+
+// Label returns the value of a label, or "" if the case doesn't set it.
+func (c RunnerLabelCase) Label(key string) string {
+	for _, label := range strings.Split(c.Labels, "/") {
+		if k, v, ok := strings.Cut(label, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// RunnerLabelMatrix returns the label combinations to run for a stack: on-demand and spot x64
+// runners, an arm64 runner and a large-disk runner, plus a runner in the subnets private_mode
+// doesn't place runners in by default.
+func RunnerLabelMatrix(config ScenarioConfig) []RunnerLabelCase {
+	cases := []RunnerLabelCase{
+		{Name: "x64-on-demand", Labels: "cpu=2/ram=8/family=m7a+m7i/image=ubuntu24-full-x64/spot=false"},
+		{Name: "x64-spot", Labels: "cpu=4/family=c7a+c7i/image=ubuntu24-full-x64/spot=true"},
+		{Name: "arm64", Labels: "cpu=2/family=m7g+m8g/image=ubuntu24-full-arm64/spot=false"},
+		{Name: "large-disk", Labels: "cpu=2/family=m7a+m7i/image=ubuntu24-full-x64/disk=large/spot=false"},
+	}
+	switch config.PrivateMode {
+	case "true":
+		cases = append(cases, RunnerLabelCase{Name: "private", Labels: "cpu=2/family=m7a+m7i/image=ubuntu24-full-x64/private=true/spot=false"})
+	case "always":
+		cases = append(cases, RunnerLabelCase{Name: "public", Labels: "cpu=2/family=m7a+m7i/image=ubuntu24-full-x64/private=false/spot=false"})
+	}
+	return cases
+}
+
+// RunnerLabelExpectation is where and how the stack should place runners.
+type RunnerLabelExpectation struct {
+	PrivateMode     string
+	PublicSubnets   []string
+	PrivateSubnets  []string
+	DefaultDiskSize int
+	LargeDiskSize   int
+}
+
+// RunnerLabelExpectation returns the expected runner placement for the scenario's module inputs.
+func (c ScenarioConfig) RunnerLabelExpectation(publicSubnets, privateSubnets []string) RunnerLabelExpectation {
+	return RunnerLabelExpectation{
+		PrivateMode:     c.PrivateMode,
+		PublicSubnets:   publicSubnets,
+		PrivateSubnets:  privateSubnets,
+		DefaultDiskSize: c.RunnerDefaultDiskSize,
+		LargeDiskSize:   c.RunnerLargeDiskSize,
+	}
+}
+
+// expectsPrivateSubnet reports whether a job with the given private label lands in a private subnet.
+func (e RunnerLabelExpectation) expectsPrivateSubnet(label string) bool {
+	switch e.PrivateMode {
+	case "only":
+		return true
+	case "always":
+		return label != "false"
+	case "true":
+		return label == "true"
+	default:
+		return false
+	}
+}
+
+// renderLabelMatrixWorkflow returns a workflow that runs one job per case on pushes to branch.
+// Each job prints the instance's identity, subnet, public IP, root volume size and architecture
+// in a "Runner facts" group, read from IMDS and the OS so they survive the instance.
+func renderLabelMatrixWorkflow(branch, environment string, cases []RunnerLabelCase) string {
+	var b strings.Builder
+	b.WriteString("name: RunsOn label matrix\n")
+	b.WriteString("on:\n  push:\n    branches:\n")
+	fmt.Fprintf(&b, "      - %s\n", strconv.Quote(branch))
+	b.WriteString("jobs:\n  runner:\n    name: ${{ matrix.name }}\n")
+	fmt.Fprintf(&b, "    runs-on: runs-on=${{ github.run_id }}/env=%s/${{ matrix.labels }}\n", environment)
+	b.WriteString("    strategy:\n      fail-fast: false\n      matrix:\n        include:\n")
+	for _, c := range cases {
+		fmt.Fprintf(&b, "          - name: %s\n            labels: %s\n", strconv.Quote(c.Name), strconv.Quote(c.Labels))
+	}
+	b.WriteString(`    steps:
+      - name: Runner facts
+        run: |
+          TOKEN=$(curl -sf -X PUT http://169.254.169.254/latest/api/token -H "X-aws-ec2-metadata-token-ttl-seconds: 300")
+          imds() { curl -sf -H "X-aws-ec2-metadata-token: $TOKEN" "http://169.254.169.254/latest/meta-data/$1"; }
+          MAC=$(imds mac)
+          ROOT_DISK=$(lsblk -n -o PKNAME "$(findmnt -n -o SOURCE /)")
+          echo "::group::` + RunnerFactsGroup + `"
+          echo "Instance ID: $(imds instance-id)"
+          echo "Subnet ID: $(imds network/interfaces/macs/$MAC/subnet-id)"
+          echo "Public IPv4: $(imds public-ipv4 || echo none)"
+          echo "Root volume bytes: $(lsblk -b -d -n -o SIZE "/dev/$ROOT_DISK")"
+          echo "Architecture: $(uname -m)"
+          echo "::endgroup::"
+`)
+	return b.String()
+}
+
+// RunnerFacts is what a label matrix job reports about its runner, completed with EC2 data.
+type RunnerFacts struct {
+	InstanceID    string
+	SubnetID      string
+	PublicIP      bool
+	RootVolumeGiB int
+	Architecture  string // as reported by uname -m
+	InstanceType  string
+	Spot          bool
+	VCPUs         int
+	MemoryGiB     float64
+}
+
+// parseRunnerFacts reads the "Runner facts" group of a label matrix job log.
+func parseRunnerFacts(log string) (RunnerFacts, error) {
+	values, found := logGroupValues(log, RunnerFactsGroup)
+	if !found {
+		return RunnerFacts{}, fmt.Errorf("no %q log group", RunnerFactsGroup)
+	}
+	rootBytes, err := strconv.ParseInt(values["root volume bytes"], 10, 64)
+	if err != nil {
+		return RunnerFacts{}, fmt.Errorf("invalid root volume size %q", values["root volume bytes"])
+	}
+	return RunnerFacts{
+		InstanceID:    values["instance id"],
+		SubnetID:      values["subnet id"],
+		PublicIP:      values["public ipv4"] != "" && values["public ipv4"] != "none",
+		RootVolumeGiB: int(rootBytes >> 30),
+		Architecture:  values["architecture"],
+	}, nil
+}
+
+// addInstanceFacts fills in the type, lifecycle and size of the runner's instance.
+func (f *RunnerFacts) addInstanceFacts(instance ec2types.Instance, info ec2types.InstanceTypeInfo) {
+	f.InstanceType = string(instance.InstanceType)
+	f.Spot = instance.InstanceLifecycle == ec2types.InstanceLifecycleTypeSpot
+	if info.VCpuInfo != nil {
+		f.VCPUs = int(aws.ToInt32(info.VCpuInfo.DefaultVCpus))
+	}
+	if info.MemoryInfo != nil {
+		f.MemoryGiB = float64(aws.ToInt64(info.MemoryInfo.SizeInMiB)) / 1024
+	}
+}
+
+// labelRangeContains reports whether value satisfies a RunsOn numeric label, either "n" or a
+// "min+max" range.
+func labelRangeContains(label string, value float64) bool {
+	minimum, maximum, isRange := strings.Cut(label, "+")
+	lo, err := strconv.ParseFloat(minimum, 64)
+	if err != nil {
+		return false
+	}
+	if !isRange {
+		return value == lo
+	}
+	hi, err := strconv.ParseFloat(maximum, 64)
+	return err == nil && value >= lo && value <= hi
+}
+
+// runnerLabelViolations checks that the runner of a label matrix job matches its labels.
+// Returns one message per violation.
+func runnerLabelViolations(c RunnerLabelCase, facts RunnerFacts, expected RunnerLabelExpectation) []string {
+	var violations []string
+	fail := func(format string, args ...any) {
+		violations = append(violations, fmt.Sprintf("job %s: ", c.Name)+fmt.Sprintf(format, args...))
+	}
+
+	if family := c.Label("family"); family != "" {
+		instanceFamily, _, _ := strings.Cut(facts.InstanceType, ".")
+		if !slices.ContainsFunc(strings.Split(family, "+"), func(f string) bool { return strings.HasPrefix(instanceFamily, f) }) {
+			fail("instance type %s is not in family %s", facts.InstanceType, family)
+		}
+	}
+	if cpu := c.Label("cpu"); cpu != "" && !labelRangeContains(cpu, float64(facts.VCPUs)) {
+		fail("instance type %s has %d vCPUs, labels ask for cpu=%s", facts.InstanceType, facts.VCPUs, cpu)
+	}
+	if ram := c.Label("ram"); ram != "" && !labelRangeContains(ram, facts.MemoryGiB) {
+		fail("instance type %s has %g GiB of memory, labels ask for ram=%s", facts.InstanceType, facts.MemoryGiB, ram)
+	}
+	if spot := c.Label("spot"); spot != "" && facts.Spot != (spot != "false") {
+		fail("instance spot=%t, labels ask for spot=%s", facts.Spot, spot)
+	}
+	if image := c.Label("image"); image != "" {
+		architecture := "x86_64"
+		if strings.HasSuffix(image, "-arm64") {
+			architecture = "aarch64"
+		}
+		if facts.Architecture != architecture {
+			fail("runner architecture is %s, image %s is %s", facts.Architecture, image, architecture)
+		}
+	}
+
+	diskSize := expected.DefaultDiskSize
+	if c.Label("disk") == "large" {
+		diskSize = expected.LargeDiskSize
+	}
+	if facts.RootVolumeGiB != diskSize {
+		fail("root volume is %d GiB, expected %d GiB", facts.RootVolumeGiB, diskSize)
+	}
+
+	if expected.expectsPrivateSubnet(c.Label("private")) {
+		if !slices.Contains(expected.PrivateSubnets, facts.SubnetID) {
+			fail("runner is in subnet %s, expected one of the private subnets %v", facts.SubnetID, expected.PrivateSubnets)
+		}
+		if facts.PublicIP {
+			fail("runner in a private subnet has a public IP")
+		}
+	} else {
+		if !slices.Contains(expected.PublicSubnets, facts.SubnetID) {
+			fail("runner is in subnet %s, expected one of the public subnets %v", facts.SubnetID, expected.PublicSubnets)
+		}
+		if !facts.PublicIP {
+			fail("runner in a public subnet has no public IP")
+		}
+	}
+	return violations
+}
+
+// pushWorkflowBranch creates branch from the default branch and commits the workflow to it,
+// which triggers a run. Returns the commit SHA and a function deleting the branch.
+func pushWorkflowBranch(ctx context.Context, client *github.Client, owner, repo, branch, workflowPath, content, message string) (string, func() error, error) {
+	repository, _, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	base, _, err := client.Git.GetRef(ctx, owner, repo, "heads/"+repository.GetDefaultBranch())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get default branch: %w", err)
+	}
+	_, _, err = client.Git.CreateRef(ctx, owner, repo, &github.Reference{
+		Ref:    github.Ptr("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: base.Object.SHA},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+	deleteBranch := func() error {
+		_, err := client.Git.DeleteRef(ctx, owner, repo, "heads/"+branch)
+		return err
+	}
+
+	// Committing a workflow file needs a token with the workflow scope
+	commit, _, err := client.Repositories.CreateFile(ctx, owner, repo, workflowPath, &github.RepositoryContentFileOptions{
+		Message: github.Ptr(message),
+		Content: []byte(content),
+		Branch:  github.Ptr(branch),
+	})
+	if err != nil {
+		return "", deleteBranch, fmt.Errorf("failed to commit %s: %w", workflowPath, err)
+	}
+	return commit.Commit.GetSHA(), deleteBranch, nil
+}
+
+// waitForPushRun polls for the push run of a commit and returns its ID.
+func waitForPushRun(t *testing.T, ctx context.Context, client *github.Client, owner, repo, branch, sha string, timeout time.Duration) (int64, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		runs, _, err := client.Actions.ListRepositoryWorkflowRuns(ctx, owner, repo, &github.ListWorkflowRunsOptions{
+			Branch:  branch,
+			Event:   "push",
+			HeadSHA: sha,
+		})
+		if err != nil {
+			t.Logf("Error listing workflow runs: %v (retrying...)", err)
+		} else if len(runs.WorkflowRuns) > 0 {
+			run := runs.WorkflowRuns[0]
+			t.Logf("Found workflow run %d (%s)", run.GetID(), run.GetHTMLURL())
+			return run.GetID(), nil
+		}
+		time.Sleep(10 * time.Second)
+	}
+	return 0, fmt.Errorf("timeout waiting for the push run of %s on %s", sha, branch)
+}
+
+// describeRunnerInstances returns the instances and the instance type details of the given runners.
+func describeRunnerInstances(ctx context.Context, client *ec2.Client, instanceIDs []string) (map[string]ec2types.Instance, map[ec2types.InstanceType]ec2types.InstanceTypeInfo, error) {
+	instances := map[string]ec2types.Instance{}
+	paginator := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{InstanceIds: instanceIDs})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				instances[aws.ToString(instance.InstanceId)] = instance
+			}
+		}
+	}
+
+	var instanceTypes []ec2types.InstanceType
+	for _, instance := range instances {
+		if !slices.Contains(instanceTypes, instance.InstanceType) {
+			instanceTypes = append(instanceTypes, instance.InstanceType)
+		}
+	}
+	types := map[ec2types.InstanceType]ec2types.InstanceTypeInfo{}
+	if len(instanceTypes) > 0 {
+		result, err := client.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{InstanceTypes: instanceTypes})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to describe instance types: %w", err)
+		}
+		for _, info := range result.InstanceTypes {
+			types[info.InstanceType] = info
+		}
+	}
+	return instances, types, nil
+}
+
+// ValidateRunnerLabelMatrix pushes a workflow running one job per label case to a throwaway
+// branch of repo, waits for the run, and checks that each job's runner was launched by the stack
+// with the instance type, lifecycle, architecture, subnet and root volume size its labels ask
+// for. The branch is deleted afterwards. The GitHub token needs the repo and workflow scopes.
+func ValidateRunnerLabelMatrix(t *testing.T, repo, stackName, environment string, cases []RunnerLabelCase, expected RunnerLabelExpectation) {
+	client, err := getGitHubClient()
+	require.NoError(t, err, "Failed to create GitHub client")
+
+	owner, repoName, err := parseRepo(repo)
+	require.NoError(t, err, "Invalid repo format")
+
+	ctx := context.Background()
+	startTime := time.Now()
+	branch := "terratest/" + stackName + "-labels"
+	workflowPath := path.Join(".github/workflows", stackName+"-labels.yml")
+
+	sha, deleteBranch, err := pushWorkflowBranch(ctx, client, owner, repoName, branch, workflowPath,
+		renderLabelMatrixWorkflow(branch, environment, cases), "Add RunsOn label matrix workflow")
+	if deleteBranch != nil {
+		defer func() {
+			if err := deleteBranch(); err != nil {
+				t.Logf("Warning: failed to delete branch %s: %v", branch, err)
+			}
+		}()
+	}
+	require.NoError(t, err, "Failed to push the label matrix workflow")
+	t.Logf("Pushed label matrix workflow to %s (%s)", branch, sha)
+
+	runID, err := waitForPushRun(t, ctx, client, owner, repoName, branch, sha, 5*time.Minute)
+	require.NoError(t, err, "Label matrix run not found")
+
+	err = MonitorWorkflowJobStates(t, repo, runID, 5*time.Minute)
+	require.NoError(t, err, "Label matrix jobs stuck in queue")
+
+	conclusion := WaitForWorkflowCompletion(t, repo, runID, 20*time.Minute)
+	assert.Equal(t, "success", conclusion, "Label matrix run should succeed")
+
+	jobs := ValidateWorkflowJobRunners(t, repo, runID, stackName, startTime)
+	ValidateRunnerLaunched(t, stackName, runID, RunsOnJobIDs(jobs))
+	byName := map[string]WorkflowJobLog{}
+	var instanceIDs []string
+	for _, job := range jobs {
+		byName[job.Name] = job
+		if job.Runner.InstanceID != "" {
+			instanceIDs = append(instanceIDs, job.Runner.InstanceID)
+		}
+	}
+	require.NotEmpty(t, instanceIDs, "No label matrix job reported a RunsOn runner")
+
+	cfg := MustGetAWSConfig(ctx)
+	instances, instanceTypes, err := describeRunnerInstances(ctx, ec2.NewFromConfig(cfg), instanceIDs)
+	require.NoError(t, err)
+
+	for _, c := range cases {
+		job, ok := byName[c.Name]
+		if !assert.True(t, ok, "Label matrix has no job %s", c.Name) || job.Runner.InstanceID == "" {
+			continue
+		}
+		facts, err := parseRunnerFacts(job.Log)
+		if !assert.NoError(t, err, "Job %s", c.Name) {
+			continue
+		}
+		if facts.InstanceID != job.Runner.InstanceID {
+			assert.Fail(t, "Runner label mismatch", "job %s: IMDS reports instance %s, runner metadata %s", c.Name, facts.InstanceID, job.Runner.InstanceID)
+			continue
+		}
+		instance := instances[facts.InstanceID]
+		facts.addInstanceFacts(instance, instanceTypes[instance.InstanceType])
+
+		violations := runnerLabelViolations(c, facts, expected)
+		for _, violation := range violations {
+			assert.Fail(t, "Runner label mismatch", violation)
+		}
+		if len(violations) == 0 {
+			t.Logf("✓ Job %s (%s) ran on %s %s in %s with a %d GiB root volume",
+				c.Name, c.Labels, facts.InstanceID, facts.InstanceType, facts.SubnetID, facts.RootVolumeGiB)
+		}
+	}
+}
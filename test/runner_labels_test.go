@@ -0,0 +1,217 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRunnerLabelMatrix(t *testing.T) {
+	names := func(cases []RunnerLabelCase) []string {
+		var names []string
+		for _, c := range cases {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+	base := []string{"x64-on-demand", "x64-spot", "arm64", "large-disk"}
+
+	for mode, extra := range map[string][]string{
+		"false":  nil,
+		"true":   {"private"},
+		"always": {"public"},
+		"only":   nil,
+	} {
+		config := DefaultScenarioConfig()
+		config.PrivateMode = mode
+		assert.Equal(t, append(base, extra...), names(RunnerLabelMatrix(config)), "private_mode=%s", mode)
+	}
+
+	c := RunnerLabelMatrix(DefaultScenarioConfig())[3]
+	assert.Equal(t, "large", c.Label("disk"))
+	assert.Equal(t, "m7a+m7i", c.Label("family"))
+	assert.Empty(t, c.Label("private"))
+}
+
+func TestRenderLabelMatrixWorkflow(t *testing.T) {
+	config := DefaultScenarioConfig()
+	config.PrivateMode = "true"
+	cases := RunnerLabelMatrix(config)
+
+	var workflow struct {
+		On struct {
+			Push struct {
+				Branches []string `yaml:"branches"`
+			} `yaml:"push"`
+		} `yaml:"on"`
+		Jobs map[string]struct {
+			Name     string `yaml:"name"`
+			RunsOn   string `yaml:"runs-on"`
+			Strategy struct {
+				Matrix struct {
+					Include []map[string]string `yaml:"include"`
+				} `yaml:"matrix"`
+			} `yaml:"strategy"`
+			Steps []struct {
+				Run string `yaml:"run"`
+			} `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(renderLabelMatrixWorkflow("terratest/test-123-labels", "test", cases)), &workflow))
+
+	assert.Equal(t, []string{"terratest/test-123-labels"}, workflow.On.Push.Branches)
+	job := workflow.Jobs["runner"]
+	assert.Equal(t, "${{ matrix.name }}", job.Name)
+	assert.Equal(t, "runs-on=${{ github.run_id }}/env=test/${{ matrix.labels }}", job.RunsOn)
+	require.Len(t, job.Strategy.Matrix.Include, len(cases))
+	for i, c := range cases {
+		assert.Equal(t, map[string]string{"name": c.Name, "labels": c.Labels}, job.Strategy.Matrix.Include[i])
+	}
+	require.Len(t, job.Steps, 1)
+	assert.Contains(t, job.Steps[0].Run, `echo "::group::Runner facts"`)
+}
+
+func TestParseRunnerFacts(t *testing.T) {
+	facts, err := parseRunnerFacts(readJobLog(t, "job-label-matrix.log"))
+	require.NoError(t, err)
+	assert.Equal(t, RunnerFacts{
+		InstanceID:    "i-0c4d5e6f708192a3b",
+		SubnetID:      "subnet-0b2c3d4e5f6a7b8c9",
+		RootVolumeGiB: 80,
+		Architecture:  "x86_64",
+	}, facts)
+
+	_, err = parseRunnerFacts(readJobLog(t, "job-success.log"))
+	assert.ErrorContains(t, err, `no "Runner facts" log group`)
+}
+
+func TestLabelRangeContains(t *testing.T) {
+	assert.True(t, labelRangeContains("2", 2))
+	assert.False(t, labelRangeContains("2", 4))
+	assert.True(t, labelRangeContains("2+8", 4))
+	assert.False(t, labelRangeContains("2+8", 16))
+	assert.False(t, labelRangeContains("large", 2))
+}
+
+func TestRunnerLabelViolations(t *testing.T) {
+	expected := RunnerLabelExpectation{
+		PrivateMode:     "true",
+		PublicSubnets:   []string{"subnet-0a1b2c3d4e5f6a7b8"},
+		PrivateSubnets:  []string{"subnet-0b2c3d4e5f6a7b8c9"},
+		DefaultDiskSize: 40,
+		LargeDiskSize:   80,
+	}
+	onDemand := RunnerLabelCase{Name: "x64-on-demand", Labels: "cpu=2/ram=8/family=m7a+m7i/image=ubuntu24-full-x64/spot=false"}
+	valid := func() RunnerFacts {
+		return RunnerFacts{
+			InstanceID:    "i-0c4d5e6f708192a3b",
+			SubnetID:      "subnet-0a1b2c3d4e5f6a7b8",
+			PublicIP:      true,
+			RootVolumeGiB: 40,
+			Architecture:  "x86_64",
+			InstanceType:  "m7a.large",
+			VCPUs:         2,
+			MemoryGiB:     8,
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		c        RunnerLabelCase
+		mutate   func(f *RunnerFacts)
+		expected func(e *RunnerLabelExpectation)
+		contains []string
+	}{
+		{
+			name:   "Valid",
+			c:      onDemand,
+			mutate: func(f *RunnerFacts) {},
+		},
+		{
+			name: "WrongInstance",
+			c:    onDemand,
+			mutate: func(f *RunnerFacts) {
+				f.InstanceType, f.VCPUs, f.MemoryGiB, f.Spot = "c7g.xlarge", 4, 8, true
+				f.Architecture = "aarch64"
+			},
+			contains: []string{
+				"job x64-on-demand: instance type c7g.xlarge is not in family m7a+m7i",
+				"job x64-on-demand: instance type c7g.xlarge has 4 vCPUs, labels ask for cpu=2",
+				"job x64-on-demand: instance spot=true, labels ask for spot=false",
+				"job x64-on-demand: runner architecture is aarch64, image ubuntu24-full-x64 is x86_64",
+			},
+		},
+		{
+			name:   "Memory",
+			c:      onDemand,
+			mutate: func(f *RunnerFacts) { f.MemoryGiB = 4 },
+			contains: []string{
+				"job x64-on-demand: instance type m7a.large has 4 GiB of memory, labels ask for ram=8",
+			},
+		},
+		{
+			name:   "Arm64",
+			c:      RunnerLabelCase{Name: "arm64", Labels: "cpu=2/family=m7g+m8g/image=ubuntu24-full-arm64/spot=false"},
+			mutate: func(f *RunnerFacts) { f.InstanceType, f.Architecture = "m8g.large", "aarch64" },
+		},
+		{
+			name:   "LargeDiskGotDefault",
+			c:      RunnerLabelCase{Name: "large-disk", Labels: "cpu=2/family=m7a+m7i/disk=large/spot=false"},
+			mutate: func(f *RunnerFacts) {},
+			contains: []string{
+				"job large-disk: root volume is 40 GiB, expected 80 GiB",
+			},
+		},
+		{
+			name: "PrivateLabel",
+			c:    RunnerLabelCase{Name: "private", Labels: "cpu=2/family=m7a+m7i/private=true/spot=false"},
+			mutate: func(f *RunnerFacts) {
+				f.SubnetID, f.PublicIP = "subnet-0b2c3d4e5f6a7b8c9", false
+			},
+		},
+		{
+			name:   "PrivateLabelInPublicSubnet",
+			c:      RunnerLabelCase{Name: "private", Labels: "cpu=2/family=m7a+m7i/private=true/spot=false"},
+			mutate: func(f *RunnerFacts) {},
+			contains: []string{
+				"job private: runner is in subnet subnet-0a1b2c3d4e5f6a7b8, expected one of the private subnets [subnet-0b2c3d4e5f6a7b8c9]",
+				"job private: runner in a private subnet has a public IP",
+			},
+		},
+		{
+			name:     "PrivateModeOnly",
+			c:        onDemand,
+			mutate:   func(f *RunnerFacts) { f.PublicIP = false },
+			expected: func(e *RunnerLabelExpectation) { e.PrivateMode = "only" },
+			contains: []string{
+				"job x64-on-demand: runner is in subnet subnet-0a1b2c3d4e5f6a7b8, expected one of the private subnets [subnet-0b2c3d4e5f6a7b8c9]",
+			},
+		},
+		{
+			name:   "PublicWithoutIP",
+			c:      onDemand,
+			mutate: func(f *RunnerFacts) { f.PublicIP = false },
+			contains: []string{
+				"job x64-on-demand: runner in a public subnet has no public IP",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			facts := valid()
+			tc.mutate(&facts)
+			e := expected
+			if tc.expected != nil {
+				tc.expected(&e)
+			}
+			violations := runnerLabelViolations(tc.c, facts, e)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			for i, want := range tc.contains {
+				assert.Equal(t, want, violations[i])
+			}
+		})
+	}
+}
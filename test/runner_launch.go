@@ -0,0 +1,170 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// RUNNER LAUNCH CORRELATION
+// =============================================================================
+
+// Tags RunsOn puts on every runner instance it launches for a workflow job.
+const (
+	RunnerRunIDTag = "runs-on-workflow-run-id"
+	RunnerJobIDTag = "runs-on-workflow-job-id"
+)
+
+// RunnerLaunch is the instance the stack launched for one workflow job.
+type RunnerLaunch struct {
+	JobID         int64
+	InstanceID    string
+	InstanceType  string
+	Spot          bool
+	SubnetID      string // empty once a terminated instance's network interface is gone
+	LaunchTime    time.Time
+	TerminateTime time.Time // zero until the instance is terminated
+}
+
+// terminationTimePattern matches the time in a terminated instance's state transition reason,
+// e.g. "User initiated (2025-02-06 10:15:02 GMT)".
+var terminationTimePattern = regexp.MustCompile(`\((\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} GMT)\)`)
+
+// instanceTag returns the value of a tag of an instance, or "" if the instance doesn't have it.
+func instanceTag(instance ec2types.Instance, key string) string {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// newRunnerLaunch describes the runner instance of a job.
+func newRunnerLaunch(jobID int64, instance ec2types.Instance) RunnerLaunch {
+	launch := RunnerLaunch{
+		JobID:        jobID,
+		InstanceID:   aws.ToString(instance.InstanceId),
+		InstanceType: string(instance.InstanceType),
+		Spot:         instance.InstanceLifecycle == ec2types.InstanceLifecycleTypeSpot,
+		SubnetID:     aws.ToString(instance.SubnetId),
+		LaunchTime:   aws.ToTime(instance.LaunchTime),
+	}
+	if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated {
+		if match := terminationTimePattern.FindStringSubmatch(aws.ToString(instance.StateTransitionReason)); match != nil {
+			launch.TerminateTime, _ = time.Parse("2006-01-02 15:04:05 MST", match[1])
+		}
+	}
+	return launch
+}
+
+// runRunnerInstances returns every instance of the stack tagged with the workflow run, in any state.
+func runRunnerInstances(ctx context.Context, client ec2.DescribeInstancesAPIClient, stackName string, runID int64) ([]ec2types.Instance, error) {
+	var instances []ec2types.Instance
+	paginator := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("tag:runs-on-stack-name"), Values: []string{stackName}},
+			{Name: aws.String("tag:" + RunnerRunIDTag), Values: []string{strconv.FormatInt(runID, 10)}},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+	}
+	return instances, nil
+}
+
+// correlateRunnerLaunches matches the instances of a run to its jobs by the job ID tag. Returns
+// one launch per job that has exactly one instance, and one message per job without an instance,
+// job with several instances, and instance of no listed job.
+func correlateRunnerLaunches(instances []ec2types.Instance, jobIDs []int64) ([]RunnerLaunch, []string) {
+	byJob := map[int64][]ec2types.Instance{}
+	var violations []string
+	for _, instance := range instances {
+		instanceID := aws.ToString(instance.InstanceId)
+		jobTag := instanceTag(instance, RunnerJobIDTag)
+		jobID, err := strconv.ParseInt(jobTag, 10, 64)
+		if err != nil || !slices.Contains(jobIDs, jobID) {
+			violations = append(violations, fmt.Sprintf("instance %s (%s=%q) is not the runner of any job of the run", instanceID, RunnerJobIDTag, jobTag))
+			continue
+		}
+		byJob[jobID] = append(byJob[jobID], instance)
+	}
+
+	var launches []RunnerLaunch
+	for _, jobID := range jobIDs {
+		switch matched := byJob[jobID]; len(matched) {
+		case 0:
+			violations = append(violations, fmt.Sprintf("job %d: no runner instance launched", jobID))
+		case 1:
+			launches = append(launches, newRunnerLaunch(jobID, matched[0]))
+		default:
+			var ids []string
+			for _, instance := range matched {
+				ids = append(ids, aws.ToString(instance.InstanceId))
+			}
+			violations = append(violations, fmt.Sprintf("job %d: %d runner instances launched (%s), expected 1", jobID, len(matched), strings.Join(ids, ", ")))
+		}
+	}
+	return launches, violations
+}
+
+// RunsOnJobIDs returns the IDs of the jobs of a run that asked for a RunsOn runner.
+func RunsOnJobIDs(jobs []WorkflowJobLog) []int64 {
+	var ids []int64
+	for _, job := range jobs {
+		if job.Conclusion == "skipped" {
+			continue
+		}
+		if slices.ContainsFunc(job.Labels, func(label string) bool {
+			return label == "runs-on" || strings.HasPrefix(label, "runs-on=") || strings.HasPrefix(label, "runs-on/")
+		}) {
+			ids = append(ids, job.ID)
+		}
+	}
+	return ids
+}
+
+// ValidateRunnerLaunched checks that the stack launched exactly one runner instance for each of
+// the given jobs of a workflow run, and no instance for anything else in the run. Instances are
+// matched by the RunsOn run and job ID tags, so concurrent tests and real traffic on the stack
+// don't count. Returns the launch of every job that has one.
+func ValidateRunnerLaunched(t *testing.T, stackName string, runID int64, jobIDs []int64) []RunnerLaunch {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := ec2.NewFromConfig(cfg)
+
+	instances, err := runRunnerInstances(ctx, client, stackName, runID)
+	require.NoError(t, err, "Failed to list runner instances of run %d", runID)
+
+	launches, violations := correlateRunnerLaunches(instances, jobIDs)
+	for _, violation := range violations {
+		assert.Fail(t, "Runner launch mismatch", violation)
+	}
+	for _, launch := range launches {
+		lifecycle := "on-demand"
+		if launch.Spot {
+			lifecycle = "spot"
+		}
+		t.Logf("✓ Job %d ran on %s (%s, %s) in %s, launched at %s", launch.JobID, launch.InstanceID,
+			launch.InstanceType, lifecycle, launch.SubnetID, launch.LaunchTime.Format(time.RFC3339))
+	}
+	return launches
+}
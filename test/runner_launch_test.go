@@ -0,0 +1,187 @@
+package test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// matchesTagFilters reports whether tags satisfy every "tag:<key>" filter. Other filters are ignored.
+func matchesTagFilters(tags []ec2types.Tag, filters []ec2types.Filter) bool {
+	return !slices.ContainsFunc(filters, func(filter ec2types.Filter) bool {
+		key, ok := strings.CutPrefix(aws.ToString(filter.Name), "tag:")
+		return ok && !slices.ContainsFunc(tags, func(tag ec2types.Tag) bool {
+			return aws.ToString(tag.Key) == key && slices.Contains(filter.Values, aws.ToString(tag.Value))
+		})
+	})
+}
+
+// fakeEC2Instances applies DescribeInstances instance ID and tag filters to a fixed instance
+// list and serves one reservation per page.
+type fakeEC2Instances struct {
+	instances []ec2types.Instance
+}
+
+func (f *fakeEC2Instances) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	var matched []ec2types.Instance
+	for _, instance := range f.instances {
+		if len(params.InstanceIds) > 0 && !slices.Contains(params.InstanceIds, aws.ToString(instance.InstanceId)) {
+			continue
+		}
+		if matchesTagFilters(instance.Tags, params.Filters) {
+			matched = append(matched, instance)
+		}
+	}
+
+	page := 0
+	if params.NextToken != nil {
+		page = len(aws.ToString(params.NextToken))
+	}
+	out := &ec2.DescribeInstancesOutput{}
+	if page < len(matched) {
+		out.Reservations = []ec2types.Reservation{{Instances: matched[page : page+1]}}
+	}
+	if page+1 < len(matched) {
+		out.NextToken = aws.String(strings.Repeat(".", page+1))
+	}
+	return out, nil
+}
+
+func runnerInstance(id, stack, runID, jobID string) ec2types.Instance {
+	instance := ec2types.Instance{
+		InstanceId:   aws.String(id),
+		InstanceType: ec2types.InstanceTypeM7aLarge,
+		SubnetId:     aws.String("subnet-0a1b2c3d4e5f6a7b8"),
+		LaunchTime:   aws.Time(time.Date(2025, 2, 6, 10, 4, 2, 0, time.UTC)),
+		State:        &ec2types.InstanceState{Name: ec2types.InstanceStateNameRunning},
+		Tags:         []ec2types.Tag{{Key: aws.String("runs-on-stack-name"), Value: aws.String(stack)}},
+	}
+	if runID != "" {
+		instance.Tags = append(instance.Tags, ec2types.Tag{Key: aws.String(RunnerRunIDTag), Value: aws.String(runID)})
+	}
+	if jobID != "" {
+		instance.Tags = append(instance.Tags, ec2types.Tag{Key: aws.String(RunnerJobIDTag), Value: aws.String(jobID)})
+	}
+	return instance
+}
+
+func TestRunRunnerInstances(t *testing.T) {
+	api := &fakeEC2Instances{instances: []ec2types.Instance{
+		runnerInstance("i-0000000000000000a", "test-123", "42", "1"),
+		runnerInstance("i-0000000000000000b", "test-123", "43", "9"),
+		runnerInstance("i-0000000000000000c", "test-456", "42", "1"),
+		runnerInstance("i-0000000000000000d", "test-123", "42", "2"),
+	}}
+
+	instances, err := runRunnerInstances(context.Background(), api, "test-123", 42)
+	require.NoError(t, err)
+	var ids []string
+	for _, instance := range instances {
+		ids = append(ids, aws.ToString(instance.InstanceId))
+	}
+	assert.Equal(t, []string{"i-0000000000000000a", "i-0000000000000000d"}, ids, "other runs and stacks are ignored, every page is read")
+}
+
+func TestNewRunnerLaunch(t *testing.T) {
+	instance := runnerInstance("i-0000000000000000a", "test-123", "42", "1")
+	instance.InstanceLifecycle = ec2types.InstanceLifecycleTypeSpot
+
+	launch := newRunnerLaunch(1, instance)
+	assert.Equal(t, RunnerLaunch{
+		JobID:        1,
+		InstanceID:   "i-0000000000000000a",
+		InstanceType: "m7a.large",
+		Spot:         true,
+		SubnetID:     "subnet-0a1b2c3d4e5f6a7b8",
+		LaunchTime:   time.Date(2025, 2, 6, 10, 4, 2, 0, time.UTC),
+	}, launch)
+
+	instance.State.Name = ec2types.InstanceStateNameTerminated
+	instance.StateTransitionReason = aws.String("User initiated (2025-02-06 10:15:02 GMT)")
+	instance.SubnetId = nil
+	launch = newRunnerLaunch(1, instance)
+	assert.Equal(t, time.Date(2025, 2, 6, 10, 15, 2, 0, time.UTC), launch.TerminateTime.UTC())
+	assert.Empty(t, launch.SubnetID)
+}
+
+func TestCorrelateRunnerLaunches(t *testing.T) {
+	valid := func() []ec2types.Instance {
+		return []ec2types.Instance{
+			runnerInstance("i-0000000000000000a", "test-123", "42", "1"),
+			runnerInstance("i-0000000000000000b", "test-123", "42", "2"),
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		mutate   func(instances []ec2types.Instance) []ec2types.Instance
+		launches []string
+		contains []string
+	}{
+		{
+			name:     "OnePerJob",
+			mutate:   func(instances []ec2types.Instance) []ec2types.Instance { return instances },
+			launches: []string{"i-0000000000000000a", "i-0000000000000000b"},
+		},
+		{
+			name:     "Missing",
+			mutate:   func(instances []ec2types.Instance) []ec2types.Instance { return instances[:1] },
+			launches: []string{"i-0000000000000000a"},
+			contains: []string{"job 2: no runner instance launched"},
+		},
+		{
+			name: "Duplicate",
+			mutate: func(instances []ec2types.Instance) []ec2types.Instance {
+				return append(instances, runnerInstance("i-0000000000000000c", "test-123", "42", "2"))
+			},
+			launches: []string{"i-0000000000000000a"},
+			contains: []string{"job 2: 2 runner instances launched (i-0000000000000000b, i-0000000000000000c), expected 1"},
+		},
+		{
+			name: "Extra",
+			mutate: func(instances []ec2types.Instance) []ec2types.Instance {
+				return append(instances,
+					runnerInstance("i-0000000000000000c", "test-123", "42", "3"),
+					runnerInstance("i-0000000000000000d", "test-123", "42", ""))
+			},
+			launches: []string{"i-0000000000000000a", "i-0000000000000000b"},
+			contains: []string{
+				`instance i-0000000000000000c (runs-on-workflow-job-id="3") is not the runner of any job of the run`,
+				`instance i-0000000000000000d (runs-on-workflow-job-id="") is not the runner of any job of the run`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			launches, violations := correlateRunnerLaunches(tc.mutate(valid()), []int64{1, 2})
+			var ids []string
+			for _, launch := range launches {
+				ids = append(ids, launch.InstanceID)
+			}
+			assert.Equal(t, tc.launches, ids)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			for i, want := range tc.contains {
+				assert.Equal(t, want, violations[i])
+			}
+		})
+	}
+}
+
+func TestRunsOnJobIDs(t *testing.T) {
+	jobs := []WorkflowJobLog{
+		{ID: 1, Conclusion: "success", Labels: []string{"runs-on=13711094342/runner=2cpu-linux-x64"}},
+		{ID: 2, Conclusion: "success", Labels: []string{"runs-on", "runner=2cpu-linux-x64"}},
+		{ID: 3, Conclusion: "success", Labels: []string{"ubuntu-latest"}},
+		{ID: 4, Conclusion: "skipped", Labels: []string{"runs-on=13711094342/runner=2cpu-linux-x64"}},
+	}
+	assert.Equal(t, []int64{1, 2}, RunsOnJobIDs(jobs))
+}
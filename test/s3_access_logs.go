@@ -0,0 +1,175 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sjysngh/runs-on-tf/test/accesslog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// S3 ACCESS LOG VALIDATORS
+// =============================================================================
+
+// Access log prefixes set by aws_s3_bucket_logging in s3.tf
+const (
+	ConfigAccessLogPrefix = "s3-config-access-logs/"
+	CacheAccessLogPrefix  = "s3-cache-access-logs/"
+)
+
+// ValidateS3AccessLogTarget checks that the source bucket logs to exactly the logging bucket and
+// prefix, and that the logging bucket enforces bucket-owner object ownership.
+func ValidateS3AccessLogTarget(t *testing.T, sourceBucket, loggingBucket, targetPrefix string) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := s3.NewFromConfig(cfg)
+
+	logging, err := client.GetBucketLogging(ctx, &s3.GetBucketLoggingInput{
+		Bucket: aws.String(sourceBucket),
+	})
+	require.NoError(t, err, "Failed to get bucket logging for %s", sourceBucket)
+	require.NotNil(t, logging.LoggingEnabled, "Bucket %s should have logging enabled", sourceBucket)
+	assert.Equal(t, loggingBucket, aws.ToString(logging.LoggingEnabled.TargetBucket),
+		"Bucket %s should log to %s", sourceBucket, loggingBucket)
+	assert.Equal(t, targetPrefix, aws.ToString(logging.LoggingEnabled.TargetPrefix),
+		"Bucket %s should log under %s", sourceBucket, targetPrefix)
+
+	ownership, err := client.GetBucketOwnershipControls(ctx, &s3.GetBucketOwnershipControlsInput{
+		Bucket: aws.String(loggingBucket),
+	})
+	require.NoError(t, err, "Failed to get ownership controls for %s", loggingBucket)
+	require.Len(t, ownership.OwnershipControls.Rules, 1, "Bucket %s should have one ownership rule", loggingBucket)
+	assert.Equal(t, s3types.ObjectOwnershipBucketOwnerEnforced, ownership.OwnershipControls.Rules[0].ObjectOwnership,
+		"Bucket %s should enforce bucket-owner object ownership", loggingBucket)
+
+	t.Logf("✓ Bucket %s logs to s3://%s/%s", sourceBucket, loggingBucket, targetPrefix)
+}
+
+// ValidateS3AccessLogDelivery makes a PUT and a GET against the source bucket, tagged with a unique
+// user agent, then polls the logging bucket until both requests appear in delivered access logs.
+// S3 delivers access logs on a best-effort basis, usually within a few minutes but sometimes much later.
+func ValidateS3AccessLogDelivery(t *testing.T, sourceBucket, loggingBucket, targetPrefix string, timeout time.Duration) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := s3.NewFromConfig(cfg)
+
+	started := time.Now().UTC()
+	marker := "accesslog-" + strconv.FormatInt(started.UnixNano(), 36)
+	key := fmt.Sprintf("access-log-check/%s/%s", GetTestID(), marker)
+
+	tagged := cfg.Copy()
+	tagged.AppID = marker
+	taggedClient := s3.NewFromConfig(tagged)
+
+	_, err := taggedClient.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(sourceBucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(marker),
+	})
+	require.NoError(t, err, "Failed to put s3://%s/%s", sourceBucket, key)
+	defer func() {
+		_, _ = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(sourceBucket), Key: aws.String(key)})
+	}()
+
+	object, err := taggedClient.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(sourceBucket),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err, "Failed to get s3://%s/%s", sourceBucket, key)
+	_ = object.Body.Close()
+	t.Logf("Made tagged PUT and GET on s3://%s/%s (user agent app/%s)", sourceBucket, key, marker)
+
+	wanted := []string{"REST.PUT.OBJECT", "REST.GET.OBJECT"}
+	found := map[string]accesslog.Record{}
+	seen := map[string]bool{}
+	startAfter := targetPrefix + started.Add(-5*time.Minute).Format(accesslog.KeyTimeLayout)
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+			Bucket:     aws.String(loggingBucket),
+			Prefix:     aws.String(targetPrefix),
+			StartAfter: aws.String(startAfter),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			require.NoError(t, err, "Failed to list s3://%s/%s", loggingBucket, targetPrefix)
+			for _, item := range page.Contents {
+				logKey := aws.ToString(item.Key)
+				if seen[logKey] {
+					continue
+				}
+				seen[logKey] = true
+
+				records, err := readAccessLogObject(ctx, client, loggingBucket, logKey)
+				require.NoError(t, err, "Failed to parse access log s3://%s/%s", loggingBucket, logKey)
+				for operation, record := range findTaggedRequests(records, sourceBucket, key, marker) {
+					found[operation] = record
+				}
+			}
+		}
+
+		missing := missingOperations(found, wanted)
+		if len(missing) == 0 {
+			for _, operation := range wanted {
+				record := found[operation]
+				t.Logf("✓ %s %s logged at %s (status %d, requester %s)",
+					operation, key, record.Time.Format(time.RFC3339), record.HTTPStatus, record.Requester)
+			}
+			return
+		}
+		t.Logf("Still missing %v in %d access logs under s3://%s/%s, waiting...",
+			missing, len(seen), loggingBucket, targetPrefix)
+		time.Sleep(30 * time.Second)
+	}
+
+	for _, operation := range missingOperations(found, wanted) {
+		assert.Fail(t, fmt.Sprintf("%s on s3://%s/%s was not logged under s3://%s/%s within %v",
+			operation, sourceBucket, key, loggingBucket, targetPrefix, timeout))
+	}
+}
+
+// readAccessLogObject downloads and parses one delivered access log object.
+func readAccessLogObject(ctx context.Context, client *s3.Client, bucket, key string) ([]accesslog.Record, error) {
+	object, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Body.Close()
+	return accesslog.Parse(object.Body)
+}
+
+// findTaggedRequests returns the records for key in bucket whose user agent carries the marker, by operation.
+func findTaggedRequests(records []accesslog.Record, bucket, key, marker string) map[string]accesslog.Record {
+	found := map[string]accesslog.Record{}
+	for _, record := range records {
+		if record.Bucket == bucket && record.Key == key && strings.Contains(record.UserAgent, "app/"+marker) {
+			found[record.Operation] = record
+		}
+	}
+	return found
+}
+
+// missingOperations returns the wanted operations that have no record in found. Other operations
+// in found don't count.
+func missingOperations(found map[string]accesslog.Record, wanted []string) []string {
+	var missing []string
+	for _, operation := range wanted {
+		if _, ok := found[operation]; !ok {
+			missing = append(missing, operation)
+		}
+	}
+	return missing
+}
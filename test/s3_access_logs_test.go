@@ -0,0 +1,36 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/sjysngh/runs-on-tf/test/accesslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindTaggedRequests(t *testing.T) {
+	key := "access-log-check/t1/accesslog-abc"
+	records := []accesslog.Record{
+		{Bucket: "stack-cache", Key: key, Operation: "REST.PUT.OBJECT", UserAgent: "aws-sdk-go-v2/1.47.1 os/linux app/accesslog-abc"},
+		{Bucket: "stack-cache", Key: key, Operation: "REST.GET.OBJECT", UserAgent: "aws-sdk-go-v2/1.47.1 os/linux app/accesslog-abc"},
+		{Bucket: "stack-cache", Key: key, Operation: "REST.HEAD.OBJECT", UserAgent: "aws-cli/2.15.0"},
+		{Bucket: "stack-config", Key: key, Operation: "REST.DELETE.OBJECT", UserAgent: "app/accesslog-abc"},
+		{Bucket: "stack-cache", Key: "cache/other", Operation: "REST.GET.OBJECT", UserAgent: "app/accesslog-abc"},
+	}
+
+	found := findTaggedRequests(records, "stack-cache", key, "accesslog-abc")
+	assert.Len(t, found, 2)
+	assert.Contains(t, found, "REST.PUT.OBJECT")
+	assert.Contains(t, found, "REST.GET.OBJECT")
+}
+
+func TestMissingOperations(t *testing.T) {
+	wanted := []string{"REST.PUT.OBJECT", "REST.GET.OBJECT"}
+	assert.Empty(t, missingOperations(map[string]accesslog.Record{
+		"REST.PUT.OBJECT": {}, "REST.GET.OBJECT": {},
+	}, wanted))
+
+	// An extra operation doesn't stand in for a missing one
+	assert.Equal(t, []string{"REST.GET.OBJECT"}, missingOperations(map[string]accesslog.Record{
+		"REST.PUT.OBJECT": {}, "REST.HEAD.OBJECT": {},
+	}, wanted))
+}
@@ -0,0 +1,220 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// S3 LIFECYCLE VALIDATORS
+// =============================================================================
+
+// LifecycleObjectKind is the kind of bucket entry a lifecycle rule acts on.
+type LifecycleObjectKind int
+
+const (
+	// CurrentVersion is the current version of an object, aged from creation
+	CurrentVersion LifecycleObjectKind = iota
+	// NoncurrentVersion is a previous version, aged from when it became noncurrent
+	NoncurrentVersion
+	// ExpiredDeleteMarker is a delete marker with no remaining noncurrent versions
+	ExpiredDeleteMarker
+	// IncompleteMultipartUpload is an upload that was initiated but never completed
+	IncompleteMultipartUpload
+)
+
+func (k LifecycleObjectKind) String() string {
+	switch k {
+	case NoncurrentVersion:
+		return "noncurrent"
+	case ExpiredDeleteMarker:
+		return "delete-marker"
+	case IncompleteMultipartUpload:
+		return "multipart-upload"
+	default:
+		return "current"
+	}
+}
+
+// LifecycleObject is a simulated bucket entry of a given age in days.
+type LifecycleObject struct {
+	Key     string
+	Kind    LifecycleObjectKind
+	AgeDays int
+}
+
+func (o LifecycleObject) String() string {
+	return fmt.Sprintf("%s %s aged %dd", o.Kind, o.Key, o.AgeDays)
+}
+
+// LifecycleCase is an object and whether the bucket's lifecycle rules should have removed it.
+type LifecycleCase struct {
+	Object  LifecycleObject
+	Expired bool
+}
+
+// EvaluateLifecycle reports whether any enabled rule removes the object, and which rule.
+// Rules filtering on tags or object size never match, since simulated objects have neither.
+func EvaluateLifecycle(rules []s3types.LifecycleRule, object LifecycleObject) (bool, string) {
+	for _, rule := range rules {
+		if rule.Status != s3types.ExpirationStatusEnabled || !lifecycleRuleMatches(rule, object.Key) {
+			continue
+		}
+		if lifecycleRuleExpires(rule, object) {
+			return true, aws.ToString(rule.ID)
+		}
+	}
+	return false, ""
+}
+
+// lifecycleRuleMatches reports whether the rule's filter selects the key.
+func lifecycleRuleMatches(rule s3types.LifecycleRule, key string) bool {
+	// rule.Prefix is deprecated but still returned for rules created without a filter
+	prefix := aws.ToString(rule.Prefix)
+	if filter := rule.Filter; filter != nil {
+		if filter.Tag != nil || filter.ObjectSizeGreaterThan != nil || filter.ObjectSizeLessThan != nil {
+			return false
+		}
+		if filter.And != nil {
+			if len(filter.And.Tags) > 0 || filter.And.ObjectSizeGreaterThan != nil || filter.And.ObjectSizeLessThan != nil {
+				return false
+			}
+			prefix = aws.ToString(filter.And.Prefix)
+		} else {
+			prefix = aws.ToString(filter.Prefix)
+		}
+	}
+	return strings.HasPrefix(key, prefix)
+}
+
+// lifecycleRuleExpires reports whether a matching rule removes an object of this kind and age.
+func lifecycleRuleExpires(rule s3types.LifecycleRule, object LifecycleObject) bool {
+	switch object.Kind {
+	case CurrentVersion:
+		days := aws.ToInt32(expirationDays(rule))
+		return days > 0 && object.AgeDays >= int(days)
+	case NoncurrentVersion:
+		if rule.NoncurrentVersionExpiration == nil {
+			return false
+		}
+		days := aws.ToInt32(rule.NoncurrentVersionExpiration.NoncurrentDays)
+		return days > 0 && object.AgeDays >= int(days)
+	case ExpiredDeleteMarker:
+		return rule.Expiration != nil && aws.ToBool(rule.Expiration.ExpiredObjectDeleteMarker)
+	case IncompleteMultipartUpload:
+		if rule.AbortIncompleteMultipartUpload == nil {
+			return false
+		}
+		days := aws.ToInt32(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation)
+		return days > 0 && object.AgeDays >= int(days)
+	}
+	return false
+}
+
+// expirationDays returns the current-version expiration in days, or nil when the rule has none.
+func expirationDays(rule s3types.LifecycleRule) *int32 {
+	if rule.Expiration == nil {
+		return nil
+	}
+	return rule.Expiration.Days
+}
+
+// lifecycleCaseMismatches evaluates every case and returns one message per wrong outcome.
+func lifecycleCaseMismatches(rules []s3types.LifecycleRule, cases []LifecycleCase) []string {
+	var mismatches []string
+	for _, c := range cases {
+		expired, ruleID := EvaluateLifecycle(rules, c.Object)
+		if expired == c.Expired {
+			continue
+		}
+		if expired {
+			mismatches = append(mismatches, fmt.Sprintf("%s should be kept but rule %q expires it", c.Object, ruleID))
+		} else {
+			mismatches = append(mismatches, fmt.Sprintf("%s should be expired but no rule removes it", c.Object))
+		}
+	}
+	return mismatches
+}
+
+// ConfigBucketLifecycleCases are the expected outcomes for the config bucket:
+// agents/v1 and runs-on/db/ expire after 30 days, everything else is kept.
+func ConfigBucketLifecycleCases() []LifecycleCase {
+	return []LifecycleCase{
+		{LifecycleObject{"agents/v1/runs-on-agent-linux-x86_64", CurrentVersion, 29}, false},
+		{LifecycleObject{"agents/v1/runs-on-agent-linux-x86_64", CurrentVersion, 30}, true},
+		{LifecycleObject{"agents/v1/runs-on-agent-linux-x86_64", NoncurrentVersion, 6}, false},
+		{LifecycleObject{"agents/v1/runs-on-agent-linux-x86_64", NoncurrentVersion, 7}, true},
+		{LifecycleObject{"runs-on/db/jobs/123.json", CurrentVersion, 29}, false},
+		{LifecycleObject{"runs-on/db/jobs/123.json", CurrentVersion, 30}, true},
+		{LifecycleObject{"runs-on/db/jobs/123.json", NoncurrentVersion, 1}, true},
+		{LifecycleObject{"runs-on/config.yml", CurrentVersion, 365}, false},
+		{LifecycleObject{"runs-on/config.yml", NoncurrentVersion, 365}, false},
+		{LifecycleObject{"runs-on/config.yml", ExpiredDeleteMarker, 0}, true},
+		{LifecycleObject{"runs-on/config.yml", IncompleteMultipartUpload, 6}, false},
+		{LifecycleObject{"runs-on/config.yml", IncompleteMultipartUpload, 7}, true},
+	}
+}
+
+// CacheBucketLifecycleCases are the expected outcomes for the cache bucket:
+// cache/ expires after cacheExpirationDays, runners/ after 1 day.
+func CacheBucketLifecycleCases(cacheExpirationDays int) []LifecycleCase {
+	cases := []LifecycleCase{
+		{LifecycleObject{"cache/actions/linux-node-modules.tgz", CurrentVersion, cacheExpirationDays}, true},
+		{LifecycleObject{"cache/actions/linux-node-modules.tgz", NoncurrentVersion, 1}, true},
+		{LifecycleObject{"runners/i-0123456789abcdef0/config.json", CurrentVersion, 0}, false},
+		{LifecycleObject{"runners/i-0123456789abcdef0/config.json", CurrentVersion, 1}, true},
+		{LifecycleObject{"runners/i-0123456789abcdef0/config.json", NoncurrentVersion, 1}, true},
+		{LifecycleObject{"docker/registry/blob", CurrentVersion, 365}, false},
+		{LifecycleObject{"docker/registry/blob", ExpiredDeleteMarker, 0}, true},
+		{LifecycleObject{"docker/registry/blob", IncompleteMultipartUpload, 0}, false},
+		{LifecycleObject{"docker/registry/blob", IncompleteMultipartUpload, 1}, true},
+	}
+	if cacheExpirationDays > 0 {
+		cases = append(cases, LifecycleCase{LifecycleObject{"cache/actions/linux-node-modules.tgz", CurrentVersion, cacheExpirationDays - 1}, false})
+	}
+	return cases
+}
+
+// LoggingBucketLifecycleCases are the expected outcomes for the logging bucket:
+// every log expires after 90 days and previous versions after 30.
+func LoggingBucketLifecycleCases() []LifecycleCase {
+	return []LifecycleCase{
+		{LifecycleObject{"s3-cache-access-logs/2024-01-01-00-00-00-ABCDEF", CurrentVersion, 89}, false},
+		{LifecycleObject{"s3-cache-access-logs/2024-01-01-00-00-00-ABCDEF", CurrentVersion, 90}, true},
+		{LifecycleObject{"s3-cache-access-logs/2024-01-01-00-00-00-ABCDEF", NoncurrentVersion, 29}, false},
+		{LifecycleObject{"s3-cache-access-logs/2024-01-01-00-00-00-ABCDEF", NoncurrentVersion, 30}, true},
+		{LifecycleObject{"s3-cache-access-logs/2024-01-01-00-00-00-ABCDEF", ExpiredDeleteMarker, 0}, true},
+		{LifecycleObject{"s3-cache-access-logs/2024-01-01-00-00-00-ABCDEF", IncompleteMultipartUpload, 6}, false},
+		{LifecycleObject{"s3-cache-access-logs/2024-01-01-00-00-00-ABCDEF", IncompleteMultipartUpload, 7}, true},
+	}
+}
+
+// ValidateS3Lifecycle fetches the bucket's lifecycle configuration and checks that simulated
+// objects of each prefix, kind and age are expired or kept as expected.
+func ValidateS3Lifecycle(t *testing.T, bucketName string, cases []LifecycleCase) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := s3.NewFromConfig(cfg)
+
+	result, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+	require.NoError(t, err, "Failed to get lifecycle configuration for %s", bucketName)
+	require.NotEmpty(t, result.Rules, "Bucket %s has no lifecycle rules", bucketName)
+
+	mismatches := lifecycleCaseMismatches(result.Rules, cases)
+	for _, mismatch := range mismatches {
+		assert.Fail(t, fmt.Sprintf("Bucket %s: %s", bucketName, mismatch))
+	}
+	if len(mismatches) == 0 {
+		t.Logf("✓ Bucket %s lifecycle rules match %d simulated objects", bucketName, len(cases))
+	}
+}
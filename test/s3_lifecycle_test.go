@@ -0,0 +1,94 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// expireRule builds an enabled lifecycle rule as GetBucketLifecycleConfiguration returns it
+func expireRule(id, prefix string, days, noncurrentDays int32) s3types.LifecycleRule {
+	rule := s3types.LifecycleRule{
+		ID:     aws.String(id),
+		Status: s3types.ExpirationStatusEnabled,
+		Filter: &s3types.LifecycleRuleFilter{Prefix: aws.String(prefix)},
+	}
+	if days > 0 {
+		rule.Expiration = &s3types.LifecycleExpiration{Days: aws.Int32(days)}
+	}
+	if noncurrentDays > 0 {
+		rule.NoncurrentVersionExpiration = &s3types.NoncurrentVersionExpiration{NoncurrentDays: aws.Int32(noncurrentDays)}
+	}
+	return rule
+}
+
+// cleanupRules are the multipart and delete marker rules every bucket in s3.tf has
+func cleanupRules(abortDays int32) []s3types.LifecycleRule {
+	return []s3types.LifecycleRule{
+		{
+			ID:                             aws.String("CleanupIncompleteMultipartUploads"),
+			Status:                         s3types.ExpirationStatusEnabled,
+			Filter:                         &s3types.LifecycleRuleFilter{},
+			AbortIncompleteMultipartUpload: &s3types.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int32(abortDays)},
+		},
+		{
+			ID:         aws.String("CleanupExpiredObjectDeleteMarkers"),
+			Status:     s3types.ExpirationStatusEnabled,
+			Filter:     &s3types.LifecycleRuleFilter{},
+			Expiration: &s3types.LifecycleExpiration{ExpiredObjectDeleteMarker: aws.Bool(true)},
+		},
+	}
+}
+
+func TestLifecycleCases(t *testing.T) {
+	configRules := append([]s3types.LifecycleRule{
+		expireRule("ExpireAgentBinaries", "agents/v1", 30, 7),
+		expireRule("ExpireDbEntries", "runs-on/db/", 30, 1),
+	}, cleanupRules(7)...)
+	cacheRules := append([]s3types.LifecycleRule{
+		expireRule("ExpireRunnerConfig", "runners/", 1, 1),
+		expireRule("ExpireCache", "cache/", 10, 1),
+	}, cleanupRules(1)...)
+	loggingRules := append([]s3types.LifecycleRule{
+		expireRule("DeleteOldLogs", "", 90, 30),
+	}, cleanupRules(7)...)
+
+	t.Run("MatchesS3Configuration", func(t *testing.T) {
+		assert.Empty(t, lifecycleCaseMismatches(configRules, ConfigBucketLifecycleCases()))
+		assert.Empty(t, lifecycleCaseMismatches(cacheRules, CacheBucketLifecycleCases(10)))
+		assert.Empty(t, lifecycleCaseMismatches(loggingRules, LoggingBucketLifecycleCases()))
+	})
+
+	t.Run("WrongPrefix", func(t *testing.T) {
+		rules := append([]s3types.LifecycleRule{
+			expireRule("ExpireRunnerConfig", "runner/", 1, 1),
+			expireRule("ExpireCache", "cache/", 10, 1),
+		}, cleanupRules(1)...)
+		assert.Len(t, lifecycleCaseMismatches(rules, CacheBucketLifecycleCases(10)), 2)
+	})
+
+	t.Run("WrongDays", func(t *testing.T) {
+		rules := append([]s3types.LifecycleRule{
+			expireRule("ExpireRunnerConfig", "runners/", 1, 1),
+			expireRule("ExpireCache", "cache/", 7, 1),
+		}, cleanupRules(1)...)
+		assert.Len(t, lifecycleCaseMismatches(rules, CacheBucketLifecycleCases(10)), 1)
+	})
+
+	t.Run("DisabledRule", func(t *testing.T) {
+		rules := append([]s3types.LifecycleRule{}, loggingRules...)
+		rules[0].Status = s3types.ExpirationStatusDisabled
+		assert.Len(t, lifecycleCaseMismatches(rules, LoggingBucketLifecycleCases()), 2)
+	})
+
+	t.Run("LegacyPrefix", func(t *testing.T) {
+		rule := expireRule("ExpireCache", "", 10, 0)
+		rule.Filter = nil
+		rule.Prefix = aws.String("cache/")
+		expired, ruleID := EvaluateLifecycle([]s3types.LifecycleRule{rule}, LifecycleObject{"cache/x", CurrentVersion, 10})
+		assert.True(t, expired)
+		assert.Equal(t, "ExpireCache", ruleID)
+	})
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// S3 BUCKET POLICY VALIDATORS
+// =============================================================================
+
+// s3LogDeliveryPrincipal is the service principal that delivers S3 server access logs.
+const s3LogDeliveryPrincipal = "logging.s3.amazonaws.com"
+
+// BucketPolicyExpectation describes the policy s3.tf should attach to a bucket.
+type BucketPolicyExpectation struct {
+	BucketName string
+	AccountID  string
+
+	// LogSourceBuckets are the buckets allowed to deliver access logs here.
+	// Empty means the policy must not grant log delivery at all.
+	LogSourceBuckets []string
+}
+
+// s3BucketArn returns the ARN of an S3 bucket.
+func s3BucketArn(bucketName string) string {
+	return "arn:aws:s3:::" + bucketName
+}
+
+// ValidateS3BucketPolicy checks the bucket policy:
+//   - A DenyUnencryptedConnections statement denies s3:* to everyone when aws:SecureTransport
+//     is false, on both the bucket and /* ARNs
+//   - Log delivery is granted only to logging.s3.amazonaws.com, only PutObject into this bucket,
+//     only from the expected source buckets and account
+//   - A signed request over plain HTTP is refused while the same request over HTTPS succeeds
+func ValidateS3BucketPolicy(t *testing.T, expected BucketPolicyExpectation) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := s3.NewFromConfig(cfg)
+
+	result, err := client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{
+		Bucket: aws.String(expected.BucketName),
+	})
+	require.NoError(t, err, "Failed to get bucket policy for %s", expected.BucketName)
+	policy, err := ParsePolicyDocument(aws.ToString(result.Policy))
+	require.NoError(t, err, "Failed to parse bucket policy for %s", expected.BucketName)
+
+	violations := bucketPolicyViolations(policy, expected)
+	for _, violation := range violations {
+		assert.Fail(t, fmt.Sprintf("Bucket %s: %s", expected.BucketName, violation))
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ Bucket %s policy enforces TLS (log delivery sources: %v)", expected.BucketName, expected.LogSourceBuckets)
+	}
+
+	_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(expected.BucketName),
+		MaxKeys: aws.Int32(1),
+	})
+	require.NoError(t, err, "ListObjectsV2 over HTTPS should succeed for %s", expected.BucketName)
+
+	insecure := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(fmt.Sprintf("http://s3.%s.amazonaws.com", cfg.Region))
+		o.UsePathStyle = true
+	})
+	_, err = insecure.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(expected.BucketName),
+		MaxKeys: aws.Int32(1),
+	})
+	require.Error(t, err, "ListObjectsV2 over HTTP should be refused for %s", expected.BucketName)
+	var apiErr smithy.APIError
+	require.True(t, errors.As(err, &apiErr), "Expected an S3 error over HTTP, got %v", err)
+	assert.Equal(t, "AccessDenied", apiErr.ErrorCode(), "HTTP request to %s should be denied by the bucket policy", expected.BucketName)
+	t.Logf("✓ Bucket %s refuses requests over HTTP", expected.BucketName)
+}
+
+// bucketPolicyViolations compares a parsed bucket policy with the expectation.
+// Returns one message per violation.
+func bucketPolicyViolations(policy PolicyDocument, expected BucketPolicyExpectation) []string {
+	var violations []string
+	bucketArn := s3BucketArn(expected.BucketName)
+
+	var tlsDeny, logGrants []PolicyStatement
+	for _, statement := range policy.Statement {
+		if statement.Effect == "Deny" && statement.ConditionValues("Bool", "aws:SecureTransport").Contains("false") {
+			tlsDeny = append(tlsDeny, statement)
+		}
+		if statement.Effect == "Allow" && statement.Principal["Service"].Contains(s3LogDeliveryPrincipal) {
+			logGrants = append(logGrants, statement)
+		}
+	}
+
+	if len(tlsDeny) == 0 {
+		violations = append(violations, "no Deny statement on aws:SecureTransport=false")
+	}
+	for _, statement := range tlsDeny {
+		if !statement.Principal.IsWildcard() {
+			violations = append(violations, fmt.Sprintf("statement %q should deny every principal", statement.Sid))
+		}
+		if !statement.Action.Contains("s3:*") {
+			violations = append(violations, fmt.Sprintf("statement %q should deny s3:*, got %v", statement.Sid, statement.Action))
+		}
+		for _, resource := range []string{bucketArn, bucketArn + "/*"} {
+			if !statement.Resource.Contains(resource) {
+				violations = append(violations, fmt.Sprintf("statement %q does not cover %s", statement.Sid, resource))
+			}
+		}
+	}
+
+	if len(expected.LogSourceBuckets) == 0 {
+		for _, statement := range logGrants {
+			violations = append(violations, fmt.Sprintf("statement %q grants log delivery but none is expected", statement.Sid))
+		}
+		return violations
+	}
+
+	if len(logGrants) != 1 {
+		violations = append(violations, fmt.Sprintf("expected exactly 1 log delivery grant, got %d", len(logGrants)))
+	}
+	var sourceArns []string
+	for _, source := range expected.LogSourceBuckets {
+		sourceArns = append(sourceArns, s3BucketArn(source))
+	}
+	sort.Strings(sourceArns)
+	for _, statement := range logGrants {
+		if len(statement.Principal) != 1 || len(statement.Principal["Service"]) != 1 {
+			violations = append(violations, fmt.Sprintf("statement %q should grant only %s", statement.Sid, s3LogDeliveryPrincipal))
+		}
+		if len(statement.Action) != 1 || !statement.Action.Contains("s3:PutObject") {
+			violations = append(violations, fmt.Sprintf("statement %q should allow only s3:PutObject, got %v", statement.Sid, statement.Action))
+		}
+		if len(statement.Resource) != 1 || !statement.Resource.Contains(bucketArn+"/*") {
+			violations = append(violations, fmt.Sprintf("statement %q should be scoped to %s/*, got %v", statement.Sid, bucketArn, statement.Resource))
+		}
+		account := statement.ConditionValues("StringEquals", "aws:SourceAccount")
+		if len(account) != 1 || account[0] != expected.AccountID {
+			violations = append(violations, fmt.Sprintf("statement %q should require aws:SourceAccount=%s, got %v", statement.Sid, expected.AccountID, account))
+		}
+		sources := append([]string{}, statement.ConditionValues("ArnLike", "aws:SourceArn")...)
+		sources = append(sources, statement.ConditionValues("ArnEquals", "aws:SourceArn")...)
+		sort.Strings(sources)
+		if fmt.Sprint(sources) != fmt.Sprint(sourceArns) {
+			violations = append(violations, fmt.Sprintf("statement %q should require aws:SourceArn in %v, got %v", statement.Sid, sourceArns, sources))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loggingBucketPolicy is the policy s3.tf renders for the logging bucket
+const loggingBucketPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "DenyUnencryptedConnections",
+      "Effect": "Deny",
+      "Principal": "*",
+      "Action": "s3:*",
+      "Resource": ["arn:aws:s3:::stack-logging", "arn:aws:s3:::stack-logging/*"],
+      "Condition": {"Bool": {"aws:SecureTransport": "false"}}
+    },
+    {
+      "Sid": "S3ServerAccessLogsPolicy",
+      "Effect": "Allow",
+      "Principal": {"Service": "logging.s3.amazonaws.com"},
+      "Action": "s3:PutObject",
+      "Resource": "arn:aws:s3:::stack-logging/*",
+      "Condition": {
+        "StringEquals": {"aws:SourceAccount": "123456789012"},
+        "ArnLike": {"aws:SourceArn": ["arn:aws:s3:::stack-config", "arn:aws:s3:::stack-cache"]}
+      }
+    }
+  ]
+}`
+
+func TestParsePolicyDocument(t *testing.T) {
+	policy, err := ParsePolicyDocument(loggingBucketPolicy)
+	require.NoError(t, err)
+	require.Len(t, policy.Statement, 2)
+	assert.True(t, policy.Statement[0].Principal.IsWildcard())
+	assert.Equal(t, StringOrSlice{"s3:*"}, policy.Statement[0].Action)
+	assert.Equal(t, StringOrSlice{"false"}, policy.Statement[0].ConditionValues("Bool", "aws:SecureTransport"))
+	assert.Equal(t, StringOrSlice{"logging.s3.amazonaws.com"}, policy.Statement[1].Principal["Service"])
+
+	encoded, err := ParsePolicyDocument("%7B%22Version%22%3A%222012-10-17%22%2C%22Statement%22%3A%5B%5D%7D")
+	require.NoError(t, err)
+	assert.Equal(t, "2012-10-17", encoded.Version)
+}
+
+func TestBucketPolicyViolations(t *testing.T) {
+	logging := BucketPolicyExpectation{
+		BucketName:       "stack-logging",
+		AccountID:        "123456789012",
+		LogSourceBuckets: []string{"stack-config", "stack-cache"},
+	}
+
+	testCases := []struct {
+		name       string
+		policy     string
+		expected   BucketPolicyExpectation
+		violations int
+	}{
+		{"Compliant", loggingBucketPolicy, logging, 0},
+		{"DenyMissingObjectARN", strings.Replace(loggingBucketPolicy, `, "arn:aws:s3:::stack-logging/*"]`, `]`, 1), logging, 1},
+		{"NoTLSDeny", strings.Replace(loggingBucketPolicy, `"aws:SecureTransport": "false"`, `"aws:SecureTransport": "true"`, 1), logging, 1},
+		{"WrongAccount", strings.Replace(loggingBucketPolicy, `"123456789012"`, `"210987654321"`, 1), logging, 1},
+		{"ExtraSource", strings.Replace(loggingBucketPolicy, `"arn:aws:s3:::stack-cache"]`, `"arn:aws:s3:::stack-cache", "arn:aws:s3:::*"]`, 1), logging, 1},
+		{"BroadResource", strings.Replace(loggingBucketPolicy, `"Resource": "arn:aws:s3:::stack-logging/*"`, `"Resource": "*"`, 1), logging, 1},
+		{"BroadAction", strings.Replace(loggingBucketPolicy, `"Action": "s3:PutObject"`, `"Action": "s3:*"`, 1), logging, 1},
+		{"UnexpectedGrant", loggingBucketPolicy, BucketPolicyExpectation{BucketName: "stack-logging"}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := ParsePolicyDocument(tc.policy)
+			require.NoError(t, err)
+			violations := bucketPolicyViolations(policy, tc.expected)
+			assert.Len(t, violations, tc.violations, "violations: %v", violations)
+		})
+	}
+}
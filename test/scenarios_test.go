@@ -3,9 +3,13 @@ package test
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gruntwork-io/terratest/modules/files"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,10 +23,11 @@ func TestScenarioBasic(t *testing.T) {
 	config.EnableEFS = false
 	config.EnableECR = false
 	config.EnableNAT = false
+	config.SQSOldestMessageThresholdSeconds = 600
 
 	// Deploy VPC first
 	vpcOptions := &terraform.Options{
-		TerraformDir:    "./fixtures/vpc",
+		TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
 		TerraformBinary: "tofu",
 		Vars:            config.ToVPCVars(),
 		NoColor:         true,
@@ -37,7 +42,7 @@ func TestScenarioBasic(t *testing.T) {
 
 	// Deploy runs-on module (root module)
 	moduleOptions := &terraform.Options{
-		TerraformDir:    "../",
+		TerraformDir:    copyModuleToTemp(t),
 		TerraformBinary: "tofu",
 		Vars:            config.ToModuleVars(vpcID, publicSubnets, privateSubnets),
 		NoColor:         true,
@@ -73,8 +78,23 @@ func TestScenarioBasic(t *testing.T) {
 	})
 
 	t.Run("Security/S3AccessLogging", func(t *testing.T) {
-		ValidateS3BucketLogging(t, configBucket, loggingBucket)
-		ValidateS3BucketLogging(t, cacheBucket, loggingBucket)
+		ValidateS3AccessLogTarget(t, configBucket, loggingBucket, ConfigAccessLogPrefix)
+		ValidateS3AccessLogTarget(t, cacheBucket, loggingBucket, CacheAccessLogPrefix)
+	})
+
+	t.Run("Security/S3AccessLogDelivery", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("Access log delivery can take up to an hour")
+		}
+		// Tagged requests must show up in the delivered access logs under each bucket's prefix
+		t.Run("Config", func(t *testing.T) {
+			t.Parallel()
+			ValidateS3AccessLogDelivery(t, configBucket, loggingBucket, ConfigAccessLogPrefix, 30*time.Minute)
+		})
+		t.Run("Cache", func(t *testing.T) {
+			t.Parallel()
+			ValidateS3AccessLogDelivery(t, cacheBucket, loggingBucket, CacheAccessLogPrefix, 30*time.Minute)
+		})
 	})
 
 	t.Run("Security/S3PublicAccessBlocked", func(t *testing.T) {
@@ -83,10 +103,40 @@ func TestScenarioBasic(t *testing.T) {
 		ValidateS3BucketPublicAccessBlocked(t, loggingBucket)
 	})
 
+	t.Run("Security/S3BucketPolicy", func(t *testing.T) {
+		accountID := terraform.Output(t, moduleOptions, "aws_account_id")
+		ValidateS3BucketPolicy(t, BucketPolicyExpectation{BucketName: configBucket, AccountID: accountID})
+		ValidateS3BucketPolicy(t, BucketPolicyExpectation{BucketName: cacheBucket, AccountID: accountID})
+		ValidateS3BucketPolicy(t, BucketPolicyExpectation{
+			BucketName:       loggingBucket,
+			AccountID:        accountID,
+			LogSourceBuckets: []string{configBucket, cacheBucket},
+		})
+	})
+
 	t.Run("Security/IAMMinimalPermissions", func(t *testing.T) {
 		ValidateIAMRoleNotOverlyPermissive(t, ec2RoleName)
 	})
 
+	t.Run("Security/IAMBaseline", func(t *testing.T) {
+		baseline, err := LoadIAMBaseline(RunnerRoleBaseline)
+		require.NoError(t, err)
+		ValidateIAMRoleBaseline(t, ec2RoleName, baseline, config.IAMFeatures(), iamBaselineVars(t, moduleOptions, config))
+	})
+
+	t.Run("Security/AppRunnerPermissions", func(t *testing.T) {
+		// A plan of the deployed stack has every policy ARN known
+		ValidatePlannedAppRunnerPermissions(t, PlanModule(t, moduleOptions), iamBaselineVars(t, moduleOptions, config))
+	})
+
+	t.Run("Security/RunnerSecurityGroups", func(t *testing.T) {
+		ValidateRunnerSecurityGroups(t,
+			terraform.OutputList(t, moduleOptions, "security_group_ids"),
+			launchTemplateIDs(t, moduleOptions),
+			terraform.Output(t, moduleOptions, "apprunner_service_arn"),
+			config.SecurityGroupExpectation(stackName))
+	})
+
 	// ===== COMPLIANCE VALIDATIONS =====
 	t.Run("Compliance/S3Versioning", func(t *testing.T) {
 		ValidateS3BucketVersioning(t, configBucket, "Enabled")
@@ -94,15 +144,54 @@ func TestScenarioBasic(t *testing.T) {
 		ValidateS3BucketVersioning(t, loggingBucket, "Enabled")
 	})
 
+	t.Run("Compliance/S3Lifecycle", func(t *testing.T) {
+		ValidateS3Lifecycle(t, configBucket, ConfigBucketLifecycleCases())
+		ValidateS3Lifecycle(t, cacheBucket, CacheBucketLifecycleCases(config.CacheExpirationDays))
+		ValidateS3Lifecycle(t, loggingBucket, LoggingBucketLifecycleCases())
+	})
+
 	t.Run("Compliance/LogRetention", func(t *testing.T) {
 		ValidateCloudWatchLogRetention(t, logGroupName)
 	})
 
+	t.Run("Compliance/CostAllocationTags", func(t *testing.T) {
+		// Launch template tags, with the provider default_tags and their Name, must reach the
+		// instance, volumes and ENIs, and the instance must join the <stack>-ec2-instances group
+		ValidateRunnerTagPropagation(t,
+			terraform.Output(t, moduleOptions, "launch_template_linux_default_id"),
+			publicSubnets[0], stackName, config.RunnerTags(stackName))
+	})
+
+	t.Run("Compliance/TagAudit", func(t *testing.T) {
+		// Every stack resource, and every launch template tag_specifications block,
+		// must carry the default tags, runs-on-stack-name and the cost allocation tag
+		ValidatePlannedTagCompliance(t, PlanModule(t, moduleOptions), config.StackTags(stackName))
+		ValidateStackTagCompliance(t, moduleOptions, stackName, config.StackTags(stackName))
+	})
+
+	t.Run("Compliance/Alarms", func(t *testing.T) {
+		ValidateAlarms(t, stackName, config.AlarmExpectations(stackName,
+			terraform.Output(t, moduleOptions, "apprunner_service_arn"),
+			terraform.Output(t, moduleOptions, "sqs_queue_main_url"),
+			terraform.Output(t, moduleOptions, "sns_topic_arn")))
+	})
+
+	t.Run("Compliance/Dashboard", func(t *testing.T) {
+		events, err := LoadLogEvents(AppRunnerLogCorpus)
+		require.NoError(t, err)
+		ValidateDashboard(t, terraform.Output(t, moduleOptions, "dashboard_name"),
+			terraform.Output(t, moduleOptions, "apprunner_log_group_name"), events)
+	})
+
 	// ===== ADVANCED VALIDATIONS =====
 	t.Run("Advanced/AppRunnerHealth", func(t *testing.T) {
 		ValidateAppRunnerHealth(t, appRunnerURL, 10)
 	})
 
+	t.Run("Advanced/AlarmNotification", func(t *testing.T) {
+		ValidateAlarmNotification(t, stackName+"-app-daily-budget", terraform.Output(t, moduleOptions, "sns_topic_arn"), 5*time.Minute)
+	})
+
 	// ===== FUNCTIONAL VALIDATIONS =====
 	// These tests launch an EC2 instance and verify it can actually use the infrastructure
 	// Note: IAM policy allows:
@@ -194,9 +283,19 @@ func TestScenarioBasic(t *testing.T) {
 		conclusion := WaitForWorkflowCompletion(t, testRepo, runID, 10*time.Minute)
 		assert.Equal(t, "success", conclusion, "Workflow should succeed")
 
-		// Validate runner was launched
-		launched := ValidateRunnerLaunched(t, stackName, startTime)
-		assert.True(t, launched, "Runner instance should have been launched")
+		// Each job's log should name a runner the stack launched; failed job logs are attached
+		jobs := ValidateWorkflowJobRunners(t, testRepo, runID, stackName, startTime)
+
+		// Exactly one runner per RunsOn job of this run, matched by the run and job ID tags
+		launches := ValidateRunnerLaunched(t, stackName, runID, RunsOnJobIDs(jobs))
+		assert.NotEmpty(t, launches, "Runner instance should have been launched")
+
+		// Runners terminate after their jobs and leave no volumes, ENIs or snapshots behind
+		ValidateRunnerCleanup(t, stackName, launches, RunnerTerminationTimeout, time.Duration(config.RunnerMaxRuntime)*time.Minute)
+
+		// The scheduled runner should now show up on the dashboard
+		ValidateDashboardQueriesLive(t, terraform.Output(t, moduleOptions, "dashboard_name"), startTime,
+			[]string{"Total Runners Scheduled (Current Period)"}, 5*time.Minute)
 	})
 
 	fmt.Printf("\n✅ Basic scenario deployment successful!\n")
@@ -220,7 +319,7 @@ func TestScenarioFullFeatured(t *testing.T) {
 
 	// Deploy VPC with NAT
 	vpcOptions := &terraform.Options{
-		TerraformDir:    "./fixtures/vpc",
+		TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
 		TerraformBinary: "tofu",
 		Vars:            config.ToVPCVars(),
 		NoColor:         true,
@@ -235,7 +334,7 @@ func TestScenarioFullFeatured(t *testing.T) {
 
 	// Deploy runs-on module with all features
 	moduleOptions := &terraform.Options{
-		TerraformDir:    "../",
+		TerraformDir:    copyModuleToTemp(t),
 		TerraformBinary: "tofu",
 		Vars:            config.ToModuleVars(vpcID, publicSubnets, privateSubnets),
 		NoColor:         true,
@@ -275,8 +374,8 @@ func TestScenarioFullFeatured(t *testing.T) {
 	})
 
 	t.Run("Security/S3AccessLogging", func(t *testing.T) {
-		ValidateS3BucketLogging(t, configBucket, loggingBucket)
-		ValidateS3BucketLogging(t, cacheBucket, loggingBucket)
+		ValidateS3AccessLogTarget(t, configBucket, loggingBucket, ConfigAccessLogPrefix)
+		ValidateS3AccessLogTarget(t, cacheBucket, loggingBucket, CacheAccessLogPrefix)
 	})
 
 	t.Run("Security/S3PublicAccessBlocked", func(t *testing.T) {
@@ -289,6 +388,31 @@ func TestScenarioFullFeatured(t *testing.T) {
 		ValidateIAMRoleNotOverlyPermissive(t, ec2RoleName)
 	})
 
+	t.Run("Security/IAMBaseline", func(t *testing.T) {
+		baseline, err := LoadIAMBaseline(RunnerRoleBaseline)
+		require.NoError(t, err)
+		ValidateIAMRoleBaseline(t, ec2RoleName, baseline, config.IAMFeatures(), iamBaselineVars(t, moduleOptions, config))
+	})
+
+	t.Run("Security/AppRunnerPermissions", func(t *testing.T) {
+		// A plan of the deployed stack has every policy ARN known
+		ValidatePlannedAppRunnerPermissions(t, PlanModule(t, moduleOptions), iamBaselineVars(t, moduleOptions, config))
+	})
+
+	t.Run("Security/RunnerSecurityGroups", func(t *testing.T) {
+		ValidateRunnerSecurityGroups(t,
+			terraform.OutputList(t, moduleOptions, "security_group_ids"),
+			launchTemplateIDs(t, moduleOptions),
+			terraform.Output(t, moduleOptions, "apprunner_service_arn"),
+			config.SecurityGroupExpectation(stackName))
+	})
+
+	t.Run("Security/EFSConfiguration", func(t *testing.T) {
+		expected := config.EFSExpectation(publicSubnets, terraform.OutputList(t, moduleOptions, "security_group_ids"))
+		ValidateEFSConfiguration(t, moduleOptions, expected)
+		ValidatePlannedEFSConfiguration(t, PlanModule(t, moduleOptions), expected)
+	})
+
 	// ===== COMPLIANCE VALIDATIONS =====
 	t.Run("Compliance/S3Versioning", func(t *testing.T) {
 		ValidateS3BucketVersioning(t, configBucket, "Enabled")
@@ -300,6 +424,19 @@ func TestScenarioFullFeatured(t *testing.T) {
 		ValidateCloudWatchLogRetention(t, logGroupName)
 	})
 
+	t.Run("Compliance/Alarms", func(t *testing.T) {
+		// sqs_queue_oldest_message_threshold_seconds is left at 0, so only the budget alarm exists
+		ValidateAlarms(t, stackName, config.AlarmExpectations(stackName,
+			terraform.Output(t, moduleOptions, "apprunner_service_arn"),
+			terraform.Output(t, moduleOptions, "sqs_queue_main_url"),
+			terraform.Output(t, moduleOptions, "sns_topic_arn")))
+	})
+
+	t.Run("Compliance/ECRRepository", func(t *testing.T) {
+		now := time.Now()
+		ValidateECRRepository(t, terraform.Output(t, moduleOptions, "ecr_repository_name"), EphemeralRegistryExpectation(now), now)
+	})
+
 	// ===== ADVANCED VALIDATIONS =====
 	t.Run("Advanced/AppRunnerHealth", func(t *testing.T) {
 		ValidateAppRunnerHealth(t, appRunnerURL, 10)
@@ -414,9 +551,15 @@ func TestScenarioFullFeatured(t *testing.T) {
 		conclusion := WaitForWorkflowCompletion(t, testRepo, runID, 10*time.Minute)
 		assert.Equal(t, "success", conclusion, "Workflow should succeed")
 
-		// Validate runner was launched
-		launched := ValidateRunnerLaunched(t, stackName, startTime)
-		assert.True(t, launched, "Runner instance should have been launched")
+		// Each job's log should name a runner the stack launched; failed job logs are attached
+		jobs := ValidateWorkflowJobRunners(t, testRepo, runID, stackName, startTime)
+
+		// Exactly one runner per RunsOn job of this run, matched by the run and job ID tags
+		launches := ValidateRunnerLaunched(t, stackName, runID, RunsOnJobIDs(jobs))
+		assert.NotEmpty(t, launches, "Runner instance should have been launched")
+
+		// Runners terminate after their jobs and leave no volumes, ENIs or snapshots behind
+		ValidateRunnerCleanup(t, stackName, launches, RunnerTerminationTimeout, time.Duration(config.RunnerMaxRuntime)*time.Minute)
 	})
 
 	fmt.Printf("\n✅ Full-featured deployment successful!\n")
@@ -425,3 +568,545 @@ func TestScenarioFullFeatured(t *testing.T) {
 	fmt.Printf("   EFS: %s\n", efsFileSystemID)
 	fmt.Printf("   ECR: %s\n", ecrURL)
 }
+
+// TestScenarioRunnerLabels runs a matrix of label combinations with private_mode = "true" and checks
+// each job's runner matches its labels. Pushes a generated workflow to a throwaway branch of the
+// test repo, so it needs the RunsOn app registered (see Basic's JobExecution) and a GITHUB_TOKEN
+// with the workflow scope.
+func TestScenarioRunnerLabels(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("Skipping runner label matrix (requires NAT)")
+	}
+	if os.Getenv("GITHUB_TOKEN") == "" {
+		t.Skip("GITHUB_TOKEN not set")
+	}
+	testRepo := os.Getenv("RUNS_ON_TEST_REPO")
+	if testRepo == "" {
+		testRepo = os.Getenv("GITHUB_REPOSITORY")
+	}
+	if testRepo == "" {
+		t.Skip("RUNS_ON_TEST_REPO or GITHUB_REPOSITORY not set")
+	}
+
+	config := DefaultScenarioConfig()
+	config.EnableEFS = false
+	config.EnableECR = false
+	config.EnableNAT = true
+	config.PrivateMode = "true" // Runners opt into the private subnets with the private=true label
+
+	// Deploy VPC with NAT, so the private subnets are passed to the module
+	vpcOptions := &terraform.Options{
+		TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
+		TerraformBinary: "tofu",
+		Vars:            config.ToVPCVars(),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, vpcOptions)
+	terraform.InitAndApply(t, vpcOptions)
+
+	vpcID := terraform.Output(t, vpcOptions, "vpc_id")
+	publicSubnets := terraform.OutputList(t, vpcOptions, "public_subnets")
+	privateSubnets := terraform.OutputList(t, vpcOptions, "private_subnets")
+
+	moduleOptions := &terraform.Options{
+		TerraformDir:    copyModuleToTemp(t),
+		TerraformBinary: "tofu",
+		Vars:            config.ToModuleVars(vpcID, publicSubnets, privateSubnets),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, moduleOptions)
+	terraform.InitAndApply(t, moduleOptions)
+
+	stackName := terraform.Output(t, moduleOptions, "stack_name")
+	appRunnerURL := terraform.Output(t, moduleOptions, "apprunner_service_url")
+
+	ValidateAppRunnerHealth(t, appRunnerURL, 20)
+	err := WaitForAppRegistration(t, appRunnerURL, testRepo, GetTestID(), 30*time.Minute)
+	require.NoError(t, err, "RunsOn app not registered")
+
+	ValidateRunnerLabelMatrix(t, testRepo, stackName, moduleOptions.Vars["environment"].(string),
+		RunnerLabelMatrix(config), config.RunnerLabelExpectation(publicSubnets, privateSubnets))
+
+	fmt.Printf("\n✅ Runner label matrix successful!\n")
+	fmt.Printf("   Stack: %s\n", stackName)
+}
+
+// TestScenarioWindows tests that Windows runners launched from the Windows launch template
+// get the same S3 and CloudWatch access as Linux runners
+func TestScenarioWindows(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("Skipping Windows scenario (Windows instances take 10+ minutes to become SSM-ready)")
+	}
+
+	config := DefaultScenarioConfig()
+	config.EnableEFS = false
+	config.EnableECR = false
+	config.EnableNAT = false
+
+	// Deploy VPC first
+	vpcOptions := &terraform.Options{
+		TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
+		TerraformBinary: "tofu",
+		Vars:            config.ToVPCVars(),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, vpcOptions)
+	terraform.InitAndApply(t, vpcOptions)
+
+	// Get VPC outputs
+	vpcID := terraform.Output(t, vpcOptions, "vpc_id")
+	publicSubnets := terraform.OutputList(t, vpcOptions, "public_subnets")
+	privateSubnets := terraform.OutputList(t, vpcOptions, "private_subnets")
+
+	// Deploy runs-on module (root module)
+	moduleOptions := &terraform.Options{
+		TerraformDir:    copyModuleToTemp(t),
+		TerraformBinary: "tofu",
+		Vars:            config.ToModuleVars(vpcID, publicSubnets, privateSubnets),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, moduleOptions)
+	terraform.InitAndApply(t, moduleOptions)
+
+	// Get outputs
+	stackName := terraform.Output(t, moduleOptions, "stack_name")
+	configBucket := terraform.Output(t, moduleOptions, "config_bucket_name")
+	cacheBucket := terraform.Output(t, moduleOptions, "cache_bucket_name")
+	logGroupName := terraform.Output(t, moduleOptions, "ec2_instance_log_group_name")
+
+	// ===== FUNCTIONAL VALIDATIONS =====
+	// Same checks as the Linux scenarios, run through AWS-RunPowerShellScript
+	t.Run("Functional", func(t *testing.T) {
+		launchTemplateID := terraform.Output(t, moduleOptions, "launch_template_windows_default_id")
+		require.NotEmpty(t, launchTemplateID, "Windows launch template ID should not be empty")
+
+		// Launch Windows instance in public subnet (needs public IP for SSM)
+		instanceID := LaunchTestInstanceForOS(t, OSWindows, launchTemplateID, publicSubnets[0], true)
+		defer TerminateTestInstance(t, instanceID)
+
+		// Windows boots and registers with SSM much slower than Amazon Linux
+		ready := WaitForInstanceReady(t, instanceID, 15*time.Minute)
+		require.True(t, ready, "Windows instance failed to become SSM-ready within timeout")
+
+		t.Run("S3Access", func(t *testing.T) {
+			ValidateS3AccessFromEC2ForOS(t, OSWindows, instanceID, cacheBucket, configBucket)
+		})
+
+		t.Run("CloudWatchLogging", func(t *testing.T) {
+			ValidateEC2CloudWatchLogsForOS(t, OSWindows, instanceID, logGroupName)
+		})
+	})
+
+	fmt.Printf("\n✅ Windows scenario deployment successful!\n")
+	fmt.Printf("   Stack: %s\n", stackName)
+}
+
+// TestScenarioBringYourOwnSecurityGroup tests that user-provided security groups are passed
+// through to launch templates and App Runner untouched, instead of creating a runner security group.
+// A second stack in the same VPC covers the created group with SSH not allowed.
+func TestScenarioBringYourOwnSecurityGroup(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("Skipping bring-your-own security group scenario")
+	}
+
+	config := DefaultScenarioConfig()
+	config.EnableBYOSecurityGroup = true
+	sshOffConfig := DefaultScenarioConfig()
+	sshOffConfig.TestID = config.TestID + "-b"
+	sshOffConfig.SSHAllowed = false
+
+	// Deploy VPC with the bring-your-own security group
+	vpcOptions := &terraform.Options{
+		TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
+		TerraformBinary: "tofu",
+		Vars:            config.ToVPCVars(),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, vpcOptions)
+	terraform.InitAndApply(t, vpcOptions)
+
+	// Get VPC outputs
+	vpcID := terraform.Output(t, vpcOptions, "vpc_id")
+	publicSubnets := terraform.OutputList(t, vpcOptions, "public_subnets")
+	privateSubnets := terraform.OutputList(t, vpcOptions, "private_subnets")
+	config.SecurityGroupIDs = terraform.OutputList(t, vpcOptions, "byo_security_group_ids")
+	require.Len(t, config.SecurityGroupIDs, 1, "VPC fixture should create one bring-your-own security group")
+
+	// Deploy runs-on module with security_group_ids set
+	moduleOptions := &terraform.Options{
+		TerraformDir:    copyModuleToTemp(t),
+		TerraformBinary: "tofu",
+		Vars:            config.ToModuleVars(vpcID, publicSubnets, privateSubnets),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, moduleOptions)
+	terraform.InitAndApply(t, moduleOptions)
+
+	// Deploy a second stack that creates its runner security group without SSH ingress
+	sshOffOptions := &terraform.Options{
+		TerraformDir:    copyModuleToTemp(t),
+		TerraformBinary: "tofu",
+		Vars:            sshOffConfig.ToModuleVars(vpcID, publicSubnets, privateSubnets),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, sshOffOptions)
+	terraform.InitAndApply(t, sshOffOptions)
+
+	stackName := terraform.Output(t, moduleOptions, "stack_name")
+	sshOffStackName := terraform.Output(t, sshOffOptions, "stack_name")
+
+	t.Run("Security/RunnerSecurityGroups", func(t *testing.T) {
+		ValidateRunnerSecurityGroups(t,
+			terraform.OutputList(t, moduleOptions, "security_group_ids"),
+			launchTemplateIDs(t, moduleOptions),
+			terraform.Output(t, moduleOptions, "apprunner_service_arn"),
+			config.SecurityGroupExpectation(stackName))
+	})
+
+	t.Run("Security/RunnerSecurityGroupsWithoutSSH", func(t *testing.T) {
+		ValidateRunnerSecurityGroups(t,
+			terraform.OutputList(t, sshOffOptions, "security_group_ids"),
+			launchTemplateIDs(t, sshOffOptions),
+			terraform.Output(t, sshOffOptions, "apprunner_service_arn"),
+			sshOffConfig.SecurityGroupExpectation(sshOffStackName))
+	})
+
+	fmt.Printf("\n✅ Bring-your-own security group deployment successful!\n")
+	fmt.Printf("   Stacks: %s, %s (SSH not allowed)\n", stackName, sshOffStackName)
+	fmt.Printf("   Security groups: %v\n", config.SecurityGroupIDs)
+}
+
+// TestScenarioPermissionBoundary tests that permission_boundary_arn reaches every IAM role the
+// module creates, in the plan and on the deployed stack
+func TestScenarioPermissionBoundary(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("Skipping permission boundary scenario")
+	}
+
+	config := DefaultScenarioConfig()
+	config.EnablePermissionBoundary = true
+	// Creates the Slack webhook role too; alerts are never delivered during the test
+	config.AlertSlackWebhookURL = "https://hooks.slack.com/services/T00000000/B00000000/terratest"
+
+	// Deploy VPC with the boundary policy
+	vpcOptions := &terraform.Options{
+		TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
+		TerraformBinary: "tofu",
+		Vars:            config.ToVPCVars(),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, vpcOptions)
+	terraform.InitAndApply(t, vpcOptions)
+
+	// Get VPC outputs
+	vpcID := terraform.Output(t, vpcOptions, "vpc_id")
+	publicSubnets := terraform.OutputList(t, vpcOptions, "public_subnets")
+	privateSubnets := terraform.OutputList(t, vpcOptions, "private_subnets")
+	config.PermissionBoundaryARN = terraform.Output(t, vpcOptions, "permission_boundary_arn")
+	require.NotEmpty(t, config.PermissionBoundaryARN, "VPC fixture should create the boundary policy")
+
+	// Deploy runs-on module with permission_boundary_arn set
+	moduleOptions := &terraform.Options{
+		TerraformDir:    copyModuleToTemp(t),
+		TerraformBinary: "tofu",
+		Vars:            config.ToModuleVars(vpcID, publicSubnets, privateSubnets),
+		NoColor:         true,
+	}
+	stackName := moduleOptions.Vars["stack_name"].(string)
+	// apprunner-ecr-access needs a private app image; TestModuleRolesSetPermissionsBoundary covers it offline
+	roles := []string{"ec2-instance-role", "apprunner-role", "scheduler-role", "slack-webhook-role"}
+
+	t.Run("Plan/PermissionBoundaries", func(t *testing.T) {
+		ValidatePlannedPermissionBoundaries(t, PlanModule(t, moduleOptions), stackName, config.PermissionBoundaryARN, roles)
+	})
+
+	defer terraform.Destroy(t, moduleOptions)
+	terraform.InitAndApply(t, moduleOptions)
+
+	t.Run("Security/PermissionBoundaries", func(t *testing.T) {
+		ValidatePermissionBoundaries(t, stackName, config.PermissionBoundaryARN, roles)
+	})
+
+	fmt.Printf("\n✅ Permission boundary deployment successful!\n")
+	fmt.Printf("   Stack: %s\n", stackName)
+	fmt.Printf("   Boundary: %s\n", config.PermissionBoundaryARN)
+}
+
+// TestScenarioCrossStackIsolation deploys two stacks into the shared fixture VPC and checks that
+// a runner of stack A cannot reach the buckets, volumes or queues of stack B
+func TestScenarioCrossStackIsolation(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("Skipping cross-stack isolation scenario")
+	}
+
+	config := DefaultScenarioConfig()
+	otherConfig := config
+	otherConfig.TestID = config.TestID + "-b"
+
+	// Deploy the VPC both stacks share
+	vpcOptions := &terraform.Options{
+		TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
+		TerraformBinary: "tofu",
+		Vars:            config.ToVPCVars(),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, vpcOptions)
+	terraform.InitAndApply(t, vpcOptions)
+
+	// Get VPC outputs
+	vpcID := terraform.Output(t, vpcOptions, "vpc_id")
+	publicSubnets := terraform.OutputList(t, vpcOptions, "public_subnets")
+	privateSubnets := terraform.OutputList(t, vpcOptions, "private_subnets")
+	azs := terraform.OutputList(t, vpcOptions, "azs")
+
+	// Each stack gets its own copy of the module so their states stay apart
+	moduleOptions := &terraform.Options{
+		TerraformDir:    copyModuleToTemp(t),
+		TerraformBinary: "tofu",
+		Vars:            config.ToModuleVars(vpcID, publicSubnets, privateSubnets),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, moduleOptions)
+	terraform.InitAndApply(t, moduleOptions)
+
+	otherModuleOptions := &terraform.Options{
+		TerraformDir:    copyModuleToTemp(t),
+		TerraformBinary: "tofu",
+		Vars:            otherConfig.ToModuleVars(vpcID, publicSubnets, privateSubnets),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, otherModuleOptions)
+	terraform.InitAndApply(t, otherModuleOptions)
+
+	vars := iamBaselineVars(t, moduleOptions, config)
+	otherVars := iamBaselineVars(t, otherModuleOptions, otherConfig)
+
+	t.Run("Security/CrossStackPermissions", func(t *testing.T) {
+		ValidateCrossStackPermissions(t, terraform.Output(t, moduleOptions, "ec2_instance_role_name"), vars, otherVars)
+	})
+
+	t.Run("Functional/CrossStackIsolation", func(t *testing.T) {
+		// Stands in for a runner volume of stack B
+		volumeID := CreateStackVolume(t, otherVars.StackName, azs[0])
+		defer DeleteStackVolume(t, volumeID)
+
+		launchTemplateID := terraform.Output(t, moduleOptions, "launch_template_linux_default_id")
+		instanceID := LaunchTestInstance(t, launchTemplateID, publicSubnets[0], true)
+		defer TerminateTestInstance(t, instanceID)
+
+		ready := WaitForInstanceReady(t, instanceID, 5*time.Minute)
+		require.True(t, ready, "Instance failed to become SSM-ready within timeout")
+
+		ValidateCrossStackIsolation(t, instanceID, CrossStackTarget{
+			StackName:    otherVars.StackName,
+			ConfigBucket: otherVars.ConfigBucket,
+			QueueURL:     terraform.Output(t, otherModuleOptions, "sqs_queue_main_url"),
+			VolumeID:     volumeID,
+		})
+	})
+
+	fmt.Printf("\n✅ Cross-stack isolation deployment successful!\n")
+	fmt.Printf("   Stacks: %s, %s\n", vars.StackName, otherVars.StackName)
+}
+
+// TestScenarioSubnetMatrix plans the VPC fixture and the module with 1 to 4 subnets without
+// deploying anything. The fixture must place the subnets in distinct AZs of AWS_REGION and the
+// module must plan exactly one EFS mount target per subnet.
+func TestScenarioSubnetMatrix(t *testing.T) {
+	t.Parallel()
+
+	for subnetCount := 1; subnetCount <= 4; subnetCount++ {
+		t.Run(fmt.Sprintf("Subnets%d", subnetCount), func(t *testing.T) {
+			t.Parallel()
+
+			config := DefaultScenarioConfig()
+			config.SubnetCount = subnetCount
+			config.EnableEFS = true
+
+			// Each plan runs in its own copy so parallel inits don't share .terraform
+			vpcOptions := &terraform.Options{
+				TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
+				TerraformBinary: "tofu",
+				Vars:            config.ToVPCVars(),
+				NoColor:         true,
+			}
+			ValidatePlannedFixtureSubnets(t, PlanModule(t, vpcOptions), config.AWSRegion, subnetCount)
+
+			publicSubnets := placeholderSubnetIDs("public", subnetCount)
+			moduleOptions := &terraform.Options{
+				TerraformDir:    copyModuleToTemp(t),
+				TerraformBinary: "tofu",
+				Vars:            config.ToModuleVars(placeholderVPCID, publicSubnets, placeholderSubnetIDs("private", subnetCount)),
+				NoColor:         true,
+			}
+			plan := PlanModule(t, moduleOptions)
+
+			assert.Len(t, plannedResources(plan, "aws_efs_mount_target"), subnetCount,
+				"Should plan one EFS mount target per subnet")
+			ValidatePlannedEFSConfiguration(t, plan, config.EFSExpectation(publicSubnets, nil))
+		})
+	}
+}
+
+// TestScenarioRunnerLeaks checks an already deployed, long-running stack for runners older than
+// runner_max_runtime and for detached volumes, ENIs and snapshots tagged with the stack. Deploys
+// nothing; set RUNS_ON_LEAK_CHECK_STACK to the stack name to run it.
+func TestScenarioRunnerLeaks(t *testing.T) {
+	stackName := os.Getenv("RUNS_ON_LEAK_CHECK_STACK")
+	if stackName == "" {
+		t.Skip("RUNS_ON_LEAK_CHECK_STACK not set")
+	}
+
+	maxRuntime, err := strconv.Atoi(GetOptionalEnv("RUNS_ON_RUNNER_MAX_RUNTIME", "720"))
+	require.NoError(t, err, "RUNS_ON_RUNNER_MAX_RUNTIME should be a number of minutes")
+
+	ValidateRunnerCleanup(t, stackName, nil, 0, time.Duration(maxRuntime)*time.Minute)
+}
+
+// TestScenarioSpotInterruption runs long jobs on spot runners and interrupts them: the jobs must be
+// retried on new runners, and the spot circuit breaker must trip so the retries run on-demand.
+// Interrupts through AWS FIS when RUNS_ON_FIS_ROLE_ARN is set, otherwise by sending the warning
+// event to the stack's events queue. Needs the RunsOn app registered (see Basic's JobExecution).
+func TestScenarioSpotInterruption(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("Skipping spot interruption drill")
+	}
+	if os.Getenv("GITHUB_TOKEN") == "" {
+		t.Skip("GITHUB_TOKEN not set")
+	}
+	testRepo := os.Getenv("RUNS_ON_TEST_REPO")
+	if testRepo == "" {
+		testRepo = os.Getenv("GITHUB_REPOSITORY")
+	}
+	if testRepo == "" {
+		t.Skip("RUNS_ON_TEST_REPO or GITHUB_REPOSITORY not set")
+	}
+
+	config := DefaultScenarioConfig()
+	config.EnableEFS = false
+	config.EnableECR = false
+	config.EnableNAT = false
+	breaker, err := ParseSpotCircuitBreaker(config.SpotCircuitBreaker)
+	require.NoError(t, err)
+
+	method := SpotInterruptionEvent
+	fisRoleARN := os.Getenv("RUNS_ON_FIS_ROLE_ARN")
+	if fisRoleARN != "" {
+		method = SpotInterruptionFIS
+	}
+
+	// Deploy VPC first
+	vpcOptions := &terraform.Options{
+		TerraformDir:    copyTerraformToTemp(t, "fixtures/vpc"),
+		TerraformBinary: "tofu",
+		Vars:            config.ToVPCVars(),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, vpcOptions)
+	terraform.InitAndApply(t, vpcOptions)
+
+	vpcID := terraform.Output(t, vpcOptions, "vpc_id")
+	publicSubnets := terraform.OutputList(t, vpcOptions, "public_subnets")
+	privateSubnets := terraform.OutputList(t, vpcOptions, "private_subnets")
+
+	moduleOptions := &terraform.Options{
+		TerraformDir:    copyModuleToTemp(t),
+		TerraformBinary: "tofu",
+		Vars:            config.ToModuleVars(vpcID, publicSubnets, privateSubnets),
+		NoColor:         true,
+	}
+	defer terraform.Destroy(t, moduleOptions)
+	terraform.InitAndApply(t, moduleOptions)
+
+	appRunnerURL := terraform.Output(t, moduleOptions, "apprunner_service_url")
+	target := SpotDrillTarget{
+		StackName:      terraform.Output(t, moduleOptions, "stack_name"),
+		AccountID:      terraform.Output(t, moduleOptions, "aws_account_id"),
+		Environment:    moduleOptions.Vars["environment"].(string),
+		EventsQueueURL: terraform.Output(t, moduleOptions, "sqs_queue_events_url"),
+		AppLogGroup:    terraform.Output(t, moduleOptions, "apprunner_log_group_name"),
+	}
+
+	ValidateAppRunnerHealth(t, appRunnerURL, 20)
+	err = WaitForAppRegistration(t, appRunnerURL, testRepo, GetTestID(), 30*time.Minute)
+	require.NoError(t, err, "RunsOn app not registered")
+	t.Logf("Drill interrupts %d spot runners (%s)", breaker.Interruptions, method)
+
+	ValidateSpotInterruptionDrill(t, testRepo, target, breaker, method, fisRoleARN)
+	ValidateRunnerCleanup(t, target.StackName, nil, RunnerTerminationTimeout, time.Duration(config.RunnerMaxRuntime)*time.Minute)
+
+	fmt.Printf("\n✅ Spot interruption drill successful!\n")
+	fmt.Printf("   Stack: %s\n", target.StackName)
+	fmt.Printf("   Interruptions: %d via %s\n", breaker.Interruptions, method)
+}
+
+// iamBaselineVars collects the stack-specific values of the runner role's policies from the module outputs.
+func iamBaselineVars(t *testing.T, moduleOptions *terraform.Options, config ScenarioConfig) IAMBaselineVars {
+	vars := IAMBaselineVars{
+		Region:       GetAWSRegion(),
+		AccountID:    terraform.Output(t, moduleOptions, "aws_account_id"),
+		StackName:    terraform.Output(t, moduleOptions, "stack_name"),
+		ConfigBucket: terraform.Output(t, moduleOptions, "config_bucket_name"),
+		CacheBucket:  terraform.Output(t, moduleOptions, "cache_bucket_name"),
+	}
+	if config.EnableECR {
+		_, vars.EphemeralRegistry, _ = strings.Cut(terraform.Output(t, moduleOptions, "ecr_repository_url"), "/")
+	}
+	return vars
+}
+
+// launchTemplateIDs returns the IDs of all four runner launch templates
+func launchTemplateIDs(t *testing.T, moduleOptions *terraform.Options) []string {
+	var ids []string
+	for _, output := range []string{
+		"launch_template_linux_default_id",
+		"launch_template_windows_default_id",
+		"launch_template_linux_private_id",
+		"launch_template_windows_private_id",
+	} {
+		ids = append(ids, terraform.Output(t, moduleOptions, output))
+	}
+	return ids
+}
+
+// copyModuleToTemp copies the root module to a temp dir with fixtures/provider, which applies
+// the scenario's default tags through provider default_tags as the VPC fixture does.
+func copyModuleToTemp(t *testing.T) string {
+	dir := copyTerraformToTemp(t, "..")
+	require.NoError(t, files.CopyFile(filepath.Join("fixtures", "provider", "provider.tf"), filepath.Join(dir, "terratest_provider.tf")),
+		"Failed to add the test provider to %s", dir)
+	return dir
+}
+
+// copyTerraformToTemp copies the Terraform configuration in dir to a new temp dir, so scenarios
+// running in parallel don't share .terraform or state. Copying the repo root leaves out this test
+// suite. Hidden files and state are skipped as in files.CopyTerraformFolderToTemp.
+func copyTerraformToTemp(t *testing.T, dir string) string {
+	testDir, err := filepath.Abs(".")
+	require.NoError(t, err)
+	filter := func(path string) bool {
+		if abs, err := filepath.Abs(path); err == nil && abs == testDir {
+			return false
+		}
+		if files.PathIsTerraformLockFile(path) {
+			return true
+		}
+		return !files.PathContainsHiddenFileOrFolder(path) && !files.PathContainsTerraformStateOrVars(path)
+	}
+	tempDir, err := files.CopyFolderToTemp(dir, strings.ReplaceAll(t.Name(), "/", "-"), filter)
+	require.NoError(t, err, "Failed to copy %s to a temp dir", dir)
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+	return tempDir
+}
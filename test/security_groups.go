@@ -0,0 +1,217 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// SECURITY GROUP VALIDATORS
+// =============================================================================
+
+// RunnerSecurityGroupExpectation describes how security_groups.tf should have configured runner networking.
+type RunnerSecurityGroupExpectation struct {
+	StackName string
+
+	// ProvidedGroupIDs is the security_group_ids input. When non-empty the module must not
+	// create its own group and must pass these IDs through untouched.
+	ProvidedGroupIDs []string
+
+	SSHAllowed   bool
+	SSHCIDRRange string
+}
+
+// SecurityGroupExpectation builds the runner security group expectation for the scenario config
+func (c ScenarioConfig) SecurityGroupExpectation(stackName string) RunnerSecurityGroupExpectation {
+	return RunnerSecurityGroupExpectation{
+		StackName:        stackName,
+		ProvidedGroupIDs: c.SecurityGroupIDs,
+		SSHAllowed:       c.SSHAllowed,
+		SSHCIDRRange:     c.SSHCIDRRange,
+	}
+}
+
+// ValidateRunnerSecurityGroups checks the runner security groups and where they are wired:
+//   - The security_group_ids output is the created group, or exactly the provided groups
+//   - A created group has no ingress unless SSH is allowed, and then only TCP 22 from ssh_cidr_range
+//   - A created group allows all egress on IPv4 and IPv6, and nothing else
+//   - Provided groups get no module-managed rules
+//   - Every launch template and RUNS_ON_SECURITY_GROUP_ID use the same groups
+func ValidateRunnerSecurityGroups(t *testing.T, securityGroupIDs, launchTemplateIDs []string, appRunnerServiceArn string, expected RunnerSecurityGroupExpectation) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := ec2.NewFromConfig(cfg)
+
+	byo := len(expected.ProvidedGroupIDs) > 0
+	if byo {
+		assert.ElementsMatch(t, expected.ProvidedGroupIDs, securityGroupIDs,
+			"security_group_ids output should be the provided security groups")
+	} else {
+		require.Len(t, securityGroupIDs, 1, "Module should create exactly one runner security group")
+	}
+
+	rules, err := describeSecurityGroupRules(ctx, client, securityGroupIDs)
+	require.NoError(t, err, "Failed to describe security group rules")
+
+	if byo {
+		for _, rule := range rules {
+			assert.False(t, hasTag(rule.Tags, "runs-on-stack-name", expected.StackName),
+				"Provided security group %s should not get module-managed rule %s",
+				aws.ToString(rule.GroupId), aws.ToString(rule.SecurityGroupRuleId))
+		}
+		created, err := client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+			Filters: []ec2types.Filter{
+				{Name: aws.String("group-name"), Values: []string{expected.StackName + "-runners-*"}},
+			},
+		})
+		require.NoError(t, err, "Failed to describe security groups")
+		assert.Empty(t, created.SecurityGroups, "Module should not create a runner security group when groups are provided")
+	} else {
+		for _, violation := range runnerSecurityGroupRuleViolations(rules, expected) {
+			assert.Fail(t, violation)
+		}
+	}
+
+	for _, templateID := range launchTemplateIDs {
+		groups, err := getLaunchTemplateSecurityGroups(ctx, client, templateID)
+		require.NoError(t, err, "Failed to read launch template %s", templateID)
+		assert.ElementsMatch(t, securityGroupIDs, groups,
+			"Launch template %s should use the runner security groups", templateID)
+	}
+
+	if appRunnerServiceArn != "" {
+		env := GetAppRunnerEnvironment(t, appRunnerServiceArn)
+		assert.Equal(t, strings.Join(securityGroupIDs, ","), env["RUNS_ON_SECURITY_GROUP_ID"],
+			"RUNS_ON_SECURITY_GROUP_ID should list the runner security groups")
+	}
+
+	t.Logf("Runner security groups %v validated (byo=%t, ssh=%t)", securityGroupIDs, byo, expected.SSHAllowed)
+}
+
+// runnerSecurityGroupRuleViolations compares the rules of a module-created runner security group
+// with what security_groups.tf should produce. Returns one message per violation.
+func runnerSecurityGroupRuleViolations(rules []ec2types.SecurityGroupRule, expected RunnerSecurityGroupExpectation) []string {
+	var violations []string
+	var ingress, egress []ec2types.SecurityGroupRule
+	for _, rule := range rules {
+		if aws.ToBool(rule.IsEgress) {
+			egress = append(egress, rule)
+		} else {
+			ingress = append(ingress, rule)
+		}
+	}
+
+	if !expected.SSHAllowed {
+		for _, rule := range ingress {
+			violations = append(violations, fmt.Sprintf("unexpected ingress rule %s while SSH is not allowed", describeRule(rule)))
+		}
+	} else {
+		if len(ingress) != 1 {
+			violations = append(violations, fmt.Sprintf("expected exactly 1 SSH ingress rule, got %d", len(ingress)))
+		}
+		for _, rule := range ingress {
+			if aws.ToString(rule.IpProtocol) != "tcp" || aws.ToInt32(rule.FromPort) != 22 || aws.ToInt32(rule.ToPort) != 22 {
+				violations = append(violations, fmt.Sprintf("ingress rule %s should be tcp/22", describeRule(rule)))
+			}
+			if aws.ToString(rule.CidrIpv4) != expected.SSHCIDRRange {
+				violations = append(violations, fmt.Sprintf("ingress rule %s should allow exactly %s", describeRule(rule), expected.SSHCIDRRange))
+			}
+			if rule.CidrIpv6 != nil || rule.ReferencedGroupInfo != nil || rule.PrefixListId != nil {
+				violations = append(violations, fmt.Sprintf("ingress rule %s should only have an IPv4 CIDR source", describeRule(rule)))
+			}
+		}
+	}
+
+	wantEgress := map[string]bool{"-1 0.0.0.0/0": false, "-1 ::/0": false}
+	for _, rule := range egress {
+		key := fmt.Sprintf("%s %s%s", aws.ToString(rule.IpProtocol), aws.ToString(rule.CidrIpv4), aws.ToString(rule.CidrIpv6))
+		if _, ok := wantEgress[key]; !ok {
+			violations = append(violations, fmt.Sprintf("unexpected egress rule %s", describeRule(rule)))
+			continue
+		}
+		wantEgress[key] = true
+	}
+	var missing []string
+	for key, found := range wantEgress {
+		if !found {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		violations = append(violations, fmt.Sprintf("missing all-traffic egress rule %q", key))
+	}
+
+	return violations
+}
+
+// describeSecurityGroupRules returns every rule of the given security groups.
+func describeSecurityGroupRules(ctx context.Context, client *ec2.Client, groupIDs []string) ([]ec2types.SecurityGroupRule, error) {
+	var rules []ec2types.SecurityGroupRule
+	paginator := ec2.NewDescribeSecurityGroupRulesPaginator(client, &ec2.DescribeSecurityGroupRulesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("group-id"), Values: groupIDs},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, page.SecurityGroupRules...)
+	}
+	return rules, nil
+}
+
+// getLaunchTemplateSecurityGroups returns the security groups of the latest launch template version.
+func getLaunchTemplateSecurityGroups(ctx context.Context, client *ec2.Client, launchTemplateID string) ([]string, error) {
+	result, err := client.DescribeLaunchTemplateVersions(ctx, &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateId: aws.String(strings.Split(launchTemplateID, ":")[0]),
+		Versions:         []string{"$Latest"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.LaunchTemplateVersions) == 0 {
+		return nil, fmt.Errorf("launch template %s has no versions", launchTemplateID)
+	}
+
+	data := result.LaunchTemplateVersions[0].LaunchTemplateData
+	groups := append([]string{}, data.SecurityGroupIds...)
+	for _, ni := range data.NetworkInterfaces {
+		groups = append(groups, ni.Groups...)
+	}
+	return groups, nil
+}
+
+// describeRule formats a security group rule for assertion messages.
+func describeRule(rule ec2types.SecurityGroupRule) string {
+	source := aws.ToString(rule.CidrIpv4) + aws.ToString(rule.CidrIpv6)
+	if rule.ReferencedGroupInfo != nil {
+		source = aws.ToString(rule.ReferencedGroupInfo.GroupId)
+	}
+	if rule.PrefixListId != nil {
+		source = aws.ToString(rule.PrefixListId)
+	}
+	return fmt.Sprintf("%s(%s %d-%d %s)", aws.ToString(rule.SecurityGroupRuleId),
+		aws.ToString(rule.IpProtocol), aws.ToInt32(rule.FromPort), aws.ToInt32(rule.ToPort), source)
+}
+
+// hasTag reports whether the tag list contains key=value.
+func hasTag(tags []ec2types.Tag, key, value string) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key && aws.ToString(tag.Value) == value {
+			return true
+		}
+	}
+	return false
+}
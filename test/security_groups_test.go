@@ -0,0 +1,59 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// sgRule builds a security group rule for rule validation tests
+func sgRule(egress bool, protocol string, port int32, cidrV4, cidrV6 string) ec2types.SecurityGroupRule {
+	rule := ec2types.SecurityGroupRule{
+		SecurityGroupRuleId: aws.String("sgr-test"),
+		IsEgress:            aws.Bool(egress),
+		IpProtocol:          aws.String(protocol),
+		FromPort:            aws.Int32(port),
+		ToPort:              aws.Int32(port),
+	}
+	if cidrV4 != "" {
+		rule.CidrIpv4 = aws.String(cidrV4)
+	}
+	if cidrV6 != "" {
+		rule.CidrIpv6 = aws.String(cidrV6)
+	}
+	return rule
+}
+
+func TestRunnerSecurityGroupRuleViolations(t *testing.T) {
+	egressV4 := sgRule(true, "-1", -1, "0.0.0.0/0", "")
+	egressV6 := sgRule(true, "-1", -1, "", "::/0")
+	ssh := sgRule(false, "tcp", 22, "10.0.0.0/8", "")
+	sshAllowed := RunnerSecurityGroupExpectation{SSHAllowed: true, SSHCIDRRange: "10.0.0.0/8"}
+	sshDenied := RunnerSecurityGroupExpectation{SSHAllowed: false, SSHCIDRRange: "10.0.0.0/8"}
+
+	testCases := []struct {
+		name       string
+		rules      []ec2types.SecurityGroupRule
+		expected   RunnerSecurityGroupExpectation
+		violations int
+	}{
+		{"SSHAllowed", []ec2types.SecurityGroupRule{ssh, egressV4, egressV6}, sshAllowed, 0},
+		{"SSHDisabled", []ec2types.SecurityGroupRule{egressV4, egressV6}, sshDenied, 0},
+		{"IngressWhenSSHDisabled", []ec2types.SecurityGroupRule{ssh, egressV4, egressV6}, sshDenied, 1},
+		{"MissingSSHRule", []ec2types.SecurityGroupRule{egressV4, egressV6}, sshAllowed, 1},
+		{"WrongSSHCIDR", []ec2types.SecurityGroupRule{sgRule(false, "tcp", 22, "0.0.0.0/0", ""), egressV4, egressV6}, sshAllowed, 1},
+		{"WrongSSHPort", []ec2types.SecurityGroupRule{sgRule(false, "tcp", 2222, "10.0.0.0/8", ""), egressV4, egressV6}, sshAllowed, 1},
+		{"IPv6SSHSource", []ec2types.SecurityGroupRule{ssh, sgRule(false, "tcp", 22, "", "::/0"), egressV4, egressV6}, sshAllowed, 3},
+		{"MissingIPv6Egress", []ec2types.SecurityGroupRule{ssh, egressV4}, sshAllowed, 1},
+		{"ExtraEgress", []ec2types.SecurityGroupRule{ssh, egressV4, egressV6, sgRule(true, "tcp", 443, "0.0.0.0/0", "")}, sshAllowed, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := runnerSecurityGroupRuleViolations(tc.rules, tc.expected)
+			assert.Len(t, violations, tc.violations, "violations: %v", violations)
+		})
+	}
+}
@@ -0,0 +1,560 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/fis"
+	fistypes "github.com/aws/aws-sdk-go-v2/service/fis/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/google/go-github/v68/github"
+	"github.com/sjysngh/runs-on-tf/test/logsinsights"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// SPOT INTERRUPTION DRILL
+// =============================================================================
+
+// SpotInterruptionWarning is the detail-type of the event EC2 sends before reclaiming a spot instance.
+const SpotInterruptionWarning = "EC2 Spot Instance Interruption Warning"
+
+// spotInterruptionNotice is how long EC2 warns before reclaiming a spot instance.
+const spotInterruptionNotice = 2 * time.Minute
+
+// SpotInterruptionMethod selects how the drill interrupts spot runners.
+type SpotInterruptionMethod string
+
+const (
+	// SpotInterruptionEvent sends the warning event to the stack's events queue and terminates the
+	// instance once the notice has passed. EventBridge rejects PutEvents with the reserved aws.ec2
+	// source, so the event goes straight to the queue the spot interruption rule targets.
+	SpotInterruptionEvent SpotInterruptionMethod = "event"
+
+	// SpotInterruptionFIS runs an AWS FIS experiment with aws:ec2:send-spot-instance-interruptions,
+	// which has EC2 send the real warning and reclaim the instance.
+	SpotInterruptionFIS SpotInterruptionMethod = "fis"
+)
+
+// SpotCircuitBreaker is a parsed spot_circuit_breaker setting: after Interruptions interruptions
+// within Window, the app launches on-demand runners for Block.
+type SpotCircuitBreaker struct {
+	Interruptions int
+	Window        time.Duration
+	Block         time.Duration
+}
+
+// ParseSpotCircuitBreaker parses a spot_circuit_breaker value such as "2/15/30".
+func ParseSpotCircuitBreaker(value string) (SpotCircuitBreaker, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 3 {
+		return SpotCircuitBreaker{}, fmt.Errorf("spot circuit breaker %q is not <interruptions>/<window>/<block>", value)
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return SpotCircuitBreaker{}, fmt.Errorf("spot circuit breaker %q: %q is not a positive number", value, part)
+		}
+		numbers[i] = n
+	}
+	return SpotCircuitBreaker{
+		Interruptions: numbers[0],
+		Window:        time.Duration(numbers[1]) * time.Minute,
+		Block:         time.Duration(numbers[2]) * time.Minute,
+	}, nil
+}
+
+// SpotDrillTarget is the stack the drill runs against.
+type SpotDrillTarget struct {
+	StackName      string
+	AccountID      string
+	Environment    string // the module's environment input, matched by the env label
+	EventsQueueURL string // sqs_queue_events_url
+	AppLogGroup    string // apprunner_log_group_name
+}
+
+// spotInstanceARN returns the ARN of an instance in the given partition, region and account.
+func spotInstanceARN(partition, region, accountID, instanceID string) string {
+	return fmt.Sprintf("arn:%s:ec2:%s:%s:instance/%s", partition, region, accountID, instanceID)
+}
+
+// spotInterruptionWarningEvent returns the event EventBridge delivers to the events queue for a
+// spot interruption warning of the instance.
+func spotInterruptionWarningEvent(instanceID, partition, region, accountID string, at time.Time) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"version":     "0",
+		"id":          fmt.Sprintf("terratest-%s-%d", instanceID, at.UnixNano()),
+		"detail-type": SpotInterruptionWarning,
+		"source":      "aws.ec2",
+		"account":     accountID,
+		"time":        at.UTC().Format(time.RFC3339),
+		"region":      region,
+		"resources":   []string{spotInstanceARN(partition, region, accountID, instanceID)},
+		"detail": map[string]string{
+			"instance-id":     instanceID,
+			"instance-action": "terminate",
+		},
+	})
+}
+
+// renderSpotDrillWorkflow returns a workflow that runs the given number of long jobs on spot
+// runners retried when interrupted. Retried attempts finish right away.
+func renderSpotDrillWorkflow(branch, environment string, jobs int) string {
+	var names []string
+	for i := 1; i <= jobs; i++ {
+		names = append(names, strconv.Quote(fmt.Sprintf("drill-%d", i)))
+	}
+	var b strings.Builder
+	b.WriteString("name: RunsOn spot interruption drill\n")
+	b.WriteString("on:\n  push:\n    branches:\n")
+	fmt.Fprintf(&b, "      - %s\n", strconv.Quote(branch))
+	b.WriteString("jobs:\n  drill:\n    name: ${{ matrix.name }}\n")
+	fmt.Fprintf(&b, "    runs-on: runs-on=${{ github.run_id }}/env=%s/cpu=2/family=m7a+m7i+c7a+c7i/spot=true/retry=when-interrupted\n", environment)
+	b.WriteString("    strategy:\n      fail-fast: false\n      matrix:\n")
+	fmt.Fprintf(&b, "        name: [%s]\n", strings.Join(names, ", "))
+	b.WriteString(`    steps:
+      - name: Work until interrupted
+        run: |
+          if [ "${{ github.run_attempt }}" = "1" ]; then sleep 1500; fi
+`)
+	return b.String()
+}
+
+// appSpotInterruption is a spot_interruption record of the App Runner application log.
+type appSpotInterruption struct {
+	Time                 time.Time `json:"time"`
+	InstanceID           string    `json:"instance_id"`
+	TripCount            int       `json:"trip_count"`
+	CircuitBreakerActive bool      `json:"circuit_breaker_active"`
+}
+
+// spotBreakerLog is what the application log tells about spot interruptions.
+type spotBreakerLog struct {
+	Interruptions []appSpotInterruption
+	TrippedAt     time.Time // first time the circuit breaker was reported active, zero if never
+}
+
+// parseSpotBreakerLog reads the spot_interruption records and the circuit breaker state of the
+// metrics snapshots from application log events. Other events are ignored.
+func parseSpotBreakerLog(events []logsinsights.Event) spotBreakerLog {
+	var log spotBreakerLog
+	tripped := func(at time.Time) {
+		if log.TrippedAt.IsZero() || at.Before(log.TrippedAt) {
+			log.TrippedAt = at
+		}
+	}
+	for _, event := range events {
+		var record struct {
+			MetricType         string `json:"metric_type"`
+			SpotCircuitBreaker struct {
+				Active bool `json:"active"`
+			} `json:"spot_circuit_breaker"`
+		}
+		if json.Unmarshal([]byte(event.Message), &record) != nil {
+			continue
+		}
+		switch record.MetricType {
+		case "spot_interruption":
+			var interruption appSpotInterruption
+			if json.Unmarshal([]byte(event.Message), &interruption) != nil {
+				continue
+			}
+			if interruption.Time.IsZero() {
+				interruption.Time = event.Timestamp
+			}
+			log.Interruptions = append(log.Interruptions, interruption)
+			if interruption.CircuitBreakerActive {
+				tripped(interruption.Time)
+			}
+		case "snapshot":
+			if record.SpotCircuitBreaker.Active {
+				tripped(event.Timestamp)
+			}
+		}
+	}
+	return log
+}
+
+// SpotDrillJob is one job of the drill: the runner that was interrupted and the runner and
+// conclusion of its retry. Retry is nil if the job was not retried.
+type SpotDrillJob struct {
+	Name            string
+	Interrupted     RunnerLaunch
+	Retry           *RunnerLaunch
+	RetryConclusion string
+}
+
+// spotDrillViolations checks that every interrupted job was recorded by the app and retried on
+// a new runner that succeeded, and that once as many interruptions as the circuit breaker allows
+// have happened within its window, it tripped and retries launched while it blocks spot are
+// on-demand. Returns one message per violation.
+func spotDrillViolations(jobs []SpotDrillJob, log spotBreakerLog, breaker SpotCircuitBreaker) []string {
+	recorded := map[string]bool{}
+	for _, interruption := range log.Interruptions {
+		recorded[interruption.InstanceID] = true
+	}
+
+	var violations []string
+	for _, job := range jobs {
+		interrupted := job.Interrupted
+		if interrupted.InstanceID == "" {
+			violations = append(violations, fmt.Sprintf("job %s: no runner instance tagged with its first attempt", job.Name))
+			continue
+		}
+		if !interrupted.Spot {
+			violations = append(violations, fmt.Sprintf("job %s: first runner %s is on-demand, the drill needs a spot runner", job.Name, interrupted.InstanceID))
+		}
+		if !recorded[interrupted.InstanceID] {
+			violations = append(violations, fmt.Sprintf("job %s: the app logged no spot interruption of %s", job.Name, interrupted.InstanceID))
+		}
+		if job.Retry == nil {
+			violations = append(violations, fmt.Sprintf("job %s: not retried after %s was interrupted", job.Name, interrupted.InstanceID))
+			continue
+		}
+		if job.Retry.InstanceID == interrupted.InstanceID {
+			violations = append(violations, fmt.Sprintf("job %s: retry ran on the interrupted runner %s", job.Name, interrupted.InstanceID))
+		}
+		if job.RetryConclusion != "success" {
+			violations = append(violations, fmt.Sprintf("job %s: retry concluded %q", job.Name, job.RetryConclusion))
+		}
+	}
+
+	if len(log.Interruptions) < breaker.Interruptions {
+		return violations
+	}
+	first, last := log.Interruptions[0].Time, log.Interruptions[breaker.Interruptions-1].Time
+	if last.Sub(first) > breaker.Window {
+		return append(violations, fmt.Sprintf("spot interruptions logged %v apart, outside the %v circuit breaker window",
+			last.Sub(first).Round(time.Second), breaker.Window))
+	}
+	if log.TrippedAt.IsZero() {
+		return append(violations, fmt.Sprintf("circuit breaker did not trip after %d spot interruptions", len(log.Interruptions)))
+	}
+	blockedUntil := log.TrippedAt.Add(breaker.Block)
+	for _, job := range jobs {
+		if job.Retry == nil || !job.Retry.Spot {
+			continue
+		}
+		if launched := job.Retry.LaunchTime; !launched.Before(log.TrippedAt) && launched.Before(blockedUntil) {
+			violations = append(violations, fmt.Sprintf("job %s: retry runner %s is spot although the circuit breaker tripped at %s",
+				job.Name, job.Retry.InstanceID, log.TrippedAt.UTC().Format(time.RFC3339)))
+		}
+	}
+	return violations
+}
+
+// spotDrillJobs pairs the first attempt of every job of the run with its latest attempt, using the
+// runner launches correlated to the job IDs of all attempts.
+func spotDrillJobs(jobs []*github.WorkflowJob, launches []RunnerLaunch) []SpotDrillJob {
+	byJobID := map[int64]RunnerLaunch{}
+	for _, launch := range launches {
+		byJobID[launch.JobID] = launch
+	}
+	first := map[string]*github.WorkflowJob{}
+	latest := map[string]*github.WorkflowJob{}
+	var names []string
+	for _, job := range jobs {
+		name := job.GetName()
+		if f, ok := first[name]; !ok || job.GetRunAttempt() < f.GetRunAttempt() {
+			if !ok {
+				names = append(names, name)
+			}
+			first[name] = job
+		}
+		if l, ok := latest[name]; !ok || job.GetRunAttempt() > l.GetRunAttempt() {
+			latest[name] = job
+		}
+	}
+
+	var drill []SpotDrillJob
+	for _, name := range names {
+		job := SpotDrillJob{Name: name, Interrupted: byJobID[first[name].GetID()]}
+		if retry := latest[name]; retry.GetRunAttempt() > first[name].GetRunAttempt() {
+			if launch, ok := byJobID[retry.GetID()]; ok {
+				job.Retry = &launch
+			}
+			job.RetryConclusion = retry.GetConclusion()
+		}
+		drill = append(drill, job)
+	}
+	return drill
+}
+
+// listRunJobsAllAttempts returns the jobs of every attempt of a run.
+func listRunJobsAllAttempts(ctx context.Context, client *github.Client, owner, repo string, runID int64) ([]*github.WorkflowJob, error) {
+	var jobs []*github.WorkflowJob
+	opts := &github.ListWorkflowJobsOptions{Filter: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Actions.ListWorkflowJobs(ctx, owner, repo, runID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs of run %d: %w", runID, err)
+		}
+		jobs = append(jobs, page.Jobs...)
+		if resp.NextPage == 0 {
+			return jobs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// waitForRetriedRun waits for a run to complete an attempt after the first. Returns the
+// conclusion and attempt of the run when that happens, or when the first attempt has been
+// completed for retryGrace without a retry starting.
+func waitForRetriedRun(t *testing.T, ctx context.Context, client *github.Client, owner, repo string, runID int64, retryGrace, timeout time.Duration) (string, int) {
+	deadline := time.Now().Add(timeout)
+	var completedAt time.Time
+	for time.Now().Before(deadline) {
+		run, _, err := client.Actions.GetWorkflowRunByID(ctx, owner, repo, runID)
+		if err != nil {
+			t.Logf("Error getting workflow status: %v", err)
+		} else {
+			attempt := run.GetRunAttempt()
+			t.Logf("Workflow attempt %d status: %s, conclusion: %s", attempt, run.GetStatus(), run.GetConclusion())
+			switch {
+			case run.GetStatus() != "completed":
+				completedAt = time.Time{}
+			case attempt > 1:
+				return run.GetConclusion(), attempt
+			case completedAt.IsZero():
+				completedAt = time.Now()
+			case time.Since(completedAt) > retryGrace:
+				return run.GetConclusion(), attempt
+			}
+		}
+		time.Sleep(15 * time.Second)
+	}
+	t.Logf("Timeout waiting for workflow run %d to be retried", runID)
+	return "", 0
+}
+
+// waitForDrillRunners waits until count runners of the run are running and returns them.
+func waitForDrillRunners(t *testing.T, ctx context.Context, client *ec2.Client, stackName string, runID int64, count int, timeout time.Duration) []ec2types.Instance {
+	deadline := time.Now().Add(timeout)
+	for {
+		instances, err := runRunnerInstances(ctx, client, stackName, runID)
+		require.NoError(t, err)
+		var running []ec2types.Instance
+		for _, instance := range instances {
+			if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameRunning {
+				running = append(running, instance)
+			}
+		}
+		if len(running) >= count {
+			return running[:count]
+		}
+		require.True(t, time.Now().Before(deadline), "Only %d of %d drill runners running after %v", len(running), count, timeout)
+		t.Logf("%d of %d drill runners running, waiting...", len(running), count)
+		time.Sleep(15 * time.Second)
+	}
+}
+
+// interruptWithEvents sends the interruption warning of every instance to the events queue, then
+// terminates the instances once the notice has passed, as EC2 would.
+func interruptWithEvents(t *testing.T, ctx context.Context, target SpotDrillTarget, instanceIDs []string) {
+	cfg := MustGetAWSConfig(ctx)
+	partition, err := GetAWSPartition(ctx, cfg)
+	require.NoError(t, err)
+	queue := sqs.NewFromConfig(cfg)
+	for _, instanceID := range instanceIDs {
+		body, err := spotInterruptionWarningEvent(instanceID, partition, GetAWSRegion(), target.AccountID, time.Now())
+		require.NoError(t, err)
+		_, err = queue.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:    aws.String(target.EventsQueueURL),
+			MessageBody: aws.String(string(body)),
+		})
+		require.NoError(t, err, "Failed to send the interruption warning of %s", instanceID)
+		t.Logf("Sent spot interruption warning for %s", instanceID)
+	}
+
+	time.Sleep(spotInterruptionNotice)
+	_, err = ec2.NewFromConfig(cfg).TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: instanceIDs})
+	require.NoError(t, err, "Failed to reclaim the interrupted runners")
+	t.Logf("Reclaimed %s", strings.Join(instanceIDs, ", "))
+}
+
+// interruptWithFIS runs a FIS experiment sending spot interruptions to every instance and waits
+// for it to complete. roleARN must allow ec2:SendSpotInstanceInterruptions.
+func interruptWithFIS(t *testing.T, ctx context.Context, target SpotDrillTarget, instanceIDs []string, roleARN string) {
+	cfg := MustGetAWSConfig(ctx)
+	client := fis.NewFromConfig(cfg)
+	partition, err := GetAWSPartition(ctx, cfg)
+	require.NoError(t, err)
+
+	var arns []string
+	for _, instanceID := range instanceIDs {
+		arns = append(arns, spotInstanceARN(partition, GetAWSRegion(), target.AccountID, instanceID))
+	}
+	template, err := client.CreateExperimentTemplate(ctx, &fis.CreateExperimentTemplateInput{
+		ClientToken: aws.String(fmt.Sprintf("%s-spot-drill-%d", target.StackName, time.Now().UnixNano())),
+		Description: aws.String("RunsOn spot interruption drill for " + target.StackName),
+		RoleArn:     aws.String(roleARN),
+		Actions: map[string]fistypes.CreateExperimentTemplateActionInput{
+			"interrupt": {
+				ActionId:   aws.String("aws:ec2:send-spot-instance-interruptions"),
+				Parameters: map[string]string{"durationBeforeInterruption": fmt.Sprintf("PT%dM", int(spotInterruptionNotice.Minutes()))},
+				Targets:    map[string]string{"SpotInstances": "runners"},
+			},
+		},
+		Targets: map[string]fistypes.CreateExperimentTemplateTargetInput{
+			"runners": {
+				ResourceType:  aws.String("aws:ec2:spot-instance"),
+				ResourceArns:  arns,
+				SelectionMode: aws.String("ALL"),
+			},
+		},
+		StopConditions: []fistypes.CreateExperimentTemplateStopConditionInput{{Source: aws.String("none")}},
+		Tags:           map[string]string{"runs-on-stack-name": target.StackName},
+	})
+	require.NoError(t, err, "Failed to create the FIS experiment template")
+	templateID := template.ExperimentTemplate.Id
+	defer func() {
+		if _, err := client.DeleteExperimentTemplate(ctx, &fis.DeleteExperimentTemplateInput{Id: templateID}); err != nil {
+			t.Logf("Warning: failed to delete FIS experiment template %s: %v", aws.ToString(templateID), err)
+		}
+	}()
+
+	experiment, err := client.StartExperiment(ctx, &fis.StartExperimentInput{
+		ClientToken:          aws.String(fmt.Sprintf("%s-spot-drill-%d", target.StackName, time.Now().UnixNano())),
+		ExperimentTemplateId: templateID,
+	})
+	require.NoError(t, err, "Failed to start the FIS experiment")
+	experimentID := experiment.Experiment.Id
+	t.Logf("Started FIS experiment %s against %s", aws.ToString(experimentID), strings.Join(instanceIDs, ", "))
+
+	deadline := time.Now().Add(spotInterruptionNotice + 5*time.Minute)
+	for time.Now().Before(deadline) {
+		result, err := client.GetExperiment(ctx, &fis.GetExperimentInput{Id: experimentID})
+		require.NoError(t, err, "Failed to get FIS experiment %s", aws.ToString(experimentID))
+		state := result.Experiment.State
+		switch state.Status {
+		case fistypes.ExperimentStatusCompleted:
+			t.Logf("FIS experiment %s completed", aws.ToString(experimentID))
+			return
+		case fistypes.ExperimentStatusFailed, fistypes.ExperimentStatusStopped, fistypes.ExperimentStatusCancelled:
+			require.Failf(t, "FIS experiment did not complete", "%s is %s: %s", aws.ToString(experimentID), state.Status, aws.ToString(state.Reason))
+		}
+		time.Sleep(15 * time.Second)
+	}
+	require.Failf(t, "FIS experiment did not complete", "%s still running after %v", aws.ToString(experimentID), spotInterruptionNotice+5*time.Minute)
+}
+
+// appLogEvents returns the spot interruption and metrics snapshot events of the application log
+// group since the given time.
+func appLogEvents(ctx context.Context, client *cloudwatchlogs.Client, logGroup string, since time.Time) ([]logsinsights.Event, error) {
+	var events []logsinsights.Event
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(client, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:  aws.String(logGroup),
+		StartTime:     aws.Int64(since.UnixMilli()),
+		FilterPattern: aws.String(`{ ($.metric_type = "spot_interruption") || ($.metric_type = "snapshot") }`),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter %s: %w", logGroup, err)
+		}
+		for _, event := range page.Events {
+			events = append(events, logsinsights.Event{
+				Timestamp: time.UnixMilli(aws.ToInt64(event.Timestamp)).UTC(),
+				Message:   aws.ToString(event.Message),
+				LogStream: aws.ToString(event.LogStreamName),
+				LogGroup:  logGroup,
+			})
+		}
+	}
+	return events, nil
+}
+
+// ValidateSpotInterruptionDrill pushes a workflow with one long spot job per interruption the
+// circuit breaker allows to a throwaway branch of repo, interrupts every job's runner once it
+// runs, and checks the app reacts as documented: each interruption is logged, each job is retried
+// on a new runner and succeeds, and the circuit breaker trips so the retries run on-demand.
+// The branch is deleted afterwards. The GitHub token needs the repo and workflow scopes.
+func ValidateSpotInterruptionDrill(t *testing.T, repo string, target SpotDrillTarget, breaker SpotCircuitBreaker, method SpotInterruptionMethod, fisRoleARN string) {
+	client, err := getGitHubClient()
+	require.NoError(t, err, "Failed to create GitHub client")
+
+	owner, repoName, err := parseRepo(repo)
+	require.NoError(t, err, "Invalid repo format")
+
+	ctx := context.Background()
+	startTime := time.Now()
+	branch := "terratest/" + target.StackName + "-spot-drill"
+	workflowPath := path.Join(".github/workflows", target.StackName+"-spot-drill.yml")
+
+	sha, deleteBranch, err := pushWorkflowBranch(ctx, client, owner, repoName, branch, workflowPath,
+		renderSpotDrillWorkflow(branch, target.Environment, breaker.Interruptions), "Add RunsOn spot interruption drill workflow")
+	if deleteBranch != nil {
+		defer func() {
+			if err := deleteBranch(); err != nil {
+				t.Logf("Warning: failed to delete branch %s: %v", branch, err)
+			}
+		}()
+	}
+	require.NoError(t, err, "Failed to push the spot drill workflow")
+
+	runID, err := waitForPushRun(t, ctx, client, owner, repoName, branch, sha, 5*time.Minute)
+	require.NoError(t, err, "Spot drill run not found")
+
+	err = MonitorWorkflowJobStates(t, repo, runID, 15*time.Minute)
+	require.NoError(t, err, "Spot drill jobs stuck in queue - is the RunsOn app registered?")
+
+	cfg := MustGetAWSConfig(ctx)
+	runners := waitForDrillRunners(t, ctx, ec2.NewFromConfig(cfg), target.StackName, runID, breaker.Interruptions, 10*time.Minute)
+	var instanceIDs []string
+	for _, runner := range runners {
+		instanceIDs = append(instanceIDs, aws.ToString(runner.InstanceId))
+	}
+
+	switch method {
+	case SpotInterruptionFIS:
+		interruptWithFIS(t, ctx, target, instanceIDs, fisRoleARN)
+	default:
+		interruptWithEvents(t, ctx, target, instanceIDs)
+	}
+
+	conclusion, attempt := waitForRetriedRun(t, ctx, client, owner, repoName, runID, 5*time.Minute, 30*time.Minute)
+	assert.Greater(t, attempt, 1, "Interrupted jobs should have been retried")
+	assert.Equal(t, "success", conclusion, "Retried spot drill run should succeed")
+
+	jobs, err := listRunJobsAllAttempts(ctx, client, owner, repoName, runID)
+	require.NoError(t, err)
+	var jobIDs []int64
+	for _, job := range jobs {
+		jobIDs = append(jobIDs, job.GetID())
+	}
+	instances, err := runRunnerInstances(ctx, ec2.NewFromConfig(cfg), target.StackName, runID)
+	require.NoError(t, err)
+	launches, mismatches := correlateRunnerLaunches(instances, jobIDs)
+	for _, mismatch := range mismatches {
+		assert.Fail(t, "Runner launch mismatch", mismatch)
+	}
+
+	events, err := appLogEvents(ctx, cloudwatchlogs.NewFromConfig(cfg), target.AppLogGroup, startTime)
+	require.NoError(t, err)
+	breakerLog := parseSpotBreakerLog(events)
+
+	drillJobs := spotDrillJobs(jobs, launches)
+	violations := spotDrillViolations(drillJobs, breakerLog, breaker)
+	for _, violation := range violations {
+		assert.Fail(t, "Spot interruption drill", violation)
+	}
+	if len(violations) == 0 {
+		for _, job := range drillJobs {
+			t.Logf("✓ Job %s: spot runner %s interrupted, retried on %s (spot=%t)",
+				job.Name, job.Interrupted.InstanceID, job.Retry.InstanceID, job.Retry.Spot)
+		}
+		t.Logf("✓ Circuit breaker tripped at %s after %d interruptions", breakerLog.TrippedAt.Format(time.RFC3339), len(breakerLog.Interruptions))
+	}
+}
@@ -0,0 +1,266 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+	"github.com/sjysngh/runs-on-tf/test/logsinsights"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseSpotCircuitBreaker(t *testing.T) {
+	breaker, err := ParseSpotCircuitBreaker(DefaultScenarioConfig().SpotCircuitBreaker)
+	require.NoError(t, err)
+	assert.Equal(t, SpotCircuitBreaker{Interruptions: 2, Window: 15 * time.Minute, Block: 30 * time.Minute}, breaker)
+
+	for _, value := range []string{"", "2/15", "2/15/30/1", "two/15/30", "0/15/30", "2/-1/30"} {
+		_, err := ParseSpotCircuitBreaker(value)
+		assert.Error(t, err, "value %q", value)
+	}
+}
+
+func TestSpotInterruptionWarningEvent(t *testing.T) {
+	at := time.Date(2025, 2, 6, 10, 2, 31, 0, time.UTC)
+	body, err := spotInterruptionWarningEvent("i-0123456789abcdef0", "aws-us-gov", "us-gov-west-1", "123456789012", at)
+	require.NoError(t, err)
+
+	var event struct {
+		DetailType string   `json:"detail-type"`
+		Source     string   `json:"source"`
+		Account    string   `json:"account"`
+		Region     string   `json:"region"`
+		Time       string   `json:"time"`
+		Resources  []string `json:"resources"`
+		Detail     struct {
+			InstanceID     string `json:"instance-id"`
+			InstanceAction string `json:"instance-action"`
+		} `json:"detail"`
+	}
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, SpotInterruptionWarning, event.DetailType)
+	assert.Equal(t, "aws.ec2", event.Source)
+	assert.Equal(t, "123456789012", event.Account)
+	assert.Equal(t, "us-gov-west-1", event.Region)
+	assert.Equal(t, "2025-02-06T10:02:31Z", event.Time)
+	assert.Equal(t, []string{"arn:aws-us-gov:ec2:us-gov-west-1:123456789012:instance/i-0123456789abcdef0"}, event.Resources)
+	assert.Equal(t, "i-0123456789abcdef0", event.Detail.InstanceID)
+	assert.Equal(t, "terminate", event.Detail.InstanceAction)
+}
+
+func TestRenderSpotDrillWorkflow(t *testing.T) {
+	var workflow struct {
+		On struct {
+			Push struct {
+				Branches []string `yaml:"branches"`
+			} `yaml:"push"`
+		} `yaml:"on"`
+		Jobs map[string]struct {
+			Name     string `yaml:"name"`
+			RunsOn   string `yaml:"runs-on"`
+			Strategy struct {
+				Matrix struct {
+					Name []string `yaml:"name"`
+				} `yaml:"matrix"`
+			} `yaml:"strategy"`
+			Steps []struct {
+				Run string `yaml:"run"`
+			} `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(renderSpotDrillWorkflow("terratest/test-123-spot-drill", "test", 2)), &workflow))
+
+	assert.Equal(t, []string{"terratest/test-123-spot-drill"}, workflow.On.Push.Branches)
+	job := workflow.Jobs["drill"]
+	assert.Equal(t, "${{ matrix.name }}", job.Name)
+	assert.Equal(t, "runs-on=${{ github.run_id }}/env=test/cpu=2/family=m7a+m7i+c7a+c7i/spot=true/retry=when-interrupted", job.RunsOn)
+	assert.Equal(t, []string{"drill-1", "drill-2"}, job.Strategy.Matrix.Name)
+	require.Len(t, job.Steps, 1)
+	assert.Contains(t, job.Steps[0].Run, `if [ "${{ github.run_attempt }}" = "1" ]; then sleep`)
+}
+
+func TestParseSpotBreakerLog(t *testing.T) {
+	corpus, err := LoadLogEvents(AppRunnerLogCorpus)
+	require.NoError(t, err)
+	log := parseSpotBreakerLog(corpus)
+	require.Len(t, log.Interruptions, 1)
+	assert.Equal(t, appSpotInterruption{
+		Time:       time.Date(2025, 2, 6, 10, 2, 31, 0, time.UTC),
+		InstanceID: "i-0123456789abcdef0",
+		TripCount:  1,
+	}, log.Interruptions[0])
+	assert.True(t, log.TrippedAt.IsZero(), "corpus breaker never trips")
+
+	// The breaker trips with the second interruption, before the next snapshot reports it
+	tripped := append(corpus,
+		logsinsights.Event{
+			Timestamp: time.Date(2025, 2, 6, 10, 7, 12, 0, time.UTC),
+			Message:   `{"time":"2025-02-06T10:07:12Z","metric_type":"spot_interruption","instance_id":"i-0fedcba9876543210","trip_count":2,"circuit_breaker_active":true}`,
+		},
+		logsinsights.Event{
+			Timestamp: time.Date(2025, 2, 6, 10, 8, 0, 0, time.UTC),
+			Message:   `{"time":"2025-02-06T10:08:00Z","metric_type":"snapshot","spot_circuit_breaker":{"active":true,"interruption_count":2}}`,
+		},
+		logsinsights.Event{Timestamp: time.Date(2025, 2, 6, 10, 9, 0, 0, time.UTC), Message: "not json"},
+	)
+	log = parseSpotBreakerLog(tripped)
+	require.Len(t, log.Interruptions, 2)
+	assert.Equal(t, "i-0fedcba9876543210", log.Interruptions[1].InstanceID)
+	assert.Equal(t, time.Date(2025, 2, 6, 10, 7, 12, 0, time.UTC), log.TrippedAt)
+}
+
+func TestSpotDrillJobs(t *testing.T) {
+	job := func(id int64, name string, attempt int, conclusion string) *github.WorkflowJob {
+		return &github.WorkflowJob{ID: github.Ptr(id), Name: github.Ptr(name), RunAttempt: github.Ptr(int64(attempt)), Conclusion: github.Ptr(conclusion)}
+	}
+	jobs := []*github.WorkflowJob{
+		job(11, "drill-1", 1, "failure"),
+		job(12, "drill-2", 1, "failure"),
+		job(21, "drill-1", 2, "success"),
+		job(22, "drill-2", 2, "success"),
+	}
+	launches := []RunnerLaunch{
+		{JobID: 11, InstanceID: "i-0000000000000000a", Spot: true},
+		{JobID: 12, InstanceID: "i-0000000000000000b", Spot: true},
+		{JobID: 21, InstanceID: "i-0000000000000000c"},
+	}
+
+	drill := spotDrillJobs(jobs, launches)
+	require.Len(t, drill, 2)
+	assert.Equal(t, SpotDrillJob{
+		Name:            "drill-1",
+		Interrupted:     launches[0],
+		Retry:           &launches[2],
+		RetryConclusion: "success",
+	}, drill[0])
+	// The retry of drill-2 has no runner, e.g. it was never picked up
+	assert.Equal(t, SpotDrillJob{Name: "drill-2", Interrupted: launches[1], RetryConclusion: "success"}, drill[1])
+
+	// Not retried
+	drill = spotDrillJobs(jobs[:2], launches)
+	require.Len(t, drill, 2)
+	assert.Nil(t, drill[0].Retry)
+	assert.Empty(t, drill[0].RetryConclusion)
+}
+
+func TestSpotDrillViolations(t *testing.T) {
+	breaker := SpotCircuitBreaker{Interruptions: 2, Window: 15 * time.Minute, Block: 30 * time.Minute}
+	trippedAt := time.Date(2025, 2, 6, 10, 7, 12, 0, time.UTC)
+	valid := func() ([]SpotDrillJob, spotBreakerLog) {
+		jobs := []SpotDrillJob{
+			{
+				Name:            "drill-1",
+				Interrupted:     RunnerLaunch{InstanceID: "i-0000000000000000a", Spot: true},
+				Retry:           &RunnerLaunch{InstanceID: "i-0000000000000000c", LaunchTime: trippedAt.Add(time.Minute)},
+				RetryConclusion: "success",
+			},
+			{
+				Name:            "drill-2",
+				Interrupted:     RunnerLaunch{InstanceID: "i-0000000000000000b", Spot: true},
+				Retry:           &RunnerLaunch{InstanceID: "i-0000000000000000d", LaunchTime: trippedAt.Add(time.Minute)},
+				RetryConclusion: "success",
+			},
+		}
+		log := spotBreakerLog{
+			Interruptions: []appSpotInterruption{
+				{Time: trippedAt.Add(-5 * time.Minute), InstanceID: "i-0000000000000000a", TripCount: 1},
+				{Time: trippedAt, InstanceID: "i-0000000000000000b", TripCount: 2, CircuitBreakerActive: true},
+			},
+			TrippedAt: trippedAt,
+		}
+		return jobs, log
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(jobs []SpotDrillJob, log *spotBreakerLog)
+		contains []string
+	}{
+		{
+			name:   "valid",
+			mutate: func([]SpotDrillJob, *spotBreakerLog) {},
+		},
+		{
+			name:     "no runner for the first attempt",
+			mutate:   func(jobs []SpotDrillJob, _ *spotBreakerLog) { jobs[0].Interrupted = RunnerLaunch{} },
+			contains: []string{"job drill-1: no runner instance tagged"},
+		},
+		{
+			name:     "first runner on-demand",
+			mutate:   func(jobs []SpotDrillJob, _ *spotBreakerLog) { jobs[1].Interrupted.Spot = false },
+			contains: []string{"job drill-2: first runner i-0000000000000000b is on-demand"},
+		},
+		{
+			name: "interruption not logged",
+			mutate: func(_ []SpotDrillJob, log *spotBreakerLog) {
+				log.Interruptions = log.Interruptions[:1]
+				log.TrippedAt = time.Time{}
+			},
+			contains: []string{"job drill-2: the app logged no spot interruption of i-0000000000000000b"},
+		},
+		{
+			name:     "not retried",
+			mutate:   func(jobs []SpotDrillJob, _ *spotBreakerLog) { jobs[0].Retry = nil },
+			contains: []string{"job drill-1: not retried"},
+		},
+		{
+			name: "retried on the same runner",
+			mutate: func(jobs []SpotDrillJob, _ *spotBreakerLog) {
+				jobs[0].Retry.InstanceID = jobs[0].Interrupted.InstanceID
+			},
+			contains: []string{"job drill-1: retry ran on the interrupted runner"},
+		},
+		{
+			name:     "retry failed",
+			mutate:   func(jobs []SpotDrillJob, _ *spotBreakerLog) { jobs[1].RetryConclusion = "failure" },
+			contains: []string{`job drill-2: retry concluded "failure"`},
+		},
+		{
+			name:     "breaker never trips",
+			mutate:   func(_ []SpotDrillJob, log *spotBreakerLog) { log.TrippedAt = time.Time{} },
+			contains: []string{"circuit breaker did not trip after 2 spot interruptions"},
+		},
+		{
+			name: "interruptions outside the window",
+			mutate: func(_ []SpotDrillJob, log *spotBreakerLog) {
+				log.Interruptions[0].Time = trippedAt.Add(-20 * time.Minute)
+				log.TrippedAt = time.Time{}
+			},
+			contains: []string{"spot interruptions logged 20m0s apart, outside the 15m0s circuit breaker window"},
+		},
+		{
+			name:     "spot retry while blocked",
+			mutate:   func(jobs []SpotDrillJob, _ *spotBreakerLog) { jobs[1].Retry.Spot = true },
+			contains: []string{"job drill-2: retry runner i-0000000000000000d is spot although the circuit breaker tripped"},
+		},
+		{
+			name: "spot retry before the trip",
+			mutate: func(jobs []SpotDrillJob, _ *spotBreakerLog) {
+				jobs[0].Retry.Spot = true
+				jobs[0].Retry.LaunchTime = trippedAt.Add(-time.Minute)
+			},
+		},
+		{
+			name: "spot retry after the block",
+			mutate: func(jobs []SpotDrillJob, _ *spotBreakerLog) {
+				jobs[0].Retry.Spot = true
+				jobs[0].Retry.LaunchTime = trippedAt.Add(31 * time.Minute)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			jobs, log := valid()
+			tc.mutate(jobs, &log)
+			violations := spotDrillViolations(jobs, log, breaker)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			for i, want := range tc.contains {
+				assert.Contains(t, violations[i], want)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	taggingtypes "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// TAG COMPLIANCE AUDIT
+// =============================================================================
+
+// TagAuditFinding is a resource (or a tag_specifications child) missing required tags.
+type TagAuditFinding struct {
+	Address string
+	Type    string
+	Missing []string
+}
+
+// TagAuditReport is the result of auditing every resource of a stack against the required tags.
+// Launch template children (instances, volumes, ENIs) get their tags from tag_specifications
+// rather than the resource itself, so they are reported separately.
+type TagAuditReport struct {
+	Checked    int
+	Untaggable []string
+	Findings   []TagAuditFinding
+
+	TagSpecificationsChecked int
+	TagSpecificationFindings []TagAuditFinding
+}
+
+// Log writes the audit summary and every finding to the test log.
+func (r TagAuditReport) Log(t *testing.T) {
+	t.Logf("Tag audit: %d taggable resources checked, %d without tags support, %d non-compliant",
+		r.Checked, len(r.Untaggable), len(r.Findings))
+	for _, finding := range r.Findings {
+		t.Logf("  ✗ %s (%s) missing %s", finding.Address, finding.Type, strings.Join(finding.Missing, ", "))
+	}
+	t.Logf("Tag audit: %d tag_specifications checked, %d non-compliant",
+		r.TagSpecificationsChecked, len(r.TagSpecificationFindings))
+	for _, finding := range r.TagSpecificationFindings {
+		t.Logf("  ✗ %s (%s) missing %s", finding.Address, finding.Type, strings.Join(finding.Missing, ", "))
+	}
+}
+
+// taggedResource is a stack resource reduced to what the tag audit needs.
+type taggedResource struct {
+	Address  string
+	Type     string
+	ARN      string
+	Taggable bool
+	Tags     map[string]string
+
+	// TagSpecifications maps a tag_specifications resource_type to its tags
+	TagSpecifications map[string]map[string]string
+}
+
+// newTaggedResource extracts tags from a planned or state resource. tags_all is preferred
+// because it includes provider default_tags; tags is used when tags_all is unknown at plan time.
+func newTaggedResource(resource *tfjson.StateResource) taggedResource {
+	tr := taggedResource{
+		Address: resource.Address,
+		Type:    resource.Type,
+		ARN:     strings.TrimSuffix(attributeString(resource, "arn"), ":*"),
+	}
+	for _, attribute := range []string{"tags_all", "tags"} {
+		if _, ok := resource.AttributeValues[attribute]; ok {
+			tr.Taggable = true
+			tr.Tags = attributeStringMap(resource, attribute)
+			break
+		}
+	}
+
+	for _, spec := range attributeBlocks(resource, "tag_specifications") {
+		resourceType, _ := spec["resource_type"].(string)
+		tags := map[string]string{}
+		if raw, ok := spec["tags"].(map[string]interface{}); ok {
+			for key, value := range raw {
+				if s, ok := value.(string); ok {
+					tags[key] = s
+				}
+			}
+		}
+		if tr.TagSpecifications == nil {
+			tr.TagSpecifications = map[string]map[string]string{}
+		}
+		tr.TagSpecifications[resourceType] = tags
+	}
+	return tr
+}
+
+// auditResourceTags checks every resource and tag_specifications block against the required tags.
+func auditResourceTags(resources []taggedResource, required map[string]string) TagAuditReport {
+	var report TagAuditReport
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Address < resources[j].Address })
+
+	for _, resource := range resources {
+		if !resource.Taggable {
+			report.Untaggable = append(report.Untaggable, resource.Address)
+		} else {
+			report.Checked++
+			if missing := missingTagValues(resource.Tags, required); len(missing) > 0 {
+				report.Findings = append(report.Findings, TagAuditFinding{
+					Address: resource.Address,
+					Type:    resource.Type,
+					Missing: missing,
+				})
+			}
+		}
+
+		specTypes := make([]string, 0, len(resource.TagSpecifications))
+		for specType := range resource.TagSpecifications {
+			specTypes = append(specTypes, specType)
+		}
+		sort.Strings(specTypes)
+		for _, specType := range specTypes {
+			report.TagSpecificationsChecked++
+			if missing := missingTagValues(resource.TagSpecifications[specType], required); len(missing) > 0 {
+				report.TagSpecificationFindings = append(report.TagSpecificationFindings, TagAuditFinding{
+					Address: fmt.Sprintf("%s.tag_specifications[%s]", resource.Address, specType),
+					Type:    specType,
+					Missing: missing,
+				})
+			}
+		}
+	}
+	return report
+}
+
+// AuditPlannedTags audits the planned values of a plan without calling AWS.
+func AuditPlannedTags(plan *terraform.PlanStruct, required map[string]string) TagAuditReport {
+	var resources []taggedResource
+	for _, resource := range plannedResources(plan, "") {
+		resources = append(resources, newTaggedResource(resource))
+	}
+	return auditResourceTags(resources, required)
+}
+
+// ValidatePlannedTagCompliance asserts that every planned resource and tag_specifications block
+// carries the required tags.
+func ValidatePlannedTagCompliance(t *testing.T, plan *terraform.PlanStruct, required map[string]string) {
+	report := AuditPlannedTags(plan, required)
+	report.Log(t)
+	assertTagAuditClean(t, report)
+}
+
+// ValidateStackTagCompliance audits the deployed stack with the Resource Groups Tagging API:
+//   - Every ARN in Terraform state is looked up, falling back to state tags_all for
+//     resource types the tagging API does not return
+//   - Resources tagged runs-on-stack-name but missing from state (created at runtime) are audited too
+//   - Launch template tag_specifications are checked from state
+func ValidateStackTagCompliance(t *testing.T, moduleOptions *terraform.Options, stackName string, required map[string]string) {
+	ctx := context.Background()
+	cfg := MustGetAWSConfig(ctx)
+	client := resourcegroupstaggingapi.NewFromConfig(cfg)
+
+	var resources []taggedResource
+	var arns []string
+	inState := map[string]bool{}
+	for _, resource := range terraformStateResources(t, moduleOptions) {
+		tr := newTaggedResource(resource)
+		resources = append(resources, tr)
+		if tr.Taggable && tr.ARN != "" {
+			arns = append(arns, tr.ARN)
+			inState[tr.ARN] = true
+		}
+	}
+
+	live, err := getResourceTagsByARN(ctx, client, arns)
+	require.NoError(t, err, "Failed to look up stack resource tags")
+	fromState := 0
+	for i := range resources {
+		if tags, ok := live[resources[i].ARN]; ok {
+			resources[i].Tags = tags
+		} else if resources[i].Taggable {
+			fromState++
+		}
+	}
+
+	stateCount := len(resources)
+	runtime, err := getResourceTagsByTag(ctx, client, "runs-on-stack-name", stackName)
+	require.NoError(t, err, "Failed to list resources tagged runs-on-stack-name=%s", stackName)
+	for arn, tags := range runtime {
+		if inState[arn] {
+			continue
+		}
+		resources = append(resources, taggedResource{Address: arn, Type: arnResourceType(arn), ARN: arn, Taggable: true, Tags: tags})
+	}
+
+	t.Logf("Tag audit: %d resources in state (%d using state tags), %d created at runtime",
+		stateCount, fromState, len(resources)-stateCount)
+	report := auditResourceTags(resources, required)
+	report.Log(t)
+	assertTagAuditClean(t, report)
+}
+
+// assertTagAuditClean fails the test for each finding, keeping tag_specifications findings distinct.
+func assertTagAuditClean(t *testing.T, report TagAuditReport) {
+	for _, finding := range report.Findings {
+		assert.Fail(t, fmt.Sprintf("%s (%s) is missing required tags: %s",
+			finding.Address, finding.Type, strings.Join(finding.Missing, ", ")))
+	}
+	for _, finding := range report.TagSpecificationFindings {
+		assert.Fail(t, fmt.Sprintf("%s does not propagate required tags to %s resources: %s",
+			finding.Address, finding.Type, strings.Join(finding.Missing, ", ")))
+	}
+	if len(report.Findings) == 0 && len(report.TagSpecificationFindings) == 0 {
+		t.Logf("✓ All %d resources and %d tag_specifications carry the required tags",
+			report.Checked, report.TagSpecificationsChecked)
+	}
+}
+
+// getResourceTagsByARN returns the tags of the given ARNs. GetResources accepts at most 100 ARNs per call.
+func getResourceTagsByARN(ctx context.Context, client *resourcegroupstaggingapi.Client, arns []string) (map[string]map[string]string, error) {
+	tags := map[string]map[string]string{}
+	for start := 0; start < len(arns); start += 100 {
+		end := min(start+100, len(arns))
+		paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(client, &resourcegroupstaggingapi.GetResourcesInput{
+			ResourceARNList: arns[start:end],
+		})
+		if err := collectTagMappings(ctx, paginator, tags); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// getResourceTagsByTag returns the tags of every resource carrying key=value.
+func getResourceTagsByTag(ctx context.Context, client *resourcegroupstaggingapi.Client, key, value string) (map[string]map[string]string, error) {
+	tags := map[string]map[string]string{}
+	paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(client, &resourcegroupstaggingapi.GetResourcesInput{
+		TagFilters: []taggingtypes.TagFilter{{Key: aws.String(key), Values: []string{value}}},
+	})
+	if err := collectTagMappings(ctx, paginator, tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// collectTagMappings drains a GetResources paginator into tags keyed by ARN.
+func collectTagMappings(ctx context.Context, paginator *resourcegroupstaggingapi.GetResourcesPaginator, tags map[string]map[string]string) error {
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mapping := range page.ResourceTagMappingList {
+			resourceTags := make(map[string]string, len(mapping.Tags))
+			for _, tag := range mapping.Tags {
+				resourceTags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			tags[aws.ToString(mapping.ResourceARN)] = resourceTags
+		}
+	}
+	return nil
+}
+
+// arnResourceType returns "service:resource-type" for an ARN, e.g. "ec2:instance", or just the service.
+func arnResourceType(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return arn
+	}
+	// S3 buckets and SNS topics have no resource type segment
+	i := strings.IndexAny(parts[5], "/:")
+	if i < 0 {
+		return parts[2]
+	}
+	return parts[2] + ":" + parts[5][:i]
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tagAuditPlanJSON is a trimmed plan with one resource per audit outcome
+const tagAuditPlanJSON = `{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "child_modules": [{
+        "address": "module.compute",
+        "resources": [
+          {
+            "address": "module.compute.aws_launch_template.linux_default",
+            "mode": "managed", "type": "aws_launch_template", "name": "linux_default",
+            "values": {
+              "tags_all": {"runs-on-stack-name": "stack", "stack": "stack", "TestID": "t1"},
+              "tag_specifications": [
+                {"resource_type": "instance", "tags": {"runs-on-stack-name": "stack", "stack": "stack", "TestID": "t1"}},
+                {"resource_type": "volume", "tags": {"runs-on-stack-name": "stack", "TestID": "t1"}}
+              ]
+            }
+          },
+          {
+            "address": "module.compute.aws_cloudwatch_log_group.runners",
+            "mode": "managed", "type": "aws_cloudwatch_log_group", "name": "runners",
+            "values": {"tags": {"runs-on-stack-name": "stack"}}
+          },
+          {
+            "address": "module.compute.aws_iam_role_policy.ec2",
+            "mode": "managed", "type": "aws_iam_role_policy", "name": "ec2",
+            "values": {"name": "ec2"}
+          },
+          {
+            "address": "module.compute.data.aws_region.current",
+            "mode": "data", "type": "aws_region", "name": "current",
+            "values": {}
+          }
+        ]
+      }]
+    }
+  }
+}`
+
+func TestAuditPlannedTags(t *testing.T) {
+	plan, err := terraform.ParsePlanJSON(tagAuditPlanJSON)
+	require.NoError(t, err)
+
+	required := map[string]string{"runs-on-stack-name": "stack", "stack": "stack", "TestID": "t1"}
+	report := AuditPlannedTags(plan, required)
+
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, []string{"module.compute.aws_iam_role_policy.ec2"}, report.Untaggable)
+	assert.Equal(t, []TagAuditFinding{{
+		Address: "module.compute.aws_cloudwatch_log_group.runners",
+		Type:    "aws_cloudwatch_log_group",
+		Missing: []string{"TestID=t1", "stack=stack"},
+	}}, report.Findings)
+
+	assert.Equal(t, 2, report.TagSpecificationsChecked)
+	assert.Equal(t, []TagAuditFinding{{
+		Address: "module.compute.aws_launch_template.linux_default.tag_specifications[volume]",
+		Type:    "volume",
+		Missing: []string{"stack=stack"},
+	}}, report.TagSpecificationFindings)
+}
+
+func TestArnResourceType(t *testing.T) {
+	testCases := map[string]string{
+		"arn:aws:ec2:us-east-1:123456789012:instance/i-0abc":            "ec2:instance",
+		"arn:aws:logs:us-east-1:123456789012:log-group:/runs-on/stack":  "logs:log-group",
+		"arn:aws:s3:::stack-cache":                                      "s3",
+		"arn:aws:apprunner:us-east-1:123456789012:service/stack/abc123": "apprunner:service",
+		"not-an-arn": "not-an-arn",
+	}
+	for arn, expected := range testCases {
+		assert.Equal(t, expected, arnResourceType(arn), arn)
+	}
+}
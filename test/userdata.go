@@ -0,0 +1,324 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// =============================================================================
+// USER-DATA RENDERING
+// =============================================================================
+
+// LinuxUserDataTemplate is the path to the Linux user-data template, relative to the test directory.
+const LinuxUserDataTemplate = "../modules/compute/user-data-linux.sh"
+
+// UserDataVars mirrors the templatefile() arguments passed by modules/compute/launch_templates.tf.
+type UserDataVars struct {
+	AppTag               string
+	BootstrapTag         string
+	EFSFileSystemID      string
+	EphemeralRegistryURI string
+	ConfigBucket         string
+	CacheBucket          string
+	Region               string
+	LogGroup             string
+	AppDebug             bool
+	RunnerMaxRuntime     int
+}
+
+// DefaultUserDataVars returns template variables matching the root module defaults
+func DefaultUserDataVars() UserDataVars {
+	return UserDataVars{
+		AppTag:           "v2.11.0",
+		BootstrapTag:     "v0.1.12",
+		ConfigBucket:     "test-config-bucket",
+		CacheBucket:      "test-cache-bucket",
+		Region:           "us-east-1",
+		LogGroup:         "/aws/ec2/test",
+		RunnerMaxRuntime: 720,
+	}
+}
+
+// toTemplateVars converts the variables into the cty values templatefile() would see.
+func (v UserDataVars) toTemplateVars() map[string]cty.Value {
+	appDebug := "false"
+	if v.AppDebug {
+		appDebug = "true"
+	}
+	return map[string]cty.Value{
+		"app_tag":                cty.StringVal(v.AppTag),
+		"bootstrap_tag":          cty.StringVal(v.BootstrapTag),
+		"efs_file_system_id":     cty.StringVal(v.EFSFileSystemID),
+		"ephemeral_registry_uri": cty.StringVal(v.EphemeralRegistryURI),
+		"config_bucket":          cty.StringVal(v.ConfigBucket),
+		"cache_bucket":           cty.StringVal(v.CacheBucket),
+		"region":                 cty.StringVal(v.Region),
+		"log_group":              cty.StringVal(v.LogGroup),
+		"app_debug":              cty.StringVal(appDebug),
+		"runner_max_runtime":     cty.NumberIntVal(int64(v.RunnerMaxRuntime)),
+	}
+}
+
+// RenderUserDataTemplate renders a user-data template with the same HCL template engine
+// used by templatefile(), so the output matches what ends up in the launch template.
+func RenderUserDataTemplate(templatePath string, vars UserDataVars) (string, error) {
+	src, err := os.ReadFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template %s: %w", templatePath, err)
+	}
+
+	expr, diags := hclsyntax.ParseTemplate(src, templatePath, hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", fmt.Errorf("failed to parse template %s: %s", templatePath, diags.Error())
+	}
+
+	val, diags := expr.Value(&hcl.EvalContext{Variables: vars.toTemplateVars()})
+	if diags.HasErrors() {
+		return "", fmt.Errorf("failed to render template %s: %s", templatePath, diags.Error())
+	}
+	return val.AsString(), nil
+}
+
+// =============================================================================
+// USER-DATA SANDBOX
+// =============================================================================
+
+// userDataBootstrapDir is the directory the Linux user-data installs the bootstrap binary into.
+const userDataBootstrapDir = "/usr/local/bin/"
+
+// callLogSeparator separates the program name and arguments in a recorded call.
+const callLogSeparator = "\x1f"
+
+// recordCallSnippet is shared by all fake binaries to append their invocation to the call log.
+const recordCallSnippet = `{ printf '%s' "$FAKE_NAME"; for a in "$@"; do printf '\x1f%s' "$a"; done; printf '\n'; } >> "$USERDATA_CALL_LOG"`
+
+// fakeCurlScript emulates curl: records the call and, on success, writes a fake bootstrap binary to -o.
+// FAKE_CURL_TRANSIENT_FAILURES simulates that many failed attempts, which only succeed if --retry covers them.
+const fakeCurlScript = `#!/bin/bash
+FAKE_NAME=curl
+` + recordCallSnippet + `
+if [ "${FAKE_CURL_EXIT:-0}" != "0" ]; then
+  exit "$FAKE_CURL_EXIT"
+fi
+out=""
+retry=0
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -o) out="$2"; shift ;;
+    --retry) retry="$2"; shift ;;
+  esac
+  shift
+done
+failures="${FAKE_CURL_TRANSIENT_FAILURES:-0}"
+for ((attempt = 0; attempt < failures; attempt++)); do
+  echo "curl-attempt-failed" >> "$USERDATA_CALL_LOG"
+  if [ "$attempt" -ge "$retry" ]; then
+    exit 28
+  fi
+done
+if [ -n "$out" ]; then
+  cp "$FAKE_BOOTSTRAP_SOURCE" "$out"
+fi
+`
+
+// fakeBootstrapScript emulates the runs-on bootstrap binary.
+const fakeBootstrapScript = `#!/bin/bash
+FAKE_NAME=bootstrap
+` + recordCallSnippet + `
+exit "${FAKE_BOOTSTRAP_EXIT:-0}"
+`
+
+// fakeUnameScript reports a fixed machine architecture so agent paths are deterministic.
+const fakeUnameScript = `#!/bin/bash
+FAKE_NAME=uname
+` + recordCallSnippet + `
+if [ "$1" = "-m" ]; then echo "$FAKE_ARCH"; else echo Linux; fi
+`
+
+// fakeRecorderScript records the call and exits successfully (aws, shutdown, sleep).
+// The FAKE_NAME placeholder is substituted per binary.
+const fakeRecorderScript = `#!/bin/bash
+FAKE_NAME=FAKE_NAME_PLACEHOLDER
+` + recordCallSnippet + `
+exit 0
+`
+
+// UserDataSandbox executes a rendered Linux user-data script locally with fake curl, aws,
+// shutdown, sleep, uname and bootstrap binaries on PATH. The bootstrap install directory is
+// redirected into the sandbox so the script never touches the host filesystem outside it.
+type UserDataSandbox struct {
+	Dir          string // Sandbox root
+	BinDir       string // Fake binaries, first on PATH
+	BootstrapDir string // Stand-in for /usr/local/bin
+	CallLog      string // File every fake appends its invocation to
+
+	Arch              string // Value returned by `uname -m`
+	CurlExitCode      int    // Non-zero simulates a failed bootstrap download
+	CurlTransientFail int    // Failed attempts before the download succeeds, subject to --retry
+	BootstrapExitCode int    // Exit code of the fake bootstrap binary
+}
+
+// UserDataCall is a single recorded invocation of a fake binary.
+type UserDataCall struct {
+	Name string
+	Args []string
+}
+
+// UserDataRun is the outcome of executing user-data in the sandbox.
+type UserDataRun struct {
+	Calls    []UserDataCall
+	Output   string
+	ExitCode int
+}
+
+// NewUserDataSandbox creates a sandbox in a temporary directory cleaned up with the test.
+func NewUserDataSandbox(t *testing.T) *UserDataSandbox {
+	dir := t.TempDir()
+	sandbox := &UserDataSandbox{
+		Dir:          dir,
+		BinDir:       filepath.Join(dir, "bin"),
+		BootstrapDir: filepath.Join(dir, "usr", "local", "bin"),
+		CallLog:      filepath.Join(dir, "calls.log"),
+		Arch:         "x86_64",
+	}
+	require.NoError(t, os.MkdirAll(sandbox.BinDir, 0o755))
+	require.NoError(t, os.MkdirAll(sandbox.BootstrapDir, 0o755))
+
+	fakes := map[string]string{
+		"curl":              fakeCurlScript,
+		"uname":             fakeUnameScript,
+		"bootstrap-release": fakeBootstrapScript,
+	}
+	for _, name := range []string{"aws", "shutdown", "sleep"} {
+		fakes[name] = strings.Replace(fakeRecorderScript, "FAKE_NAME_PLACEHOLDER", name, 1)
+	}
+	for name, script := range fakes {
+		require.NoError(t, os.WriteFile(filepath.Join(sandbox.BinDir, name), []byte(script), 0o755))
+	}
+	return sandbox
+}
+
+// BootstrapPath returns the sandboxed path of the bootstrap binary for a bootstrap tag.
+func (s *UserDataSandbox) BootstrapPath(bootstrapTag string) string {
+	return filepath.Join(s.BootstrapDir, "runs-on-bootstrap-"+bootstrapTag)
+}
+
+// PreinstallBootstrap places a fake bootstrap binary where user-data expects it,
+// simulating an AMI that already ships the binary.
+func (s *UserDataSandbox) PreinstallBootstrap(t *testing.T, bootstrapTag string) {
+	require.NoError(t, os.WriteFile(s.BootstrapPath(bootstrapTag), []byte(fakeBootstrapScript), 0o755))
+}
+
+// Run executes the rendered user-data script and returns the recorded calls.
+// The script runs through its own shebang so `bash -ex` semantics are preserved.
+func (s *UserDataSandbox) Run(t *testing.T, script string) UserDataRun {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("/bin/bash not available")
+	}
+
+	require.Contains(t, script, "BOOTSTRAP_BIN="+userDataBootstrapDir,
+		"user-data no longer installs the bootstrap binary into %s; update the sandbox", userDataBootstrapDir)
+	script = strings.Replace(script, "BOOTSTRAP_BIN="+userDataBootstrapDir, "BOOTSTRAP_BIN="+s.BootstrapDir+"/", 1)
+
+	scriptPath := filepath.Join(s.Dir, "user-data.sh")
+	require.NoError(t, os.WriteFile(scriptPath, []byte(script), 0o755))
+	require.NoError(t, os.WriteFile(s.CallLog, nil, 0o644))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, scriptPath)
+	cmd.Dir = s.Dir
+	cmd.Env = append(os.Environ(),
+		"PATH="+s.BinDir+":"+os.Getenv("PATH"),
+		"USERDATA_CALL_LOG="+s.CallLog,
+		"FAKE_ARCH="+s.Arch,
+		"FAKE_BOOTSTRAP_SOURCE="+filepath.Join(s.BinDir, "bootstrap-release"),
+		fmt.Sprintf("FAKE_CURL_EXIT=%d", s.CurlExitCode),
+		fmt.Sprintf("FAKE_CURL_TRANSIENT_FAILURES=%d", s.CurlTransientFail),
+		fmt.Sprintf("FAKE_BOOTSTRAP_EXIT=%d", s.BootstrapExitCode),
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, ctx.Err(), "user-data did not finish within timeout. output:\n%s", output)
+
+	run := UserDataRun{Output: string(output)}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		run.ExitCode = exitErr.ExitCode()
+	} else {
+		require.NoError(t, err, "Failed to execute user-data")
+	}
+
+	logData, err := os.ReadFile(s.CallLog)
+	require.NoError(t, err, "Failed to read call log")
+	run.Calls = parseCallLog(string(logData))
+
+	t.Logf("user-data exited %d with %d recorded calls", run.ExitCode, len(run.Calls))
+	return run
+}
+
+// parseCallLog parses the call log written by the fake binaries.
+func parseCallLog(data string) []UserDataCall {
+	var calls []UserDataCall
+	for _, line := range strings.Split(data, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, callLogSeparator)
+		calls = append(calls, UserDataCall{Name: fields[0], Args: fields[1:]})
+	}
+	return calls
+}
+
+// CallsTo returns the recorded calls to the named fake binary, in order.
+func (r UserDataRun) CallsTo(name string) []UserDataCall {
+	var calls []UserDataCall
+	for _, call := range r.Calls {
+		if call.Name == name {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// IndexOf returns the position of the first call to the named binary, or -1.
+func (r UserDataRun) IndexOf(name string) int {
+	for i, call := range r.Calls {
+		if call.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// FlagValue returns the value following flag in the call's arguments.
+func (c UserDataCall) FlagValue(flag string) (string, bool) {
+	for i, arg := range c.Args {
+		if arg == flag && i+1 < len(c.Args) {
+			return c.Args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// HasArg reports whether the call was made with the given argument.
+func (c UserDataCall) HasArg(arg string) bool {
+	for _, a := range c.Args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
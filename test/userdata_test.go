@@ -0,0 +1,133 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// renderLinuxUserData renders the Linux user-data template for sandbox tests
+func renderLinuxUserData(t *testing.T, vars UserDataVars) string {
+	script, err := RenderUserDataTemplate(LinuxUserDataTemplate, vars)
+	require.NoError(t, err, "Failed to render Linux user-data")
+	return script
+}
+
+func TestUserDataLinuxRender(t *testing.T) {
+	vars := DefaultUserDataVars()
+	script := renderLinuxUserData(t, vars)
+
+	assert.Contains(t, script, `export RUNS_ON_RUNNER_MAX_RUNTIME="720"`)
+	assert.Contains(t, script, `export RUNS_ON_LOG_GROUP_NAME="/aws/ec2/test"`)
+	assert.Contains(t, script, `export AWS_REGION="us-east-1"`)
+	assert.NotContains(t, script, "RUNS_ON_EFS_ID", "EFS export should only be rendered when EFS is enabled")
+	assert.NotContains(t, script, "RUNS_ON_ECR_CACHE", "ECR export should only be rendered when ECR is enabled")
+
+	vars.EFSFileSystemID = "fs-12345678"
+	vars.EphemeralRegistryURI = "123456789012.dkr.ecr.us-east-1.amazonaws.com/test"
+	script = renderLinuxUserData(t, vars)
+	assert.Contains(t, script, `export RUNS_ON_EFS_ID="fs-12345678"`)
+	assert.Contains(t, script, `export RUNS_ON_ECR_CACHE="123456789012.dkr.ecr.us-east-1.amazonaws.com/test"`)
+}
+
+func TestUserDataLinuxSandbox(t *testing.T) {
+	vars := DefaultUserDataVars()
+	expectedAgent := "s3://test-config-bucket/agents/v2.11.0/agent-linux-x86_64"
+
+	t.Run("FetchesBootstrapWhenMissing", func(t *testing.T) {
+		sandbox := NewUserDataSandbox(t)
+		run := sandbox.Run(t, renderLinuxUserData(t, vars))
+		require.Equal(t, 0, run.ExitCode, "user-data failed:\n%s", run.Output)
+
+		curls := run.CallsTo("curl")
+		require.Len(t, curls, 1, "Bootstrap should be downloaded exactly once")
+		curl := curls[0]
+		assert.True(t, curl.HasArg("https://github.com/runs-on/bootstrap/releases/download/v0.1.12/bootstrap-v0.1.12-linux-x86_64"),
+			"Unexpected bootstrap URL: %v", curl.Args)
+		out, _ := curl.FlagValue("-o")
+		assert.Equal(t, sandbox.BootstrapPath("v0.1.12"), out, "Bootstrap should be written to the versioned path")
+
+		bootstraps := run.CallsTo("bootstrap")
+		require.Len(t, bootstraps, 1, "Bootstrap should be executed once")
+		assert.Equal(t, []string{"--debug=false", "--exec", "--post-exec", "shutdown", expectedAgent}, bootstraps[0].Args)
+	})
+
+	t.Run("SkipsDownloadWhenPresent", func(t *testing.T) {
+		sandbox := NewUserDataSandbox(t)
+		sandbox.PreinstallBootstrap(t, "v0.1.12")
+		run := sandbox.Run(t, renderLinuxUserData(t, vars))
+		require.Equal(t, 0, run.ExitCode, "user-data failed:\n%s", run.Output)
+
+		assert.Empty(t, run.CallsTo("curl"), "Bootstrap should not be downloaded when already installed")
+		require.Len(t, run.CallsTo("bootstrap"), 1, "Preinstalled bootstrap should be executed")
+	})
+
+	t.Run("HonoursRetryFlags", func(t *testing.T) {
+		sandbox := NewUserDataSandbox(t)
+		sandbox.CurlTransientFail = 5
+		run := sandbox.Run(t, renderLinuxUserData(t, vars))
+		require.Equal(t, 0, run.ExitCode, "Download should survive 5 transient failures:\n%s", run.Output)
+
+		curl := run.CallsTo("curl")[0]
+		retry, _ := curl.FlagValue("--retry")
+		connectTimeout, _ := curl.FlagValue("--connect-timeout")
+		maxTime, _ := curl.FlagValue("--max-time")
+		assert.Equal(t, "5", retry)
+		assert.Equal(t, "3", connectTimeout)
+		assert.Equal(t, "15", maxTime)
+		assert.True(t, curl.HasArg("-L"), "curl should follow GitHub release redirects")
+		assert.Len(t, run.CallsTo("curl-attempt-failed"), 5)
+		assert.Len(t, run.CallsTo("bootstrap"), 1)
+
+		sandbox = NewUserDataSandbox(t)
+		sandbox.CurlTransientFail = 6
+		run = sandbox.Run(t, renderLinuxUserData(t, vars))
+		assert.NotEqual(t, 0, run.ExitCode, "Download should give up after exhausting retries")
+		assert.Empty(t, run.CallsTo("bootstrap"))
+	})
+
+	t.Run("PassesAgentPathForArch", func(t *testing.T) {
+		sandbox := NewUserDataSandbox(t)
+		sandbox.Arch = "aarch64"
+		run := sandbox.Run(t, renderLinuxUserData(t, vars))
+		require.Equal(t, 0, run.ExitCode, "user-data failed:\n%s", run.Output)
+
+		assert.True(t, run.CallsTo("curl")[0].HasArg("https://github.com/runs-on/bootstrap/releases/download/v0.1.12/bootstrap-v0.1.12-linux-aarch64"))
+		assert.True(t, run.CallsTo("bootstrap")[0].HasArg("s3://test-config-bucket/agents/v2.11.0/agent-linux-aarch64"))
+	})
+
+	t.Run("ExitTrapSchedulesShutdown", func(t *testing.T) {
+		sandbox := NewUserDataSandbox(t)
+		run := sandbox.Run(t, renderLinuxUserData(t, vars))
+
+		shutdowns := run.CallsTo("shutdown")
+		require.Len(t, shutdowns, 1, "EXIT trap should shut the instance down")
+		assert.Equal(t, []string{"-h", "now"}, shutdowns[0].Args)
+		sleeps := run.CallsTo("sleep")
+		require.Len(t, sleeps, 1)
+		assert.Equal(t, []string{"180"}, sleeps[0].Args, "Shutdown should be delayed to let logs flush")
+		assert.Greater(t, run.IndexOf("shutdown"), run.IndexOf("bootstrap"), "Shutdown should happen after bootstrap exits")
+	})
+
+	t.Run("ExitTrapShutsDownOnFailure", func(t *testing.T) {
+		sandbox := NewUserDataSandbox(t)
+		sandbox.CurlExitCode = 7
+		run := sandbox.Run(t, renderLinuxUserData(t, vars))
+
+		assert.NotEqual(t, 0, run.ExitCode, "Failed download should abort user-data")
+		assert.Empty(t, run.CallsTo("bootstrap"))
+		assert.Len(t, run.CallsTo("shutdown"), 1, "Instance should shut down even when bootstrap download fails")
+	})
+
+	t.Run("DebugModeKeepsInstance", func(t *testing.T) {
+		debugVars := vars
+		debugVars.AppDebug = true
+		sandbox := NewUserDataSandbox(t)
+		run := sandbox.Run(t, renderLinuxUserData(t, debugVars))
+		require.Equal(t, 0, run.ExitCode, "user-data failed:\n%s", run.Output)
+
+		assert.Equal(t, "--debug=true", run.CallsTo("bootstrap")[0].Args[0])
+		assert.Empty(t, run.CallsTo("shutdown"), "Debug mode should not shut the instance down")
+	})
+}
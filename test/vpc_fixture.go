@@ -0,0 +1,66 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// VPC FIXTURE HELPERS
+// =============================================================================
+
+// placeholderVPCID stands in for a VPC when the module is only planned
+const placeholderVPCID = "vpc-0123456789abcdef0"
+
+// placeholderSubnetIDs returns count syntactically valid subnet IDs for plan-only tests.
+func placeholderSubnetIDs(kind string, count int) []string {
+	prefix := map[string]string{"public": "0a", "private": "0b"}[kind]
+	ids := make([]string, count)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("subnet-%s%015x", prefix, i)
+	}
+	return ids
+}
+
+// ValidatePlannedFixtureSubnets checks the planned VPC fixture has subnetCount public and private
+// subnets, each set spread over distinct availability zones of the region.
+func ValidatePlannedFixtureSubnets(t *testing.T, plan *terraform.PlanStruct, region string, subnetCount int) {
+	violations := fixtureSubnetViolations(plan, region, subnetCount)
+	for _, violation := range violations {
+		assert.Fail(t, violation)
+	}
+	if len(violations) == 0 {
+		t.Logf("✓ VPC fixture plans %d public and private subnets in distinct %s AZs", subnetCount, region)
+	}
+}
+
+// fixtureSubnetViolations returns one message per subnet count or AZ problem in the fixture plan.
+func fixtureSubnetViolations(plan *terraform.PlanStruct, region string, subnetCount int) []string {
+	var violations []string
+	for _, kind := range []string{"public", "private"} {
+		var subnets int
+		zones := map[string]bool{}
+		for _, subnet := range plannedResources(plan, "aws_subnet") {
+			if subnet.Name != kind {
+				continue
+			}
+			subnets++
+			zone := attributeString(subnet, "availability_zone")
+			if !strings.HasPrefix(zone, region) {
+				violations = append(violations, fmt.Sprintf("%s is in AZ %q, outside region %s", subnet.Address, zone, region))
+			}
+			if zones[zone] {
+				violations = append(violations, fmt.Sprintf("%s shares AZ %s with another %s subnet", subnet.Address, zone, kind))
+			}
+			zones[zone] = true
+		}
+		if subnets != subnetCount {
+			violations = append(violations, fmt.Sprintf("expected %d %s subnets, got %d", subnetCount, kind, subnets))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureSubnetPlanJSON builds a trimmed VPC fixture plan with one public and one private subnet per AZ
+func fixtureSubnetPlanJSON(zones ...string) string {
+	var resources []string
+	for _, kind := range []string{"public", "private"} {
+		for i, zone := range zones {
+			resources = append(resources, fmt.Sprintf(`{
+              "address": "module.vpc.aws_subnet.%[1]s[%[2]d]",
+              "mode": "managed", "type": "aws_subnet", "name": "%[1]s", "index": %[2]d,
+              "values": {"availability_zone": "%[3]s"}
+            }`, kind, i, zone))
+		}
+	}
+	return `{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "child_modules": [{
+        "address": "module.vpc",
+        "resources": [` + strings.Join(resources, ",") + `]
+      }]
+    }
+  }
+}`
+}
+
+func TestFixtureSubnetViolations(t *testing.T) {
+	testCases := []struct {
+		name     string
+		zones    []string
+		count    int
+		contains []string
+	}{
+		{"OnePerAZ", []string{"eu-west-1a", "eu-west-1b"}, 2, nil},
+		{"SingleSubnet", []string{"eu-west-1a"}, 1, nil},
+		{"WrongCount", []string{"eu-west-1a", "eu-west-1b"}, 3, []string{"expected 3 public subnets, got 2", "expected 3 private subnets, got 2"}},
+		{"OtherRegion", []string{"us-east-1a"}, 1, []string{"public[0] is in AZ \"us-east-1a\"", "private[0] is in AZ \"us-east-1a\""}},
+		{"SharedAZ", []string{"eu-west-1a", "eu-west-1a"}, 2, []string{"public[1] shares AZ", "private[1] shares AZ"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := terraform.ParsePlanJSON(fixtureSubnetPlanJSON(tc.zones...))
+			require.NoError(t, err)
+			violations := fixtureSubnetViolations(plan, "eu-west-1", tc.count)
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			joined := strings.Join(violations, "\n")
+			for _, want := range tc.contains {
+				assert.Contains(t, joined, want)
+			}
+		})
+	}
+}
+
+func TestPlaceholderSubnetIDs(t *testing.T) {
+	public := placeholderSubnetIDs("public", 4)
+	private := placeholderSubnetIDs("private", 4)
+	assert.Equal(t, "subnet-0a000000000000000", public[0])
+	assert.Equal(t, "subnet-0b000000000000003", private[3])
+	for _, id := range append(public, private...) {
+		assert.Len(t, id, len("subnet-")+17, "subnet IDs have 17 hex digits")
+	}
+}
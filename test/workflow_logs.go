@@ -0,0 +1,268 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/google/go-github/v68/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// WORKFLOW JOB LOGS
+// =============================================================================
+
+// RunnerSetupGroup is the log group in which RunsOn prints the runner's metadata during job setup.
+const RunnerSetupGroup = "Set up runner"
+
+// failedJobLogLines is how much of a failed job's log is attached to the test output.
+const failedJobLogLines = 200
+
+// RunnerMetadata is what RunsOn reports about the runner in the "Set up runner" log group.
+type RunnerMetadata struct {
+	InstanceID       string
+	InstanceType     string
+	Lifecycle        string // "spot" or "on-demand"
+	Region           string
+	AvailabilityZone string
+	ImageID          string
+	Labels           string
+}
+
+// WorkflowJobLog is a job of a workflow run with its downloaded log.
+type WorkflowJobLog struct {
+	ID         int64
+	Name       string
+	Conclusion string
+	URL        string
+	RunnerName string
+	Labels     []string
+	Runner     RunnerMetadata
+	Log        string
+}
+
+// workflowJobsAPI is the subset of the GitHub Actions API used to fetch job logs.
+type workflowJobsAPI interface {
+	ListWorkflowJobs(ctx context.Context, owner, repo string, runID int64, opts *github.ListWorkflowJobsOptions) (*github.Jobs, *github.Response, error)
+	GetWorkflowJobLogs(ctx context.Context, owner, repo string, jobID int64, maxRedirects int) (*url.URL, *github.Response, error)
+}
+
+// logLineText strips the timestamp GitHub puts in front of every log line.
+func logLineText(line string) string {
+	line = strings.TrimRight(strings.TrimPrefix(line, "\ufeff"), "\r")
+	if timestamp, text, found := strings.Cut(line, " "); found {
+		if _, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			return text
+		}
+	}
+	return line
+}
+
+// logGroupValues reads the "Key: value" lines of the first log group with the given title.
+// Keys are lower-cased. Returns false if the log has no such group.
+func logGroupValues(log, group string) (map[string]string, bool) {
+	values := map[string]string{}
+	inGroup, found := false, false
+	for _, line := range strings.Split(log, "\n") {
+		text := logLineText(line)
+		switch {
+		case !found && text == "##[group]"+group:
+			inGroup, found = true, true
+			continue
+		case inGroup && strings.HasPrefix(text, "##[endgroup]"):
+			return values, true
+		case !inGroup:
+			continue
+		}
+
+		if key, value, ok := strings.Cut(strings.TrimSpace(text), ":"); ok {
+			values[strings.ToLower(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values, found
+}
+
+// parseRunnerMetadata reads the first "Set up runner" group of a job log. Returns false if the
+// log has no such group, e.g. for jobs on GitHub-hosted runners.
+func parseRunnerMetadata(log string) (RunnerMetadata, bool) {
+	values, found := logGroupValues(log, RunnerSetupGroup)
+	return RunnerMetadata{
+		InstanceID:       values["instance id"],
+		InstanceType:     values["instance type"],
+		Lifecycle:        values["instance lifecycle"],
+		Region:           values["region"],
+		AvailabilityZone: values["availability zone"],
+		ImageID:          values["image id"],
+		Labels:           values["labels"],
+	}, found
+}
+
+// logTail returns the last n lines of a log.
+func logTail(log string, n int) string {
+	lines := strings.Split(strings.TrimRight(log, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("... %d lines omitted ...\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
+
+// fetchWorkflowJobLogs lists every job of a run and downloads its log. Skipped jobs never ran
+// and have no log.
+func fetchWorkflowJobLogs(ctx context.Context, api workflowJobsAPI, httpClient *http.Client, owner, repo string, runID int64) ([]WorkflowJobLog, error) {
+	var jobs []WorkflowJobLog
+	opts := &github.ListWorkflowJobsOptions{Filter: "latest", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := api.ListWorkflowJobs(ctx, owner, repo, runID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs of run %d: %w", runID, err)
+		}
+		for _, job := range page.Jobs {
+			j := WorkflowJobLog{
+				ID:         job.GetID(),
+				Name:       job.GetName(),
+				Conclusion: job.GetConclusion(),
+				URL:        job.GetHTMLURL(),
+				RunnerName: job.GetRunnerName(),
+				Labels:     job.Labels,
+			}
+			if j.Conclusion != "skipped" {
+				if j.Log, err = downloadJobLog(ctx, api, httpClient, owner, repo, j.ID); err != nil {
+					return nil, fmt.Errorf("job %s: %w", j.Name, err)
+				}
+				j.Runner, _ = parseRunnerMetadata(j.Log)
+			}
+			jobs = append(jobs, j)
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return jobs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// downloadJobLog follows the job's log redirect and returns the plain text log.
+func downloadJobLog(ctx context.Context, api workflowJobsAPI, httpClient *http.Client, owner, repo string, jobID int64) (string, error) {
+	logURL, _, err := api.GetWorkflowJobLogs(ctx, owner, repo, jobID, 3)
+	if err != nil {
+		return "", fmt.Errorf("failed to get log URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download log: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download log: HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read log: %w", err)
+	}
+	return string(body), nil
+}
+
+// runnerMetadataViolations cross-checks the runner metadata of each job's log against the
+// instances launched for the stack. Returns one message per violation.
+func runnerMetadataViolations(jobs []WorkflowJobLog, instances []ec2types.Instance, region string) []string {
+	byID := map[string]ec2types.Instance{}
+	for _, instance := range instances {
+		byID[aws.ToString(instance.InstanceId)] = instance
+	}
+
+	var violations []string
+	for _, job := range jobs {
+		if job.Conclusion == "skipped" {
+			continue
+		}
+		runner := job.Runner
+		if runner.InstanceID == "" {
+			violations = append(violations, fmt.Sprintf("job %s: no RunsOn runner metadata in the %q log group", job.Name, RunnerSetupGroup))
+			continue
+		}
+		if runner.Region != region {
+			violations = append(violations, fmt.Sprintf("job %s: runner region is %s, expected %s", job.Name, runner.Region, region))
+		}
+		instance, ok := byID[runner.InstanceID]
+		if !ok {
+			violations = append(violations, fmt.Sprintf("job %s: instance %s was not launched by the stack during the test", job.Name, runner.InstanceID))
+			continue
+		}
+
+		lifecycle := "on-demand"
+		if instance.InstanceLifecycle == ec2types.InstanceLifecycleTypeSpot {
+			lifecycle = "spot"
+		}
+		availabilityZone := ""
+		if instance.Placement != nil {
+			availabilityZone = aws.ToString(instance.Placement.AvailabilityZone)
+		}
+		for _, field := range []struct{ name, logged, actual string }{
+			{"instance type", runner.InstanceType, string(instance.InstanceType)},
+			{"lifecycle", runner.Lifecycle, lifecycle},
+			{"availability zone", runner.AvailabilityZone, availabilityZone},
+			{"image", runner.ImageID, aws.ToString(instance.ImageId)},
+		} {
+			if field.logged != field.actual {
+				violations = append(violations, fmt.Sprintf("job %s: log reports %s %q, instance %s has %q",
+					job.Name, field.name, field.logged, runner.InstanceID, field.actual))
+			}
+		}
+	}
+	return violations
+}
+
+// ValidateWorkflowJobRunners downloads the log of every job of a workflow run, attaches the logs
+// of failed jobs to the test output, and checks that the runner each job reports was launched
+// by the stack since the given time with the reported type, lifecycle, zone and image.
+// Returns the jobs for further checks.
+func ValidateWorkflowJobRunners(t *testing.T, repo string, runID int64, stackName string, since time.Time) []WorkflowJobLog {
+	client, err := getGitHubClient()
+	require.NoError(t, err, "Failed to create GitHub client")
+
+	owner, repoName, err := parseRepo(repo)
+	require.NoError(t, err, "Invalid repo format")
+
+	ctx := context.Background()
+	jobs, err := fetchWorkflowJobLogs(ctx, client.Actions, http.DefaultClient, owner, repoName, runID)
+	require.NoError(t, err, "Failed to fetch job logs of run %d", runID)
+	require.NotEmpty(t, jobs, "Run %d has no jobs", runID)
+
+	for _, job := range jobs {
+		if job.Conclusion != "success" && job.Conclusion != "skipped" {
+			t.Logf("Job %s concluded %s (%s), log:\n%s", job.Name, job.Conclusion, job.URL, logTail(job.Log, failedJobLogLines))
+		}
+	}
+
+	cfg := MustGetAWSConfig(ctx)
+	instances, err := stackRunnerInstances(ctx, ec2.NewFromConfig(cfg), stackName, since)
+	require.NoError(t, err, "Failed to list runner instances of %s", stackName)
+
+	violations := runnerMetadataViolations(jobs, instances, GetAWSRegion())
+	for _, violation := range violations {
+		assert.Fail(t, "Runner metadata mismatch", violation)
+	}
+	if len(violations) == 0 {
+		for _, job := range jobs {
+			if job.Conclusion == "skipped" {
+				continue
+			}
+			t.Logf("✓ Job %s ran on %s (%s, %s, %s)", job.Name, job.Runner.InstanceID,
+				job.Runner.InstanceType, job.Runner.Lifecycle, job.Runner.AvailabilityZone)
+		}
+	}
+	return jobs
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/google/go-github/v68/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readJobLog(t *testing.T, name string) string {
+	data, err := os.ReadFile("testdata/github/" + name)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestParseRunnerMetadata(t *testing.T) {
+	metadata, found := parseRunnerMetadata(readJobLog(t, "job-success.log"))
+	require.True(t, found)
+	assert.Equal(t, RunnerMetadata{
+		InstanceID:       "i-0a1b2c3d4e5f60718",
+		InstanceType:     "m7a.large",
+		Lifecycle:        "spot",
+		Region:           "us-east-1",
+		AvailabilityZone: "us-east-1b",
+		ImageID:          "ami-0123456789abcdef0",
+		Labels:           "runs-on=13711094342/runner=2cpu-linux-x64/env=test",
+	}, metadata)
+
+	metadata, found = parseRunnerMetadata(readJobLog(t, "job-failure.log"))
+	require.True(t, found)
+	assert.Equal(t, "i-0f9e8d7c6b5a43210", metadata.InstanceID)
+	assert.Equal(t, "on-demand", metadata.Lifecycle)
+
+	// Other groups with key/value lines are ignored
+	_, found = parseRunnerMetadata(readJobLog(t, "job-github-hosted.log"))
+	assert.False(t, found)
+
+	// Byte order mark and CRLF line endings as served by the logs endpoint
+	metadata, found = parseRunnerMetadata("\ufeff" + strings.ReplaceAll(readJobLog(t, "job-success.log"), "\n", "\r\n"))
+	require.True(t, found)
+	assert.Equal(t, "m7a.large", metadata.InstanceType)
+}
+
+func TestLogTail(t *testing.T) {
+	assert.Equal(t, "a\nb", logTail("a\nb\n", 3))
+	assert.Equal(t, "... 2 lines omitted ...\nc\nd", logTail("a\nb\nc\nd\n", 2))
+}
+
+// fakeWorkflowJobs serves jobs one per page, with log URLs on a test server.
+type fakeWorkflowJobs struct {
+	jobs      []*github.WorkflowJob
+	serverURL string
+	logsFor   []int64
+}
+
+func (f *fakeWorkflowJobs) ListWorkflowJobs(ctx context.Context, owner, repo string, runID int64, opts *github.ListWorkflowJobsOptions) (*github.Jobs, *github.Response, error) {
+	page := max(opts.Page, 1)
+	resp := &github.Response{}
+	if page < len(f.jobs) {
+		resp.NextPage = page + 1
+	}
+	return &github.Jobs{Jobs: f.jobs[page-1 : page]}, resp, nil
+}
+
+func (f *fakeWorkflowJobs) GetWorkflowJobLogs(ctx context.Context, owner, repo string, jobID int64, maxRedirects int) (*url.URL, *github.Response, error) {
+	f.logsFor = append(f.logsFor, jobID)
+	u, err := url.Parse(f.serverURL + "/logs/" + strconv.FormatInt(jobID, 10))
+	return u, nil, err
+}
+
+func TestFetchWorkflowJobLogs(t *testing.T) {
+	logs := map[string]string{
+		"/logs/1": readJobLog(t, "job-success.log"),
+		"/logs/2": readJobLog(t, "job-failure.log"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log, ok := logs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(log))
+	}))
+	defer server.Close()
+
+	api := &fakeWorkflowJobs{serverURL: server.URL, jobs: []*github.WorkflowJob{
+		{ID: github.Ptr(int64(1)), Name: github.Ptr("test"), Conclusion: github.Ptr("success"), Labels: []string{"runs-on=13711094342/runner=2cpu-linux-x64"}},
+		{ID: github.Ptr(int64(2)), Name: github.Ptr("build"), Conclusion: github.Ptr("failure")},
+		{ID: github.Ptr(int64(3)), Name: github.Ptr("deploy"), Conclusion: github.Ptr("skipped")},
+	}}
+
+	jobs, err := fetchWorkflowJobLogs(context.Background(), api, server.Client(), "owner", "repo", 42)
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+	assert.Equal(t, []int64{1, 2}, api.logsFor, "skipped jobs have no log")
+	assert.Equal(t, "i-0a1b2c3d4e5f60718", jobs[0].Runner.InstanceID)
+	assert.Equal(t, []string{"runs-on=13711094342/runner=2cpu-linux-x64"}, jobs[0].Labels)
+	assert.Contains(t, jobs[1].Log, "##[error]Process completed with exit code 2.")
+	assert.Empty(t, jobs[2].Log)
+
+	delete(logs, "/logs/2")
+	_, err = fetchWorkflowJobLogs(context.Background(), api, server.Client(), "owner", "repo", 42)
+	assert.ErrorContains(t, err, "job build: failed to download log: HTTP 404")
+}
+
+func TestRunnerMetadataViolations(t *testing.T) {
+	instance := func(id, instanceType, az, image string, spot bool) ec2types.Instance {
+		i := ec2types.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: ec2types.InstanceType(instanceType),
+			Placement:    &ec2types.Placement{AvailabilityZone: aws.String(az)},
+			ImageId:      aws.String(image),
+		}
+		if spot {
+			i.InstanceLifecycle = ec2types.InstanceLifecycleTypeSpot
+		}
+		return i
+	}
+	valid := func() ([]WorkflowJobLog, []ec2types.Instance) {
+		success, _ := parseRunnerMetadata(readJobLog(t, "job-success.log"))
+		failure, _ := parseRunnerMetadata(readJobLog(t, "job-failure.log"))
+		return []WorkflowJobLog{
+			{Name: "test", Conclusion: "success", Runner: success},
+			{Name: "build", Conclusion: "failure", Runner: failure},
+			{Name: "deploy", Conclusion: "skipped"},
+		}, []ec2types.Instance{
+			instance("i-0a1b2c3d4e5f60718", "m7a.large", "us-east-1b", "ami-0123456789abcdef0", true),
+			instance("i-0f9e8d7c6b5a43210", "c7a.xlarge", "us-east-1c", "ami-0fedcba9876543210", false),
+			instance("i-0000000000000000a", "m7a.large", "us-east-1a", "ami-0123456789abcdef0", false),
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		mutate   func(jobs []WorkflowJobLog, instances []ec2types.Instance) ([]WorkflowJobLog, []ec2types.Instance)
+		contains []string
+	}{
+		{
+			name: "Valid",
+			mutate: func(jobs []WorkflowJobLog, instances []ec2types.Instance) ([]WorkflowJobLog, []ec2types.Instance) {
+				return jobs, instances
+			},
+		},
+		{
+			name: "InstanceNotLaunchedByStack",
+			mutate: func(jobs []WorkflowJobLog, instances []ec2types.Instance) ([]WorkflowJobLog, []ec2types.Instance) {
+				return jobs, instances[1:]
+			},
+			contains: []string{"job test: instance i-0a1b2c3d4e5f60718 was not launched by the stack during the test"},
+		},
+		{
+			name: "Mismatches",
+			mutate: func(jobs []WorkflowJobLog, instances []ec2types.Instance) ([]WorkflowJobLog, []ec2types.Instance) {
+				instances[0].InstanceType = ec2types.InstanceTypeM7aXlarge
+				instances[0].InstanceLifecycle = ""
+				instances[1].Placement.AvailabilityZone = aws.String("us-east-1a")
+				instances[1].ImageId = aws.String("ami-0aaaaaaaaaaaaaaaa")
+				return jobs, instances
+			},
+			contains: []string{
+				`job test: log reports instance type "m7a.large", instance i-0a1b2c3d4e5f60718 has "m7a.xlarge"`,
+				`job test: log reports lifecycle "spot", instance i-0a1b2c3d4e5f60718 has "on-demand"`,
+				`job build: log reports availability zone "us-east-1c", instance i-0f9e8d7c6b5a43210 has "us-east-1a"`,
+				`job build: log reports image "ami-0fedcba9876543210", instance i-0f9e8d7c6b5a43210 has "ami-0aaaaaaaaaaaaaaaa"`,
+			},
+		},
+		{
+			name: "OtherRegion",
+			mutate: func(jobs []WorkflowJobLog, instances []ec2types.Instance) ([]WorkflowJobLog, []ec2types.Instance) {
+				jobs[0].Runner.Region = "eu-west-1"
+				return jobs, instances
+			},
+			contains: []string{"job test: runner region is eu-west-1, expected us-east-1"},
+		},
+		{
+			name: "NoRunnerMetadata",
+			mutate: func(jobs []WorkflowJobLog, instances []ec2types.Instance) ([]WorkflowJobLog, []ec2types.Instance) {
+				jobs[1].Runner = RunnerMetadata{}
+				return jobs, instances
+			},
+			contains: []string{`job build: no RunsOn runner metadata in the "Set up runner" log group`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobs, instances := tc.mutate(valid())
+			violations := runnerMetadataViolations(jobs, instances, "us-east-1")
+			require.Len(t, violations, len(tc.contains), "violations: %v", violations)
+			for i, want := range tc.contains {
+				assert.Equal(t, want, violations[i])
+			}
+		})
+	}
+}